@@ -2,32 +2,149 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
 	"flag"
 	"fmt"
 	"log/slog"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
+	"github.com/driversti/hola/internal/alerts"
 	"github.com/driversti/hola/internal/api"
 	"github.com/driversti/hola/internal/auth"
 	"github.com/driversti/hola/internal/docker"
+	"github.com/driversti/hola/internal/maintenance"
 	"github.com/driversti/hola/internal/registry"
+	"github.com/driversti/hola/internal/requestid"
 	"github.com/driversti/hola/internal/update"
 	"github.com/driversti/hola/internal/ws"
 )
 
+// version is the agent version, settable at build time with
+// -ldflags "-X main.version=..." and at run time with --version.
+var version = "0.4.0"
+
 const (
-	version = "0.4.0"
-	repo    = "driversti/HoLA"
+	defaultRepo       = "driversti/HoLA"
+	defaultListenAddr = ":8420"
 )
 
+// newLogger builds the slog logger selected by --log-level and --log-format.
+func newLogger(level, format string) (*slog.Logger, error) {
+	var lvl slog.Level
+	switch strings.ToLower(level) {
+	case "debug":
+		lvl = slog.LevelDebug
+	case "info":
+		lvl = slog.LevelInfo
+	case "warn", "warning":
+		lvl = slog.LevelWarn
+	case "error":
+		lvl = slog.LevelError
+	default:
+		return nil, fmt.Errorf("unknown log level %q (want debug, info, warn or error)", level)
+	}
+
+	opts := &slog.HandlerOptions{Level: lvl}
+	switch format {
+	case "json":
+		return slog.New(requestid.LogHandler{Handler: slog.NewJSONHandler(os.Stdout, opts)}), nil
+	case "text":
+		return slog.New(requestid.LogHandler{Handler: slog.NewTextHandler(os.Stdout, opts)}), nil
+	}
+	return nil, fmt.Errorf("unknown log format %q (want json or text)", format)
+}
+
+// validateListenAddr checks that addr is a host:port pair with a valid
+// port; the host may be empty to listen on all interfaces.
+func validateListenAddr(addr string) error {
+	_, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	n, err := strconv.Atoi(port)
+	if err != nil || n < 0 || n > 65535 {
+		return fmt.Errorf("invalid port %q", port)
+	}
+	return nil
+}
+
+// stringList is a flag.Value collecting every occurrence of a repeatable flag.
+type stringList []string
+
+func (l *stringList) String() string { return strings.Join(*l, ",") }
+
+func (l *stringList) Set(v string) error {
+	*l = append(*l, v)
+	return nil
+}
+
 func main() {
 	token := flag.String("token", "", "Bearer token for API authentication")
+	logLevel := flag.String("log-level", "info", "Log level: debug, info, warn or error")
+	logFormat := flag.String("log-format", "json", "Log format: json or text")
+	verbose := flag.Bool("v", false, "Shorthand for --log-level=debug")
+	tlsCert := flag.String("tls-cert", "", "TLS certificate file; serve HTTPS (and wss) instead of plain HTTP")
+	tlsKey := flag.String("tls-key", "", "Private key file for --tls-cert")
+	tlsAuto := flag.Bool("tls-auto", false, "Serve HTTPS with a self-signed certificate kept in the data directory (LAN use)")
+	listen := flag.String("listen", "", "Address to listen on, e.g. 100.64.0.1:8420 (default: $HOLA_LISTEN or "+defaultListenAddr+")")
+	alertCPU := flag.Float64("alert-cpu", 0, "Raise a resource_alert when CPU usage exceeds this percent (0 disables)")
+	alertCPUDuration := flag.Duration("alert-cpu-duration", time.Minute, "How long CPU must stay above --alert-cpu before alerting")
+	alertMem := flag.Float64("alert-mem", 0, "Raise a resource_alert when memory usage exceeds this percent (0 disables)")
+	alertDisk := flag.Float64("alert-disk", 0, "Raise a resource_alert when disk usage exceeds this percent (0 disables)")
+	alertDiskMounts := flag.String("alert-disk-mounts", "", "Comma-separated mount points checked by --alert-disk (default: all)")
+	alertInterval := flag.Duration("alert-interval", 15*time.Second, "How often resource alert thresholds are evaluated")
+	alertWebhook := flag.String("alert-webhook", "", "URL that resource alerts are POSTed to as JSON")
+	var scanDirs stringList
+	flag.Var(&scanDirs, "scan-dir", "Directory whose subdirectories with a compose file are registered as stacks on startup (repeatable)")
+	var browseRoots stringList
+	flag.Var(&browseRoots, "browse-root", "Directory that filesystem access and stack registration are restricted to (repeatable; default: unrestricted)")
+	var corsOrigins stringList
+	flag.Var(&corsOrigins, "cors-origin", "Browser origin allowed to call the API cross-origin, e.g. https://ui.example.com or * (repeatable; default: same origin only)")
+	var wsOrigins stringList
+	flag.Var(&wsOrigins, "ws-origin", "Origin host pattern allowed to open WebSocket connections, e.g. *.example.com (repeatable; default: same host only)")
+	wsAllowAllOrigins := flag.Bool("ws-allow-all-origins", false, "Accept WebSocket connections from any origin (trusted networks only)")
+	eventHistory := flag.Int("event-history", ws.DefaultEventHistory, "Recent container events replayed to new WebSocket events subscribers (0 disables)")
+	wsPingInterval := flag.Duration("ws-ping-interval", 30*time.Second, "How often WebSocket clients are pinged; clients silent for two intervals are disconnected")
+	wsMaxMessageSize := flag.Int64("ws-max-message-size", ws.DefaultMaxMessageSize, "Largest WebSocket message accepted from a client, in bytes; larger ones close the connection")
+	maxWSConnections := flag.Int("max-ws-connections", ws.DefaultMaxConnections, "Most concurrent WebSocket connections; further upgrades are refused with 503")
+	githubToken := flag.String("github-token", "", "GitHub token for update checks, raising the API rate limit (default: $HOLA_GITHUB_TOKEN)")
+	versionFlag := flag.String("version", version, "Agent version reported by the API and compared against releases")
+	updateRepo := flag.String("update-repo", defaultRepo, "GitHub repository (owner/name) updates are fetched from")
+	updateChannel := flag.String("update-channel", update.ChannelStable, "Releases considered for updates: stable or prerelease")
+	updatePublicKey := flag.String("update-public-key", "", "Minisign public key, or path to its .pub file; updates then require a valid checksums.txt.minisig")
+	autoUpdate := flag.Duration("auto-update", 0, "Check for and install updates on this interval, e.g. 6h (0 disables)")
+	autoUpdateChannel := flag.String("auto-update-channel", "", "Releases --auto-update installs: stable or prerelease (default: --update-channel)")
+	maintenanceDuration := flag.Duration("maintenance-duration", time.Hour, "How long maintenance mode lasts when enabled without an explicit duration")
+	execTimeout := flag.Duration("exec-timeout", 30*time.Second, "Maximum run time of a command started through the container exec endpoint")
+	actionTimeout := flag.Duration("action-timeout", 5*time.Minute, "Maximum run time of a single stack action (docker compose up, pull, ...)")
+	execMaxOutput := flag.Int("exec-max-output", 1<<20, "Maximum bytes of command output returned by the container exec endpoint")
+	maxLogLines := flag.Int("max-log-lines", docker.DefaultMaxLogLines, "Most log lines one request or log subscription may fetch; large values use more memory")
+	dockerHost := flag.String("docker-host", "", "Docker daemon to manage, e.g. tcp://10.0.0.5:2376 (default: $DOCKER_HOST or the local socket)")
+	dockerTLSCert := flag.String("docker-tls-cert", "", "Client certificate for a TLS-protected Docker daemon")
+	dockerTLSKey := flag.String("docker-tls-key", "", "Private key for --docker-tls-cert")
+	dockerTLSCA := flag.String("docker-tls-ca", "", "CA certificate to verify the Docker daemon against")
+	bulkConcurrency := flag.Int("bulk-concurrency", 4, "Stacks acted on in parallel by the bulk stack actions endpoint")
+	composeBackups := flag.Int("compose-backups", 1, "Number of rotated compose file backups (.bak.1, .bak.2, ...) to keep")
 	flag.Parse()
 
+	if *verbose {
+		*logLevel = "debug"
+	}
+	logger, err := newLogger(*logLevel, *logFormat)
+	if err != nil {
+		slog.Error("invalid logging flags", "error", err)
+		os.Exit(1)
+	}
+	slog.SetDefault(logger)
+
 	if *token == "" {
 		*token = os.Getenv("HOLA_TOKEN")
 	}
@@ -36,35 +153,267 @@ func main() {
 		os.Exit(1)
 	}
 
-	logger := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo}))
-	slog.SetDefault(logger)
+	if *listen == "" {
+		*listen = os.Getenv("HOLA_LISTEN")
+	}
+	if *listen == "" {
+		*listen = defaultListenAddr
+	}
+	if err := validateListenAddr(*listen); err != nil {
+		slog.Error("invalid --listen address", "addr", *listen, "error", err)
+		os.Exit(1)
+	}
+
+	if (*tlsCert == "") != (*tlsKey == "") {
+		slog.Error("--tls-cert and --tls-key must be given together")
+		os.Exit(1)
+	}
+	if *tlsAuto && *tlsCert != "" {
+		slog.Error("--tls-auto cannot be combined with --tls-cert/--tls-key")
+		os.Exit(1)
+	}
+
+	if *githubToken == "" {
+		*githubToken = os.Getenv("HOLA_GITHUB_TOKEN")
+	}
+
+	version = strings.TrimPrefix(*versionFlag, "v")
+	// Update checks compare against releases; fail now rather than on
+	// every check.
+	if _, err := update.CompareVersions(version, version); err != nil {
+		slog.Error("invalid --version; want a semantic version such as 1.2.3", "value", *versionFlag, "error", err)
+		os.Exit(1)
+	}
+	if err := update.ValidateRepo(*updateRepo); err != nil {
+		slog.Error("invalid --update-repo", "error", err)
+		os.Exit(1)
+	}
+
+	if *composeBackups < 1 {
+		slog.Error("--compose-backups must be at least 1", "value", *composeBackups)
+		os.Exit(1)
+	}
+
+	if *maxLogLines < 1 {
+		slog.Error("--max-log-lines must be at least 1", "value", *maxLogLines)
+		os.Exit(1)
+	}
+
+	if *wsMaxMessageSize < 1 {
+		slog.Error("--ws-max-message-size must be at least 1", "value", *wsMaxMessageSize)
+		os.Exit(1)
+	}
 
-	dockerClient, err := docker.NewClient()
+	if *maxWSConnections < 1 {
+		slog.Error("--max-ws-connections must be at least 1", "value", *maxWSConnections)
+		os.Exit(1)
+	}
+
+	dockerConfig := docker.Config{
+		Host:        *dockerHost,
+		TLSCert:     *dockerTLSCert,
+		TLSKey:      *dockerTLSKey,
+		TLSCA:       *dockerTLSCA,
+		MaxLogLines: *maxLogLines,
+	}
+	dockerClient, err := docker.NewClient(dockerConfig)
 	if err != nil {
-		slog.Error("failed to connect to Docker", "error", err)
+		slog.Error("failed to create Docker client", "error", err)
 		os.Exit(1)
 	}
 	defer dockerClient.Close()
 
+	pingCtx, pingCancel := context.WithTimeout(context.Background(), 10*time.Second)
+	if err := dockerClient.Ping(pingCtx); err != nil {
+		pingCancel()
+		slog.Error("Docker daemon unreachable", "endpoint", dockerClient.Endpoint(), "error", err)
+		os.Exit(1)
+	}
+	dockerVersion, apiVersion, err := dockerClient.ServerVersion(pingCtx)
+	pingCancel()
+	if err != nil {
+		slog.Warn("failed to read Docker version", "endpoint", dockerClient.Endpoint(), "error", err)
+	}
+	slog.Info("connected to Docker", "endpoint", dockerClient.Endpoint(), "version", dockerVersion, "api_version", apiVersion)
+
+	// Stack actions shell out to the docker CLI; point it at the same daemon.
+	// The TLS settings are passed as flags (see api.Options.DockerArgs).
+	if *dockerHost != "" {
+		os.Setenv("DOCKER_HOST", *dockerHost)
+	}
+
+	if len(browseRoots) == 0 {
+		slog.Warn("filesystem browsing is unrestricted; use --browse-root to limit it")
+	}
+
 	registryStore, err := registry.NewStore("")
 	if err != nil {
 		slog.Error("failed to init registry store", "error", err)
 		os.Exit(1)
 	}
 
+	if *tlsAuto {
+		*tlsCert, *tlsKey, err = selfSignedCert(filepath.Dir(registryStore.Path()))
+		if err != nil {
+			slog.Error("failed to create self-signed certificate", "error", err)
+			os.Exit(1)
+		}
+		fingerprint, _ := certFingerprint(*tlsCert)
+		slog.Info("using self-signed TLS certificate", "cert", *tlsCert, "sha256", fingerprint)
+	}
+
+	if len(scanDirs) > 0 {
+		res, err := registryStore.Scan(scanDirs)
+		if err != nil {
+			slog.Error("failed to save scanned stacks", "error", err)
+		}
+		slog.Info("scanned stack directories", "dirs", []string(scanDirs),
+			"discovered", res.Discovered, "new", res.Registered, "stale", res.Stale)
+	}
+
 	// WebSocket event hub — listens for Docker container events.
 	eventHub := ws.NewEventHub(dockerClient)
+	eventHub.SetHistorySize(*eventHistory)
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 	go eventHub.Run(ctx)
+	go registryStore.Watch(ctx, 5*time.Second)
+
+	maint := maintenance.New()
 
-	wsHandler := ws.NewHandler(eventHub)
+	alertCfg := alerts.Config{
+		Interval:    *alertInterval,
+		CPUPercent:  *alertCPU,
+		CPUDuration: *alertCPUDuration,
+		MemPercent:  *alertMem,
+		DiskPercent: *alertDisk,
+	}
+	if *alertDiskMounts != "" {
+		alertCfg.DiskMounts = strings.Split(*alertDiskMounts, ",")
+	}
+	if alertCfg.Enabled() {
+		var webhook *alerts.Webhook
+		if *alertWebhook != "" {
+			webhook = alerts.NewWebhook(*alertWebhook)
+		}
+		monitor := alerts.NewMonitor(alertCfg, func(a alerts.Alert) {
+			eventHub.Publish(ctx, "resource_alert", a)
+			if webhook != nil {
+				go webhook.Send(ctx, a)
+			}
+		})
+		monitor.PauseWhen(maint.Active)
+		go monitor.Run(ctx)
+	}
+
+	if *wsAllowAllOrigins {
+		slog.Warn("WebSocket origin checks disabled by --ws-allow-all-origins")
+	}
+	wsHandler := ws.NewHandler(eventHub, ws.Options{
+		AllowedOrigins:  wsOrigins,
+		AllowAllOrigins: *wsAllowAllOrigins,
+		PingInterval:    *wsPingInterval,
+		MaxConnections:  *maxWSConnections,
+		MaxMessageSize:  *wsMaxMessageSize,
+	})
 	authMiddleware := auth.NewMiddleware(*token)
-	updater := update.New(version, repo)
-	router := api.NewRouter(version, authMiddleware, dockerClient, wsHandler, registryStore, updater)
+	publicKey := *updatePublicKey
+	if data, err := os.ReadFile(publicKey); publicKey != "" && err == nil {
+		publicKey = string(data)
+	}
+	newUpdater := func(channel, flagName string) *update.Updater {
+		u := update.New(version, *updateRepo)
+		u.SetToken(*githubToken)
+		if err := u.SetChannel(channel); err != nil {
+			slog.Error("invalid --"+flagName, "error", err)
+			os.Exit(1)
+		}
+		if err := u.SetPublicKey(publicKey); err != nil {
+			slog.Error("invalid --update-public-key", "error", err)
+			os.Exit(1)
+		}
+		return u
+	}
+	updater := newUpdater(*updateChannel, "update-channel")
+
+	// The auto-updater gets its own Updater so --auto-update-channel can
+	// differ from the channel manual updates use.
+	autoUpdated := make(chan struct{})
+	if *autoUpdateChannel == "" {
+		*autoUpdateChannel = *updateChannel
+	}
+	if *autoUpdate > 0 {
+		if *autoUpdate < update.MinAutoUpdateInterval {
+			slog.Error("--auto-update interval too short", "value", autoUpdate.String(), "min", update.MinAutoUpdateInterval.String())
+			os.Exit(1)
+		}
+		auto := newUpdater(*autoUpdateChannel, "auto-update-channel")
+		go func() {
+			if auto.AutoUpdate(ctx, *autoUpdate, maint.Active) {
+				close(autoUpdated)
+			}
+		}()
+	}
+	router := api.NewRouter(version, authMiddleware, dockerClient, wsHandler, registryStore, updater, api.Options{
+		ComposeBackups:      *composeBackups,
+		Maintenance:         maint,
+		MaintenanceDuration: *maintenanceDuration,
+		ExecTimeout:         *execTimeout,
+		ExecMaxOutput:       *execMaxOutput,
+		ActionTimeout:       *actionTimeout,
+		BulkConcurrency:     *bulkConcurrency,
+		BrowseRoots:         browseRoots,
+		DockerArgs:          dockerConfig.CLIArgs(),
+		CORSOrigins:         corsOrigins,
+		Config: api.AgentConfig{
+			ListenAddr:          *listen,
+			Token:               api.Redact(*token),
+			DataDir:             filepath.Dir(registryStore.Path()),
+			DockerHost:          dockerClient.Endpoint(),
+			DockerTLS:           *dockerTLSCert != "" || *dockerTLSCA != "",
+			TLS:                 *tlsCert != "",
+			TLSAuto:             *tlsAuto,
+			LogLevel:            strings.ToLower(*logLevel),
+			LogFormat:           *logFormat,
+			ComposeBackups:      *composeBackups,
+			MaintenanceDuration: maintenanceDuration.String(),
+			ScanDirs:            append([]string{}, scanDirs...),
+			BrowseRoots:         append([]string{}, browseRoots...),
+			CORSOrigins:         append([]string{}, corsOrigins...),
+			ExecTimeout:         execTimeout.String(),
+			ExecMaxOutput:       *execMaxOutput,
+			ActionTimeout:       actionTimeout.String(),
+			MaxLogLines:         *maxLogLines,
+			BulkConcurrency:     *bulkConcurrency,
+			UpdateRepo:          *updateRepo,
+			UpdateChannel:       *updateChannel,
+			UpdateSigned:        *updatePublicKey != "",
+			AutoUpdate:          autoUpdate.String(),
+			AutoUpdateChannel:   *autoUpdateChannel,
+			GitHubToken:         api.Redact(*githubToken),
+			WebSocket: api.WSConfig{
+				AllowedOrigins:  append([]string{}, wsOrigins...),
+				AllowAllOrigins: *wsAllowAllOrigins,
+				PingInterval:    wsPingInterval.String(),
+				EventHistory:    *eventHistory,
+				MaxConnections:  *maxWSConnections,
+				MaxMessageSize:  *wsMaxMessageSize,
+			},
+			Alerts: api.AlertsConfig{
+				Enabled:     alertCfg.Enabled(),
+				Interval:    alertCfg.Interval.String(),
+				CPUPercent:  alertCfg.CPUPercent,
+				CPUDuration: alertCfg.CPUDuration.String(),
+				MemPercent:  alertCfg.MemPercent,
+				DiskPercent: alertCfg.DiskPercent,
+				DiskMounts:  append([]string{}, alertCfg.DiskMounts...),
+				Webhook:     api.RedactURL(*alertWebhook),
+			},
+		},
+	})
 
 	srv := &http.Server{
-		Addr:    ":8420",
+		Addr:    *listen,
 		Handler: router,
 		// ReadHeaderTimeout (not ReadTimeout) protects HTTP header parsing
 		// without killing long-lived WebSocket connections.
@@ -74,9 +423,30 @@ func main() {
 		IdleTimeout:  60 * time.Second,
 	}
 
+	// Check the certificate up front so a bad pair fails startup too.
+	if *tlsCert != "" {
+		if _, err := tls.LoadX509KeyPair(*tlsCert, *tlsKey); err != nil {
+			slog.Error("failed to load TLS certificate", "cert", *tlsCert, "key", *tlsKey, "error", err)
+			os.Exit(1)
+		}
+	}
+
+	// Bind before serving so a taken port or unknown interface fails
+	// startup, and so the log shows the address actually bound (e.g. the
+	// port picked for ":0").
+	ln, err := net.Listen("tcp", srv.Addr)
+	if err != nil {
+		slog.Error("failed to listen", "addr", srv.Addr, "error", err)
+		os.Exit(1)
+	}
+
 	go func() {
-		slog.Info("starting HoLA agent", "port", 8420, "version", version)
-		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		slog.Info("starting HoLA agent", "addr", ln.Addr().String(), "tls", *tlsCert != "", "version", version)
+		serve := func() error { return srv.Serve(ln) }
+		if *tlsCert != "" {
+			serve = func() error { return srv.ServeTLS(ln, *tlsCert, *tlsKey) }
+		}
+		if err := serve(); err != nil && err != http.ErrServerClosed {
 			slog.Error("server failed", "error", err)
 			os.Exit(1)
 		}
@@ -84,9 +454,17 @@ func main() {
 
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-	sig := <-quit
-	slog.Info("shutting down", "signal", sig.String())
+	select {
+	case sig := <-quit:
+		slog.Info("shutting down", "signal", sig.String())
+	case <-autoUpdated:
+		// Exit cleanly; the service manager restarts us into the new binary.
+		slog.Info("shutting down to restart into the update")
+	}
 
+	// Tell WebSocket clients why they are being dropped before the event
+	// hub and server go away under them.
+	wsHandler.CloseAll("server shutting down")
 	cancel() // Stop event hub.
 
 	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 10*time.Second)
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestSelfSignedCert(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile, err := selfSignedCert(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pair, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		t.Fatalf("generated pair doesn't load: %v", err)
+	}
+	leaf := pair.Leaf
+	if !slices.Contains(leaf.DNSNames, "localhost") {
+		t.Errorf("DNS names %v lack localhost", leaf.DNSNames)
+	}
+	if left := time.Until(leaf.NotAfter); left < selfSignedValidity-2*time.Hour || left > selfSignedValidity {
+		t.Errorf("certificate valid for another %s, want about %s", left, selfSignedValidity)
+	}
+	info, err := os.Stat(keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("key file mode = %v, want 0600", info.Mode().Perm())
+	}
+
+	// The fingerprint is the SHA-256 of the certificate's DER bytes.
+	fp, err := certFingerprint(certFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sum := sha256.Sum256(leaf.Raw); fp != hex.EncodeToString(sum[:]) {
+		t.Errorf("fingerprint = %s, want %x", fp, sum)
+	}
+
+	// A restart keeps the pair, so pinned clients keep working.
+	if _, _, err := selfSignedCert(dir); err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := certFingerprint(certFile); again != fp {
+		t.Error("certificate was regenerated although still valid")
+	}
+}
+
+func TestSelfSignedCertRenewsExpiring(t *testing.T) {
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "tls-cert.pem")
+	keyFile := filepath.Join(dir, "tls-key.pem")
+
+	// A pair with a week left is due for renewal.
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "old"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(7 * 24 * time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	old, _ := certFingerprint(certFile)
+
+	if _, _, err := selfSignedCert(dir); err != nil {
+		t.Fatal(err)
+	}
+	if renewed, _ := certFingerprint(certFile); renewed == old {
+		t.Error("expiring certificate was not regenerated")
+	}
+}
+
+func TestCertFingerprintRejectsNonPEM(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cert.pem")
+	if err := os.WriteFile(path, []byte("not a certificate"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := certFingerprint(path); err == nil {
+		t.Error("want an error for a file without a PEM block")
+	}
+	if _, err := certFingerprint(filepath.Join(t.TempDir(), "missing.pem")); err == nil {
+		t.Error("want an error for a missing file")
+	}
+}
@@ -0,0 +1,207 @@
+package alerts
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/driversti/hola/internal/metrics"
+)
+
+// hysteresis is how far (in percentage points) a metric must fall below its
+// threshold before a firing alert resolves. It keeps a value hovering around
+// the threshold from flapping between firing and resolved.
+const hysteresis = 5.0
+
+// Alert states.
+const (
+	StateFiring   = "firing"
+	StateResolved = "resolved"
+)
+
+// Alert is emitted when a resource crosses (or recovers from) a threshold.
+type Alert struct {
+	Metric    string  `json:"metric"` // "cpu", "memory" or "disk"
+	Mount     string  `json:"mount,omitempty"`
+	State     string  `json:"state"`
+	Value     float64 `json:"value"`
+	Threshold float64 `json:"threshold"`
+	Time      int64   `json:"time"`
+}
+
+// Config holds the alert thresholds. A zero threshold disables that check.
+type Config struct {
+	Interval    time.Duration
+	CPUPercent  float64
+	CPUDuration time.Duration // how long CPU must stay above the threshold
+	MemPercent  float64
+	DiskPercent float64
+	DiskMounts  []string // mounts to watch; empty means all reported mounts
+}
+
+// Enabled reports whether any threshold is configured.
+func (c Config) Enabled() bool {
+	return c.CPUPercent > 0 || c.MemPercent > 0 || c.DiskPercent > 0
+}
+
+// rule tracks the debounce state of a single threshold check.
+type rule struct {
+	threshold  float64
+	sustain    time.Duration
+	aboveSince time.Time
+	firing     bool
+}
+
+// evaluate feeds a new reading into the rule and returns the resulting
+// state transition, or "" if nothing changed.
+func (r *rule) evaluate(value float64, now time.Time) string {
+	if r.firing {
+		if value < r.threshold-hysteresis {
+			r.firing = false
+			r.aboveSince = time.Time{}
+			return StateResolved
+		}
+		return ""
+	}
+
+	if value <= r.threshold {
+		r.aboveSince = time.Time{}
+		return ""
+	}
+	if r.aboveSince.IsZero() {
+		r.aboveSince = now
+	}
+	if now.Sub(r.aboveSince) >= r.sustain {
+		r.firing = true
+		return StateFiring
+	}
+	return ""
+}
+
+// Monitor periodically samples system metrics and reports threshold alerts.
+type Monitor struct {
+	cfg    Config
+	notify func(Alert)
+	paused func() bool
+
+	cpu   *rule
+	mem   *rule
+	disks map[string]*rule
+
+	metrics metrics.Collector // the monitor's own, so rates span its interval
+}
+
+// NewMonitor creates a Monitor that calls notify for every alert transition.
+func NewMonitor(cfg Config, notify func(Alert)) *Monitor {
+	if cfg.Interval <= 0 {
+		cfg.Interval = 15 * time.Second
+	}
+	m := &Monitor{cfg: cfg, notify: notify, disks: make(map[string]*rule)}
+	if cfg.CPUPercent > 0 {
+		m.cpu = &rule{threshold: cfg.CPUPercent, sustain: cfg.CPUDuration}
+	}
+	if cfg.MemPercent > 0 {
+		m.mem = &rule{threshold: cfg.MemPercent}
+	}
+	return m
+}
+
+// PauseWhen makes the monitor skip sampling while paused returns true,
+// e.g. during maintenance mode. Readings taken before a pause don't count
+// toward a sustained threshold afterwards.
+func (m *Monitor) PauseWhen(paused func() bool) {
+	m.paused = paused
+}
+
+// Run samples metrics on the configured interval until ctx is cancelled.
+func (m *Monitor) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if m.paused != nil && m.paused() {
+				m.resetPending()
+				continue
+			}
+			sm, err := m.metrics.Collect(ctx)
+			if err != nil {
+				slog.Warn("alert monitor: metrics collect failed", "error", err)
+				continue
+			}
+			m.check(sm, time.Now())
+		}
+	}
+}
+
+// check evaluates every configured rule against a metrics snapshot.
+func (m *Monitor) check(sm *metrics.SystemMetrics, now time.Time) {
+	if m.cpu != nil {
+		m.apply(m.cpu, "cpu", "", sm.CPU.UsagePercent, now)
+	}
+	if m.mem != nil {
+		m.apply(m.mem, "memory", "", sm.Memory.UsagePercent, now)
+	}
+	if m.cfg.DiskPercent > 0 {
+		for _, d := range sm.Disk {
+			if !m.watchesMount(d.MountPoint) {
+				continue
+			}
+			r, ok := m.disks[d.MountPoint]
+			if !ok {
+				r = &rule{threshold: m.cfg.DiskPercent}
+				m.disks[d.MountPoint] = r
+			}
+			m.apply(r, "disk", d.MountPoint, d.UsagePercent, now)
+		}
+	}
+}
+
+// resetPending forgets readings that were building toward a threshold but
+// hadn't fired yet.
+func (m *Monitor) resetPending() {
+	for _, r := range []*rule{m.cpu, m.mem} {
+		if r != nil && !r.firing {
+			r.aboveSince = time.Time{}
+		}
+	}
+	for _, r := range m.disks {
+		if !r.firing {
+			r.aboveSince = time.Time{}
+		}
+	}
+}
+
+func (m *Monitor) apply(r *rule, metric, mount string, value float64, now time.Time) {
+	state := r.evaluate(value, now)
+	if state == "" {
+		return
+	}
+	alert := Alert{
+		Metric:    metric,
+		Mount:     mount,
+		State:     state,
+		Value:     value,
+		Threshold: r.threshold,
+		Time:      now.Unix(),
+	}
+	slog.Info("resource alert", "metric", metric, "mount", mount, "state", state,
+		"value", fmt.Sprintf("%.1f", value), "threshold", r.threshold)
+	m.notify(alert)
+}
+
+func (m *Monitor) watchesMount(mount string) bool {
+	if len(m.cfg.DiskMounts) == 0 {
+		return true
+	}
+	for _, want := range m.cfg.DiskMounts {
+		if want == mount {
+			return true
+		}
+	}
+	return false
+}
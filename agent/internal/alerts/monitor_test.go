@@ -0,0 +1,76 @@
+package alerts
+
+import (
+	"testing"
+	"time"
+
+	"github.com/driversti/hola/internal/metrics"
+)
+
+func TestRuleEvaluate_Sustain(t *testing.T) {
+	r := &rule{threshold: 90, sustain: 30 * time.Second}
+	start := time.Unix(1000, 0)
+
+	if got := r.evaluate(95, start); got != "" {
+		t.Fatalf("first reading above threshold should not fire yet, got %q", got)
+	}
+	if got := r.evaluate(95, start.Add(10*time.Second)); got != "" {
+		t.Fatalf("should not fire before sustain elapses, got %q", got)
+	}
+	if got := r.evaluate(95, start.Add(30*time.Second)); got != StateFiring {
+		t.Fatalf("want firing after sustain, got %q", got)
+	}
+	if got := r.evaluate(97, start.Add(40*time.Second)); got != "" {
+		t.Fatalf("already firing should not re-fire, got %q", got)
+	}
+}
+
+func TestRuleEvaluate_DipResetsSustain(t *testing.T) {
+	r := &rule{threshold: 90, sustain: 30 * time.Second}
+	start := time.Unix(1000, 0)
+
+	r.evaluate(95, start)
+	r.evaluate(80, start.Add(20*time.Second))
+	if got := r.evaluate(95, start.Add(35*time.Second)); got != "" {
+		t.Fatalf("dip below threshold should reset the sustain window, got %q", got)
+	}
+}
+
+func TestRuleEvaluate_Hysteresis(t *testing.T) {
+	r := &rule{threshold: 90}
+	now := time.Unix(1000, 0)
+
+	if got := r.evaluate(91, now); got != StateFiring {
+		t.Fatalf("want firing with zero sustain, got %q", got)
+	}
+	if got := r.evaluate(88, now); got != "" {
+		t.Fatalf("value within hysteresis band should not resolve, got %q", got)
+	}
+	if got := r.evaluate(92, now); got != "" {
+		t.Fatalf("should not re-fire while still firing, got %q", got)
+	}
+	if got := r.evaluate(84, now); got != StateResolved {
+		t.Fatalf("want resolved below threshold-hysteresis, got %q", got)
+	}
+}
+
+func TestMonitorCheck_DiskMounts(t *testing.T) {
+	var got []Alert
+	m := NewMonitor(Config{DiskPercent: 80, DiskMounts: []string{"/"}}, func(a Alert) {
+		got = append(got, a)
+	})
+
+	m.check(&metrics.SystemMetrics{
+		Disk: []metrics.DiskMetric{
+			{MountPoint: "/", UsagePercent: 85},
+			{MountPoint: "/boot", UsagePercent: 99},
+		},
+	}, time.Unix(1000, 0))
+
+	if len(got) != 1 {
+		t.Fatalf("want 1 alert, got %d: %+v", len(got), got)
+	}
+	if got[0].Metric != "disk" || got[0].Mount != "/" || got[0].State != StateFiring {
+		t.Errorf("unexpected alert: %+v", got[0])
+	}
+}
@@ -0,0 +1,85 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+// profileNameRe matches the profile names docker compose accepts.
+var profileNameRe = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+// actionOptions are the optional compose settings of a stack action. The
+// zero value runs docker compose with its defaults.
+type actionOptions struct {
+	Profiles []string `json:"profiles"`
+	EnvFile  string   `json:"env_file"` // relative to the stack's working directory
+}
+
+// decodeActionOptions reads the optional JSON body of a stack action. A
+// request without a body gets the zero options.
+func decodeActionOptions(w http.ResponseWriter, r *http.Request) (actionOptions, error) {
+	var opts actionOptions
+	r.Body = http.MaxBytesReader(w, r.Body, 1<<10)
+	if err := json.NewDecoder(r.Body).Decode(&opts); err != nil && !errors.Is(err, io.EOF) {
+		return opts, errors.New("invalid JSON body")
+	}
+	return opts, nil
+}
+
+// queryActionOptions reads action options from the query string, for the
+// streaming endpoint which has no body: ?profile=a&profile=b&env_file=x.
+func queryActionOptions(r *http.Request) actionOptions {
+	q := r.URL.Query()
+	return actionOptions{Profiles: q["profile"], EnvFile: q.Get("env_file")}
+}
+
+// flags validates the options against the stack's working directory and
+// returns the matching docker compose global flags. The env file must be
+// a regular file inside dir, also after resolving symlinks, so a request
+// can't make compose read files elsewhere on the host.
+func (o actionOptions) flags(dir string) ([]string, error) {
+	var flags []string
+	for _, p := range o.Profiles {
+		if !profileNameRe.MatchString(p) {
+			return nil, fmt.Errorf("invalid profile name %q", p)
+		}
+		flags = append(flags, "--profile", p)
+	}
+
+	if o.EnvFile != "" {
+		if !filepath.IsLocal(o.EnvFile) {
+			return nil, fmt.Errorf("env_file %q must be a relative path inside the stack directory", o.EnvFile)
+		}
+		path := filepath.Join(dir, o.EnvFile)
+		resolved, err := filepath.EvalSymlinks(path)
+		if err != nil {
+			return nil, fmt.Errorf("env_file %q not found", o.EnvFile)
+		}
+		root, err := filepath.EvalSymlinks(dir)
+		if err != nil {
+			return nil, fmt.Errorf("resolving stack directory: %w", err)
+		}
+		if rel, err := filepath.Rel(root, resolved); err != nil || !filepath.IsLocal(rel) {
+			return nil, fmt.Errorf("env_file %q points outside the stack directory", o.EnvFile)
+		}
+		if info, err := os.Stat(resolved); err != nil || !info.Mode().IsRegular() {
+			return nil, fmt.Errorf("env_file %q is not a regular file", o.EnvFile)
+		}
+		flags = append(flags, "--env-file", path)
+	}
+	return flags, nil
+}
+
+// insertFlags places flags between "compose" and its subcommand.
+func insertFlags(args, flags []string) []string {
+	if len(flags) == 0 {
+		return args
+	}
+	return append(args[:1:1], append(flags, args[1:]...)...)
+}
@@ -0,0 +1,59 @@
+package api
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestActionOptionsFlags(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, ".env.prod"), []byte("TAG=1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	outside := filepath.Join(t.TempDir(), "secrets.env")
+	if err := os.WriteFile(outside, []byte("X=1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(dir, "linked.env")); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := actionOptions{Profiles: []string{"debug", "tools"}, EnvFile: ".env.prod"}.flags(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"--profile", "debug", "--profile", "tools", "--env-file", filepath.Join(dir, ".env.prod")}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("flags = %v, want %v", got, want)
+	}
+
+	if got, err := (actionOptions{}).flags(dir); err != nil || got != nil {
+		t.Errorf("zero options = %v, %v; want no flags", got, err)
+	}
+
+	for _, bad := range []actionOptions{
+		{Profiles: []string{"--all"}},
+		{EnvFile: "../secrets.env"},
+		{EnvFile: outside},
+		{EnvFile: "linked.env"},
+		{EnvFile: "missing.env"},
+		{EnvFile: "."},
+	} {
+		if _, err := bad.flags(dir); err == nil {
+			t.Errorf("%+v: expected an error", bad)
+		}
+	}
+}
+
+func TestInsertFlags(t *testing.T) {
+	args := []string{"compose", "up", "-d"}
+	got := insertFlags(args, []string{"--profile", "debug"})
+	if want := []string{"compose", "--profile", "debug", "up", "-d"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("insertFlags = %v, want %v", got, want)
+	}
+	if want := []string{"compose", "up", "-d"}; !reflect.DeepEqual(args, want) {
+		t.Errorf("input modified: %v", args)
+	}
+}
@@ -0,0 +1,150 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+
+	"github.com/driversti/hola/internal/api/respond"
+	"github.com/driversti/hola/internal/registry"
+)
+
+// actionOutput is one line of command output in an action stream.
+type actionOutput struct {
+	Stream string `json:"stream"` // "stdout" or "stderr"
+	Line   string `json:"line"`
+}
+
+// stackActionStream runs a stack action like stackAction but streams the
+// command's output as Server-Sent Events while it runs: an "output" event
+// per line, then a single "done" event with the outcome. The command is
+// bound to the request context, so it is killed if the client disconnects.
+func (h *handlers) stackActionStream(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	action := r.PathValue("action")
+
+	args := stackActionArgs(action)
+	if args == nil {
+		respond.Error(w, http.StatusBadRequest, fmt.Sprintf("unknown action: %s", action), "BAD_REQUEST")
+		return
+	}
+
+	detail, ok := h.resolveStackDir(w, r, name)
+	if !ok {
+		return
+	}
+	flags, err := queryActionOptions(r).flags(detail.WorkingDir)
+	if err != nil {
+		respond.Error(w, http.StatusBadRequest, err.Error(), "INVALID_OPTIONS")
+		return
+	}
+	args = h.composeArgs(name, detail.WorkingDir, registry.ComposeFiles(detail.WorkingDir), insertFlags(args, flags))
+
+	ctx, cancel := context.WithTimeout(r.Context(), h.opts.ActionTimeout)
+	defer cancel()
+	cmd := actionCommand(ctx, detail.WorkingDir, "docker", args...)
+	command := commandLine("docker", args...)
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		respond.Error(w, http.StatusInternalServerError, "failed to start command", "EXEC_ERROR")
+		return
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		respond.Error(w, http.StatusInternalServerError, "failed to start command", "EXEC_ERROR")
+		return
+	}
+	if err := cmd.Start(); err != nil {
+		slog.ErrorContext(r.Context(), "stack action failed to start", "name", name, "action", action, "error", err)
+		respond.Error(w, http.StatusInternalServerError, "failed to start command: "+err.Error(), "EXEC_ERROR")
+		return
+	}
+
+	send := respond.SSE(w)
+	send("start", map[string]any{"command": command})
+
+	// Both pipes feed one channel so that only this goroutine writes to w.
+	lines := make(chan actionOutput)
+	var wg sync.WaitGroup
+	for stream, pipe := range map[string]io.Reader{"stdout": stdout, "stderr": stderr} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sc := bufio.NewScanner(pipe)
+			sc.Buffer(make([]byte, 64<<10), 1<<20)
+			sc.Split(scanTerminalLines)
+			for sc.Scan() {
+				if len(sc.Bytes()) == 0 {
+					continue
+				}
+				lines <- actionOutput{Stream: stream, Line: sc.Text()}
+			}
+			// A line over the buffer limit stops the scanner. Say so and
+			// keep draining the pipe, or compose would block writing to it
+			// until the action timed out.
+			if err := sc.Err(); err != nil {
+				lines <- actionOutput{Stream: stream, Line: fmt.Sprintf("[rest of %s discarded: %s]", stream, err)}
+				io.Copy(io.Discard, pipe)
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(lines)
+	}()
+
+	// Classify from stderr as it streams past rather than buffering it all.
+	var code string
+	for line := range lines {
+		if code == "" && line.Stream == "stderr" {
+			code = classifyComposeError(line.Line)
+		}
+		send("output", line)
+	}
+	err = cmd.Wait()
+
+	if r.Context().Err() != nil {
+		slog.InfoContext(r.Context(), "stack action cancelled by client disconnect", "name", name, "action", action)
+		return
+	}
+
+	done := map[string]any{"success": err == nil, "command": command, "exit_code": cmd.ProcessState.ExitCode()}
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		slog.WarnContext(r.Context(), "stack action timed out", "name", name, "action", action, "command", command, "timeout", h.opts.ActionTimeout)
+		done["success"] = false
+		done["error"] = actionTimeoutError(action, h.opts.ActionTimeout).Error()
+		done["code"] = "ACTION_TIMEOUT"
+	} else if err != nil {
+		slog.ErrorContext(r.Context(), "stack action failed", "name", name, "action", action, "command", command, "error", err)
+		done["error"] = fmt.Sprintf("failed to %s stack: %s", action, err)
+		done["code"] = cmp.Or(code, codeComposeFailed)
+	} else {
+		slog.InfoContext(r.Context(), "stack action succeeded", "name", name, "action", action)
+		done["message"] = fmt.Sprintf("Stack '%s' %s successfully", name, actionPastTense(action))
+	}
+	send("done", done)
+}
+
+// scanTerminalLines is a bufio.SplitFunc that ends lines at "\n" or "\r",
+// so progress output that redraws a line with carriage returns (as
+// docker compose pull does) is delivered as it updates.
+func scanTerminalLines(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	if atEOF && len(data) == 0 {
+		return 0, nil, nil
+	}
+	if i := bytes.IndexAny(data, "\r\n"); i >= 0 {
+		return i + 1, data[:i], nil
+	}
+	if atEOF {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
@@ -0,0 +1,22 @@
+package api
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+)
+
+func TestScanTerminalLines(t *testing.T) {
+	input := "Pulling web\r web 10%\r web 100%\nPulled\n\nlast"
+	sc := bufio.NewScanner(strings.NewReader(input))
+	sc.Split(scanTerminalLines)
+
+	var got []string
+	for sc.Scan() {
+		got = append(got, sc.Text())
+	}
+	want := []string{"Pulling web", " web 10%", " web 100%", "Pulled", "", "last"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,68 @@
+package api
+
+import (
+	"context"
+	"os"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/driversti/hola/internal/docker"
+)
+
+// dockerInfoTTL is how long the daemon's version and rootless mode are
+// cached for agent/info before the daemon is asked again.
+const dockerInfoTTL = 5 * time.Minute
+
+// hostInfo holds what agent/info reports about the host. The hostname, OS
+// and arch are read once; the daemon's details are cached for ttl, since a
+// UI may poll the endpoint.
+type hostInfo struct {
+	hostname string
+	os       string
+	arch     string
+
+	fetch func(ctx context.Context) (version string, rootless bool, err error) // nil without a Docker client
+	ttl   time.Duration
+
+	mu            sync.Mutex
+	dockerVersion string
+	rootless      bool
+	fetched       time.Time
+}
+
+func newHostInfo(dockerClient *docker.Client) *hostInfo {
+	hostname, _ := os.Hostname()
+	hi := &hostInfo{hostname: hostname, os: runtime.GOOS, arch: runtime.GOARCH, ttl: dockerInfoTTL}
+	if dockerClient != nil {
+		hi.fetch = func(ctx context.Context) (string, bool, error) {
+			version, _, err := dockerClient.ServerVersion(ctx)
+			if err != nil {
+				return "", false, err
+			}
+			return version, dockerClient.Rootless(ctx), nil
+		}
+	}
+	return hi
+}
+
+// docker returns the daemon's version and whether it runs rootless, asking
+// the daemon when the cached answer is older than ttl or refresh is set.
+// Failures are not cached, so a daemon that comes back is seen on the next
+// call; until then the version is "unknown".
+func (hi *hostInfo) docker(ctx context.Context, refresh bool) (version string, rootless bool) {
+	if hi.fetch == nil {
+		return "unknown", false
+	}
+	hi.mu.Lock()
+	defer hi.mu.Unlock()
+	if !refresh && !hi.fetched.IsZero() && time.Since(hi.fetched) < hi.ttl {
+		return hi.dockerVersion, hi.rootless
+	}
+	version, rootless, err := hi.fetch(ctx)
+	if err != nil {
+		return "unknown", false
+	}
+	hi.dockerVersion, hi.rootless, hi.fetched = version, rootless, time.Now()
+	return version, rootless
+}
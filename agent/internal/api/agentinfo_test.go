@@ -0,0 +1,56 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestHostInfoCachesDockerDetails(t *testing.T) {
+	calls := 0
+	fail := false
+	hi := &hostInfo{ttl: time.Hour, fetch: func(context.Context) (string, bool, error) {
+		calls++
+		if fail {
+			return "", false, errors.New("daemon down")
+		}
+		return "27.1.0", true, nil
+	}}
+	ctx := context.Background()
+
+	for range 3 {
+		if v, rootless := hi.docker(ctx, false); v != "27.1.0" || !rootless {
+			t.Fatalf("docker() = %q, %v", v, rootless)
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("fetched %d times within the TTL, want 1", calls)
+	}
+
+	hi.docker(ctx, true)
+	if calls != 2 {
+		t.Fatalf("refresh did not fetch: %d calls", calls)
+	}
+
+	// A failed fetch reports unknown and is retried on the next call.
+	fail = true
+	if v, _ := hi.docker(ctx, true); v != "unknown" {
+		t.Fatalf("failed fetch gave version %q, want unknown", v)
+	}
+	fail = false
+	hi.ttl = 0
+	if v, _ := hi.docker(ctx, false); v != "27.1.0" || calls != 4 {
+		t.Fatalf("after failure got %q with %d calls, want 27.1.0 with 4", v, calls)
+	}
+}
+
+func TestHostInfoWithoutDocker(t *testing.T) {
+	hi := newHostInfo(nil)
+	if v, rootless := hi.docker(context.Background(), true); v != "unknown" || rootless {
+		t.Fatalf("docker() = %q, %v; want unknown, false", v, rootless)
+	}
+	if hi.os == "" || hi.arch == "" {
+		t.Fatalf("os/arch not set: %+v", hi)
+	}
+}
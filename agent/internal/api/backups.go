@@ -0,0 +1,186 @@
+package api
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+
+	"github.com/driversti/hola/internal/api/respond"
+)
+
+// backupFileRe matches backup files written by the agent: "x.bak" and "x.bak.N".
+var backupFileRe = regexp.MustCompile(`\.bak(\.\d+)?$`)
+
+// isBackupFile reports whether name looks like an agent-created backup.
+func isBackupFile(name string) bool {
+	return backupFileRe.MatchString(name)
+}
+
+// backupPath returns the path of the index-th backup of path (1 = newest).
+func backupPath(path string, index int) string {
+	return fmt.Sprintf("%s.bak.%d", path, index)
+}
+
+// rotateBackups shifts the existing backups of path one slot older, dropping
+// anything beyond keep, and writes data as the newest backup (.bak.1).
+func rotateBackups(path string, data []byte, perm os.FileMode, keep int) error {
+	if keep < 1 {
+		keep = 1
+	}
+
+	// Drop backups that fall off the end of the chain, including leftovers
+	// from a previously larger retention setting.
+	for i := keep; ; i++ {
+		if err := os.Remove(backupPath(path, i)); err != nil {
+			if os.IsNotExist(err) {
+				break
+			}
+			return fmt.Errorf("remove old backup: %w", err)
+		}
+	}
+
+	for i := keep - 1; i >= 1; i-- {
+		err := os.Rename(backupPath(path, i), backupPath(path, i+1))
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("rotate backup %d: %w", i, err)
+		}
+	}
+
+	return os.WriteFile(backupPath(path, 1), data, perm)
+}
+
+// composeBackup describes one entry in a compose file's backup chain.
+type composeBackup struct {
+	Index      int    `json:"index"`
+	Path       string `json:"path"`
+	Size       int64  `json:"size"`
+	ModifiedAt int64  `json:"modified_at"`
+}
+
+// listBackups returns the backup chain of path, newest first.
+func listBackups(path string) []composeBackup {
+	backups := []composeBackup{}
+	for i := 1; ; i++ {
+		p := backupPath(path, i)
+		info, err := os.Stat(p)
+		if err != nil {
+			break
+		}
+		backups = append(backups, composeBackup{
+			Index:      i,
+			Path:       p,
+			Size:       info.Size(),
+			ModifiedAt: info.ModTime().Unix(),
+		})
+	}
+	return backups
+}
+
+func (h *handlers) listComposeBackups(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+
+	composePath := h.resolveComposeFilePath(r.Context(), name)
+	if composePath == "" {
+		respond.Error(w, http.StatusNotFound, fmt.Sprintf("compose file not found for stack %q", name), "NOT_FOUND")
+		return
+	}
+
+	respond.JSON(w, http.StatusOK, map[string]any{
+		"path":    composePath,
+		"backups": listBackups(composePath),
+	})
+}
+
+func (h *handlers) restoreComposeBackup(w http.ResponseWriter, r *http.Request) {
+	index, err := strconv.Atoi(r.PathValue("index"))
+	if err != nil || index < 1 {
+		respond.Error(w, http.StatusBadRequest, "backup index must be a positive integer", "BAD_REQUEST")
+		return
+	}
+	h.restoreBackup(w, r, index)
+}
+
+// restoreLatestComposeBackup undoes the most recent compose file edit.
+func (h *handlers) restoreLatestComposeBackup(w http.ResponseWriter, r *http.Request) {
+	h.restoreBackup(w, r, 1)
+}
+
+// restoreBackup validates the index-th backup of the stack's compose file
+// and swaps it in, first rotating the current content into the backup
+// chain so the restore itself can be undone.
+func (h *handlers) restoreBackup(w http.ResponseWriter, r *http.Request, index int) {
+	name := r.PathValue("name")
+
+	composePath := h.resolveComposeFilePath(r.Context(), name)
+	if composePath == "" {
+		respond.Error(w, http.StatusNotFound, fmt.Sprintf("compose file not found for stack %q", name), "NOT_FOUND")
+		return
+	}
+
+	src := backupPath(composePath, index)
+	if _, err := os.Stat(src); os.IsNotExist(err) && index == 1 {
+		// Agents before backup rotation wrote a single unnumbered .bak.
+		src = composePath + ".bak"
+	}
+	backupData, err := os.ReadFile(src)
+	if err != nil {
+		if os.IsNotExist(err) {
+			respond.Error(w, http.StatusNotFound, fmt.Sprintf("backup %d does not exist", index), "NO_BACKUP")
+			return
+		}
+		slog.ErrorContext(r.Context(), "failed to read backup", "path", src, "error", err)
+		respond.Error(w, http.StatusInternalServerError, "failed to read backup", "IO_ERROR")
+		return
+	}
+
+	// The backup sits beside the compose file, so it validates against the
+	// same .env and relative paths.
+	warnings, err := validateCompose(r.Context(), h.opts.DockerArgs, filepath.Dir(composePath), src)
+	if err != nil {
+		respond.JSON(w, http.StatusOK, map[string]any{
+			"success":  false,
+			"error":    fmt.Sprintf("backup failed docker compose validation: %s", err),
+			"warnings": warnings,
+		})
+		return
+	}
+
+	info, err := os.Stat(composePath)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to stat compose file", "path", composePath, "error", err)
+		respond.Error(w, http.StatusInternalServerError, "failed to read compose file info", "IO_ERROR")
+		return
+	}
+	perm := info.Mode().Perm()
+
+	// Back up the current content first so the restore itself can be undone.
+	currentData, err := os.ReadFile(composePath)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to read compose file", "path", composePath, "error", err)
+		respond.Error(w, http.StatusInternalServerError, "failed to read compose file", "IO_ERROR")
+		return
+	}
+	if err := rotateBackups(composePath, currentData, perm, h.opts.ComposeBackups); err != nil {
+		slog.ErrorContext(r.Context(), "failed to create backup", "path", composePath, "error", err)
+		respond.Error(w, http.StatusInternalServerError, "failed to create backup", "IO_ERROR")
+		return
+	}
+
+	if err := os.WriteFile(composePath, backupData, perm); err != nil {
+		slog.ErrorContext(r.Context(), "failed to write compose file", "path", composePath, "error", err)
+		respond.Error(w, http.StatusInternalServerError, "failed to write compose file", "IO_ERROR")
+		return
+	}
+
+	slog.InfoContext(r.Context(), "compose file restored from backup", "stack", name, "path", composePath, "index", index)
+	respond.JSON(w, http.StatusOK, map[string]any{
+		"success":  true,
+		"message":  fmt.Sprintf("Compose file for stack '%s' restored from backup %d", name, index),
+		"content":  string(backupData),
+		"warnings": warnings,
+	})
+}
@@ -0,0 +1,73 @@
+package api
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRotateBackups(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "compose.yml")
+
+	for _, content := range []string{"v1", "v2", "v3", "v4"} {
+		if err := rotateBackups(path, []byte(content), 0o644, 3); err != nil {
+			t.Fatalf("rotateBackups(%q): %v", content, err)
+		}
+	}
+
+	want := map[int]string{1: "v4", 2: "v3", 3: "v2"}
+	for index, content := range want {
+		data, err := os.ReadFile(backupPath(path, index))
+		if err != nil {
+			t.Fatalf("backup %d: %v", index, err)
+		}
+		if string(data) != content {
+			t.Errorf("backup %d = %q, want %q", index, data, content)
+		}
+	}
+	if _, err := os.Stat(backupPath(path, 4)); !os.IsNotExist(err) {
+		t.Errorf("backup 4 should have been dropped, stat err = %v", err)
+	}
+
+	backups := listBackups(path)
+	if len(backups) != 3 {
+		t.Fatalf("listBackups returned %d entries, want 3", len(backups))
+	}
+	if backups[0].Index != 1 {
+		t.Errorf("first backup index = %d, want 1", backups[0].Index)
+	}
+}
+
+func TestRotateBackups_ShrinkRetention(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "compose.yml")
+
+	for _, content := range []string{"v1", "v2", "v3"} {
+		if err := rotateBackups(path, []byte(content), 0o644, 3); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := rotateBackups(path, []byte("v4"), 0o644, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := len(listBackups(path)); got != 1 {
+		t.Errorf("listBackups returned %d entries after shrinking retention, want 1", got)
+	}
+}
+
+func TestIsBackupFile(t *testing.T) {
+	tests := map[string]bool{
+		"compose.yml.bak":   true,
+		"compose.yml.bak.3": true,
+		"compose.yml":       false,
+		"backup.yml":        false,
+		"notes.bak.txt":     false,
+	}
+	for name, want := range tests {
+		if got := isBackupFile(name); got != want {
+			t.Errorf("isBackupFile(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
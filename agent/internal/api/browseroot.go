@@ -0,0 +1,71 @@
+package api
+
+import (
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/driversti/hola/internal/api/respond"
+)
+
+// resolveRoots cleans the configured browse roots and resolves their
+// symlinks, so they compare equal to the resolved paths checked against
+// them. A root that can't be resolved is kept as given.
+func resolveRoots(roots []string) []string {
+	resolved := make([]string, 0, len(roots))
+	for _, root := range roots {
+		root = filepath.Clean(root)
+		if real, err := filepath.EvalSymlinks(root); err == nil {
+			root = real
+		}
+		resolved = append(resolved, root)
+	}
+	return resolved
+}
+
+// withinRoots reports whether path, after symlink resolution, is one of
+// roots or lies beneath one. With no roots every path is allowed.
+func withinRoots(roots []string, path string) bool {
+	if len(roots) == 0 {
+		return true
+	}
+	path = resolveExisting(path)
+	for _, root := range roots {
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			continue
+		}
+		if rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveExisting resolves the symlinks in the deepest existing ancestor of
+// path and appends the rest, so a path about to be created is checked
+// where it would actually land.
+func resolveExisting(path string) string {
+	rest := ""
+	for {
+		if real, err := filepath.EvalSymlinks(path); err == nil {
+			return filepath.Join(real, rest)
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return filepath.Join(path, rest)
+		}
+		rest = filepath.Join(filepath.Base(path), rest)
+		path = parent
+	}
+}
+
+// allowPath writes a 403 FORBIDDEN_PATH response and returns false when
+// path is outside the configured browse roots.
+func (h *handlers) allowPath(w http.ResponseWriter, path string) bool {
+	if withinRoots(h.opts.BrowseRoots, path) {
+		return true
+	}
+	respond.Error(w, http.StatusForbidden, "path is outside the allowed browse roots", "FORBIDDEN_PATH")
+	return false
+}
@@ -0,0 +1,43 @@
+package api
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWithinRoots(t *testing.T) {
+	base := t.TempDir()
+	root := filepath.Join(base, "stacks")
+	outside := filepath.Join(base, "etc")
+	for _, dir := range []string{filepath.Join(root, "app"), outside} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	escape := filepath.Join(root, "escape")
+	if err := os.Symlink(outside, escape); err != nil {
+		t.Fatal(err)
+	}
+
+	roots := resolveRoots([]string{root})
+	tests := map[string]bool{
+		root:                           true,
+		filepath.Join(root, "app"):     true,
+		outside:                        false,
+		base:                           false,
+		escape:                         false,
+		filepath.Join(base, "stacks2"): false,
+		// Paths that don't exist yet are checked where they would land.
+		filepath.Join(root, "new", "dir"):   true,
+		filepath.Join(escape, "new", "dir"): false,
+	}
+	for path, want := range tests {
+		if got := withinRoots(roots, path); got != want {
+			t.Errorf("withinRoots(%q) = %v, want %v", path, got, want)
+		}
+	}
+	if !withinRoots(nil, outside) {
+		t.Error("no roots should allow every path")
+	}
+}
@@ -0,0 +1,161 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"sync"
+
+	"github.com/driversti/hola/internal/api/respond"
+	"github.com/driversti/hola/internal/registry"
+)
+
+// bulkResult is the outcome of one stack in a bulk action.
+type bulkResult struct {
+	Stack   string `json:"stack"`
+	Success bool   `json:"success"`
+	Message string `json:"message,omitempty"`
+	Error   string `json:"error,omitempty"`
+	Code    string `json:"code,omitempty"` // STACK_NOT_FOUND, COMPOSE_MISSING, a compose failure code or DOCKER_ERROR
+	Command string `json:"command,omitempty"`
+}
+
+// bulkStackAction runs one action against many stacks, at most
+// opts.BulkConcurrency at a time. Each stack succeeds or fails on its own;
+// the response is 200 with a result per stack, in request order.
+func (h *handlers) bulkStackAction(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, 64<<10)
+	var body struct {
+		Action string   `json:"action"`
+		Stacks []string `json:"stacks"`
+		All    bool     `json:"all"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		respond.Error(w, http.StatusBadRequest, "invalid JSON body", "BAD_REQUEST")
+		return
+	}
+	if stackActionArgs(body.Action) == nil {
+		respond.Error(w, http.StatusBadRequest, fmt.Sprintf("unknown action: %q", body.Action), "BAD_REQUEST")
+		return
+	}
+
+	names := dedupe(body.Stacks)
+	if body.All {
+		all, err := h.allStackNames(r)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to list stacks", "error", err)
+			respond.Error(w, http.StatusInternalServerError, "failed to list stacks", "DOCKER_ERROR")
+			return
+		}
+		names = all
+	}
+	if len(names) == 0 {
+		respond.Error(w, http.StatusBadRequest, "stacks must not be empty unless all is set", "BAD_REQUEST")
+		return
+	}
+
+	results := make([]bulkResult, len(names))
+	sem := make(chan struct{}, h.opts.BulkConcurrency)
+	var wg sync.WaitGroup
+	for i, name := range names {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			results[i] = h.bulkOne(r, name, body.Action)
+		}()
+	}
+	wg.Wait()
+
+	succeeded := 0
+	for _, res := range results {
+		if res.Success {
+			succeeded++
+		}
+	}
+	slog.InfoContext(r.Context(), "bulk stack action finished", "action", body.Action, "stacks", len(names), "succeeded", succeeded)
+	respond.JSON(w, http.StatusOK, map[string]any{
+		"success":   succeeded == len(results),
+		"action":    body.Action,
+		"succeeded": succeeded,
+		"failed":    len(results) - succeeded,
+		"results":   results,
+	})
+}
+
+func (h *handlers) bulkOne(r *http.Request, name, action string) bulkResult {
+	detail, err := h.stackDir(r.Context(), name)
+	if err != nil {
+		code := "DOCKER_ERROR"
+		switch {
+		case errors.Is(err, errStackNotFound):
+			code = "STACK_NOT_FOUND"
+		case errors.Is(err, errComposeMissing):
+			code = "COMPOSE_MISSING"
+		}
+		return bulkResult{Stack: name, Error: err.Error(), Code: code}
+	}
+
+	composeFiles := registry.ComposeFiles(detail.WorkingDir)
+	command, err := h.runStackAction(r.Context(), name, detail.WorkingDir, action, composeFiles, nil)
+	if err != nil {
+		code := codeComposeFailed
+		var sae *stackActionError
+		switch {
+		case errors.Is(err, errActionTimeout):
+			code = "ACTION_TIMEOUT"
+		case errors.As(err, &sae):
+			code = sae.code
+		}
+		return bulkResult{Stack: name, Error: err.Error(), Code: code, Command: command}
+	}
+	return bulkResult{
+		Stack:   name,
+		Success: true,
+		Message: fmt.Sprintf("Stack '%s' %s successfully", name, actionPastTense(action)),
+		Command: command,
+	}
+}
+
+// allStackNames returns every running or registered stack, skipping
+// registrations whose compose file is gone.
+func (h *handlers) allStackNames(r *http.Request) ([]string, error) {
+	stacks, err := h.docker.ListStacks(r.Context())
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	for _, st := range stacks {
+		seen[st.Name] = true
+	}
+	for _, rs := range h.registry.All() {
+		if !seen[rs.Name] && findComposeFile(rs.WorkingDir) != "" {
+			seen[rs.Name] = true
+		}
+	}
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// dedupe returns names without empty entries or repeats, in first-seen
+// order.
+func dedupe(names []string) []string {
+	seen := make(map[string]bool, len(names))
+	out := make([]string, 0, len(names))
+	for _, n := range names {
+		if n == "" || seen[n] {
+			continue
+		}
+		seen[n] = true
+		out = append(out, n)
+	}
+	return out
+}
@@ -0,0 +1,58 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// errActionTimeout means a stack action ran longer than --action-timeout
+// and was killed.
+var errActionTimeout = errors.New("action timed out")
+
+// actionCommand prepares a stack action command run in dir. It is killed,
+// along with every process it started, when ctx is done.
+func actionCommand(ctx context.Context, dir, name string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Dir = dir
+	setProcessGroup(cmd)
+	return cmd
+}
+
+// actionTimeoutError reports a stack action killed after timeout.
+func actionTimeoutError(action string, timeout time.Duration) error {
+	return fmt.Errorf("%w: %s did not finish within %s", errActionTimeout, action, timeout)
+}
+
+// commandLine renders a command and its arguments as a single line that can
+// be pasted into a POSIX shell. Arguments containing anything beyond a
+// conservative set of safe characters are single-quoted.
+func commandLine(name string, args ...string) string {
+	parts := make([]string, 0, len(args)+1)
+	parts = append(parts, shellQuote(name))
+	for _, a := range args {
+		parts = append(parts, shellQuote(a))
+	}
+	return strings.Join(parts, " ")
+}
+
+func shellQuote(s string) string {
+	if s == "" {
+		return "''"
+	}
+	safe := true
+	for _, r := range s {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' ||
+			strings.ContainsRune("-_./:=@,+%", r)) {
+			safe = false
+			break
+		}
+	}
+	if safe {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
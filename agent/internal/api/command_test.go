@@ -0,0 +1,100 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/driversti/hola/internal/registry"
+)
+
+func TestCommandLine(t *testing.T) {
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"compose", "-f", "/srv/app/compose.yml", "up", "-d"}, "docker compose -f /srv/app/compose.yml up -d"},
+		{[]string{"compose", "-f", "/srv/my app/compose.yml", "stop"}, "docker compose -f '/srv/my app/compose.yml' stop"},
+		{[]string{"compose", "-p", "it's"}, `docker compose -p 'it'\''s'`},
+		{[]string{"compose", "--env-file", ""}, "docker compose --env-file ''"},
+	}
+	for _, tt := range tests {
+		if got := commandLine("docker", tt.args...); got != tt.want {
+			t.Errorf("commandLine(%q) = %s, want %s", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestRunStackActionTimeoutKillsProcessGroup(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs a POSIX shell")
+	}
+	// A fake docker whose child outlives it and keeps the output pipe open:
+	// only killing the whole process group lets the action return promptly.
+	bin := t.TempDir()
+	script := "#!/bin/sh\nsleep 30 &\nwait\n"
+	if err := os.WriteFile(filepath.Join(bin, "docker"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	store, _ := registry.NewStore(t.TempDir())
+	h := &handlers{registry: store, opts: Options{ActionTimeout: 200 * time.Millisecond}}
+	dir := t.TempDir()
+
+	start := time.Now()
+	_, err := h.runStackAction(context.Background(), filepath.Base(dir), dir, "pull", nil, nil)
+	if !errors.Is(err, errActionTimeout) {
+		t.Fatalf("expected errActionTimeout, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("action took %s to return after timing out; child processes were not killed", elapsed)
+	}
+}
+
+func TestOrderedRestartReportsCancellation(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs a POSIX shell")
+	}
+	bin := t.TempDir()
+	if err := os.WriteFile(filepath.Join(bin, "docker"), []byte("#!/bin/sh\nexit 0\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	dir := t.TempDir()
+	composePath := filepath.Join(dir, "compose.yaml")
+	if err := os.WriteFile(composePath, []byte("services:\n  db: {}\n  web:\n    depends_on: [db]\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	store, _ := registry.NewStore(t.TempDir())
+	h := &handlers{registry: store, opts: Options{ActionTimeout: time.Minute}}
+
+	// The client goes away while the restart waits between services.
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	r := httptest.NewRequestWithContext(ctx, http.MethodPost, "/api/v1/stacks/app/restart", nil)
+	w := httptest.NewRecorder()
+	h.orderedRestart(w, r, "app", dir, []string{composePath}, nil, time.Hour)
+
+	var out struct {
+		Success   bool
+		Error     string
+		Restarted []string
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &out); err != nil {
+		t.Fatalf("no JSON response written: %v (%q)", err, w.Body.String())
+	}
+	if out.Success || !strings.Contains(out.Error, "cancelled before service 'web'") || !slices.Equal(out.Restarted, []string{"db"}) {
+		t.Errorf("got %+v, want a cancellation after db", out)
+	}
+}
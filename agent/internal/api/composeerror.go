@@ -0,0 +1,76 @@
+package api
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Codes for classified compose failures. Clients can rely on these; the raw
+// output stays available for display.
+const (
+	codeImageNotFound  = "IMAGE_NOT_FOUND"
+	codePortConflict   = "PORT_CONFLICT"
+	codePullAuthFailed = "PULL_AUTH_FAILED"
+	codeComposeInvalid = "COMPOSE_INVALID"
+	// codeComposeFailed is used when the output matches no known pattern.
+	codeComposeFailed = "DOCKER_ERROR"
+)
+
+// composeErrorPatterns maps lower-cased substrings of docker compose output
+// to failure codes. Order matters: the first match wins, so the ambiguous
+// "pull access denied ... repository does not exist" is listed as a missing
+// image before the generic access-denied auth patterns.
+var composeErrorPatterns = []struct {
+	substr string
+	code   string
+}{
+	{"repository does not exist", codeImageNotFound},
+	{"manifest unknown", codeImageNotFound},
+	{"not found: manifest", codeImageNotFound},
+	{"no such image", codeImageNotFound},
+	{"port is already allocated", codePortConflict},
+	{"address already in use", codePortConflict},
+	{"unauthorized", codePullAuthFailed},
+	{"authentication required", codePullAuthFailed},
+	{"no basic auth credentials", codePullAuthFailed},
+	{"requested access to the resource is denied", codePullAuthFailed},
+	{"yaml:", codeComposeInvalid},
+	{"validating ", codeComposeInvalid},
+	{"additional property", codeComposeInvalid},
+	{"additional properties", codeComposeInvalid},
+	{"refers to undefined", codeComposeInvalid},
+	{"invalid compose project", codeComposeInvalid},
+	{"no configuration file provided", codeComposeInvalid},
+}
+
+// classifyComposeError returns the failure code for docker compose output,
+// or "" if it matches no known failure mode.
+func classifyComposeError(output string) string {
+	lower := strings.ToLower(output)
+	for _, p := range composeErrorPatterns {
+		if strings.Contains(lower, p.substr) {
+			return p.code
+		}
+	}
+	return ""
+}
+
+// stackActionError is a failed compose run, carrying its raw output and
+// the classified failure code.
+type stackActionError struct {
+	action string
+	output string
+	code   string
+}
+
+func newStackActionError(action, output string) *stackActionError {
+	code := classifyComposeError(output)
+	if code == "" {
+		code = codeComposeFailed
+	}
+	return &stackActionError{action: action, output: output, code: code}
+}
+
+func (e *stackActionError) Error() string {
+	return fmt.Sprintf("failed to %s stack: %s", e.action, e.output)
+}
@@ -0,0 +1,37 @@
+package api
+
+import "testing"
+
+func TestClassifyComposeError(t *testing.T) {
+	tests := []struct {
+		output string
+		want   string
+	}{
+		{"Error response from daemon: pull access denied for nosuch/app, repository does not exist or may require 'docker login': denied: requested access to the resource is denied", codeImageNotFound},
+		{"Error response from daemon: manifest for nginx:9.9 not found: manifest unknown: manifest unknown", codeImageNotFound},
+		{"Error response from daemon: driver failed programming external connectivity on endpoint web: Bind for 0.0.0.0:80 failed: port is already allocated", codePortConflict},
+		{"Error starting userland proxy: listen tcp4 0.0.0.0:53: bind: address already in use", codePortConflict},
+		{"Error response from daemon: Head \"https://ghcr.io/v2/acme/app/manifests/latest\": unauthorized", codePullAuthFailed},
+		{"Error response from daemon: Get \"https://registry.example.com/v2/\": no basic auth credentials", codePullAuthFailed},
+		{"yaml: line 4: mapping values are not allowed in this context", codeComposeInvalid},
+		{"validating /srv/app/compose.yaml: services.web additional properties 'imagee' not allowed", codeComposeInvalid},
+		{"service \"web\" refers to undefined volume data: invalid compose project", codeComposeInvalid},
+		{"Error response from daemon: container abc is not running", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := classifyComposeError(tt.output); got != tt.want {
+			t.Errorf("classifyComposeError(%q) = %q, want %q", tt.output, got, tt.want)
+		}
+	}
+}
+
+func TestStackActionErrorDefaultsToDockerError(t *testing.T) {
+	err := newStackActionError("stop", "something odd happened")
+	if err.code != codeComposeFailed {
+		t.Errorf("code = %q, want %q", err.code, codeComposeFailed)
+	}
+	if got, want := err.Error(), "failed to stop stack: something odd happened"; got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+}
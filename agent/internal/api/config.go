@@ -0,0 +1,94 @@
+package api
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/driversti/hola/internal/api/respond"
+)
+
+// redacted replaces secret values in the reported configuration.
+const redacted = "<redacted>"
+
+// AgentConfig is the effective configuration the agent resolved from its
+// flags, environment and defaults, as reported by GET /api/v1/agent/config.
+// Secrets must be passed through Redact or RedactURL before being stored.
+type AgentConfig struct {
+	ListenAddr          string       `json:"listen_addr"`
+	TLS                 bool         `json:"tls"`
+	TLSAuto             bool         `json:"tls_auto"`
+	Token               string       `json:"token"`
+	DataDir             string       `json:"data_dir"`
+	DockerHost          string       `json:"docker_host"`
+	DockerTLS           bool         `json:"docker_tls"`
+	LogLevel            string       `json:"log_level"`
+	LogFormat           string       `json:"log_format"`
+	ComposeBackups      int          `json:"compose_backups"`
+	MaintenanceDuration string       `json:"maintenance_duration"`
+	ScanDirs            []string     `json:"scan_dirs"`
+	BrowseRoots         []string     `json:"browse_roots"`
+	CORSOrigins         []string     `json:"cors_origins"`
+	ExecTimeout         string       `json:"exec_timeout"`
+	ActionTimeout       string       `json:"action_timeout"`
+	MaxLogLines         int          `json:"max_log_lines"`
+	ExecMaxOutput       int          `json:"exec_max_output"`
+	BulkConcurrency     int          `json:"bulk_concurrency"`
+	UpdateRepo          string       `json:"update_repo"`
+	UpdateChannel       string       `json:"update_channel"`
+	UpdateSigned        bool         `json:"update_signed"` // checksums.txt must be signed with --update-public-key
+	AutoUpdate          string       `json:"auto_update"`   // interval, "0s" when disabled
+	AutoUpdateChannel   string       `json:"auto_update_channel"`
+	GitHubToken         string       `json:"github_token,omitempty"`
+	WebSocket           WSConfig     `json:"websocket"`
+	Alerts              AlertsConfig `json:"alerts"`
+}
+
+// WSConfig is the WebSocket part of AgentConfig.
+type WSConfig struct {
+	AllowedOrigins  []string `json:"allowed_origins"`
+	AllowAllOrigins bool     `json:"allow_all_origins"`
+	PingInterval    string   `json:"ping_interval"`
+	EventHistory    int      `json:"event_history"`
+	MaxConnections  int      `json:"max_connections"`
+	MaxMessageSize  int64    `json:"max_message_size"`
+}
+
+// AlertsConfig is the resource alert part of AgentConfig.
+type AlertsConfig struct {
+	Enabled     bool     `json:"enabled"`
+	Interval    string   `json:"interval"`
+	CPUPercent  float64  `json:"cpu_percent"`
+	CPUDuration string   `json:"cpu_duration"`
+	MemPercent  float64  `json:"mem_percent"`
+	DiskPercent float64  `json:"disk_percent"`
+	DiskMounts  []string `json:"disk_mounts"`
+	Webhook     string   `json:"webhook,omitempty"`
+}
+
+// Redact hides a secret while still showing whether it was set.
+func Redact(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	return redacted
+}
+
+// RedactURL keeps only the scheme and host of a URL, since webhook
+// credentials commonly live in the path, query or userinfo.
+func RedactURL(raw string) string {
+	if raw == "" {
+		return ""
+	}
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return redacted
+	}
+	if u.User == nil && u.Path == "" && u.RawQuery == "" && u.Fragment == "" {
+		return u.Scheme + "://" + u.Host
+	}
+	return u.Scheme + "://" + u.Host + "/" + redacted
+}
+
+func (h *handlers) agentConfig(w http.ResponseWriter, _ *http.Request) {
+	respond.JSON(w, http.StatusOK, h.opts.Config)
+}
@@ -0,0 +1,54 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+)
+
+// corsAllowHeaders are the request headers browsers may send cross-origin.
+const corsAllowHeaders = "Authorization, Content-Type, If-Match, X-Registry-Auth, X-Request-ID"
+
+// corsMiddleware adds CORS headers for requests from the allowed origins
+// and answers their preflight requests, which carry no credentials and so
+// must be handled before authentication. An origin of "*" allows any.
+// With no origins nothing is added and browsers enforce same-origin. The
+// WebSocket endpoint is skipped: it checks Origin itself during upgrade.
+func corsMiddleware(origins []string, next http.Handler) http.Handler {
+	if len(origins) == 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" || r.URL.Path == "/api/v1/ws" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		h := w.Header()
+		h.Add("Vary", "Origin")
+		if !corsAllowed(origins, origin) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		h.Set("Access-Control-Allow-Origin", origin)
+
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			h.Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+			h.Set("Access-Control-Allow-Headers", corsAllowHeaders)
+			h.Set("Access-Control-Max-Age", "600")
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		h.Set("Access-Control-Expose-Headers", "ETag, X-Request-ID")
+		next.ServeHTTP(w, r)
+	})
+}
+
+func corsAllowed(origins []string, origin string) bool {
+	for _, o := range origins {
+		if o == "*" || strings.EqualFold(strings.TrimSuffix(o, "/"), origin) {
+			return true
+		}
+	}
+	return false
+}
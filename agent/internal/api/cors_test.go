@@ -0,0 +1,61 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCORSMiddleware(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+	handler := corsMiddleware([]string{"https://ui.example.com"}, next)
+
+	do := func(method, path, origin string, preflight bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		if preflight {
+			req.Header.Set("Access-Control-Request-Method", "DELETE")
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := do(http.MethodOptions, "/api/v1/stacks/app/unregister", "https://ui.example.com", true)
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("preflight status = %d, want 204", rec.Code)
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "https://ui.example.com" {
+		t.Errorf("preflight Allow-Origin = %q", got)
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Headers"); got != corsAllowHeaders {
+		t.Errorf("preflight Allow-Headers = %q", got)
+	}
+
+	rec = do(http.MethodGet, "/api/v1/stacks", "https://ui.example.com", false)
+	if rec.Code != http.StatusTeapot || rec.Header().Get("Access-Control-Allow-Origin") != "https://ui.example.com" {
+		t.Errorf("allowed request: status %d, Allow-Origin %q", rec.Code, rec.Header().Get("Access-Control-Allow-Origin"))
+	}
+
+	rec = do(http.MethodGet, "/api/v1/stacks", "https://evil.example.com", false)
+	if rec.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Error("disallowed origin got Allow-Origin")
+	}
+
+	rec = do(http.MethodGet, "/api/v1/ws", "https://ui.example.com", false)
+	if rec.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Error("WebSocket route got CORS headers")
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/stacks", nil)
+	req.Header.Set("Origin", "https://ui.example.com")
+	rec = httptest.NewRecorder()
+	corsMiddleware(nil, next).ServeHTTP(rec, req)
+	if len(rec.Header()) != 0 {
+		t.Errorf("no origins configured should add no headers, got %v", rec.Header())
+	}
+}
@@ -0,0 +1,331 @@
+package api
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/driversti/hola/internal/api/respond"
+)
+
+// maskedValue stands in for secret values in GET /stacks/{name}/env.
+const maskedValue = "********"
+
+// envKeyRe matches the variable names compose accepts in an env file.
+var envKeyRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// secretKeyHints are name fragments that mark a variable as a secret.
+var secretKeyHints = []string{"PASSWORD", "PASSWD", "PASS", "SECRET", "TOKEN", "KEY", "CREDENTIAL", "AUTH", "PRIVATE"}
+
+// envEntry is one line of an env file: a variable, a comment, or (with
+// every field empty) a blank line. Keeping comments and blanks as entries
+// lets a file be edited and written back without losing its layout.
+type envEntry struct {
+	Key     string `json:"key,omitempty"`
+	Value   string `json:"value,omitempty"`
+	Comment string `json:"comment,omitempty"`
+	// Masked is set when Value was replaced by maskedValue. On write, a
+	// masked entry keeps the value currently in the file.
+	Masked bool `json:"masked,omitempty"`
+
+	raw   string // the line as read, for entries parsed from a file
+	quote byte   // quote around the value as read: 0, '\'' or '"'
+}
+
+// envInlineCommentRe finds an inline comment after an unquoted value; as
+// in compose, the '#' must follow whitespace.
+var envInlineCommentRe = regexp.MustCompile(`[ \t]+#`)
+
+// envEscaper and envUnescaper translate the escapes compose understands in
+// double-quoted values.
+var (
+	envEscaper   = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`)
+	envUnescaper = strings.NewReplacer(`\\`, `\`, `\"`, `"`, `\n`, "\n", `\r`, "\r", `\t`, "\t")
+)
+
+// parseEnv splits env file content into entries, in file order. Lines that
+// are neither comments nor KEY=VALUE assignments are kept as comments so
+// nothing is silently dropped on write-back.
+func parseEnv(content string) []envEntry {
+	entries := []envEntry{}
+	sc := bufio.NewScanner(strings.NewReader(content))
+	for sc.Scan() {
+		raw := sc.Text()
+		line := strings.TrimSpace(raw)
+		switch {
+		case line == "":
+			entries = append(entries, envEntry{raw: raw})
+			continue
+		case strings.HasPrefix(line, "#"):
+			entries = append(entries, envEntry{Comment: strings.TrimSpace(strings.TrimPrefix(line, "#")), raw: raw})
+			continue
+		}
+		key, value, ok := strings.Cut(strings.TrimPrefix(line, "export "), "=")
+		key = strings.TrimSpace(key)
+		if !ok || !envKeyRe.MatchString(key) {
+			entries = append(entries, envEntry{Comment: line, raw: raw})
+			continue
+		}
+		value, quote := unquoteEnvValue(strings.TrimSpace(value))
+		entries = append(entries, envEntry{Key: key, Value: value, raw: raw, quote: quote})
+	}
+	return entries
+}
+
+// unquoteEnvValue returns the value an env file assignment's right-hand
+// side stands for and the quote it was written with. Text after a closing
+// quote, and an inline comment after an unquoted value, are not part of it.
+func unquoteEnvValue(value string) (string, byte) {
+	if value == "" {
+		return "", 0
+	}
+	switch value[0] {
+	case '\'':
+		if end := strings.IndexByte(value[1:], '\''); end >= 0 {
+			return value[1 : 1+end], '\''
+		}
+	case '"':
+		for i := 1; i < len(value); i++ {
+			switch value[i] {
+			case '\\':
+				i++
+			case '"':
+				return envUnescaper.Replace(value[1:i]), '"'
+			}
+		}
+	}
+	if loc := envInlineCommentRe.FindStringIndex(value); loc != nil {
+		value = value[:loc[0]]
+	}
+	return value, 0
+}
+
+// renderEnv formats entries as env file content. An entry that matches a
+// line of original, the file's current entries, is written as that line
+// byte for byte, so saving doesn't reformat or requote what wasn't edited.
+func renderEnv(entries, original []envEntry) (string, error) {
+	vars := make(map[string]envEntry)
+	lines := make(map[string][]string) // comment text, "" for blanks -> raw lines
+	for _, e := range original {
+		if e.Key != "" {
+			vars[e.Key] = e
+		} else {
+			lines[e.Comment] = append(lines[e.Comment], e.raw)
+		}
+	}
+
+	var b strings.Builder
+	for _, e := range entries {
+		switch orig, ok := vars[e.Key]; {
+		case e.Key != "" && ok && orig.Value == e.Value:
+			b.WriteString(orig.raw)
+		case e.Key != "":
+			value, err := quoteEnvValue(e.Value, orig.quote)
+			if err != nil {
+				return "", fmt.Errorf("variable %q: %w", e.Key, err)
+			}
+			b.WriteString(e.Key + "=" + value)
+		case len(lines[e.Comment]) > 0:
+			b.WriteString(lines[e.Comment][0])
+			lines[e.Comment] = lines[e.Comment][1:]
+		case e.Comment != "":
+			b.WriteString("# " + e.Comment)
+		}
+		b.WriteByte('\n')
+	}
+	return b.String(), nil
+}
+
+// quoteEnvValue writes value for an env file, keeping the quote the
+// variable had (0 for none or a new variable) where the value allows it.
+// Compose interpolates '$' in unquoted and double-quoted values, so values
+// containing one are single-quoted.
+func quoteEnvValue(value string, quote byte) (string, error) {
+	switch {
+	case strings.Contains(value, "$"):
+		if strings.ContainsAny(value, "'\r\n") {
+			return "", errors.New("a value containing '$' cannot also contain a single quote or line break")
+		}
+		return "'" + value + "'", nil
+	case quote == '\'' && !strings.ContainsAny(value, "'\r\n"):
+		return "'" + value + "'", nil
+	case quote == '"' || strings.ContainsAny(value, " \t\"'#\\\r\n"):
+		return `"` + envEscaper.Replace(value) + `"`, nil
+	}
+	return value, nil
+}
+
+// isSecretKey reports whether a variable name suggests its value is a
+// credential.
+func isSecretKey(key string) bool {
+	upper := strings.ToUpper(key)
+	for _, hint := range secretKeyHints {
+		if strings.Contains(upper, hint) {
+			return true
+		}
+	}
+	return false
+}
+
+// validateEnv checks entries before they are written.
+func validateEnv(entries []envEntry) error {
+	seen := make(map[string]bool)
+	for i, e := range entries {
+		if e.Key == "" {
+			if strings.ContainsAny(e.Comment, "\r\n") {
+				return fmt.Errorf("entry %d: comment must be a single line", i)
+			}
+			continue
+		}
+		if !envKeyRe.MatchString(e.Key) {
+			return fmt.Errorf("entry %d: invalid variable name %q", i, e.Key)
+		}
+		if seen[e.Key] {
+			return fmt.Errorf("entry %d: duplicate variable %q", i, e.Key)
+		}
+		seen[e.Key] = true
+	}
+	return nil
+}
+
+// stackEnvPath returns the .env path of a stack. It writes an error response
+// and returns false when the stack can't be found or its .env resolves
+// outside the stack's working directory (e.g. a symlink to /etc/shadow).
+func (h *handlers) stackEnvPath(w http.ResponseWriter, r *http.Request, name string) (string, bool) {
+	composePath := h.resolveComposeFilePath(r.Context(), name)
+	if composePath == "" {
+		respond.Error(w, http.StatusNotFound, fmt.Sprintf("compose file not found for stack %q", name), "NOT_FOUND")
+		return "", false
+	}
+	dir := filepath.Dir(composePath)
+	path := filepath.Join(dir, ".env")
+	if !withinRoots(resolveRoots([]string{dir}), path) {
+		respond.Error(w, http.StatusForbidden, ".env resolves outside the stack directory", "FORBIDDEN_PATH")
+		return "", false
+	}
+	return path, true
+}
+
+func (h *handlers) getStackEnv(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	reveal := r.URL.Query().Get("reveal") == "true"
+
+	path, ok := h.stackEnvPath(w, r, name)
+	if !ok {
+		return
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		slog.ErrorContext(r.Context(), "failed to read env file", "path", path, "error", err)
+		respond.Error(w, http.StatusInternalServerError, "failed to read env file", "IO_ERROR")
+		return
+	}
+
+	entries := parseEnv(string(data))
+	if !reveal {
+		for i, e := range entries {
+			if e.Key != "" && e.Value != "" && isSecretKey(e.Key) {
+				entries[i].Value = maskedValue
+				entries[i].Masked = true
+			}
+		}
+	}
+	respond.JSON(w, http.StatusOK, map[string]any{
+		"path":    path,
+		"exists":  err == nil,
+		"entries": entries,
+	})
+}
+
+func (h *handlers) updateStackEnv(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+
+	r.Body = http.MaxBytesReader(w, r.Body, 1<<20) // 1 MB limit
+
+	var body struct {
+		Entries []envEntry `json:"entries"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		respond.Error(w, http.StatusBadRequest, "invalid JSON body", "BAD_REQUEST")
+		return
+	}
+	if err := validateEnv(body.Entries); err != nil {
+		respond.Error(w, http.StatusBadRequest, err.Error(), "INVALID_ENV")
+		return
+	}
+
+	path, ok := h.stackEnvPath(w, r, name)
+	if !ok {
+		return
+	}
+
+	original, err := os.ReadFile(path)
+	exists := err == nil
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		slog.ErrorContext(r.Context(), "failed to read env file", "path", path, "error", err)
+		respond.Error(w, http.StatusInternalServerError, "failed to read env file", "IO_ERROR")
+		return
+	}
+
+	// Masked entries were never shown to the client, so take their value
+	// from the file as it is now.
+	originalEntries := parseEnv(string(original))
+	current := make(map[string]string)
+	for _, e := range originalEntries {
+		if e.Key != "" {
+			current[e.Key] = e.Value
+		}
+	}
+	for i, e := range body.Entries {
+		if !e.Masked {
+			continue
+		}
+		value, ok := current[e.Key]
+		if !ok {
+			respond.Error(w, http.StatusBadRequest, fmt.Sprintf("masked variable %q is not in the current file", e.Key), "INVALID_ENV")
+			return
+		}
+		body.Entries[i].Value = value
+	}
+	content, err := renderEnv(body.Entries, originalEntries)
+	if err != nil {
+		respond.Error(w, http.StatusBadRequest, err.Error(), "INVALID_ENV")
+		return
+	}
+
+	perm := os.FileMode(0o600)
+	if exists {
+		info, err := os.Stat(path)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to stat env file", "path", path, "error", err)
+			respond.Error(w, http.StatusInternalServerError, "failed to read env file info", "IO_ERROR")
+			return
+		}
+		perm = info.Mode().Perm()
+		if err := rotateBackups(path, original, perm, h.opts.ComposeBackups); err != nil {
+			slog.ErrorContext(r.Context(), "failed to create backup", "path", path, "error", err)
+			respond.Error(w, http.StatusInternalServerError, "failed to create backup", "IO_ERROR")
+			return
+		}
+	}
+
+	if err := os.WriteFile(path, []byte(content), perm); err != nil {
+		slog.ErrorContext(r.Context(), "failed to write env file", "path", path, "error", err)
+		respond.Error(w, http.StatusInternalServerError, "failed to write env file", "IO_ERROR")
+		return
+	}
+
+	slog.InfoContext(r.Context(), "env file updated", "stack", name, "path", path)
+	respond.JSON(w, http.StatusOK, map[string]any{
+		"success": true,
+		"message": fmt.Sprintf("Env file for stack '%s' updated successfully", name),
+	})
+}
@@ -0,0 +1,117 @@
+package api
+
+import (
+	"reflect"
+	"testing"
+)
+
+// exported drops the fields parseEnv keeps for write-back.
+func exported(entries []envEntry) []envEntry {
+	out := make([]envEntry, len(entries))
+	for i, e := range entries {
+		out[i] = envEntry{Key: e.Key, Value: e.Value, Comment: e.Comment, Masked: e.Masked}
+	}
+	return out
+}
+
+func TestParseEnv(t *testing.T) {
+	content := "# database\nDB_HOST=db\nexport DB_PASSWORD=\"p@ss word\"\n\nGREETING='hi there'\nnot an assignment\n" +
+		"PORT=8080 # http\nMOTD=\"say \\\"hi\\\"\" # quoted\nHASH=a#b\n"
+	want := []envEntry{
+		{Comment: "database"},
+		{Key: "DB_HOST", Value: "db"},
+		{Key: "DB_PASSWORD", Value: "p@ss word"},
+		{},
+		{Key: "GREETING", Value: "hi there"},
+		{Comment: "not an assignment"},
+		{Key: "PORT", Value: "8080"},
+		{Key: "MOTD", Value: `say "hi"`},
+		{Key: "HASH", Value: "a#b"},
+	}
+	got := parseEnv(content)
+	if !reflect.DeepEqual(exported(got), want) {
+		t.Fatalf("parseEnv:\ngot  %+v\nwant %+v", exported(got), want)
+	}
+
+	// Written back unchanged, the file is byte for byte the same.
+	rendered, err := renderEnv(exported(got), got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rendered != content {
+		t.Errorf("unchanged round trip:\ngot  %q\nwant %q", rendered, content)
+	}
+}
+
+func TestRenderEnvChangedValues(t *testing.T) {
+	original := parseEnv("A='old'\nB=\"old\"\nC=old # note\n# keep\n")
+	tests := []struct {
+		name    string
+		entries []envEntry
+		want    string
+	}{
+		{"single quotes kept", []envEntry{{Key: "A", Value: "new"}}, "A='new'\n"},
+		{"double quotes kept", []envEntry{{Key: "B", Value: "new"}}, "B=\"new\"\n"},
+		{"unquoted kept", []envEntry{{Key: "C", Value: "new"}}, "C=new\n"},
+		{"dollar single-quoted", []envEntry{{Key: "B", Value: "pa$word"}}, "B='pa$word'\n"},
+		{"new value with spaces", []envEntry{{Key: "D", Value: "a b"}}, "D=\"a b\"\n"},
+		{"comment kept verbatim", []envEntry{{Comment: "keep"}, {Comment: "new"}}, "# keep\n# new\n"},
+	}
+	for _, tt := range tests {
+		got, err := renderEnv(tt.entries, original)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+		if back := exported(parseEnv(got)); back[0].Value != tt.entries[0].Value && tt.entries[0].Key != "" {
+			t.Errorf("%s: reads back as %q", tt.name, back[0].Value)
+		}
+	}
+
+	if _, err := renderEnv([]envEntry{{Key: "A", Value: "it's $5"}}, nil); err == nil {
+		t.Error("value with both $ and ' rendered, want error")
+	}
+}
+
+func TestValidateEnv(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []envEntry
+		wantErr bool
+	}{
+		{"valid", []envEntry{{Key: "A", Value: "1"}, {Comment: "note"}, {}}, false},
+		{"bad key", []envEntry{{Key: "1A"}}, true},
+		{"duplicate", []envEntry{{Key: "A"}, {Key: "A"}}, true},
+		{"multiline comment", []envEntry{{Comment: "a\nB=2"}}, true},
+	}
+	for _, tt := range tests {
+		if err := validateEnv(tt.entries); (err != nil) != tt.wantErr {
+			t.Errorf("%s: validateEnv error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestIsSecretKey(t *testing.T) {
+	for key, want := range map[string]bool{
+		"POSTGRES_PASSWORD": true,
+		"api_key":           true,
+		"GITHUB_TOKEN":      true,
+		"TZ":                false,
+		"DB_HOST":           false,
+	} {
+		if got := isSecretKey(key); got != want {
+			t.Errorf("isSecretKey(%q) = %v, want %v", key, got, want)
+		}
+	}
+}
+
+func TestMaskEnvList(t *testing.T) {
+	got := maskEnvList([]string{"DB_PASSWORD=hunter2", "TZ=UTC", "API_KEY=", "NOVALUE"})
+	want := []string{"DB_PASSWORD=" + maskedValue, "TZ=UTC", "API_KEY=", "NOVALUE"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("maskEnvList = %v, want %v", got, want)
+	}
+}
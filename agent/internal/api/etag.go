@@ -0,0 +1,33 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+// contentETag returns a strong ETag for file content.
+func contentETag(content []byte) string {
+	sum := sha256.Sum256(content)
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+// ifMatch reports whether the request's If-Match header, if any, matches
+// current. A missing header matches anything, so clients that don't send
+// one keep last-write-wins behaviour. If-Match uses strong comparison, so
+// weak (W/) tags never match.
+func ifMatch(r *http.Request, current []byte) bool {
+	header := r.Header.Get("If-Match")
+	if header == "" {
+		return true
+	}
+	etag := contentETag(current)
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,29 @@
+package api
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestIfMatch(t *testing.T) {
+	content := []byte("services: {}\n")
+	etag := contentETag(content)
+
+	tests := map[string]bool{
+		"":                    true,
+		"*":                   true,
+		etag:                  true,
+		`"stale", ` + etag:    true,
+		"W/" + etag:           false,
+		contentETag([]byte{}): false,
+	}
+	for header, want := range tests {
+		r := httptest.NewRequest("PUT", "/", nil)
+		if header != "" {
+			r.Header.Set("If-Match", header)
+		}
+		if got := ifMatch(r, content); got != want {
+			t.Errorf("ifMatch(%q) = %v, want %v", header, got, want)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/driversti/hola/internal/api/respond"
+	"github.com/driversti/hola/internal/docker"
+)
+
+// execRequest is the body of POST /api/v1/containers/{id}/exec.
+type execRequest struct {
+	Cmd []string `json:"cmd"`
+	TTY bool     `json:"tty"`
+}
+
+// containerExec runs a one-off command in a container and returns its exit
+// code and combined output. The command is bounded by the configured exec
+// timeout and its output by the configured size limit.
+func (h *handlers) containerExec(w http.ResponseWriter, r *http.Request) {
+	containerID := r.PathValue("id")
+
+	r.Body = http.MaxBytesReader(w, r.Body, 64<<10)
+	var req execRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respond.Error(w, http.StatusBadRequest, "invalid JSON body", "BAD_REQUEST")
+		return
+	}
+	if len(req.Cmd) == 0 {
+		respond.Error(w, http.StatusBadRequest, "cmd is required", "BAD_REQUEST")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), h.opts.ExecTimeout)
+	defer cancel()
+
+	start := time.Now()
+	result, err := h.docker.Exec(ctx, containerID, req.Cmd, req.TTY, h.opts.ExecMaxOutput)
+	if err != nil {
+		switch {
+		case errors.Is(err, docker.ErrContainerNotFound):
+			respond.Error(w, http.StatusNotFound, err.Error(), "CONTAINER_NOT_FOUND")
+		case errors.Is(err, docker.ErrContainerNotRunning):
+			respond.Error(w, http.StatusConflict, err.Error(), "CONTAINER_NOT_RUNNING")
+		case errors.Is(err, context.DeadlineExceeded):
+			slog.WarnContext(r.Context(), "container exec timed out", "container", containerID, "cmd", req.Cmd, "timeout", h.opts.ExecTimeout)
+			respond.Error(w, http.StatusGatewayTimeout,
+				"command did not finish within "+h.opts.ExecTimeout.String(), "EXEC_TIMEOUT")
+		default:
+			slog.ErrorContext(r.Context(), "container exec failed", "container", containerID, "cmd", req.Cmd, "error", err)
+			respond.Error(w, http.StatusInternalServerError, "failed to exec in container: "+err.Error(), "EXEC_ERROR")
+		}
+		return
+	}
+
+	slog.InfoContext(r.Context(), "container exec finished", "container", containerID, "cmd", req.Cmd,
+		"exit_code", result.ExitCode, "duration", time.Since(start))
+	respond.JSON(w, http.StatusOK, result)
+}
@@ -0,0 +1,142 @@
+package api
+
+import (
+	"compress/gzip"
+	"net/http"
+	"strings"
+)
+
+// gzipMinSize is the response size below which compression isn't worth the
+// CPU: a small JSON error or health response would barely shrink.
+const gzipMinSize = 1024
+
+// gzipMiddleware compresses responses for clients that accept gzip.
+// WebSocket upgrades and Server-Sent Events streams are passed through
+// untouched, since both need their bytes delivered as they are written.
+func gzipMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.EqualFold(r.Header.Get("Upgrade"), "websocket") ||
+			strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Add("Vary", "Accept-Encoding")
+		if !acceptsGzip(r.Header.Get("Accept-Encoding")) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		gw := &gzipResponseWriter{ResponseWriter: w}
+		defer gw.close()
+		next.ServeHTTP(gw, r)
+	})
+}
+
+// acceptsGzip reports whether an Accept-Encoding header allows gzip.
+func acceptsGzip(header string) bool {
+	for _, part := range strings.Split(header, ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if strings.TrimSpace(coding) != "gzip" {
+			continue
+		}
+		return strings.ReplaceAll(strings.TrimSpace(params), " ", "") != "q=0"
+	}
+	return false
+}
+
+// gzipResponseWriter holds back the first gzipMinSize bytes of a response
+// to decide whether to compress it: a response that ends before reaching
+// the threshold is sent as is.
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	status      int
+	buf         []byte
+	gz          *gzip.Writer
+	passthrough bool
+}
+
+func (w *gzipResponseWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+}
+
+func (w *gzipResponseWriter) Write(p []byte) (int, error) {
+	switch {
+	case w.gz != nil:
+		return w.gz.Write(p)
+	case w.passthrough:
+		return w.ResponseWriter.Write(p)
+	}
+
+	w.buf = append(w.buf, p...)
+	if len(w.buf) < gzipMinSize {
+		return len(p), nil
+	}
+	if err := w.start(); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// start sends the headers and the held-back bytes, compressed unless the
+// handler already encoded the body itself.
+func (w *gzipResponseWriter) start() error {
+	h := w.Header()
+	if h.Get("Content-Encoding") != "" {
+		return w.plain()
+	}
+	h.Del("Content-Length")
+	h.Set("Content-Encoding", "gzip")
+	w.sendHeader()
+	w.gz = gzip.NewWriter(w.ResponseWriter)
+	buf := w.buf
+	w.buf = nil
+	_, err := w.gz.Write(buf)
+	return err
+}
+
+// plain sends the headers and held-back bytes uncompressed and passes
+// everything after them straight through.
+func (w *gzipResponseWriter) plain() error {
+	w.passthrough = true
+	w.sendHeader()
+	buf := w.buf
+	w.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	_, err := w.ResponseWriter.Write(buf)
+	return err
+}
+
+func (w *gzipResponseWriter) sendHeader() {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+}
+
+// Flush implements http.Flusher. Flushing before the threshold commits the
+// response to being uncompressed.
+func (w *gzipResponseWriter) Flush() {
+	switch {
+	case w.gz != nil:
+		w.gz.Flush()
+	case !w.passthrough:
+		w.plain()
+	}
+	// Through a ResponseController so writers that wrap the connection and
+	// expose it only through Unwrap are flushed too.
+	http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+func (w *gzipResponseWriter) close() {
+	switch {
+	case w.gz != nil:
+		w.gz.Close()
+	case !w.passthrough:
+		w.plain()
+	}
+}
@@ -0,0 +1,102 @@
+package api
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/driversti/hola/internal/api/respond"
+)
+
+func TestGzipMiddleware(t *testing.T) {
+	images := make([]map[string]string, 500)
+	for i := range images {
+		images[i] = map[string]string{"id": "sha256:0123456789abcdef", "repository": "ghcr.io/example/app"}
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /images", func(w http.ResponseWriter, r *http.Request) {
+		respond.JSON(w, http.StatusOK, images)
+	})
+	mux.HandleFunc("GET /health", func(w http.ResponseWriter, r *http.Request) {
+		respond.JSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	})
+	mux.HandleFunc("GET /ws", func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := w.(*gzipResponseWriter); ok {
+			t.Error("WebSocket upgrade must not be wrapped")
+		}
+		w.WriteHeader(http.StatusSwitchingProtocols)
+	})
+	srv := httptest.NewServer(gzipMiddleware(mux))
+	defer srv.Close()
+
+	get := func(path, encoding string, header map[string]string) *http.Response {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodGet, srv.URL+path, nil)
+		// Setting Accept-Encoding ourselves stops the transport from
+		// transparently decompressing the body.
+		req.Header.Set("Accept-Encoding", encoding)
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	resp := get("/images", "gzip, deflate", nil)
+	defer resp.Body.Close()
+	if resp.Header.Get("Content-Encoding") != "gzip" {
+		t.Fatalf("large response Content-Encoding = %q, want gzip", resp.Header.Get("Content-Encoding"))
+	}
+	if resp.Header.Get("Vary") != "Accept-Encoding" {
+		t.Errorf("Vary = %q, want Accept-Encoding", resp.Header.Get("Vary"))
+	}
+	zr, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []map[string]string
+	if err := json.NewDecoder(zr).Decode(&got); err != nil || len(got) != len(images) {
+		t.Fatalf("decoded %d images (err %v), want %d", len(got), err, len(images))
+	}
+
+	resp = get("/images", "identity", nil)
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.Header.Get("Content-Encoding") != "" || !strings.HasPrefix(string(body), "[") {
+		t.Errorf("response without gzip accepted: Content-Encoding %q, body %.20q", resp.Header.Get("Content-Encoding"), body)
+	}
+
+	resp = get("/health", "gzip", nil)
+	body, _ = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.Header.Get("Content-Encoding") != "" || !strings.Contains(string(body), `"ok"`) {
+		t.Errorf("small response should not be compressed: Content-Encoding %q, body %q", resp.Header.Get("Content-Encoding"), body)
+	}
+
+	resp = get("/ws", "gzip", map[string]string{"Connection": "Upgrade", "Upgrade": "websocket"})
+	resp.Body.Close()
+	if resp.Header.Get("Content-Encoding") != "" {
+		t.Errorf("WebSocket upgrade got Content-Encoding %q", resp.Header.Get("Content-Encoding"))
+	}
+}
+
+func TestAcceptsGzip(t *testing.T) {
+	for header, want := range map[string]bool{
+		"gzip":                true,
+		"deflate, gzip;q=1.0": true,
+		"br":                  false,
+		"gzip;q=0":            false,
+		"":                    false,
+	} {
+		if got := acceptsGzip(header); got != want {
+			t.Errorf("acceptsGzip(%q) = %v, want %v", header, got, want)
+		}
+	}
+}
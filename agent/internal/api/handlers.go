@@ -4,12 +4,14 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"log/slog"
 	"net/http"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"regexp"
 	"runtime"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
@@ -21,6 +23,7 @@ import (
 	"github.com/driversti/hola/internal/docker"
 	"github.com/driversti/hola/internal/metrics"
 	"github.com/driversti/hola/internal/registry"
+	"github.com/driversti/hola/internal/requestid"
 	"github.com/driversti/hola/internal/update"
 	"gopkg.in/yaml.v3"
 )
@@ -30,38 +33,149 @@ type handlers struct {
 	docker   *docker.Client
 	registry *registry.Store
 	updater  *update.Updater
+	host     *hostInfo
+	opts     Options
+
+	// One collector per endpoint, so the network rates of each cover the
+	// time since that endpoint was last called.
+	sysMetrics  metrics.Collector
+	promMetrics metrics.Collector
 }
 
 // --- System endpoints ---
 
-func (h *handlers) health(w http.ResponseWriter, _ *http.Request) {
+// health is the liveness check: it answers without touching any
+// dependency. ?deep=true runs the readiness check instead.
+func (h *handlers) health(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("deep") == "true" {
+		h.ready(w, r)
+		return
+	}
 	respond.JSON(w, http.StatusOK, map[string]string{"status": "ok"})
 }
 
-func (h *handlers) agentInfo(w http.ResponseWriter, _ *http.Request) {
-	hostname, _ := os.Hostname()
+// readyTimeout bounds the Docker round trip of the readiness check, so a
+// hung daemon fails the check instead of stalling the load balancer.
+const readyTimeout = 3 * time.Second
+
+// ready is the readiness check: 200 while the Docker daemon answers,
+// 503 with status "degraded" when it doesn't.
+func (h *handlers) ready(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), readyTimeout)
+	defer cancel()
+
+	err := errors.New("docker client not configured")
+	if h.docker != nil {
+		err = h.docker.Ping(ctx)
+	}
+	if err != nil {
+		slog.WarnContext(r.Context(), "readiness check failed: docker unreachable", "error", err)
+		respond.JSON(w, http.StatusServiceUnavailable, map[string]string{
+			"status": "degraded",
+			"docker": "unreachable",
+		})
+		return
+	}
+
+	resp := map[string]string{"status": "ok", "docker": "ok"}
+	if version, _, err := h.docker.ServerVersion(ctx); err == nil {
+		resp["docker_version"] = version
+	}
+	respond.JSON(w, http.StatusOK, resp)
+}
+
+// agentInfo describes the agent and its host. The Docker details are
+// cached; ?refresh=true asks the daemon again.
+func (h *handlers) agentInfo(w http.ResponseWriter, r *http.Request) {
+	euid := os.Geteuid() // -1 on Windows
+	dockerVersion, rootless := h.host.docker(r.Context(), r.URL.Query().Get("refresh") == "true")
 
 	info := struct {
-		Version       string `json:"version"`
-		Hostname      string `json:"hostname"`
-		OS            string `json:"os"`
-		Arch          string `json:"arch"`
-		DockerVersion string `json:"docker_version"`
+		Version        string `json:"version"`
+		Hostname       string `json:"hostname"`
+		OS             string `json:"os"`
+		Arch           string `json:"arch"`
+		DockerVersion  string `json:"docker_version"`
+		EUID           int    `json:"euid"`
+		IsRoot         bool   `json:"is_root"`
+		RootlessDocker bool   `json:"rootless_docker"`
+		Maintenance    bool   `json:"maintenance"`
 	}{
-		Version:       h.version,
-		Hostname:      hostname,
-		OS:            runtime.GOOS,
-		Arch:          runtime.GOARCH,
-		DockerVersion: dockerVersion(),
+		Version:        h.version,
+		Hostname:       h.host.hostname,
+		OS:             h.host.os,
+		Arch:           h.host.arch,
+		DockerVersion:  dockerVersion,
+		EUID:           euid,
+		IsRoot:         euid == 0,
+		RootlessDocker: rootless,
+		Maintenance:    h.opts.Maintenance.Active(),
 	}
 
 	respond.JSON(w, http.StatusOK, info)
 }
 
+func (h *handlers) agentVersion(w http.ResponseWriter, r *http.Request) {
+	compare := r.URL.Query().Get("compare")
+	if compare == "" {
+		respond.JSON(w, http.StatusOK, map[string]any{"version": h.version})
+		return
+	}
+
+	result, err := update.CompareVersions(h.version, compare)
+	if err != nil {
+		respond.Error(w, http.StatusBadRequest, err.Error(), "BAD_REQUEST")
+		return
+	}
+	respond.JSON(w, http.StatusOK, map[string]any{
+		"version": h.version,
+		"compare": compare,
+		"result":  result,
+	})
+}
+
+func (h *handlers) maintenanceStatus(w http.ResponseWriter, _ *http.Request) {
+	respond.JSON(w, http.StatusOK, h.opts.Maintenance.Status())
+}
+
+func (h *handlers) setMaintenance(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, 1<<10)
+	var body struct {
+		Enabled  bool   `json:"enabled"`
+		Duration string `json:"duration"` // Go duration, e.g. "30m"
+		Reason   string `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		respond.Error(w, http.StatusBadRequest, "invalid JSON body", "BAD_REQUEST")
+		return
+	}
+
+	if !body.Enabled {
+		slog.InfoContext(r.Context(), "maintenance mode disabled")
+		respond.JSON(w, http.StatusOK, h.opts.Maintenance.Disable())
+		return
+	}
+
+	d := h.opts.MaintenanceDuration
+	if body.Duration != "" {
+		parsed, err := time.ParseDuration(body.Duration)
+		if err != nil || parsed <= 0 {
+			respond.Error(w, http.StatusBadRequest, "duration must be a positive Go duration such as 30m or 2h", "BAD_REQUEST")
+			return
+		}
+		d = parsed
+	}
+
+	st := h.opts.Maintenance.Enable(d, body.Reason)
+	slog.InfoContext(r.Context(), "maintenance mode enabled", "until", st.Until, "reason", body.Reason)
+	respond.JSON(w, http.StatusOK, st)
+}
+
 func (h *handlers) systemMetrics(w http.ResponseWriter, r *http.Request) {
-	m, err := metrics.Collect(r.Context())
+	opts := metrics.Options{AllDisks: r.URL.Query().Get("all") == "true"}
+	m, err := h.sysMetrics.CollectWithOptions(r.Context(), opts)
 	if err != nil {
-		slog.Error("failed to collect metrics", "error", err)
+		slog.ErrorContext(r.Context(), "failed to collect metrics", "error", err)
 		respond.Error(w, http.StatusInternalServerError, "failed to collect system metrics", "METRICS_ERROR")
 		return
 	}
@@ -77,13 +191,13 @@ func (h *handlers) checkUpdate(w http.ResponseWriter, r *http.Request) {
 		case errors.Is(err, update.ErrNoReleases):
 			respond.Error(w, http.StatusNotFound, "no releases available", "NO_RELEASES")
 		case errors.Is(err, update.ErrRateLimited):
-			respond.Error(w, http.StatusTooManyRequests, "GitHub API rate limit exceeded, try again later", "RATE_LIMITED")
+			rateLimited(w, err)
 		case errors.Is(err, update.ErrAssetNotFound):
 			respond.Error(w, http.StatusNotFound,
 				fmt.Sprintf("no binary available for %s/%s", runtime.GOOS, runtime.GOARCH),
 				"PLATFORM_NOT_AVAILABLE")
 		default:
-			slog.Error("failed to check for updates", "error", err)
+			slog.ErrorContext(r.Context(), "failed to check for updates", "error", err)
 			respond.Error(w, http.StatusBadGateway, "failed to check for updates", "GITHUB_ERROR")
 		}
 		return
@@ -92,32 +206,51 @@ func (h *handlers) checkUpdate(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *handlers) applyUpdate(w http.ResponseWriter, r *http.Request) {
-	err := h.updater.Apply(r.Context())
-	if err != nil {
-		switch {
-		case errors.Is(err, update.ErrAlreadyLatest):
+	// The body is optional: without one the latest release is installed.
+	var body struct {
+		Version        string `json:"version"`
+		AllowDowngrade bool   `json:"allow_downgrade"`
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, 1<<10)
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil && !errors.Is(err, io.EOF) {
+		respond.Error(w, http.StatusBadRequest, "invalid JSON body", "BAD_REQUEST")
+		return
+	}
+
+	// Clients asking for an event stream get download progress as it
+	// happens instead of a single response at the end.
+	if strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+		send := respond.SSE(w)
+		ctx := update.WithProgress(r.Context(), func(p update.Progress) {
+			send("progress", p)
+		})
+		if err := h.runUpdate(ctx, body.Version, body.AllowDowngrade); err != nil {
+			status, msg, code := updateFailure(r.Context(), err, body.Version)
+			if status == http.StatusOK {
+				send("done", map[string]any{"success": false, "message": msg})
+			} else {
+				send("error", respond.NewError(msg, code, requestid.FromContext(r.Context())))
+			}
+			return
+		}
+		send("done", map[string]any{
+			"success": true,
+			"message": "update applied successfully, agent is restarting",
+		})
+		exitForRestart("agent updated, exiting for restart")
+		return
+	}
+
+	if err := h.runUpdate(r.Context(), body.Version, body.AllowDowngrade); err != nil {
+		status, msg, code := updateFailure(r.Context(), err, body.Version)
+		if status == http.StatusOK {
 			respond.JSON(w, http.StatusOK, map[string]any{
 				"success": false,
-				"message": "already running the latest version",
+				"message": msg,
 			})
-		case errors.Is(err, update.ErrNoReleases):
-			respond.Error(w, http.StatusNotFound, "no releases available", "NO_RELEASES")
-		case errors.Is(err, update.ErrRateLimited):
-			respond.Error(w, http.StatusTooManyRequests, "GitHub API rate limit exceeded", "RATE_LIMITED")
-		case errors.Is(err, update.ErrAssetNotFound):
-			respond.Error(w, http.StatusNotFound,
-				fmt.Sprintf("no binary available for %s/%s", runtime.GOOS, runtime.GOARCH),
-				"PLATFORM_NOT_AVAILABLE")
-		case errors.Is(err, update.ErrChecksumsNotFound):
-			respond.Error(w, http.StatusUnprocessableEntity,
-				"release is missing checksums.txt, refusing to update", "CHECKSUMS_MISSING")
-		case errors.Is(err, update.ErrChecksumMismatch):
-			respond.Error(w, http.StatusUnprocessableEntity,
-				"downloaded binary failed checksum verification", "CHECKSUM_MISMATCH")
-		default:
-			slog.Error("failed to apply update", "error", err)
-			respond.Error(w, http.StatusInternalServerError, "update failed: "+err.Error(), "UPDATE_FAILED")
+			return
 		}
+		respond.Error(w, status, msg, code)
 		return
 	}
 
@@ -129,20 +262,142 @@ func (h *handlers) applyUpdate(w http.ResponseWriter, r *http.Request) {
 	if f, ok := w.(http.Flusher); ok {
 		f.Flush()
 	}
+	exitForRestart("agent updated, exiting for restart")
+}
+
+// runUpdate installs version, or the latest release when version is empty.
+func (h *handlers) runUpdate(ctx context.Context, version string, allowDowngrade bool) error {
+	if version != "" {
+		return h.updater.ApplyVersion(ctx, version, allowDowngrade)
+	}
+	return h.updater.Apply(ctx)
+}
+
+// rateLimited writes the 429 for a GitHub rate limit. When GitHub said when
+// the limit lifts, that is passed on as Retry-After and reset_at.
+func rateLimited(w http.ResponseWriter, err error) {
+	body := struct {
+		respond.ErrorResponse
+		ResetAt string `json:"reset_at,omitempty"`
+	}{ErrorResponse: respond.NewError("GitHub API rate limit exceeded, try again later", "RATE_LIMITED", w.Header().Get(requestid.Header))}
+
+	var rle *update.RateLimitError
+	if errors.As(err, &rle) && !rle.ResetAt.IsZero() {
+		wait := max(time.Until(rle.ResetAt), 0)
+		w.Header().Set("Retry-After", strconv.Itoa(int(wait.Round(time.Second)/time.Second)))
+		body.ResetAt = rle.ResetAt.UTC().Format(time.RFC3339)
+	}
+	respond.JSON(w, http.StatusTooManyRequests, body)
+}
+
+// updateFailure maps an update error to a response status, message and
+// error code. A 200 status means nothing needed installing, which is
+// reported as success=false rather than as an error.
+func updateFailure(ctx context.Context, err error, version string) (status int, msg, code string) {
+	switch {
+	case errors.Is(err, update.ErrAlreadyLatest):
+		return http.StatusOK, "already running the latest version", ""
+	case errors.Is(err, update.ErrSameVersion):
+		return http.StatusOK, "already running version " + version, ""
+	case errors.Is(err, update.ErrDowngradeRefused):
+		return http.StatusUnprocessableEntity,
+			version + " is older than the running version, set allow_downgrade to install it",
+			"DOWNGRADE_REFUSED"
+	case errors.Is(err, update.ErrReleaseNotFound):
+		return http.StatusNotFound, "no release tagged " + version, "RELEASE_NOT_FOUND"
+	case errors.Is(err, update.ErrNoReleases):
+		return http.StatusNotFound, "no releases available", "NO_RELEASES"
+	case errors.Is(err, update.ErrRateLimited):
+		return http.StatusTooManyRequests, "GitHub API rate limit exceeded", "RATE_LIMITED"
+	case errors.Is(err, update.ErrAssetNotFound):
+		return http.StatusNotFound,
+			fmt.Sprintf("no binary available for %s/%s", runtime.GOOS, runtime.GOARCH),
+			"PLATFORM_NOT_AVAILABLE"
+	case errors.Is(err, update.ErrChecksumsNotFound):
+		return http.StatusUnprocessableEntity,
+			"release is missing checksums.txt, refusing to update", "CHECKSUMS_MISSING"
+	case errors.Is(err, update.ErrChecksumMismatch):
+		return http.StatusUnprocessableEntity,
+			"downloaded binary failed checksum verification", "CHECKSUM_MISMATCH"
+	case errors.Is(err, update.ErrSignatureInvalid):
+		return http.StatusUnprocessableEntity,
+			"release signature is missing or invalid, refusing to update: " + err.Error(), "SIGNATURE_INVALID"
+	default:
+		slog.ErrorContext(ctx, "failed to apply update", "error", err)
+		return http.StatusInternalServerError, "update failed: " + err.Error(), "UPDATE_FAILED"
+	}
+}
 
+// exitForRestart exits the process shortly after the current response has
+// been written, leaving the restart to the service manager.
+func exitForRestart(reason string) {
 	go func() {
 		time.Sleep(500 * time.Millisecond)
-		slog.Info("agent updated, exiting for restart")
+		slog.Info(reason)
 		os.Exit(0)
 	}()
 }
 
+func (h *handlers) rollbackStatus(w http.ResponseWriter, _ *http.Request) {
+	respond.JSON(w, http.StatusOK, map[string]any{
+		"available": h.updater.RollbackAvailable(),
+	})
+}
+
+func (h *handlers) rollback(w http.ResponseWriter, r *http.Request) {
+	if err := h.updater.Rollback(r.Context()); err != nil {
+		if errors.Is(err, update.ErrNoBackup) {
+			respond.Error(w, http.StatusUnprocessableEntity,
+				"no previous binary to roll back to", "NO_BACKUP")
+			return
+		}
+		slog.ErrorContext(r.Context(), "failed to roll back", "error", err)
+		respond.Error(w, http.StatusInternalServerError, "rollback failed: "+err.Error(), "ROLLBACK_FAILED")
+		return
+	}
+
+	respond.JSON(w, http.StatusOK, map[string]any{
+		"success": true,
+		"message": "rolled back to previous binary, agent is restarting",
+	})
+
+	if f, ok := w.(http.Flusher); ok {
+		f.Flush()
+	}
+
+	exitForRestart("agent rolled back, exiting for restart")
+}
+
 // --- Stack read endpoints ---
 
+// stackStatuses are the values of docker.Stack.Status listStacks can
+// filter on.
+var stackStatuses = []string{"running", "stopped", "partial", "down"}
+
 func (h *handlers) listStacks(w http.ResponseWriter, r *http.Request) {
+	// source selects which stacks are listed: "registry" (registered only),
+	// "running" (label-discovered only) or "all" (the merged view).
+	source := r.URL.Query().Get("source")
+	if source == "" {
+		source = "all"
+	}
+	if source != "all" && source != "registry" && source != "running" {
+		respond.Error(w, http.StatusBadRequest, "source must be one of registry, running, all", "BAD_REQUEST")
+		return
+	}
+
+	// status may be repeated; without it every status is listed.
+	statuses := r.URL.Query()["status"]
+	for _, st := range statuses {
+		if !slices.Contains(stackStatuses, st) {
+			respond.Error(w, http.StatusBadRequest, "status must be one of running, stopped, partial, down", "BAD_REQUEST")
+			return
+		}
+	}
+
 	stacks, err := h.docker.ListStacks(r.Context())
 	if err != nil {
-		slog.Error("failed to list stacks", "error", err)
+		slog.ErrorContext(r.Context(), "failed to list stacks", "error", err)
 		respond.Error(w, http.StatusInternalServerError, "failed to list stacks", "DOCKER_ERROR")
 		return
 	}
@@ -156,16 +411,42 @@ func (h *handlers) listStacks(w http.ResponseWriter, r *http.Request) {
 	for _, rs := range h.registry.All() {
 		if idx, ok := byName[rs.Name]; ok {
 			stacks[idx].Registered = true
-		} else {
+			stacks[idx].RegisteredAt = rs.RegisteredAt
+			stacks[idx].UpdatedAt = rs.UpdatedAt
+		} else if source != "running" {
 			stacks = append(stacks, docker.Stack{
-				Name:       rs.Name,
-				Status:     "down",
-				WorkingDir: rs.WorkingDir,
-				Registered: true,
+				Name:         rs.Name,
+				Status:       "down",
+				WorkingDir:   rs.WorkingDir,
+				Registered:   true,
+				Stale:        findComposeFile(rs.WorkingDir) == "",
+				RegisteredAt: rs.RegisteredAt,
+				UpdatedAt:    rs.UpdatedAt,
 			})
 		}
 	}
 
+	if source == "registry" {
+		registered := stacks[:0]
+		for _, st := range stacks {
+			if st.Registered {
+				registered = append(registered, st)
+			}
+		}
+		stacks = registered
+	}
+
+	// Filter last so registered stacks added as "down" are covered too.
+	if len(statuses) > 0 {
+		matching := stacks[:0]
+		for _, st := range stacks {
+			if slices.Contains(statuses, st.Status) {
+				matching = append(matching, st)
+			}
+		}
+		stacks = matching
+	}
+
 	sort.Slice(stacks, func(i, j int) bool {
 		return stacks[i].Name < stacks[j].Name
 	})
@@ -191,7 +472,7 @@ func (h *handlers) getStack(w http.ResponseWriter, r *http.Request) {
 			respond.Error(w, http.StatusNotFound, err.Error(), "STACK_NOT_FOUND")
 			return
 		}
-		slog.Error("failed to get stack", "name", name, "error", err)
+		slog.ErrorContext(r.Context(), "failed to get stack", "name", name, "error", err)
 		respond.Error(w, http.StatusInternalServerError, "failed to get stack", "DOCKER_ERROR")
 		return
 	}
@@ -207,6 +488,7 @@ func (h *handlers) getComposeFile(w http.ResponseWriter, r *http.Request) {
 			if rs := h.registry.Get(name); rs != nil {
 				cf2, err2 := h.docker.GetComposeFileFromDir(rs.WorkingDir)
 				if err2 == nil {
+					w.Header().Set("ETag", contentETag([]byte(cf2.Content)))
 					respond.JSON(w, http.StatusOK, cf2)
 					return
 				}
@@ -214,10 +496,11 @@ func (h *handlers) getComposeFile(w http.ResponseWriter, r *http.Request) {
 			respond.Error(w, http.StatusNotFound, err.Error(), "NOT_FOUND")
 			return
 		}
-		slog.Error("failed to get compose file", "name", name, "error", err)
+		slog.ErrorContext(r.Context(), "failed to get compose file", "name", name, "error", err)
 		respond.Error(w, http.StatusInternalServerError, "failed to read compose file", "DOCKER_ERROR")
 		return
 	}
+	w.Header().Set("ETag", contentETag([]byte(cf.Content)))
 	respond.JSON(w, http.StatusOK, cf)
 }
 
@@ -260,7 +543,7 @@ func (h *handlers) updateComposeFile(w http.ResponseWriter, r *http.Request) {
 	// Write content to a temp file in the same directory for docker compose validation.
 	tmpFile, err := os.CreateTemp(dir, ".compose-validate-*.yml")
 	if err != nil {
-		slog.Error("failed to create temp file", "error", err)
+		slog.ErrorContext(r.Context(), "failed to create temp file", "error", err)
 		respond.Error(w, http.StatusInternalServerError, "failed to create temp file", "IO_ERROR")
 		return
 	}
@@ -269,24 +552,19 @@ func (h *handlers) updateComposeFile(w http.ResponseWriter, r *http.Request) {
 
 	if _, err := tmpFile.WriteString(body.Content); err != nil {
 		tmpFile.Close()
-		slog.Error("failed to write temp file", "error", err)
+		slog.ErrorContext(r.Context(), "failed to write temp file", "error", err)
 		respond.Error(w, http.StatusInternalServerError, "failed to write temp file", "IO_ERROR")
 		return
 	}
 	tmpFile.Close()
 
 	// Validate with docker compose.
-	cmd := exec.CommandContext(r.Context(), "docker", "compose", "-f", tmpPath, "config", "-q")
-	cmd.Dir = dir
-	output, err := cmd.CombinedOutput()
+	warnings, err := validateCompose(r.Context(), h.opts.DockerArgs, dir, tmpPath)
 	if err != nil {
-		detail := strings.TrimSpace(string(output))
-		if detail == "" {
-			detail = err.Error()
-		}
 		respond.JSON(w, http.StatusOK, map[string]any{
-			"success": false,
-			"error":   fmt.Sprintf("docker compose validation failed: %s", detail),
+			"success":  false,
+			"error":    fmt.Sprintf("docker compose validation failed: %s", err),
+			"warnings": warnings,
 		})
 		return
 	}
@@ -294,36 +572,110 @@ func (h *handlers) updateComposeFile(w http.ResponseWriter, r *http.Request) {
 	// Preserve original file permissions.
 	fileInfo, err := os.Stat(composePath)
 	if err != nil {
-		slog.Error("failed to stat compose file", "path", composePath, "error", err)
+		slog.ErrorContext(r.Context(), "failed to stat compose file", "path", composePath, "error", err)
 		respond.Error(w, http.StatusInternalServerError, "failed to read compose file info", "IO_ERROR")
 		return
 	}
 	perm := fileInfo.Mode().Perm()
 
-	// Create .bak backup of original.
+	// Rotate the original into the .bak.N backup chain.
 	originalData, err := os.ReadFile(composePath)
 	if err != nil {
-		slog.Error("failed to read original compose file", "path", composePath, "error", err)
+		slog.ErrorContext(r.Context(), "failed to read original compose file", "path", composePath, "error", err)
 		respond.Error(w, http.StatusInternalServerError, "failed to read original compose file", "IO_ERROR")
 		return
 	}
-	if err := os.WriteFile(composePath+".bak", originalData, perm); err != nil {
-		slog.Error("failed to create backup", "path", composePath+".bak", "error", err)
+
+	// Refuse to overwrite changes made since the editor loaded the file.
+	if !ifMatch(r, originalData) {
+		w.Header().Set("ETag", contentETag(originalData))
+		respond.Error(w, http.StatusPreconditionFailed, "compose file changed since it was loaded", "PRECONDITION_FAILED")
+		return
+	}
+	if err := rotateBackups(composePath, originalData, perm, h.opts.ComposeBackups); err != nil {
+		slog.ErrorContext(r.Context(), "failed to create backup", "path", composePath, "error", err)
 		respond.Error(w, http.StatusInternalServerError, "failed to create backup", "IO_ERROR")
 		return
 	}
 
 	// Write new content to the compose file.
 	if err := os.WriteFile(composePath, []byte(body.Content), perm); err != nil {
-		slog.Error("failed to write compose file", "path", composePath, "error", err)
+		slog.ErrorContext(r.Context(), "failed to write compose file", "path", composePath, "error", err)
 		respond.Error(w, http.StatusInternalServerError, "failed to write compose file", "IO_ERROR")
 		return
 	}
 
-	slog.Info("compose file updated", "stack", name, "path", composePath)
+	slog.InfoContext(r.Context(), "compose file updated", "stack", name, "path", composePath)
+	w.Header().Set("ETag", contentETag([]byte(body.Content)))
 	respond.JSON(w, http.StatusOK, map[string]any{
-		"success": true,
-		"message": fmt.Sprintf("Compose file for stack '%s' updated successfully", name),
+		"success":  true,
+		"message":  fmt.Sprintf("Compose file for stack '%s' updated successfully", name),
+		"warnings": warnings,
+	})
+}
+
+// validateComposeContent checks arbitrary compose content without touching
+// any stack: the content is written to a scratch directory that is removed
+// afterwards, so no .env is available for interpolation.
+func (h *handlers) validateComposeContent(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, 1<<20) // 1 MB limit
+
+	var body struct {
+		Content string `json:"content"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		respond.Error(w, http.StatusBadRequest, "invalid JSON body", "BAD_REQUEST")
+		return
+	}
+	if strings.TrimSpace(body.Content) == "" {
+		respond.Error(w, http.StatusBadRequest, "content must not be empty", "BAD_REQUEST")
+		return
+	}
+
+	var parsed any
+	if err := yaml.Unmarshal([]byte(body.Content), &parsed); err != nil {
+		respond.JSON(w, http.StatusOK, map[string]any{
+			"valid": false,
+			"error": fmt.Sprintf("invalid YAML syntax: %s", err),
+		})
+		return
+	}
+
+	dir, err := os.MkdirTemp("", "hola-compose-validate-")
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to create temp dir", "error", err)
+		respond.Error(w, http.StatusInternalServerError, "failed to create temp dir", "IO_ERROR")
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "compose.yml")
+	if err := os.WriteFile(path, []byte(body.Content), 0o600); err != nil {
+		slog.ErrorContext(r.Context(), "failed to write temp file", "error", err)
+		respond.Error(w, http.StatusInternalServerError, "failed to write temp file", "IO_ERROR")
+		return
+	}
+
+	warnings, err := validateCompose(r.Context(), h.opts.DockerArgs, dir, path)
+	if err != nil {
+		respond.JSON(w, http.StatusOK, map[string]any{
+			"valid":    false,
+			"error":    fmt.Sprintf("docker compose validation failed: %s", err),
+			"warnings": warnings,
+		})
+		return
+	}
+
+	normalized, err := renderCompose(r.Context(), h.opts.DockerArgs, dir, path)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to render compose config", "error", err)
+		respond.Error(w, http.StatusInternalServerError, "failed to render compose config", "DOCKER_ERROR")
+		return
+	}
+	respond.JSON(w, http.StatusOK, map[string]any{
+		"valid":      true,
+		"normalized": normalized,
+		"warnings":   warnings,
 	})
 }
 
@@ -337,14 +689,96 @@ func (h *handlers) resolveComposeFilePath(ctx context.Context, stackName string)
 
 	// Fall back to registry for downed/registered stacks.
 	if rs := h.registry.Get(stackName); rs != nil {
-		if path := findComposeFile(rs.WorkingDir); path != "" {
-			return path
+		if files := registry.ComposeFiles(rs.WorkingDir); len(files) > 0 {
+			return files[0]
 		}
 	}
 
 	return ""
 }
 
+// --- Container inspect ---
+
+// containerInspect returns the raw Docker inspect result. Env values whose
+// names look like secrets are masked unless ?reveal=true.
+func (h *handlers) containerInspect(w http.ResponseWriter, r *http.Request) {
+	containerID := r.PathValue("id")
+	reveal := r.URL.Query().Get("reveal") == "true"
+
+	info, err := h.docker.InspectContainer(r.Context(), containerID)
+	if err != nil {
+		if errors.Is(err, docker.ErrContainerNotFound) {
+			respond.Error(w, http.StatusNotFound, err.Error(), "CONTAINER_NOT_FOUND")
+			return
+		}
+		slog.ErrorContext(r.Context(), "failed to inspect container", "container", containerID, "error", err)
+		respond.Error(w, http.StatusInternalServerError, "failed to inspect container", "DOCKER_ERROR")
+		return
+	}
+	if !reveal && info.Config != nil {
+		info.Config.Env = maskEnvList(info.Config.Env)
+	}
+	respond.JSON(w, http.StatusOK, info)
+}
+
+// psArgsRe limits ?ps_args= to ps option syntax, e.g. "aux" or
+// "-eo pid,user,%cpu,comm".
+var psArgsRe = regexp.MustCompile(`^[A-Za-z0-9 ,=%_-]{0,100}$`)
+
+// containerTop lists the processes running in a container without exec-ing
+// into it, so it works for images that ship no shell.
+func (h *handlers) containerTop(w http.ResponseWriter, r *http.Request) {
+	containerID := r.PathValue("id")
+	psArgs := r.URL.Query().Get("ps_args")
+	if !psArgsRe.MatchString(psArgs) {
+		respond.Error(w, http.StatusBadRequest, "ps_args may only contain letters, digits, spaces and ,=%_-", "BAD_REQUEST")
+		return
+	}
+
+	procs, err := h.docker.ContainerTop(r.Context(), containerID, psArgs)
+	if err != nil {
+		switch {
+		case errors.Is(err, docker.ErrContainerNotFound):
+			respond.Error(w, http.StatusNotFound, err.Error(), "CONTAINER_NOT_FOUND")
+		case errors.Is(err, docker.ErrContainerNotRunning):
+			respond.Error(w, http.StatusConflict, err.Error(), "NOT_RUNNING")
+		default:
+			slog.ErrorContext(r.Context(), "failed to list container processes", "container", containerID, "error", err)
+			respond.Error(w, http.StatusInternalServerError, "failed to list container processes", "DOCKER_ERROR")
+		}
+		return
+	}
+	respond.JSON(w, http.StatusOK, procs)
+}
+
+func (h *handlers) containerLimits(w http.ResponseWriter, r *http.Request) {
+	containerID := r.PathValue("id")
+	limits, err := h.docker.ContainerLimits(r.Context(), containerID)
+	if err != nil {
+		if errors.Is(err, docker.ErrContainerNotFound) {
+			respond.Error(w, http.StatusNotFound, err.Error(), "CONTAINER_NOT_FOUND")
+			return
+		}
+		slog.ErrorContext(r.Context(), "failed to read container limits", "container", containerID, "error", err)
+		respond.Error(w, http.StatusInternalServerError, "failed to read container limits", "DOCKER_ERROR")
+		return
+	}
+	respond.JSON(w, http.StatusOK, limits)
+}
+
+// maskEnvList replaces the values of secret-looking KEY=VALUE entries.
+func maskEnvList(env []string) []string {
+	out := make([]string, len(env))
+	for i, kv := range env {
+		key, value, ok := strings.Cut(kv, "=")
+		if ok && value != "" && isSecretKey(key) {
+			kv = key + "=" + maskedValue
+		}
+		out[i] = kv
+	}
+	return out
+}
+
 // --- Container logs ---
 
 func (h *handlers) containerLogs(w http.ResponseWriter, r *http.Request) {
@@ -359,22 +793,305 @@ func (h *handlers) containerLogs(w http.ResponseWriter, r *http.Request) {
 
 	since := r.URL.Query().Get("since")
 
-	entries, cID, cName, err := h.docker.GetContainerLogs(r.Context(), containerID, lines, since)
+	filter, err := logFilterFromQuery(r)
+	if err != nil {
+		respond.Error(w, http.StatusBadRequest, err.Error(), "BAD_FILTER")
+		return
+	}
+
+	maxBytes := docker.DefaultMaxLogBytes
+	if v := r.URL.Query().Get("max_bytes"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			respond.Error(w, http.StatusBadRequest, "max_bytes must be a positive integer", "BAD_REQUEST")
+			return
+		}
+		maxBytes = min(n, docker.DefaultMaxLogBytes)
+	}
+
+	contextBefore, contextAfter, err := logContextFromQuery(r)
 	if err != nil {
-		slog.Error("failed to get container logs", "container", containerID, "error", err)
+		respond.Error(w, http.StatusBadRequest, err.Error(), "BAD_REQUEST")
+		return
+	}
+
+	logs, err := h.docker.GetContainerLogs(r.Context(), containerID, lines, since, maxBytes)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to get container logs", "container", containerID, "error", err)
 		respond.Error(w, http.StatusInternalServerError, "failed to get container logs", "DOCKER_ERROR")
 		return
 	}
 
+	if logs.Truncated {
+		slog.WarnContext(r.Context(), "container logs truncated", "container", containerID, "max_bytes", maxBytes)
+	}
+
+	// Restrict to the stream first so context lines come from it too.
+	entries := filter.FilterStream(logs.Entries)
+	if filter.HasGrep() {
+		entries = docker.FilterLogs(entries, func(e docker.LogEntry) bool {
+			return filter.MatchMessage(e.Message)
+		}, contextBefore, contextAfter)
+	}
+
 	respond.JSON(w, http.StatusOK, map[string]any{
-		"container_id":   cID,
-		"container_name": cName,
+		"container_id":   logs.ID,
+		"container_name": logs.Name,
 		"lines":          entries,
+		"truncated":      logs.Truncated,
+	})
+}
+
+// serviceLogs returns the logs of every container of a stack service,
+// merged in timestamp order, so clients needn't map service to container.
+func (h *handlers) serviceLogs(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	service := r.PathValue("service")
+
+	lines := 100
+	if v := r.URL.Query().Get("lines"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			lines = n
+		}
+	}
+	since := r.URL.Query().Get("since")
+	filter, err := logFilterFromQuery(r)
+	if err != nil {
+		respond.Error(w, http.StatusBadRequest, err.Error(), "BAD_FILTER")
+		return
+	}
+
+	containers, err := h.docker.ServiceContainers(r.Context(), name, service)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to list service containers", "stack", name, "service", service, "error", err)
+		respond.Error(w, http.StatusInternalServerError, "failed to list service containers", "DOCKER_ERROR")
+		return
+	}
+	if len(containers) == 0 {
+		respond.Error(w, http.StatusNotFound,
+			fmt.Sprintf("no containers for service %q in stack %q", service, name), "SERVICE_NOT_FOUND")
+		return
+	}
+
+	names := make([]string, 0, len(containers))
+	sets := make([][]docker.LogEntry, 0, len(containers))
+	truncated := false
+	for _, ctr := range containers {
+		logs, err := h.docker.GetContainerLogs(r.Context(), ctr.ID, lines, since, 0)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to get container logs", "container", ctr.ID, "error", err)
+			respond.Error(w, http.StatusInternalServerError, "failed to get container logs", "DOCKER_ERROR")
+			return
+		}
+		entries := logs.Entries
+		truncated = truncated || logs.Truncated
+		if len(containers) > 1 {
+			for i := range entries {
+				entries[i].Container = ctr.Name
+			}
+		}
+		names = append(names, ctr.Name)
+		kept := entries[:0]
+		for _, e := range entries {
+			if filter.Match(e.Stream, e.Message) {
+				kept = append(kept, e)
+			}
+		}
+		sets = append(sets, kept)
+	}
+
+	merged := docker.MergeLogs(sets...)
+	if len(merged) > lines {
+		merged = merged[len(merged)-lines:]
+	}
+
+	respond.JSON(w, http.StatusOK, map[string]any{
+		"stack":      name,
+		"service":    service,
+		"containers": names,
+		"lines":      merged,
+		"truncated":  truncated,
 	})
 }
 
+// maxStackLogBytes caps the log text returned by stackLogs; the oldest
+// lines are dropped beyond it.
+const maxStackLogBytes = 8 << 20
+
+// stackLogs returns the logs of every container of a stack merged in
+// timestamp order, each line tagged with its service and container. lines
+// and since apply to each container.
+func (h *handlers) stackLogs(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+
+	lines := 100
+	if v := r.URL.Query().Get("lines"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			lines = n
+		}
+	}
+	since := r.URL.Query().Get("since")
+	filter, err := logFilterFromQuery(r)
+	if err != nil {
+		respond.Error(w, http.StatusBadRequest, err.Error(), "BAD_FILTER")
+		return
+	}
+
+	detail, err := h.docker.GetStack(r.Context(), name)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			respond.Error(w, http.StatusNotFound, err.Error(), "STACK_NOT_FOUND")
+			return
+		}
+		slog.ErrorContext(r.Context(), "failed to get stack", "name", name, "error", err)
+		respond.Error(w, http.StatusInternalServerError, "failed to get stack", "DOCKER_ERROR")
+		return
+	}
+
+	names := make([]string, 0, len(detail.Containers))
+	sets := make([][]docker.LogEntry, 0, len(detail.Containers))
+	readTruncated := false
+	for _, ctr := range detail.Containers {
+		logs, err := h.docker.GetContainerLogs(r.Context(), ctr.ID, lines, since, 0)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to get container logs", "container", ctr.ID, "error", err)
+			respond.Error(w, http.StatusInternalServerError, "failed to get container logs", "DOCKER_ERROR")
+			return
+		}
+		names = append(names, ctr.Name)
+		readTruncated = readTruncated || logs.Truncated
+		kept := logs.Entries[:0]
+		for _, e := range logs.Entries {
+			if filter.Match(e.Stream, e.Message) {
+				e.Container, e.Service = ctr.Name, ctr.Service
+				kept = append(kept, e)
+			}
+		}
+		sets = append(sets, kept)
+	}
+
+	merged, truncated := docker.TailLogsBySize(docker.MergeLogs(sets...), maxStackLogBytes)
+	truncated = truncated || readTruncated
+	if truncated {
+		slog.WarnContext(r.Context(), "stack logs truncated", "stack", name, "max_bytes", maxStackLogBytes)
+	}
+
+	respond.JSON(w, http.StatusOK, map[string]any{
+		"stack":      name,
+		"containers": names,
+		"lines":      merged,
+		"truncated":  truncated,
+	})
+}
+
+// logFilterFromQuery builds a log filter from ?grep=, ?regex=true and
+// ?stream=stdout|stderr|both.
+func logFilterFromQuery(r *http.Request) (*docker.LogFilter, error) {
+	q := r.URL.Query()
+	return docker.NewLogFilter(q.Get("grep"), q.Get("regex") == "true", q.Get("stream"))
+}
+
+// logContextFromQuery reads context_before and context_after, the lines
+// kept around each grep match. Both default to 0.
+func logContextFromQuery(r *http.Request) (before, after int, err error) {
+	q := r.URL.Query()
+	for _, p := range []struct {
+		name string
+		n    *int
+	}{{"context_before", &before}, {"context_after", &after}} {
+		v := q.Get(p.name)
+		if v == "" {
+			continue
+		}
+		if *p.n, err = strconv.Atoi(v); err != nil || *p.n < 0 {
+			return 0, 0, fmt.Errorf("%s must be a non-negative integer", p.name)
+		}
+	}
+	return before, after, nil
+}
+
 // --- Stack write endpoints ---
 
+// errStackNotFound and errComposeMissing are returned by stackDir.
+var (
+	errStackNotFound  = errors.New("stack not found")
+	errComposeMissing = errors.New("compose file missing")
+)
+
+// stackDir finds the stack's working directory, falling back to the
+// registry for stacks that are down. A registered stack whose compose file
+// is gone yields errComposeMissing, so compose is never run in a missing
+// directory.
+func (h *handlers) stackDir(ctx context.Context, name string) (*docker.StackDetail, error) {
+	detail, err := h.docker.GetStack(ctx, name)
+	if err == nil {
+		return detail, nil
+	}
+	if !strings.Contains(err.Error(), "not found") {
+		return nil, err
+	}
+	rs := h.registry.Get(name)
+	if rs == nil {
+		return nil, fmt.Errorf("%w: %s", errStackNotFound, err)
+	}
+	if findComposeFile(rs.WorkingDir) == "" {
+		return nil, fmt.Errorf("%w: no compose file in %s; the stack's directory was moved or deleted", errComposeMissing, rs.WorkingDir)
+	}
+	return &docker.StackDetail{
+		Name:       rs.Name,
+		Status:     "down",
+		WorkingDir: rs.WorkingDir,
+	}, nil
+}
+
+// resolveStackDir is stackDir for handlers: on failure it writes the error
+// response (404 STACK_NOT_FOUND, 422 COMPOSE_MISSING or 500) and returns
+// ok=false.
+func (h *handlers) resolveStackDir(w http.ResponseWriter, r *http.Request, name string) (*docker.StackDetail, bool) {
+	detail, err := h.stackDir(r.Context(), name)
+	switch {
+	case err == nil:
+		return detail, true
+	case errors.Is(err, errStackNotFound):
+		respond.Error(w, http.StatusNotFound, err.Error(), "STACK_NOT_FOUND")
+	case errors.Is(err, errComposeMissing):
+		respond.Error(w, http.StatusUnprocessableEntity, err.Error(), "COMPOSE_MISSING")
+	default:
+		slog.ErrorContext(r.Context(), "failed to get stack for action", "name", name, "error", err)
+		respond.Error(w, http.StatusInternalServerError, "failed to get stack", "DOCKER_ERROR")
+	}
+	return nil, false
+}
+
+// stackActionArgs returns the docker arguments for a stack action, or nil
+// if the action is unknown.
+func stackActionArgs(action string) []string {
+	switch action {
+	case "start":
+		return []string{"compose", "up", "-d"}
+	case "stop":
+		return []string{"compose", "stop"}
+	case "restart":
+		return []string{"compose", "restart"}
+	case "down":
+		return []string{"compose", "down"}
+	case "pull":
+		return []string{"compose", "pull"}
+	}
+	return nil
+}
+
+// composeArgs inserts the project flag and a -f flag per compose file
+// after "compose".
+func (h *handlers) composeArgs(name, dir string, composeFiles []string, args []string) []string {
+	var flags []string
+	flags = append(flags, h.projectArgs(name, dir)...)
+	for _, f := range composeFiles {
+		flags = append(flags, "-f", f)
+	}
+	return append(slices.Clone(h.opts.DockerArgs), insertFlags(args, flags)...)
+}
+
 func (h *handlers) stackAction(w http.ResponseWriter, r *http.Request) {
 	name := r.PathValue("name")
 
@@ -382,72 +1099,223 @@ func (h *handlers) stackAction(w http.ResponseWriter, r *http.Request) {
 	parts := strings.Split(r.URL.Path, "/")
 	action := parts[len(parts)-1]
 
-	// Resolve working directory from the stack (or registry for downed stacks).
-	detail, err := h.docker.GetStack(r.Context(), name)
-	if err != nil {
-		if strings.Contains(err.Error(), "not found") {
-			if rs := h.registry.Get(name); rs != nil {
-				detail = &docker.StackDetail{
-					Name:       rs.Name,
-					Status:     "down",
-					WorkingDir: rs.WorkingDir,
-				}
-			} else {
-				respond.Error(w, http.StatusNotFound, err.Error(), "STACK_NOT_FOUND")
-				return
-			}
-		} else {
-			slog.Error("failed to get stack for action", "name", name, "error", err)
-			respond.Error(w, http.StatusInternalServerError, "failed to get stack", "DOCKER_ERROR")
+	detail, ok := h.resolveStackDir(w, r, name)
+	if !ok {
+		return
+	}
+
+	ordered := action == "restart" && r.URL.Query().Get("ordered") == "true"
+	var delay time.Duration
+	if v := r.URL.Query().Get("delay_seconds"); ordered && v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			respond.Error(w, http.StatusBadRequest, "delay_seconds must be a non-negative integer", "BAD_REQUEST")
 			return
 		}
-	}
-
-	var args []string
-	switch action {
-	case "start":
-		args = []string{"compose", "up", "-d"}
-	case "stop":
-		args = []string{"compose", "stop"}
-	case "restart":
-		args = []string{"compose", "restart"}
-	case "down":
-		args = []string{"compose", "down"}
-	case "pull":
-		args = []string{"compose", "pull"}
-	default:
-		respond.Error(w, http.StatusBadRequest, fmt.Sprintf("unknown action: %s", action), "BAD_REQUEST")
+		delay = time.Duration(n) * time.Second
+	}
+
+	args := stackActionArgs(action)
+	if args == nil {
+		respond.Error(w, http.StatusBadRequest, fmt.Sprintf("unknown action: %s", action), "BAD_REQUEST")
+		return
+	}
+
+	opts, err := decodeActionOptions(w, r)
+	if err != nil {
+		respond.Error(w, http.StatusBadRequest, err.Error(), "BAD_REQUEST")
+		return
+	}
+	flags, err := opts.flags(detail.WorkingDir)
+	if err != nil {
+		respond.Error(w, http.StatusBadRequest, err.Error(), "INVALID_OPTIONS")
+		return
+	}
+
+	// Find the compose file and any overrides in the working dir.
+	composeFiles := registry.ComposeFiles(detail.WorkingDir)
+
+	if ordered {
+		h.orderedRestart(w, r, name, detail.WorkingDir, composeFiles, flags, delay)
+		return
+	}
+
+	command, err := h.runStackAction(r.Context(), name, detail.WorkingDir, action, composeFiles, flags)
+	if errors.Is(err, errActionTimeout) {
+		respond.Error(w, http.StatusGatewayTimeout, err.Error(), "ACTION_TIMEOUT")
+		return
+	}
+	if err != nil {
+		resp := map[string]any{
+			"success": false,
+			"error":   err.Error(),
+			"command": command,
+		}
+		var sae *stackActionError
+		if errors.As(err, &sae) {
+			resp["code"] = sae.code
+			resp["output"] = sae.output
+		}
+		respond.JSON(w, http.StatusOK, resp)
 		return
 	}
 
-	// Find compose file in working dir
-	composeFile := findComposeFile(detail.WorkingDir)
+	respond.JSON(w, http.StatusOK, map[string]any{
+		"success": true,
+		"message": fmt.Sprintf("Stack '%s' %s successfully", name, actionPastTense(action)),
+		"command": command,
+	})
+}
 
-	if composeFile != "" {
-		args = append(args[:1], append([]string{"-f", composeFile}, args[1:]...)...)
-	}
+// runStackAction runs a compose action for a stack to completion and
+// returns the command line it ran. The error carries compose's output, or
+// wraps errActionTimeout if the action outlived --action-timeout.
+func (h *handlers) runStackAction(ctx context.Context, name, dir, action string, composeFiles, flags []string) (string, error) {
+	args := h.composeArgs(name, dir, composeFiles, insertFlags(stackActionArgs(action), flags))
 
-	cmd := exec.CommandContext(r.Context(), "docker", args...)
-	cmd.Dir = detail.WorkingDir
+	ctx, cancel := context.WithTimeout(ctx, h.opts.ActionTimeout)
+	defer cancel()
+	cmd := actionCommand(ctx, dir, "docker", args...)
+	command := commandLine("docker", args...)
 
 	output, err := cmd.CombinedOutput()
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		slog.WarnContext(ctx, "stack action timed out", "name", name, "action", action, "command", command, "timeout", h.opts.ActionTimeout)
+		return command, actionTimeoutError(action, h.opts.ActionTimeout)
+	}
 	if err != nil {
-		slog.Error("stack action failed", "name", name, "action", action, "error", err, "output", string(output))
+		slog.ErrorContext(ctx, "stack action failed", "name", name, "action", action, "command", command, "error", err, "output", string(output))
 		detail := strings.TrimSpace(string(output))
 		if detail == "" {
 			detail = err.Error()
 		}
+		return command, newStackActionError(action, detail)
+	}
+
+	slog.InfoContext(ctx, "stack action succeeded", "name", name, "action", action)
+	return command, nil
+}
+
+// orderedRestart restarts a stack's services one at a time in dependency
+// order, waiting delay between services.
+func (h *handlers) orderedRestart(w http.ResponseWriter, r *http.Request, name, dir string, composeFiles, flags []string, delay time.Duration) {
+	if len(composeFiles) == 0 {
+		respond.Error(w, http.StatusNotFound, fmt.Sprintf("compose file not found for stack %q", name), "NOT_FOUND")
+		return
+	}
+	contents := make([][]byte, 0, len(composeFiles))
+	for _, f := range composeFiles {
+		content, err := os.ReadFile(f)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to read compose file", "path", f, "error", err)
+			respond.Error(w, http.StatusInternalServerError, "failed to read compose file", "IO_ERROR")
+			return
+		}
+		contents = append(contents, content)
+	}
+	services, err := docker.ServiceOrder(contents...)
+	if err != nil {
 		respond.JSON(w, http.StatusOK, map[string]any{
 			"success": false,
-			"error":   fmt.Sprintf("failed to %s stack: %s", action, detail),
+			"error":   fmt.Sprintf("failed to order services: %s", err),
 		})
 		return
 	}
 
-	slog.Info("stack action succeeded", "name", name, "action", action)
+	// One command per service; the response lists those actually run.
+	commands := make([]string, 0, len(services))
+	for i, svc := range services {
+		if i > 0 && delay > 0 {
+			select {
+			case <-r.Context().Done():
+				slog.WarnContext(r.Context(), "ordered restart cancelled", "name", name, "before_service", svc, "error", r.Context().Err())
+				respond.JSON(w, http.StatusOK, map[string]any{
+					"success":   false,
+					"error":     fmt.Sprintf("restart cancelled before service '%s': %s", svc, r.Context().Err()),
+					"restarted": services[:i],
+					"commands":  commands,
+				})
+				return
+			case <-time.After(delay):
+			}
+		}
+
+		args := h.composeArgs(name, dir, composeFiles, insertFlags([]string{"compose", "restart", svc}, flags))
+		// The timeout is per service, so long delays don't eat into it.
+		ctx, cancel := context.WithTimeout(r.Context(), h.opts.ActionTimeout)
+		cmd := actionCommand(ctx, dir, "docker", args...)
+		commands = append(commands, commandLine("docker", args...))
+		output, err := cmd.CombinedOutput()
+		timedOut := errors.Is(ctx.Err(), context.DeadlineExceeded)
+		cancel()
+		if timedOut {
+			slog.WarnContext(r.Context(), "ordered restart timed out", "name", name, "service", svc, "timeout", h.opts.ActionTimeout)
+			respond.Error(w, http.StatusGatewayTimeout, actionTimeoutError("restart of service '"+svc+"'", h.opts.ActionTimeout).Error(), "ACTION_TIMEOUT")
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(r.Context(), "ordered restart failed", "name", name, "service", svc, "error", err, "output", string(output))
+			detail := strings.TrimSpace(string(output))
+			if detail == "" {
+				detail = err.Error()
+			}
+			respond.JSON(w, http.StatusOK, map[string]any{
+				"success":   false,
+				"error":     fmt.Sprintf("failed to restart service '%s': %s", svc, detail),
+				"restarted": services[:i],
+				"commands":  commands,
+			})
+			return
+		}
+	}
+
+	slog.InfoContext(r.Context(), "stack action succeeded", "name", name, "action", "restart", "ordered", true)
 	respond.JSON(w, http.StatusOK, map[string]any{
-		"success": true,
-		"message": fmt.Sprintf("Stack '%s' %s successfully", name, actionPastTense(action)),
+		"success":   true,
+		"message":   fmt.Sprintf("Stack '%s' restarted successfully", name),
+		"restarted": services,
+		"commands":  commands,
+	})
+}
+
+// --- Containers ---
+
+func (h *handlers) listContainers(w http.ResponseWriter, r *http.Request) {
+	limit, offset, err := parsePagination(r)
+	if err != nil {
+		respond.Error(w, http.StatusBadRequest, err.Error(), "BAD_REQUEST")
+		return
+	}
+	q := r.URL.Query()
+	state, stack := q.Get("state"), q.Get("stack")
+	name := strings.ToLower(q.Get("name"))
+
+	containers, err := h.docker.ListContainers(r.Context())
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to list containers", "error", err)
+		respond.Error(w, http.StatusInternalServerError, "failed to list containers", "DOCKER_ERROR")
+		return
+	}
+
+	filtered := make([]docker.ContainerInfo, 0, len(containers))
+	for _, ctr := range containers {
+		if state != "" && ctr.State != state {
+			continue
+		}
+		if stack != "" && ctr.Stack != stack {
+			continue
+		}
+		if name != "" && !strings.Contains(strings.ToLower(ctr.Name), name) {
+			continue
+		}
+		filtered = append(filtered, ctr)
+	}
+
+	respond.JSON(w, http.StatusOK, map[string]any{
+		"containers": paginate(filtered, limit, offset),
+		"total":      len(filtered),
+		"limit":      limit,
+		"offset":     offset,
 	})
 }
 
@@ -459,21 +1327,24 @@ func (h *handlers) containerAction(w http.ResponseWriter, r *http.Request) {
 	parts := strings.Split(r.URL.Path, "/")
 	action := parts[len(parts)-1]
 
-	var err error
+	var (
+		err    error
+		result *docker.StopResult
+	)
 	switch action {
 	case "start":
 		err = h.docker.StartContainer(r.Context(), containerID)
 	case "stop":
-		err = h.docker.StopContainer(r.Context(), containerID)
+		result, err = h.docker.StopContainer(r.Context(), containerID)
 	case "restart":
-		err = h.docker.RestartContainer(r.Context(), containerID)
+		result, err = h.docker.RestartContainer(r.Context(), containerID)
 	default:
 		respond.Error(w, http.StatusBadRequest, fmt.Sprintf("unknown action: %s", action), "BAD_REQUEST")
 		return
 	}
 
 	if err != nil {
-		slog.Error("container action failed", "container", containerID, "action", action, "error", err)
+		slog.ErrorContext(r.Context(), "container action failed", "container", containerID, "action", action, "error", err)
 		respond.JSON(w, http.StatusOK, map[string]any{
 			"success": false,
 			"error":   fmt.Sprintf("failed to %s container: %s", action, err.Error()),
@@ -481,13 +1352,69 @@ func (h *handlers) containerAction(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	slog.Info("container action succeeded", "container", containerID, "action", action)
-	respond.JSON(w, http.StatusOK, map[string]any{
+	slog.InfoContext(r.Context(), "container action succeeded", "container", containerID, "action", action)
+	resp := map[string]any{
 		"success": true,
 		"message": fmt.Sprintf("Container %s %s successfully", containerID, actionPastTense(action)),
+	}
+	if result != nil {
+		resp["exit_code"] = result.ExitCode
+		resp["force_killed"] = result.ForceKilled
+		resp["grace_period_seconds"] = result.GracePeriodSeconds
+		if result.ForceKilled {
+			slog.WarnContext(r.Context(), "container ignored its stop signal and was killed",
+				"container", containerID, "grace_period_seconds", result.GracePeriodSeconds)
+		}
+	}
+	respond.JSON(w, http.StatusOK, resp)
+}
+
+// removeContainer deletes a container. Running containers are refused with
+// 409 unless ?force=true; ?volumes=true also removes its anonymous volumes.
+func (h *handlers) removeContainer(w http.ResponseWriter, r *http.Request) {
+	containerID := r.PathValue("id")
+	force := r.URL.Query().Get("force") == "true"
+	volumes := r.URL.Query().Get("volumes") == "true"
+
+	if err := h.docker.RemoveContainer(r.Context(), containerID, force, volumes); err != nil {
+		switch {
+		case errors.Is(err, docker.ErrContainerNotFound):
+			respond.Error(w, http.StatusNotFound, err.Error(), "CONTAINER_NOT_FOUND")
+		case errors.Is(err, docker.ErrContainerRunning):
+			respond.Error(w, http.StatusConflict, "container is running; stop it first or set force=true", "CONTAINER_RUNNING")
+		default:
+			slog.ErrorContext(r.Context(), "failed to remove container", "container", containerID, "error", err)
+			respond.JSON(w, http.StatusOK, map[string]any{
+				"success": false,
+				"error":   fmt.Sprintf("failed to remove container: %s", err.Error()),
+			})
+		}
+		return
+	}
+
+	slog.InfoContext(r.Context(), "container removed", "container", containerID, "force", force, "volumes", volumes)
+	respond.JSON(w, http.StatusOK, map[string]any{
+		"success": true,
+		"message": fmt.Sprintf("Container %s removed successfully", containerID),
 	})
 }
 
+func (h *handlers) containerStats(w http.ResponseWriter, r *http.Request) {
+	containerID := r.PathValue("id")
+
+	stats, err := h.docker.ContainerStatsOnce(r.Context(), containerID)
+	if err != nil {
+		if errors.Is(err, docker.ErrContainerNotFound) {
+			respond.Error(w, http.StatusNotFound, err.Error(), "CONTAINER_NOT_FOUND")
+			return
+		}
+		slog.ErrorContext(r.Context(), "failed to get container stats", "container", containerID, "error", err)
+		respond.Error(w, http.StatusInternalServerError, "failed to get container stats", "DOCKER_ERROR")
+		return
+	}
+	respond.JSON(w, http.StatusOK, stats)
+}
+
 // --- Filesystem browse ---
 
 type fsEntry struct {
@@ -498,12 +1425,22 @@ type fsEntry struct {
 	Size           int64  `json:"size"`
 	ModifiedAt     int64  `json:"modified_at"`
 	FileType       string `json:"file_type"`
+	IsEnvFile      bool   `json:"is_env_file"`
+}
+
+// isEnvFile reports whether name looks like a compose environment file:
+// .env itself, variants such as .env.prod, or files ending in .env.
+func isEnvFile(name string) bool {
+	return name == ".env" || strings.HasPrefix(name, ".env.") || strings.HasSuffix(name, ".env")
 }
 
 func (h *handlers) browsePath(w http.ResponseWriter, r *http.Request) {
 	reqPath := r.URL.Query().Get("path")
 	if reqPath == "" {
 		reqPath = "/"
+		if len(h.opts.BrowseRoots) > 0 {
+			reqPath = h.opts.BrowseRoots[0]
+		}
 	}
 
 	cleanPath := filepath.Clean(reqPath)
@@ -511,6 +1448,10 @@ func (h *handlers) browsePath(w http.ResponseWriter, r *http.Request) {
 		respond.Error(w, http.StatusBadRequest, "path must be absolute", "BAD_REQUEST")
 		return
 	}
+	if !h.allowPath(w, cleanPath) {
+		return
+	}
+	showHidden := r.URL.Query().Get("show_hidden") == "true"
 
 	dirEntries, err := os.ReadDir(cleanPath)
 	if err != nil {
@@ -521,8 +1462,9 @@ func (h *handlers) browsePath(w http.ResponseWriter, r *http.Request) {
 	entries := make([]fsEntry, 0, len(dirEntries))
 	for _, de := range dirEntries {
 		name := de.Name()
-		// Skip hidden entries (dot-prefixed) and .bak files.
-		if strings.HasPrefix(name, ".") || strings.HasSuffix(name, ".bak") {
+		// Skip .bak/.bak.N files, and hidden (dot-prefixed) entries unless
+		// asked for.
+		if isBackupFile(name) || (!showHidden && strings.HasPrefix(name, ".")) {
 			continue
 		}
 
@@ -533,8 +1475,13 @@ func (h *handlers) browsePath(w http.ResponseWriter, r *http.Request) {
 			IsDir: de.IsDir(),
 		}
 
+		// An entry that vanished or can't be stat'ed is still listed, just
+		// without size and time. A directory's size is left zero: the
+		// inode size says nothing about its contents.
 		if info, err := de.Info(); err == nil {
-			entry.Size = info.Size()
+			if !de.IsDir() {
+				entry.Size = info.Size()
+			}
 			entry.ModifiedAt = info.ModTime().Unix()
 		}
 
@@ -543,6 +1490,7 @@ func (h *handlers) browsePath(w http.ResponseWriter, r *http.Request) {
 			if ext != "" {
 				entry.FileType = ext[1:] // strip leading dot
 			}
+			entry.IsEnvFile = isEnvFile(name)
 		}
 
 		if de.IsDir() {
@@ -593,6 +1541,9 @@ func (h *handlers) readFile(w http.ResponseWriter, r *http.Request) {
 		respond.Error(w, http.StatusBadRequest, "path must be absolute", "BAD_REQUEST")
 		return
 	}
+	if !h.allowPath(w, cleanPath) {
+		return
+	}
 
 	resolved, err := filepath.EvalSymlinks(cleanPath)
 	if err != nil {
@@ -618,7 +1569,7 @@ func (h *handlers) readFile(w http.ResponseWriter, r *http.Request) {
 
 	data, err := os.ReadFile(resolved)
 	if err != nil {
-		slog.Error("failed to read file", "path", resolved, "error", err)
+		slog.ErrorContext(r.Context(), "failed to read file", "path", resolved, "error", err)
 		respond.Error(w, http.StatusInternalServerError, "failed to read file", "IO_ERROR")
 		return
 	}
@@ -661,6 +1612,9 @@ func (h *handlers) writeFile(w http.ResponseWriter, r *http.Request) {
 		respond.Error(w, http.StatusBadRequest, "path must be absolute", "BAD_REQUEST")
 		return
 	}
+	if !h.allowPath(w, cleanPath) {
+		return
+	}
 
 	resolved, err := filepath.EvalSymlinks(filepath.Dir(cleanPath))
 	if err != nil {
@@ -675,24 +1629,24 @@ func (h *handlers) writeFile(w http.ResponseWriter, r *http.Request) {
 		perm = info.Mode().Perm()
 		originalData, err := os.ReadFile(targetPath)
 		if err != nil {
-			slog.Error("failed to read original file for backup", "path", targetPath, "error", err)
+			slog.ErrorContext(r.Context(), "failed to read original file for backup", "path", targetPath, "error", err)
 			respond.Error(w, http.StatusInternalServerError, "failed to read original file", "IO_ERROR")
 			return
 		}
 		if err := os.WriteFile(targetPath+".bak", originalData, perm); err != nil {
-			slog.Error("failed to create backup", "path", targetPath+".bak", "error", err)
+			slog.ErrorContext(r.Context(), "failed to create backup", "path", targetPath+".bak", "error", err)
 			respond.Error(w, http.StatusInternalServerError, "failed to create backup", "IO_ERROR")
 			return
 		}
 	}
 
 	if err := os.WriteFile(targetPath, []byte(body.Content), perm); err != nil {
-		slog.Error("failed to write file", "path", targetPath, "error", err)
+		slog.ErrorContext(r.Context(), "failed to write file", "path", targetPath, "error", err)
 		respond.Error(w, http.StatusInternalServerError, "failed to write file", "IO_ERROR")
 		return
 	}
 
-	slog.Info("file written", "path", targetPath)
+	slog.InfoContext(r.Context(), "file written", "path", targetPath)
 	respond.JSON(w, http.StatusOK, map[string]any{
 		"success": true,
 		"message": fmt.Sprintf("File '%s' saved successfully", targetPath),
@@ -713,6 +1667,9 @@ func (h *handlers) mkdirPath(w http.ResponseWriter, r *http.Request) {
 		respond.Error(w, http.StatusBadRequest, "path must be absolute", "BAD_REQUEST")
 		return
 	}
+	if !h.allowPath(w, cleanPath) {
+		return
+	}
 
 	if _, err := os.Stat(cleanPath); err == nil {
 		respond.Error(w, http.StatusConflict, "path already exists", "ALREADY_EXISTS")
@@ -720,12 +1677,12 @@ func (h *handlers) mkdirPath(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := os.MkdirAll(cleanPath, 0755); err != nil {
-		slog.Error("failed to create directory", "path", cleanPath, "error", err)
+		slog.ErrorContext(r.Context(), "failed to create directory", "path", cleanPath, "error", err)
 		respond.Error(w, http.StatusInternalServerError, "failed to create directory", "IO_ERROR")
 		return
 	}
 
-	slog.Info("directory created", "path", cleanPath)
+	slog.InfoContext(r.Context(), "directory created", "path", cleanPath)
 	respond.JSON(w, http.StatusOK, map[string]any{
 		"success": true,
 		"message": fmt.Sprintf("Directory '%s' created successfully", cleanPath),
@@ -749,6 +1706,9 @@ func (h *handlers) renamePath(w http.ResponseWriter, r *http.Request) {
 		respond.Error(w, http.StatusBadRequest, "both paths must be absolute", "BAD_REQUEST")
 		return
 	}
+	if !h.allowPath(w, oldClean) || !h.allowPath(w, newClean) {
+		return
+	}
 
 	if _, err := os.Stat(oldClean); err != nil {
 		respond.Error(w, http.StatusNotFound, fmt.Sprintf("source path not found: %s", err), "NOT_FOUND")
@@ -767,12 +1727,12 @@ func (h *handlers) renamePath(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := os.Rename(oldClean, newClean); err != nil {
-		slog.Error("failed to rename", "old", oldClean, "new", newClean, "error", err)
+		slog.ErrorContext(r.Context(), "failed to rename", "old", oldClean, "new", newClean, "error", err)
 		respond.Error(w, http.StatusInternalServerError, "failed to rename", "IO_ERROR")
 		return
 	}
 
-	slog.Info("path renamed", "old", oldClean, "new", newClean)
+	slog.InfoContext(r.Context(), "path renamed", "old", oldClean, "new", newClean)
 	respond.JSON(w, http.StatusOK, map[string]any{
 		"success": true,
 		"message": fmt.Sprintf("Renamed '%s' to '%s'", oldClean, newClean),
@@ -805,6 +1765,9 @@ func (h *handlers) deletePath(w http.ResponseWriter, r *http.Request) {
 		respond.Error(w, http.StatusBadRequest, "path must be absolute", "BAD_REQUEST")
 		return
 	}
+	if !h.allowPath(w, cleanPath) {
+		return
+	}
 
 	resolved, err := filepath.EvalSymlinks(cleanPath)
 	if err != nil {
@@ -826,19 +1789,19 @@ func (h *handlers) deletePath(w http.ResponseWriter, r *http.Request) {
 
 	if info.IsDir() {
 		if err := os.RemoveAll(resolved); err != nil {
-			slog.Error("failed to delete directory", "path", resolved, "error", err)
+			slog.ErrorContext(r.Context(), "failed to delete directory", "path", resolved, "error", err)
 			respond.Error(w, http.StatusInternalServerError, "failed to delete directory", "IO_ERROR")
 			return
 		}
 	} else {
 		if err := os.Remove(resolved); err != nil {
-			slog.Error("failed to delete file", "path", resolved, "error", err)
+			slog.ErrorContext(r.Context(), "failed to delete file", "path", resolved, "error", err)
 			respond.Error(w, http.StatusInternalServerError, "failed to delete file", "IO_ERROR")
 			return
 		}
 	}
 
-	slog.Info("path deleted", "path", resolved)
+	slog.InfoContext(r.Context(), "path deleted", "path", resolved)
 	respond.JSON(w, http.StatusOK, map[string]any{
 		"success": true,
 		"message": fmt.Sprintf("'%s' deleted successfully", resolved),
@@ -847,9 +1810,25 @@ func (h *handlers) deletePath(w http.ResponseWriter, r *http.Request) {
 
 // --- Stack registration ---
 
+// projectNameRe is the character set docker compose accepts for project names.
+var projectNameRe = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// projectArgs returns the -p flag for a stack registered under a custom
+// name, so compose uses that name as the project instead of deriving it
+// from the directory. It returns nil when the default applies.
+func (h *handlers) projectArgs(name, dir string) []string {
+	rs := h.registry.Get(name)
+	if rs == nil || rs.WorkingDir != dir || filepath.Base(dir) == name {
+		return nil
+	}
+	return []string{"-p", name}
+}
+
 func (h *handlers) registerStack(w http.ResponseWriter, r *http.Request) {
 	var body struct {
-		Path string `json:"path"`
+		Path  string `json:"path"`
+		Name  string `json:"name"`  // overrides the directory-derived name
+		Force bool   `json:"force"` // replace a different stack registered under the same name
 	}
 	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
 		respond.Error(w, http.StatusBadRequest, "invalid JSON body", "BAD_REQUEST")
@@ -861,6 +1840,9 @@ func (h *handlers) registerStack(w http.ResponseWriter, r *http.Request) {
 		respond.Error(w, http.StatusBadRequest, "path must be absolute", "BAD_REQUEST")
 		return
 	}
+	if !h.allowPath(w, cleanPath) {
+		return
+	}
 
 	composeFile := findComposeFile(cleanPath)
 	if composeFile == "" {
@@ -869,8 +1851,25 @@ func (h *handlers) registerStack(w http.ResponseWriter, r *http.Request) {
 	}
 
 	name := filepath.Base(cleanPath)
+	if body.Name != "" {
+		if !projectNameRe.MatchString(body.Name) {
+			respond.Error(w, http.StatusBadRequest,
+				"name must start with a lowercase letter or digit and contain only lowercase letters, digits, '_' and '-'",
+				"BAD_REQUEST")
+			return
+		}
+		name = body.Name
+	}
+
+	if existing := h.registry.Get(name); existing != nil && existing.WorkingDir != cleanPath && !body.Force {
+		respond.Error(w, http.StatusConflict,
+			fmt.Sprintf("stack %q is already registered for %s; set force to replace it", name, existing.WorkingDir),
+			"NAME_CONFLICT")
+		return
+	}
+
 	if err := h.registry.Register(name, cleanPath, composeFile); err != nil {
-		slog.Error("failed to register stack", "name", name, "error", err)
+		slog.ErrorContext(r.Context(), "failed to register stack", "name", name, "error", err)
 		respond.Error(w, http.StatusInternalServerError, "failed to register stack", "REGISTRY_ERROR")
 		return
 	}
@@ -891,7 +1890,7 @@ func (h *handlers) unregisterStack(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := h.registry.Unregister(name); err != nil {
-		slog.Error("failed to unregister stack", "name", name, "error", err)
+		slog.ErrorContext(r.Context(), "failed to unregister stack", "name", name, "error", err)
 		respond.Error(w, http.StatusInternalServerError, "failed to unregister stack", "REGISTRY_ERROR")
 		return
 	}
@@ -902,12 +1901,29 @@ func (h *handlers) unregisterStack(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+func (h *handlers) pruneStaleStacks(w http.ResponseWriter, r *http.Request) {
+	removed, err := h.registry.PruneStale()
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to prune stale stacks", "error", err)
+		respond.Error(w, http.StatusInternalServerError, "failed to prune stale stacks", "REGISTRY_ERROR")
+		return
+	}
+	if removed == nil {
+		removed = []string{}
+	}
+	slog.InfoContext(r.Context(), "pruned stale stacks", "removed", removed)
+	respond.JSON(w, http.StatusOK, map[string]any{
+		"success": true,
+		"removed": removed,
+	})
+}
+
 // --- Docker resources ---
 
 func (h *handlers) dockerDiskUsage(w http.ResponseWriter, r *http.Request) {
 	summary, err := h.docker.DiskUsage(r.Context())
 	if err != nil {
-		slog.Error("failed to get disk usage", "error", err)
+		slog.ErrorContext(r.Context(), "failed to get disk usage", "error", err)
 		respond.Error(w, http.StatusInternalServerError, "failed to get disk usage", "DOCKER_ERROR")
 		return
 	}
@@ -915,13 +1931,64 @@ func (h *handlers) dockerDiskUsage(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *handlers) listImages(w http.ResponseWriter, r *http.Request) {
+	limit, offset, err := parsePaginationOrAll(r)
+	if err != nil {
+		respond.Error(w, http.StatusBadRequest, err.Error(), "BAD_REQUEST")
+		return
+	}
+	dangling, err := parseBoolFilter(r, "dangling")
+	if err != nil {
+		respond.Error(w, http.StatusBadRequest, err.Error(), "BAD_REQUEST")
+		return
+	}
+	inUse, err := parseBoolFilter(r, "in_use")
+	if err != nil {
+		respond.Error(w, http.StatusBadRequest, err.Error(), "BAD_REQUEST")
+		return
+	}
+
 	images, err := h.docker.ListImages(r.Context())
 	if err != nil {
-		slog.Error("failed to list images", "error", err)
+		slog.ErrorContext(r.Context(), "failed to list images", "error", err)
 		respond.Error(w, http.StatusInternalServerError, "failed to list images", "DOCKER_ERROR")
 		return
 	}
-	respond.JSON(w, http.StatusOK, map[string]any{"images": images})
+
+	filtered := filterImages(images, dangling, inUse)
+	respond.JSON(w, http.StatusOK, map[string]any{
+		"images": paginate(filtered, limit, offset),
+		"total":  len(filtered),
+		"limit":  limit,
+		"offset": offset,
+	})
+}
+
+// filterImages keeps the images matching the optional dangling and in_use
+// filters. In-use is taken from ListImages, which already mapped containers
+// to images.
+func filterImages(images []docker.ImageInfo, dangling, inUse *bool) []docker.ImageInfo {
+	out := make([]docker.ImageInfo, 0, len(images))
+	for _, img := range images {
+		if dangling != nil && isDangling(img) != *dangling {
+			continue
+		}
+		if inUse != nil && img.InUse != *inUse {
+			continue
+		}
+		out = append(out, img)
+	}
+	return out
+}
+
+// isDangling reports whether an image has no tag, which Docker lists as
+// none or as "<none>:<none>".
+func isDangling(img docker.ImageInfo) bool {
+	for _, tag := range img.Tags {
+		if tag != "<none>:<none>" {
+			return false
+		}
+	}
+	return true
 }
 
 func (h *handlers) removeImage(w http.ResponseWriter, r *http.Request) {
@@ -929,7 +1996,7 @@ func (h *handlers) removeImage(w http.ResponseWriter, r *http.Request) {
 	force := r.URL.Query().Get("force") == "true"
 
 	if err := h.docker.RemoveImage(r.Context(), id, force); err != nil {
-		slog.Error("failed to remove image", "id", id, "error", err)
+		slog.ErrorContext(r.Context(), "failed to remove image", "id", id, "error", err)
 		respond.JSON(w, http.StatusOK, map[string]any{
 			"success": false,
 			"error":   fmt.Sprintf("failed to remove image: %s", err),
@@ -943,12 +2010,95 @@ func (h *handlers) removeImage(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// pullImage pulls an image ahead of a deploy. Private registries take the
+// base64 credential Docker uses, passed through in an X-Registry-Auth
+// header. With Accept: text/event-stream the layer progress is streamed as
+// it happens; otherwise the pull is drained and summarized.
+func (h *handlers) pullImage(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, 1<<10)
+	var body struct {
+		Image string `json:"image"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		respond.Error(w, http.StatusBadRequest, "invalid JSON body", "BAD_REQUEST")
+		return
+	}
+	if _, err := docker.NormalizeImageRef(body.Image); err != nil {
+		respond.Error(w, http.StatusBadRequest, err.Error(), "INVALID_REFERENCE")
+		return
+	}
+	auth := r.Header.Get("X-Registry-Auth")
+
+	if strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+		send := respond.SSE(w)
+		result, err := h.docker.PullImage(r.Context(), body.Image, auth, func(p docker.PullProgress) {
+			send("progress", p)
+		})
+		if err != nil {
+			if r.Context().Err() == nil {
+				slog.ErrorContext(r.Context(), "image pull failed", "image", body.Image, "error", err)
+				_, msg, code := pullFailure(err)
+				send("error", respond.NewError(msg, code, requestid.FromContext(r.Context())))
+			}
+			return
+		}
+		slog.InfoContext(r.Context(), "image pulled", "image", result.Image, "status", result.Status)
+		send("done", result)
+		return
+	}
+
+	result, err := h.docker.PullImage(r.Context(), body.Image, auth, nil)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "image pull failed", "image", body.Image, "error", err)
+		status, msg, code := pullFailure(err)
+		respond.Error(w, status, msg, code)
+		return
+	}
+	slog.InfoContext(r.Context(), "image pulled", "image", result.Image, "status", result.Status)
+	respond.JSON(w, http.StatusOK, result)
+}
+
+// pullFailure maps an image pull error to its HTTP status, message and
+// error code.
+func pullFailure(err error) (int, string, string) {
+	if errors.Is(err, docker.ErrImageNotFound) {
+		return http.StatusNotFound, err.Error(), "IMAGE_NOT_FOUND"
+	}
+	return http.StatusBadGateway, err.Error(), "PULL_FAILED"
+}
+
+// parsePruneFilter reads ?until= (a Go duration such as 24h) and any
+// number of ?label=key or ?label=key=value.
+func parsePruneFilter(r *http.Request) (docker.PruneFilter, error) {
+	var f docker.PruneFilter
+	q := r.URL.Query()
+	if v := q.Get("until"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return f, fmt.Errorf("until must be a positive duration such as 24h")
+		}
+		f.Until = d
+	}
+	for _, l := range q["label"] {
+		if key, _, _ := strings.Cut(l, "="); key == "" {
+			return f, fmt.Errorf("label must be key or key=value")
+		}
+		f.Labels = append(f.Labels, l)
+	}
+	return f, nil
+}
+
 func (h *handlers) pruneImages(w http.ResponseWriter, r *http.Request) {
 	dryRun := r.URL.Query().Get("dry_run") == "true"
+	filter, err := parsePruneFilter(r)
+	if err != nil {
+		respond.Error(w, http.StatusBadRequest, err.Error(), "BAD_REQUEST")
+		return
+	}
 
-	result, err := h.docker.PruneImages(r.Context(), dryRun)
+	result, err := h.docker.PruneImages(r.Context(), dryRun, filter)
 	if err != nil {
-		slog.Error("failed to prune images", "dry_run", dryRun, "error", err)
+		slog.ErrorContext(r.Context(), "failed to prune images", "dry_run", dryRun, "error", err)
 		respond.Error(w, http.StatusInternalServerError, "failed to prune images", "DOCKER_ERROR")
 		return
 	}
@@ -958,19 +2108,44 @@ func (h *handlers) pruneImages(w http.ResponseWriter, r *http.Request) {
 func (h *handlers) listVolumes(w http.ResponseWriter, r *http.Request) {
 	volumes, err := h.docker.ListVolumes(r.Context())
 	if err != nil {
-		slog.Error("failed to list volumes", "error", err)
+		slog.ErrorContext(r.Context(), "failed to list volumes", "error", err)
 		respond.Error(w, http.StatusInternalServerError, "failed to list volumes", "DOCKER_ERROR")
 		return
 	}
 	respond.JSON(w, http.StatusOK, map[string]any{"volumes": volumes})
 }
 
+func (h *handlers) createVolume(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, 64<<10)
+	var body struct {
+		Name   string            `json:"name"`
+		Driver string            `json:"driver"`
+		Labels map[string]string `json:"labels"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		respond.Error(w, http.StatusBadRequest, "invalid JSON body", "BAD_REQUEST")
+		return
+	}
+	if body.Name == "" {
+		respond.Error(w, http.StatusBadRequest, "name is required", "BAD_REQUEST")
+		return
+	}
+
+	vol, err := h.docker.CreateVolume(r.Context(), body.Name, body.Driver, body.Labels)
+	if err != nil {
+		createFailure(w, r, "volume", body.Name, err)
+		return
+	}
+	slog.InfoContext(r.Context(), "volume created", "name", vol.Name, "driver", vol.Driver)
+	respond.JSON(w, http.StatusCreated, vol)
+}
+
 func (h *handlers) removeVolume(w http.ResponseWriter, r *http.Request) {
 	name := r.PathValue("name")
 	force := r.URL.Query().Get("force") == "true"
 
 	if err := h.docker.RemoveVolume(r.Context(), name, force); err != nil {
-		slog.Error("failed to remove volume", "name", name, "error", err)
+		slog.ErrorContext(r.Context(), "failed to remove volume", "name", name, "error", err)
 		respond.JSON(w, http.StatusOK, map[string]any{
 			"success": false,
 			"error":   fmt.Sprintf("failed to remove volume: %s", err),
@@ -989,7 +2164,7 @@ func (h *handlers) pruneVolumes(w http.ResponseWriter, r *http.Request) {
 
 	result, err := h.docker.PruneVolumes(r.Context(), dryRun)
 	if err != nil {
-		slog.Error("failed to prune volumes", "dry_run", dryRun, "error", err)
+		slog.ErrorContext(r.Context(), "failed to prune volumes", "dry_run", dryRun, "error", err)
 		respond.Error(w, http.StatusInternalServerError, "failed to prune volumes", "DOCKER_ERROR")
 		return
 	}
@@ -999,18 +2174,58 @@ func (h *handlers) pruneVolumes(w http.ResponseWriter, r *http.Request) {
 func (h *handlers) listNetworks(w http.ResponseWriter, r *http.Request) {
 	networks, err := h.docker.ListNetworks(r.Context())
 	if err != nil {
-		slog.Error("failed to list networks", "error", err)
+		slog.ErrorContext(r.Context(), "failed to list networks", "error", err)
 		respond.Error(w, http.StatusInternalServerError, "failed to list networks", "DOCKER_ERROR")
 		return
 	}
 	respond.JSON(w, http.StatusOK, map[string]any{"networks": networks})
 }
 
+func (h *handlers) createNetwork(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, 64<<10)
+	var body struct {
+		Name     string            `json:"name"`
+		Driver   string            `json:"driver"`
+		Internal bool              `json:"internal"`
+		Labels   map[string]string `json:"labels"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		respond.Error(w, http.StatusBadRequest, "invalid JSON body", "BAD_REQUEST")
+		return
+	}
+	if body.Name == "" {
+		respond.Error(w, http.StatusBadRequest, "name is required", "BAD_REQUEST")
+		return
+	}
+
+	n, err := h.docker.CreateNetwork(r.Context(), body.Name, body.Driver, body.Internal, body.Labels)
+	if err != nil {
+		createFailure(w, r, "network", body.Name, err)
+		return
+	}
+	slog.InfoContext(r.Context(), "network created", "name", n.Name, "driver", n.Driver, "internal", n.Internal)
+	respond.JSON(w, http.StatusCreated, n)
+}
+
+// createFailure writes the error response for a failed volume or network
+// create.
+func createFailure(w http.ResponseWriter, r *http.Request, kind, name string, err error) {
+	switch {
+	case errors.Is(err, docker.ErrAlreadyExists):
+		respond.Error(w, http.StatusConflict, err.Error(), "ALREADY_EXISTS")
+	case errors.Is(err, docker.ErrBuiltinNetwork), errors.Is(err, docker.ErrInvalidSpec):
+		respond.Error(w, http.StatusBadRequest, err.Error(), "BAD_REQUEST")
+	default:
+		slog.ErrorContext(r.Context(), "failed to create "+kind, "name", name, "error", err)
+		respond.Error(w, http.StatusInternalServerError, "failed to create "+kind, "DOCKER_ERROR")
+	}
+}
+
 func (h *handlers) removeNetwork(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("id")
 
 	if err := h.docker.RemoveNetwork(r.Context(), id); err != nil {
-		slog.Error("failed to remove network", "id", id, "error", err)
+		slog.ErrorContext(r.Context(), "failed to remove network", "id", id, "error", err)
 		respond.JSON(w, http.StatusOK, map[string]any{
 			"success": false,
 			"error":   fmt.Sprintf("failed to remove network: %s", err),
@@ -1029,7 +2244,7 @@ func (h *handlers) pruneNetworks(w http.ResponseWriter, r *http.Request) {
 
 	result, err := h.docker.PruneNetworks(r.Context(), dryRun)
 	if err != nil {
-		slog.Error("failed to prune networks", "dry_run", dryRun, "error", err)
+		slog.ErrorContext(r.Context(), "failed to prune networks", "dry_run", dryRun, "error", err)
 		respond.Error(w, http.StatusInternalServerError, "failed to prune networks", "DOCKER_ERROR")
 		return
 	}
@@ -1038,40 +2253,94 @@ func (h *handlers) pruneNetworks(w http.ResponseWriter, r *http.Request) {
 
 func (h *handlers) pruneBuildCache(w http.ResponseWriter, r *http.Request) {
 	dryRun := r.URL.Query().Get("dry_run") == "true"
+	filter, err := parsePruneFilter(r)
+	if err != nil {
+		respond.Error(w, http.StatusBadRequest, err.Error(), "BAD_REQUEST")
+		return
+	}
 
-	result, err := h.docker.PruneBuildCache(r.Context(), dryRun)
+	result, err := h.docker.PruneBuildCache(r.Context(), dryRun, filter)
 	if err != nil {
-		slog.Error("failed to prune build cache", "dry_run", dryRun, "error", err)
+		if errors.Is(err, docker.ErrInvalidSpec) {
+			respond.Error(w, http.StatusBadRequest, err.Error(), "BAD_REQUEST")
+			return
+		}
+		slog.ErrorContext(r.Context(), "failed to prune build cache", "dry_run", dryRun, "error", err)
 		respond.Error(w, http.StatusInternalServerError, "failed to prune build cache", "DOCKER_ERROR")
 		return
 	}
 	respond.JSON(w, http.StatusOK, result)
 }
 
-// --- Helpers ---
+// pruneAll runs the selected prunes in one request, for a single "clean
+// up" action. A failing prune doesn't stop the others; its error is
+// reported under its resource type.
+func (h *handlers) pruneAll(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, 1<<10)
+	var body struct {
+		Images     bool `json:"images"`
+		Volumes    bool `json:"volumes"`
+		Networks   bool `json:"networks"`
+		BuildCache bool `json:"build_cache"`
+		DryRun     bool `json:"dry_run"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		respond.Error(w, http.StatusBadRequest, "invalid JSON body", "BAD_REQUEST")
+		return
+	}
 
-func dockerVersion() string {
-	out, err := exec.Command("docker", "version", "--format", "{{.Server.Version}}").Output()
-	if err != nil {
-		return "unknown"
+	prunes := []struct {
+		kind     string
+		selected bool
+		run      func(context.Context, bool) (*docker.PruneResult, error)
+	}{
+		{"images", body.Images, func(ctx context.Context, dryRun bool) (*docker.PruneResult, error) {
+			return h.docker.PruneImages(ctx, dryRun, docker.PruneFilter{})
+		}},
+		{"volumes", body.Volumes, h.docker.PruneVolumes},
+		{"networks", body.Networks, h.docker.PruneNetworks},
+		{"build_cache", body.BuildCache, func(ctx context.Context, dryRun bool) (*docker.PruneResult, error) {
+			return h.docker.PruneBuildCache(ctx, dryRun, docker.PruneFilter{})
+		}},
+	}
+
+	results := make(map[string]*docker.PruneResult)
+	errs := make(map[string]string)
+	var total int64
+	for _, p := range prunes {
+		if !p.selected {
+			continue
+		}
+		result, err := p.run(r.Context(), body.DryRun)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "prune failed", "kind", p.kind, "dry_run", body.DryRun, "error", err)
+			errs[p.kind] = err.Error()
+			continue
+		}
+		results[p.kind] = result
+		total += result.SpaceReclaimed
+	}
+	if len(results) == 0 && len(errs) == 0 {
+		respond.Error(w, http.StatusBadRequest, "select at least one of images, volumes, networks, build_cache", "BAD_REQUEST")
+		return
 	}
-	return strings.TrimSpace(string(out))
-}
 
-func findComposeFile(dir string) string {
-	candidates := []string{
-		"docker-compose.yml",
-		"docker-compose.yaml",
-		"compose.yml",
-		"compose.yaml",
+	resp := map[string]any{
+		"success":         len(errs) == 0,
+		"dry_run":         body.DryRun,
+		"results":         results,
+		"space_reclaimed": total,
 	}
-	for _, name := range candidates {
-		path := filepath.Join(dir, name)
-		if _, err := os.Stat(path); err == nil {
-			return path
-		}
+	if len(errs) > 0 {
+		resp["errors"] = errs
 	}
-	return ""
+	respond.JSON(w, http.StatusOK, resp)
+}
+
+// --- Helpers ---
+
+func findComposeFile(dir string) string {
+	return registry.FindComposeFile(dir)
 }
 
 func actionPastTense(action string) string {
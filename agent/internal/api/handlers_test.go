@@ -1,15 +1,28 @@
 package api_test
 
 import (
+	"bytes"
 	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
 	"runtime"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/driversti/hola/internal/api"
+	"github.com/driversti/hola/internal/api/respond"
 	"github.com/driversti/hola/internal/auth"
+	"github.com/driversti/hola/internal/docker"
 	"github.com/driversti/hola/internal/registry"
+	"github.com/driversti/hola/internal/requestid"
 	"github.com/driversti/hola/internal/update"
 	"github.com/driversti/hola/internal/ws"
 )
@@ -17,7 +30,127 @@ import (
 func newTestRouter(t *testing.T) http.Handler {
 	t.Helper()
 	store, _ := registry.NewStore(t.TempDir())
-	return api.NewRouter("0.1.0-test", auth.NewMiddleware("test-token"), nil, ws.NewHandler(nil), store, update.New("0.1.0-test", "driversti/HoLA"))
+	return api.NewRouter("0.1.0-test", auth.NewMiddleware("test-token"), nil, ws.NewHandler(nil, ws.Options{}), store, update.New("0.1.0-test", "driversti/HoLA"), api.Options{})
+}
+
+// newFakeDocker returns a client for a fake daemon that has no containers.
+// routes answers other requests, keyed by path suffix.
+func newFakeDocker(t *testing.T, routes map[string]http.HandlerFunc) *docker.Client {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Api-Version", "1.45")
+		for suffix, h := range routes {
+			if strings.HasSuffix(r.URL.Path, suffix) {
+				h(w, r)
+				return
+			}
+		}
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/_ping"):
+			w.Write([]byte("OK"))
+		case strings.HasSuffix(r.URL.Path, "/containers/json"):
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte("[]"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	dc, err := docker.NewClient(docker.Config{Host: "tcp://" + srv.Listener.Addr().String()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { dc.Close() })
+	return dc
+}
+
+// newDockerTestServer serves a router backed by a fake daemon (see
+// newFakeDocker) and an empty registry.
+func newDockerTestServer(t *testing.T, routes map[string]http.HandlerFunc) *httptest.Server {
+	t.Helper()
+	store, err := registry.NewStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(api.NewRouter("0.1.0-test", auth.NewMiddleware("test-token"), newFakeDocker(t, routes), ws.NewHandler(nil, ws.Options{}), store, update.New("0.1.0-test", "driversti/HoLA"), api.Options{}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// newStackTestServer serves a router backed by a fake daemon (see
+// newFakeDocker), with a down stack "app" registered in a temporary
+// directory holding compose. It returns the server and that directory.
+func newStackTestServer(t *testing.T, compose string, routes map[string]http.HandlerFunc, opts api.Options) (*httptest.Server, string) {
+	t.Helper()
+	dir := filepath.Join(t.TempDir(), "app")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	composePath := filepath.Join(dir, "compose.yaml")
+	if err := os.WriteFile(composePath, []byte(compose), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	store, err := registry.NewStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Register("app", dir, composePath); err != nil {
+		t.Fatal(err)
+	}
+
+	router := api.NewRouter("0.1.0-test", auth.NewMiddleware("test-token"), newFakeDocker(t, routes), ws.NewHandler(nil, ws.Options{}), store, update.New("0.1.0-test", "driversti/HoLA"), opts)
+	srv := httptest.NewServer(router)
+	t.Cleanup(srv.Close)
+	return srv, dir
+}
+
+// fakeDockerCLI puts a docker executable running the shell script body
+// first on PATH, so handlers that shell out to the CLI can be tested. It
+// skips the test where there is no POSIX shell.
+func fakeDockerCLI(t *testing.T, body string) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("needs a POSIX shell")
+	}
+	bin := t.TempDir()
+	if err := os.WriteFile(filepath.Join(bin, "docker"), []byte("#!/bin/sh\n"+body+"\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+// call sends an authenticated request with an optional JSON body and
+// decodes the JSON response into out, when out is non-nil.
+func call(t *testing.T, srv *httptest.Server, method, path string, body any, header http.Header, out any) *http.Response {
+	t.Helper()
+	var r io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r = strings.NewReader(string(b))
+	}
+	req, err := http.NewRequest(method, srv.URL+path, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Authorization", "Bearer test-token")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			t.Fatalf("%s %s: decode response: %v", method, path, err)
+		}
+	}
+	return resp
 }
 
 func TestHealthEndpoint(t *testing.T) {
@@ -41,6 +174,28 @@ func TestHealthEndpoint(t *testing.T) {
 	}
 }
 
+func TestReadyReportsUnreachableDocker(t *testing.T) {
+	srv := httptest.NewServer(newTestRouter(t))
+	defer srv.Close()
+
+	for _, path := range []string{"/api/v1/ready", "/api/v1/health?deep=true"} {
+		resp, err := http.Get(srv.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var body map[string]string
+		json.NewDecoder(resp.Body).Decode(&body)
+		resp.Body.Close()
+
+		if resp.StatusCode != http.StatusServiceUnavailable {
+			t.Errorf("%s: want 503, got %d", path, resp.StatusCode)
+		}
+		if body["status"] != "degraded" || body["docker"] != "unreachable" {
+			t.Errorf("%s: unexpected body %v", path, body)
+		}
+	}
+}
+
 func TestAgentInfoRequiresAuth(t *testing.T) {
 	srv := httptest.NewServer(newTestRouter(t))
 	defer srv.Close()
@@ -78,6 +233,8 @@ func TestAgentInfoWithAuth(t *testing.T) {
 		Hostname string `json:"hostname"`
 		OS       string `json:"os"`
 		Arch     string `json:"arch"`
+		EUID     int    `json:"euid"`
+		IsRoot   bool   `json:"is_root"`
 	}
 	json.NewDecoder(resp.Body).Decode(&info)
 
@@ -90,4 +247,983 @@ func TestAgentInfoWithAuth(t *testing.T) {
 	if info.Arch != runtime.GOARCH {
 		t.Errorf("want arch %s, got %q", runtime.GOARCH, info.Arch)
 	}
+	if info.EUID != os.Geteuid() || info.IsRoot != (os.Geteuid() == 0) {
+		t.Errorf("want euid %d, got euid %d is_root %v", os.Geteuid(), info.EUID, info.IsRoot)
+	}
+}
+
+func TestListStacksRejectsUnknownSource(t *testing.T) {
+	srv := httptest.NewServer(newTestRouter(t))
+	defer srv.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/api/v1/stacks?source=bogus", nil)
+	req.Header.Set("Authorization", "Bearer test-token")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("want 400, got %d", resp.StatusCode)
+	}
+}
+
+func TestListStacksRejectsUnknownStatus(t *testing.T) {
+	srv := httptest.NewServer(newTestRouter(t))
+	defer srv.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/api/v1/stacks?status=stopped&status=exited", nil)
+	req.Header.Set("Authorization", "Bearer test-token")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("want 400, got %d", resp.StatusCode)
+	}
+}
+
+func TestErrorResponseCarriesRequestIDAndTimestamp(t *testing.T) {
+	srv := httptest.NewServer(newTestRouter(t))
+	defer srv.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/api/v1/stacks?source=bogus", nil)
+	req.Header.Set("Authorization", "Bearer test-token")
+	req.Header.Set("X-Request-ID", "trace-42")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if got := resp.Header.Get("X-Request-ID"); got != "trace-42" {
+		t.Errorf("X-Request-ID = %q, want trace-42", got)
+	}
+	var body respond.ErrorResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if body.RequestID != "trace-42" {
+		t.Errorf("request_id = %q, want trace-42", body.RequestID)
+	}
+	if body.Error == "" || body.Code != "BAD_REQUEST" {
+		t.Errorf("error envelope = %+v", body)
+	}
+	if _, err := time.Parse(time.RFC3339, body.Timestamp); err != nil {
+		t.Errorf("timestamp %q is not RFC3339: %v", body.Timestamp, err)
+	}
+
+	resp2, err := http.Get(srv.URL + "/api/v1/health")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp2.Body.Close()
+	if resp2.Header.Get("X-Request-ID") == "" {
+		t.Error("no X-Request-ID generated for a request without one")
+	}
+}
+
+func TestContainerTopRejectsUnsafePsArgs(t *testing.T) {
+	srv := httptest.NewServer(newTestRouter(t))
+	defer srv.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/api/v1/containers/abc/top?ps_args=aux%3Brm", nil)
+	req.Header.Set("Authorization", "Bearer test-token")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("want 400, got %d", resp.StatusCode)
+	}
+}
+
+func TestContainerLogsRejectsBadMaxBytes(t *testing.T) {
+	srv := httptest.NewServer(newTestRouter(t))
+	defer srv.Close()
+
+	for _, v := range []string{"0", "-1", "lots"} {
+		var out struct{ Code string }
+		resp := call(t, srv, http.MethodGet, "/api/v1/containers/abc/logs?max_bytes="+v, nil, nil, &out)
+		if resp.StatusCode != http.StatusBadRequest || out.Code != "BAD_REQUEST" {
+			t.Errorf("max_bytes=%s: want 400 BAD_REQUEST, got %d %s", v, resp.StatusCode, out.Code)
+		}
+	}
+}
+
+func TestContainerLogsRejectsBadContext(t *testing.T) {
+	srv := httptest.NewServer(newTestRouter(t))
+	defer srv.Close()
+
+	for _, q := range []string{"context_before=-1", "context_after=-2", "context_before=two", "context_after=1.5"} {
+		var out struct{ Code string }
+		resp := call(t, srv, http.MethodGet, "/api/v1/containers/abc/logs?grep=x&"+q, nil, nil, &out)
+		if resp.StatusCode != http.StatusBadRequest || out.Code != "BAD_REQUEST" {
+			t.Errorf("%s: want 400 BAD_REQUEST, got %d %s", q, resp.StatusCode, out.Code)
+		}
+	}
+}
+
+func TestSearchRejectsBadRegex(t *testing.T) {
+	srv := newDockerTestServer(t, nil)
+
+	var out struct{ Code string }
+	resp := call(t, srv, http.MethodGet, "/api/v1/search?regex=true&q=%5B", nil, nil, &out)
+	if resp.StatusCode != http.StatusBadRequest || out.Code != "BAD_FILTER" {
+		t.Errorf("want 400 BAD_FILTER, got %d %s", resp.StatusCode, out.Code)
+	}
+}
+
+func TestAgentVersionRejectsInvalidCompare(t *testing.T) {
+	srv := httptest.NewServer(newTestRouter(t))
+	defer srv.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/api/v1/agent/version?compare=not-a-version", nil)
+	req.Header.Set("Authorization", "Bearer test-token")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("want 400, got %d", resp.StatusCode)
+	}
+}
+
+func TestAgentConfigRedactsSecrets(t *testing.T) {
+	store, _ := registry.NewStore(t.TempDir())
+	router := api.NewRouter("0.1.0-test", auth.NewMiddleware("test-token"), nil, ws.NewHandler(nil, ws.Options{}), store, update.New("0.1.0-test", "driversti/HoLA"), api.Options{
+		Config: api.AgentConfig{
+			ListenAddr: ":8420",
+			Token:      api.Redact("test-token"),
+			Alerts:     api.AlertsConfig{Webhook: api.RedactURL("https://hooks.example.com/services/secret?key=abc")},
+		},
+	})
+	srv := httptest.NewServer(router)
+	defer srv.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/api/v1/agent/config", nil)
+	req.Header.Set("Authorization", "Bearer test-token")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("want 200, got %d", resp.StatusCode)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	for _, secret := range []string{"test-token", "secret", "key=abc"} {
+		if strings.Contains(string(body), secret) {
+			t.Errorf("config leaks %q: %s", secret, body)
+		}
+	}
+
+	var cfg api.AgentConfig
+	if err := json.Unmarshal(body, &cfg); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.ListenAddr != ":8420" || cfg.Token == "" {
+		t.Errorf("unexpected config: %+v", cfg)
+	}
+	if cfg.Alerts.Webhook != "https://hooks.example.com/<redacted>" {
+		t.Errorf("webhook = %q", cfg.Alerts.Webhook)
+	}
+}
+
+func TestMaintenanceToggle(t *testing.T) {
+	srv := httptest.NewServer(newTestRouter(t))
+	defer srv.Close()
+
+	do := func(method, path, body string) *http.Response {
+		t.Helper()
+		req, _ := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer test-token")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	resp := do(http.MethodPost, "/api/v1/agent/maintenance", `{"enabled":true,"duration":"soon"}`)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("invalid duration: want 400, got %d", resp.StatusCode)
+	}
+
+	resp = do(http.MethodPost, "/api/v1/agent/maintenance", `{"enabled":true,"duration":"30m","reason":"disk swap"}`)
+	var st struct {
+		Enabled bool   `json:"enabled"`
+		Until   string `json:"until"`
+		Reason  string `json:"reason"`
+	}
+	json.NewDecoder(resp.Body).Decode(&st)
+	resp.Body.Close()
+	if !st.Enabled || st.Until == "" || st.Reason != "disk swap" {
+		t.Fatalf("unexpected status after enable: %+v", st)
+	}
+
+	resp = do(http.MethodGet, "/api/v1/agent/info", "")
+	var info struct {
+		Maintenance bool `json:"maintenance"`
+	}
+	json.NewDecoder(resp.Body).Decode(&info)
+	resp.Body.Close()
+	if !info.Maintenance {
+		t.Error("agent info should report maintenance mode")
+	}
+
+	resp = do(http.MethodPost, "/api/v1/agent/maintenance", `{"enabled":false}`)
+	resp.Body.Close()
+	resp = do(http.MethodGet, "/api/v1/agent/maintenance", "")
+	json.NewDecoder(resp.Body).Decode(&st)
+	resp.Body.Close()
+	if st.Enabled {
+		t.Error("maintenance mode should be off after disabling")
+	}
+}
+
+func TestRegisterStackCustomName(t *testing.T) {
+	srv := httptest.NewServer(newTestRouter(t))
+	defer srv.Close()
+
+	root := t.TempDir()
+	for _, dir := range []string{"a/app", "b/app"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(root, dir, "compose.yaml"), []byte("services: {}\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	register := func(body map[string]any) (int, string) {
+		t.Helper()
+		b, _ := json.Marshal(body)
+		req, _ := http.NewRequest(http.MethodPost, srv.URL+"/api/v1/stacks/register", strings.NewReader(string(b)))
+		req.Header.Set("Authorization", "Bearer test-token")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var out struct {
+			Name string `json:"name"`
+			Code string `json:"code"`
+		}
+		json.NewDecoder(resp.Body).Decode(&out)
+		if out.Code != "" {
+			return resp.StatusCode, out.Code
+		}
+		return resp.StatusCode, out.Name
+	}
+
+	a, b := filepath.Join(root, "a/app"), filepath.Join(root, "b/app")
+
+	if status, name := register(map[string]any{"path": a}); status != http.StatusOK || name != "app" {
+		t.Fatalf("default name: got %d %q", status, name)
+	}
+	if status, code := register(map[string]any{"path": b}); status != http.StatusConflict || code != "NAME_CONFLICT" {
+		t.Fatalf("clashing name: want 409 NAME_CONFLICT, got %d %q", status, code)
+	}
+	if status, name := register(map[string]any{"path": b, "name": "app-b"}); status != http.StatusOK || name != "app-b" {
+		t.Fatalf("custom name: got %d %q", status, name)
+	}
+	if status, code := register(map[string]any{"path": b, "name": "App B"}); status != http.StatusBadRequest || code != "BAD_REQUEST" {
+		t.Fatalf("invalid name: want 400, got %d %q", status, code)
+	}
+	if status, name := register(map[string]any{"path": b, "name": "app", "force": true}); status != http.StatusOK || name != "app" {
+		t.Fatalf("forced overwrite: got %d %q", status, name)
+	}
+}
+
+func TestBrowseShowHidden(t *testing.T) {
+	srv := httptest.NewServer(newTestRouter(t))
+	defer srv.Close()
+
+	root := t.TempDir()
+	for name, content := range map[string]string{"compose.yml": "services: {}\n", ".env": "A=1\n", ".env.prod": "A=2\n"} {
+		if err := os.WriteFile(filepath.Join(root, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(root, "data"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	browse := func(query string) map[string]map[string]any {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodGet, srv.URL+"/api/v1/fs/browse?path="+root+query, nil)
+		req.Header.Set("Authorization", "Bearer test-token")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var out struct {
+			Entries []map[string]any `json:"entries"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+			t.Fatal(err)
+		}
+		byName := map[string]map[string]any{}
+		for _, e := range out.Entries {
+			byName[e["name"].(string)] = e
+		}
+		return byName
+	}
+
+	entries := browse("")
+	if _, ok := entries[".env"]; ok || len(entries) != 2 {
+		t.Fatalf("default listing should hide dot-files, got %v", entries)
+	}
+	if size := entries["data"]["size"].(float64); size != 0 {
+		t.Errorf("directory size = %v, want 0", size)
+	}
+
+	entries = browse("&show_hidden=true")
+	if len(entries) != 4 {
+		t.Fatalf("show_hidden listing: got %d entries, want 4", len(entries))
+	}
+	for name, want := range map[string]bool{".env": true, ".env.prod": true, "compose.yml": false} {
+		if got := entries[name]["is_env_file"].(bool); got != want {
+			t.Errorf("%s is_env_file = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestBulkStackActionValidation(t *testing.T) {
+	srv := httptest.NewServer(newTestRouter(t))
+	defer srv.Close()
+
+	for _, body := range []string{
+		`{"action": "explode", "stacks": ["a"]}`,
+		`{"action": "start", "stacks": []}`,
+		`{"action": "start", "stacks": [""]}`,
+	} {
+		req, _ := http.NewRequest(http.MethodPost, srv.URL+"/api/v1/stacks/actions", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer test-token")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", body, resp.StatusCode)
+		}
+	}
+}
+
+type envEntry struct {
+	Key     string `json:"key,omitempty"`
+	Value   string `json:"value,omitempty"`
+	Comment string `json:"comment,omitempty"`
+	Masked  bool   `json:"masked,omitempty"`
+}
+
+func TestBulkStackActionPartialFailure(t *testing.T) {
+	fakeDockerCLI(t, "exit 0") // succeeds at everything
+	srv, _ := newStackTestServer(t, "services: {}\n", nil, api.Options{})
+
+	var out struct {
+		Success           bool
+		Succeeded, Failed int
+		Results           []struct {
+			Stack   string
+			Success bool
+			Code    string
+			Command string
+		}
+	}
+	body := map[string]any{"action": "start", "stacks": []string{"ghost", "app"}}
+	resp := call(t, srv, http.MethodPost, "/api/v1/stacks/actions", body, nil, &out)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+	if out.Success || out.Succeeded != 1 || out.Failed != 1 || len(out.Results) != 2 {
+		t.Fatalf("got %+v, want one success and one failure", out)
+	}
+	// Results keep the request order.
+	if r := out.Results[0]; r.Stack != "ghost" || r.Success || r.Code != "STACK_NOT_FOUND" {
+		t.Errorf("ghost result = %+v, want STACK_NOT_FOUND", r)
+	}
+	if r := out.Results[1]; r.Stack != "app" || !r.Success || !strings.Contains(r.Command, "compose") {
+		t.Errorf("app result = %+v, want success with its command", r)
+	}
+}
+
+func TestStackEnvMasksAndWritesBack(t *testing.T) {
+	srv, dir := newStackTestServer(t, "services: {}\n", nil, api.Options{})
+	envPath := filepath.Join(dir, ".env")
+	original := "# db\nDB_PASSWORD='pa$word'\nPORT=8080 # http\n"
+	if err := os.WriteFile(envPath, []byte(original), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	var masked struct{ Entries []envEntry }
+	call(t, srv, http.MethodGet, "/api/v1/stacks/app/env", nil, nil, &masked)
+	want := []envEntry{{Comment: "db"}, {Key: "DB_PASSWORD", Value: "********", Masked: true}, {Key: "PORT", Value: "8080"}}
+	if !reflect.DeepEqual(masked.Entries, want) {
+		t.Fatalf("masked entries = %+v, want %+v", masked.Entries, want)
+	}
+
+	var revealed struct{ Entries []envEntry }
+	call(t, srv, http.MethodGet, "/api/v1/stacks/app/env?reveal=true", nil, nil, &revealed)
+	if e := revealed.Entries[1]; e.Value != "pa$word" || e.Masked {
+		t.Fatalf("revealed entry = %+v", e)
+	}
+
+	// Writing back the masked listing with one change keeps the secret and
+	// every untouched line as it was.
+	masked.Entries[2].Value = "9090"
+	var out struct{ Code string }
+	if resp := call(t, srv, http.MethodPut, "/api/v1/stacks/app/env", map[string]any{"entries": masked.Entries}, nil, &out); resp.StatusCode != http.StatusOK {
+		t.Fatalf("PUT env: %d %s", resp.StatusCode, out.Code)
+	}
+	data, err := os.ReadFile(envPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "# db\nDB_PASSWORD='pa$word'\nPORT=9090\n"; string(data) != want {
+		t.Errorf("env file = %q, want %q", data, want)
+	}
+
+	// A masked entry for a variable the file doesn't have can't be resolved.
+	unknown := []envEntry{{Key: "API_TOKEN", Value: "********", Masked: true}}
+	if resp := call(t, srv, http.MethodPut, "/api/v1/stacks/app/env", map[string]any{"entries": unknown}, nil, &out); resp.StatusCode != http.StatusBadRequest || out.Code != "INVALID_ENV" {
+		t.Errorf("unknown masked variable: want 400 INVALID_ENV, got %d %s", resp.StatusCode, out.Code)
+	}
+}
+
+func TestStackEnvRejectsSymlinkOutsideStack(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks need privileges on Windows")
+	}
+	srv, dir := newStackTestServer(t, "services: {}\n", nil, api.Options{})
+	secret := filepath.Join(t.TempDir(), "secret")
+	if err := os.WriteFile(secret, []byte("ROOT_PASSWORD=x\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(secret, filepath.Join(dir, ".env")); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, method := range []string{http.MethodGet, http.MethodPut} {
+		var out struct{ Code string }
+		var body any
+		if method == http.MethodPut {
+			body = map[string]any{"entries": []envEntry{{Key: "A", Value: "1"}}}
+		}
+		resp := call(t, srv, method, "/api/v1/stacks/app/env", body, nil, &out)
+		if resp.StatusCode != http.StatusForbidden || out.Code != "FORBIDDEN_PATH" {
+			t.Errorf("%s: want 403 FORBIDDEN_PATH, got %d %s", method, resp.StatusCode, out.Code)
+		}
+	}
+	if data, _ := os.ReadFile(secret); string(data) != "ROOT_PASSWORD=x\n" {
+		t.Errorf("symlink target modified: %q", data)
+	}
+}
+
+func TestFSEndpointsRespectBrowseRoots(t *testing.T) {
+	root, outside := t.TempDir(), t.TempDir()
+	inside := filepath.Join(root, "notes.txt")
+	secret := filepath.Join(outside, "secret")
+	for _, path := range []string{inside, secret} {
+		if err := os.WriteFile(path, []byte("x\n"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	escape := filepath.Join(root, "escape")
+	if err := os.Symlink(outside, escape); err != nil {
+		t.Skipf("symlinks unavailable: %v", err)
+	}
+	srv, _ := newStackTestServer(t, "services: {}\n", nil, api.Options{BrowseRoots: []string{root}})
+
+	if resp := call(t, srv, http.MethodGet, "/api/v1/fs/read?path="+inside, nil, nil, nil); resp.StatusCode != http.StatusOK {
+		t.Fatalf("read inside the root: want 200, got %d", resp.StatusCode)
+	}
+	for _, tt := range []struct {
+		method, path string
+		body         any
+	}{
+		{http.MethodGet, "/api/v1/fs/read?path=" + secret, nil},
+		{http.MethodGet, "/api/v1/fs/read?path=" + filepath.Join(escape, "secret"), nil},
+		{http.MethodPut, "/api/v1/fs/write", map[string]string{"path": secret, "content": "pwned"}},
+		{http.MethodPut, "/api/v1/fs/write", map[string]string{"path": filepath.Join(escape, "new"), "content": "pwned"}},
+		{http.MethodPost, "/api/v1/fs/mkdir", map[string]string{"path": filepath.Join(escape, "dir")}},
+		{http.MethodPost, "/api/v1/fs/rename", map[string]string{"old_path": secret, "new_path": filepath.Join(root, "stolen")}},
+		{http.MethodPost, "/api/v1/fs/rename", map[string]string{"old_path": inside, "new_path": filepath.Join(outside, "moved")}},
+		{http.MethodDelete, "/api/v1/fs/delete?path=" + secret, nil},
+	} {
+		var out struct{ Code string }
+		resp := call(t, srv, tt.method, tt.path, tt.body, nil, &out)
+		if resp.StatusCode != http.StatusForbidden || out.Code != "FORBIDDEN_PATH" {
+			t.Errorf("%s %s %v: want 403 FORBIDDEN_PATH, got %d %s", tt.method, tt.path, tt.body, resp.StatusCode, out.Code)
+		}
+	}
+
+	if data, err := os.ReadFile(secret); err != nil || string(data) != "x\n" {
+		t.Errorf("file outside the root changed: %q, %v", data, err)
+	}
+	if _, err := os.Stat(inside); err != nil {
+		t.Errorf("file inside the root was moved: %v", err)
+	}
+	for _, name := range []string{"new", "dir", "moved"} {
+		if _, err := os.Stat(filepath.Join(outside, name)); err == nil {
+			t.Errorf("%s was created outside the root", name)
+		}
+	}
+}
+
+func TestStackListStaleFollowsComposeFile(t *testing.T) {
+	srv, dir := newStackTestServer(t, "services: {}\n", nil, api.Options{})
+	composePath := filepath.Join(dir, "compose.yaml")
+
+	stale := func() bool {
+		t.Helper()
+		var out struct {
+			Stacks []struct {
+				Name  string
+				Stale bool
+			}
+		}
+		call(t, srv, http.MethodGet, "/api/v1/stacks", nil, nil, &out)
+		if len(out.Stacks) != 1 || out.Stacks[0].Name != "app" {
+			t.Fatalf("want only app listed, got %+v", out.Stacks)
+		}
+		return out.Stacks[0].Stale
+	}
+
+	if stale() {
+		t.Error("stack with its compose file listed as stale")
+	}
+	if err := os.Rename(composePath, composePath+".moved"); err != nil {
+		t.Fatal(err)
+	}
+	if !stale() {
+		t.Error("stack without its compose file not listed as stale")
+	}
+	// Once the file is back the stack is usable again and listed as such.
+	if err := os.Rename(composePath+".moved", composePath); err != nil {
+		t.Fatal(err)
+	}
+	if stale() {
+		t.Error("stack still listed as stale after its compose file came back")
+	}
+}
+
+func TestStackActionComposeMissing(t *testing.T) {
+	srv, dir := newStackTestServer(t, "services: {}\n", nil, api.Options{})
+	if err := os.Remove(filepath.Join(dir, "compose.yaml")); err != nil {
+		t.Fatal(err)
+	}
+
+	var out struct{ Code string }
+	resp := call(t, srv, http.MethodPost, "/api/v1/stacks/app/start", nil, nil, &out)
+	if resp.StatusCode != http.StatusUnprocessableEntity || out.Code != "COMPOSE_MISSING" {
+		t.Fatalf("want 422 COMPOSE_MISSING, got %d %s", resp.StatusCode, out.Code)
+	}
+}
+
+func TestRemoveContainer(t *testing.T) {
+	inspect := func(running bool) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprintf(w, `{"Id":"abc","State":{"Running":%v}}`, running)
+		}
+	}
+	remove := func(status int, removed *bool) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			*removed = true
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(status)
+			w.Write([]byte(`{"message":"daemon says no"}`))
+		}
+	}
+
+	tests := []struct {
+		name        string
+		query       string
+		running     bool
+		status      int // the daemon's answer to the remove
+		want        int
+		wantCode    string
+		wantRemoved bool
+	}{
+		{"stopped", "", false, http.StatusNoContent, http.StatusOK, "", true},
+		{"running", "", true, http.StatusNoContent, http.StatusConflict, "CONTAINER_RUNNING", false},
+		{"running with force", "?force=true", true, http.StatusNoContent, http.StatusOK, "", true},
+		// Started between the check and the remove: the daemon refuses.
+		{"started meanwhile", "", false, http.StatusConflict, http.StatusConflict, "CONTAINER_RUNNING", true},
+		{"gone meanwhile", "", false, http.StatusNotFound, http.StatusNotFound, "CONTAINER_NOT_FOUND", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var removed bool
+			srv := newDockerTestServer(t, map[string]http.HandlerFunc{
+				"/containers/abc/json": inspect(tt.running),
+				"/containers/abc":      remove(tt.status, &removed),
+			})
+
+			var out struct{ Code string }
+			resp := call(t, srv, http.MethodDelete, "/api/v1/containers/abc"+tt.query, nil, nil, &out)
+			if resp.StatusCode != tt.want || out.Code != tt.wantCode {
+				t.Errorf("got %d %q, want %d %q", resp.StatusCode, out.Code, tt.want, tt.wantCode)
+			}
+			if removed != tt.wantRemoved {
+				t.Errorf("daemon remove called = %v, want %v", removed, tt.wantRemoved)
+			}
+		})
+	}
+}
+
+func TestStackDetailsInspectsEachContainer(t *testing.T) {
+	services := []string{"web", "db", "cache"}
+	routes := map[string]http.HandlerFunc{
+		"/containers/json": func(w http.ResponseWriter, r *http.Request) {
+			var list []map[string]any
+			for _, svc := range services {
+				list = append(list, map[string]any{
+					"Id":     svc + "-0123456789abcdef",
+					"Names":  []string{"/app-" + svc + "-1"},
+					"Labels": map[string]string{"com.docker.compose.project": "app", "com.docker.compose.service": svc},
+					"State":  "running",
+				})
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(list)
+		},
+	}
+	for _, svc := range services {
+		routes["/containers/"+svc+"-0123456789abcdef/json"] = func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprintf(w, `{"Id":%q,"HostConfig":{"RestartPolicy":{"Name":"policy-%s"}},"State":{"Running":true}}`, svc, svc)
+		}
+	}
+	srv, _ := newStackTestServer(t, "services: {}\n", routes, api.Options{})
+
+	var out struct {
+		Containers []struct {
+			Service       string
+			RestartPolicy string `json:"restart_policy"`
+		}
+	}
+	if resp := call(t, srv, http.MethodGet, "/api/v1/stacks/app", nil, nil, &out); resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+	if len(out.Containers) != len(services) {
+		t.Fatalf("got %d containers, want %d", len(out.Containers), len(services))
+	}
+	for _, c := range out.Containers {
+		if c.RestartPolicy != "policy-"+c.Service {
+			t.Errorf("%s has restart policy %q, want policy-%s", c.Service, c.RestartPolicy, c.Service)
+		}
+	}
+}
+
+func TestRestoreComposeBackup(t *testing.T) {
+	// Validation fails for any file mentioning "broken"; $3 is the -f path.
+	fakeDockerCLI(t, `if grep -q broken "$3"; then echo "services.web: broken" >&2; exit 1; fi`)
+
+	type result struct {
+		Success bool
+		Code    string
+		Error   string
+		Content string
+	}
+	restore := func(srv *httptest.Server, path string) (int, result) {
+		var out result
+		resp := call(t, srv, http.MethodPost, "/api/v1/stacks/app/compose"+path, nil, nil, &out)
+		return resp.StatusCode, out
+	}
+
+	t.Run("no backup", func(t *testing.T) {
+		srv, _ := newStackTestServer(t, "services: {}\n", nil, api.Options{})
+		for _, path := range []string{"/restore", "/backups/3/restore"} {
+			if status, out := restore(srv, path); status != http.StatusNotFound || out.Code != "NO_BACKUP" {
+				t.Errorf("%s: want 404 NO_BACKUP, got %d %s", path, status, out.Code)
+			}
+		}
+	})
+
+	t.Run("backup fails validation", func(t *testing.T) {
+		srv, dir := newStackTestServer(t, "services: {}\n", nil, api.Options{})
+		composePath := filepath.Join(dir, "compose.yaml")
+		if err := os.WriteFile(composePath+".bak.1", []byte("services: broken\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		status, out := restore(srv, "/restore")
+		if status != http.StatusOK || out.Success || !strings.Contains(out.Error, "broken") {
+			t.Fatalf("want a failed validation, got %d %+v", status, out)
+		}
+		if data, _ := os.ReadFile(composePath); string(data) != "services: {}\n" {
+			t.Errorf("compose file changed to %q", data)
+		}
+	})
+
+	t.Run("legacy .bak", func(t *testing.T) {
+		srv, dir := newStackTestServer(t, "services: {}\n", nil, api.Options{})
+		composePath := filepath.Join(dir, "compose.yaml")
+		if err := os.WriteFile(composePath+".bak", []byte("services: {old: {}}\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		status, out := restore(srv, "/restore")
+		if status != http.StatusOK || !out.Success || out.Content != "services: {old: {}}\n" {
+			t.Fatalf("want the legacy backup restored, got %d %+v", status, out)
+		}
+		if data, _ := os.ReadFile(composePath); string(data) != out.Content {
+			t.Errorf("compose file = %q, want the backup", data)
+		}
+		// The replaced content is itself backed up, so the restore can be undone.
+		if data, _ := os.ReadFile(composePath + ".bak.1"); string(data) != "services: {}\n" {
+			t.Errorf("backup 1 = %q, want the replaced content", data)
+		}
+	})
+}
+
+func TestPruneAll(t *testing.T) {
+	reply := func(status int, body string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(status)
+			w.Write([]byte(body))
+		}
+	}
+	routes := map[string]http.HandlerFunc{
+		"/images/prune":     reply(http.StatusOK, `{"ImagesDeleted":[{"Deleted":"sha256:aaa"},{"Untagged":"old:1"}],"SpaceReclaimed":100}`),
+		"/build/prune":      reply(http.StatusOK, `{"CachesDeleted":["c1","c2"],"SpaceReclaimed":50}`),
+		"/volumes/prune":    reply(http.StatusInternalServerError, `{"message":"volume store locked"}`),
+		"/networks/prune":   reply(http.StatusOK, `{"NetworksDeleted":["unexpected"]}`),
+		"/containers/prune": reply(http.StatusOK, `{}`),
+	}
+	srv := newDockerTestServer(t, routes)
+
+	var out struct {
+		Success        bool
+		Results        map[string]docker.PruneResult
+		Errors         map[string]string
+		SpaceReclaimed int64 `json:"space_reclaimed"`
+	}
+	body := map[string]bool{"images": true, "volumes": true, "build_cache": true}
+	if resp := call(t, srv, http.MethodPost, "/api/v1/docker/prune", body, nil, &out); resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+
+	// One failed kind fails the whole call but not the others; networks
+	// weren't asked for.
+	if out.Success {
+		t.Error("success = true despite the volume prune failing")
+	}
+	if got := out.Results["images"]; got.Count != 1 || got.SpaceReclaimed != 100 {
+		t.Errorf("images result = %+v, want 1 item and 100 bytes", got)
+	}
+	if got := out.Results["build_cache"]; got.Count != 2 || got.SpaceReclaimed != 50 {
+		t.Errorf("build_cache result = %+v, want 2 items and 50 bytes", got)
+	}
+	if _, ok := out.Results["networks"]; ok || len(out.Results) != 2 {
+		t.Errorf("results = %+v, want only images and build_cache", out.Results)
+	}
+	if len(out.Errors) != 1 || !strings.Contains(out.Errors["volumes"], "volume store locked") {
+		t.Errorf("errors = %+v, want the volume failure", out.Errors)
+	}
+	if out.SpaceReclaimed != 150 {
+		t.Errorf("space_reclaimed = %d, want 150", out.SpaceReclaimed)
+	}
+
+	var bad struct{ Code string }
+	if resp := call(t, srv, http.MethodPost, "/api/v1/docker/prune", map[string]bool{}, nil, &bad); resp.StatusCode != http.StatusBadRequest || bad.Code != "BAD_REQUEST" {
+		t.Errorf("empty selection: want 400 BAD_REQUEST, got %d %s", resp.StatusCode, bad.Code)
+	}
+}
+
+// lockedBuffer is a bytes.Buffer safe for the server's goroutines to log
+// into while the test reads it.
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestCreateFailureLogsRequestID(t *testing.T) {
+	var logs lockedBuffer
+	prev := slog.Default()
+	slog.SetDefault(slog.New(requestid.LogHandler{Handler: slog.NewJSONHandler(&logs, nil)}))
+	t.Cleanup(func() { slog.SetDefault(prev) })
+
+	srv := newDockerTestServer(t, map[string]http.HandlerFunc{
+		"/volumes/data": func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, `{"message":"no such volume"}`, http.StatusNotFound)
+		},
+		"/volumes/create": func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, `{"message":"disk full"}`, http.StatusInternalServerError)
+		},
+	})
+	header := http.Header{requestid.Header: {"create-vol-1"}}
+	if resp := call(t, srv, http.MethodPost, "/api/v1/docker/volumes", map[string]any{"name": "data"}, header, nil); resp.StatusCode != http.StatusInternalServerError {
+		t.Fatalf("want 500, got %d", resp.StatusCode)
+	}
+
+	for _, line := range strings.Split(logs.String(), "\n") {
+		if strings.Contains(line, `"msg":"failed to create volume"`) {
+			if !strings.Contains(line, `"request_id":"create-vol-1"`) {
+				t.Errorf("failure logged without the request id: %s", line)
+			}
+			return
+		}
+	}
+	t.Errorf("no failure logged:\n%s", logs.String())
+}
+
+func TestCreateVolumeAndNetwork(t *testing.T) {
+	reply := func(status int, body string, called *bool) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if called != nil {
+				*called = true
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(status)
+			w.Write([]byte(body))
+		}
+	}
+
+	tests := []struct {
+		name       string
+		path       string
+		body       map[string]any
+		routes     func(created *bool) map[string]http.HandlerFunc
+		want       int
+		wantCode   string
+		wantCreate bool
+	}{
+		{"new volume", "/api/v1/docker/volumes", map[string]any{"name": "data"},
+			func(created *bool) map[string]http.HandlerFunc {
+				return map[string]http.HandlerFunc{
+					"/volumes/data":   reply(http.StatusNotFound, `{"message":"no such volume"}`, nil),
+					"/volumes/create": reply(http.StatusCreated, `{"Name":"data","Driver":"local"}`, created),
+				}
+			}, http.StatusCreated, "", true},
+		// Docker would quietly return the existing volume.
+		{"existing volume", "/api/v1/docker/volumes", map[string]any{"name": "data"},
+			func(created *bool) map[string]http.HandlerFunc {
+				return map[string]http.HandlerFunc{
+					"/volumes/data":   reply(http.StatusOK, `{"Name":"data","Driver":"local"}`, nil),
+					"/volumes/create": reply(http.StatusCreated, `{"Name":"data","Driver":"local"}`, created),
+				}
+			}, http.StatusConflict, "ALREADY_EXISTS", false},
+		{"existing network", "/api/v1/docker/networks", map[string]any{"name": "backend"},
+			func(created *bool) map[string]http.HandlerFunc {
+				return map[string]http.HandlerFunc{
+					"/networks/create": reply(http.StatusConflict, `{"message":"network with name backend already exists"}`, created),
+				}
+			}, http.StatusConflict, "ALREADY_EXISTS", true},
+		{"invalid network", "/api/v1/docker/networks", map[string]any{"name": "backend", "driver": "nope"},
+			func(created *bool) map[string]http.HandlerFunc {
+				return map[string]http.HandlerFunc{
+					"/networks/create": reply(http.StatusBadRequest, `{"message":"plugin not found"}`, created),
+				}
+			}, http.StatusBadRequest, "BAD_REQUEST", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var created bool
+			srv := newDockerTestServer(t, tt.routes(&created))
+
+			var out struct{ Code string }
+			resp := call(t, srv, http.MethodPost, tt.path, tt.body, nil, &out)
+			if resp.StatusCode != tt.want || out.Code != tt.wantCode {
+				t.Errorf("got %d %q, want %d %q", resp.StatusCode, out.Code, tt.want, tt.wantCode)
+			}
+			if created != tt.wantCreate {
+				t.Errorf("daemon create called = %v, want %v", created, tt.wantCreate)
+			}
+		})
+	}
+}
+
+func TestCreateNetworkRejectsBuiltinNames(t *testing.T) {
+	var created bool
+	srv := newDockerTestServer(t, map[string]http.HandlerFunc{
+		"/networks/create": func(w http.ResponseWriter, r *http.Request) { created = true },
+	})
+
+	for _, name := range []string{"bridge", "host", "none"} {
+		var out struct{ Code string }
+		resp := call(t, srv, http.MethodPost, "/api/v1/docker/networks", map[string]any{"name": name}, nil, &out)
+		if resp.StatusCode != http.StatusBadRequest || out.Code != "BAD_REQUEST" {
+			t.Errorf("%s: want 400 BAD_REQUEST, got %d %s", name, resp.StatusCode, out.Code)
+		}
+	}
+	if created {
+		t.Error("a builtin network name reached the daemon")
+	}
+}
+
+func TestUpdateComposeFileIfMatch(t *testing.T) {
+	fakeDockerCLI(t, "exit 0") // every file validates
+	srv, dir := newStackTestServer(t, "services: {}\n", nil, api.Options{})
+	composePath := filepath.Join(dir, "compose.yaml")
+
+	loaded := call(t, srv, http.MethodGet, "/api/v1/stacks/app/compose", nil, nil, nil).Header.Get("ETag")
+	if loaded == "" {
+		t.Fatal("GET compose sent no ETag")
+	}
+
+	// Someone edits the file after the editor loaded it.
+	if err := os.WriteFile(composePath, []byte("services: {web: {}}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	put := func(etag string) (*http.Response, string) {
+		var out struct{ Code string }
+		body := map[string]string{"content": "services: {api: {}}\n"}
+		resp := call(t, srv, http.MethodPut, "/api/v1/stacks/app/compose", body, http.Header{"If-Match": {etag}}, &out)
+		return resp, out.Code
+	}
+
+	resp, code := put(loaded)
+	if resp.StatusCode != http.StatusPreconditionFailed || code != "PRECONDITION_FAILED" {
+		t.Fatalf("stale If-Match: want 412 PRECONDITION_FAILED, got %d %s", resp.StatusCode, code)
+	}
+	current := resp.Header.Get("ETag")
+	if current == "" || current == loaded {
+		t.Fatalf("412 carries ETag %q, want the current file's", current)
+	}
+	if data, _ := os.ReadFile(composePath); string(data) != "services: {web: {}}\n" {
+		t.Fatalf("compose file overwritten: %q", data)
+	}
+
+	// If-Match needs a strong match.
+	if resp, code := put("W/" + current); resp.StatusCode != http.StatusPreconditionFailed {
+		t.Errorf("weak If-Match: want 412, got %d %s", resp.StatusCode, code)
+	}
+	if resp, code := put(current); resp.StatusCode != http.StatusOK {
+		t.Errorf("current If-Match: want 200, got %d %s", resp.StatusCode, code)
+	}
+	if data, _ := os.ReadFile(composePath); string(data) != "services: {api: {}}\n" {
+		t.Errorf("compose file = %q after a matching save", data)
+	}
 }
@@ -0,0 +1,418 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/driversti/hola/internal/api/respond"
+	"github.com/driversti/hola/internal/docker"
+	"github.com/driversti/hola/internal/maintenance"
+	"github.com/driversti/hola/internal/metrics"
+	"github.com/driversti/hola/internal/update"
+)
+
+// schema is an OpenAPI schema object.
+type schema = map[string]any
+
+func ref(name string) schema      { return schema{"$ref": "#/components/schemas/" + name} }
+func arrayOf(items schema) schema { return schema{"type": "array", "items": items} }
+
+// object builds an inline object schema from name/schema pairs.
+func object(props ...any) schema {
+	p := make(map[string]any, len(props)/2)
+	for i := 0; i+1 < len(props); i += 2 {
+		p[props[i].(string)] = props[i+1]
+	}
+	return schema{"type": "object", "properties": p}
+}
+
+var (
+	strSchema  = schema{"type": "string"}
+	intSchema  = schema{"type": "integer"}
+	boolSchema = schema{"type": "boolean"}
+	anyObject  = schema{"type": "object"}
+)
+
+// apiParam is a query parameter of a route.
+type apiParam struct {
+	name, typ, description string
+}
+
+// apiRoute documents one route registered in NewRouter. A nil response
+// means a bare action result ({"success": ..., "message"/"error": ...}).
+type apiRoute struct {
+	method, path, tag, summary string
+	query                      []apiParam
+	body                       schema
+	response                   schema
+	public                     bool
+	stream                     bool // can answer with text/event-stream
+}
+
+// componentTypes are the Go types published under components/schemas,
+// keyed by schema name. Their schemas are derived from the json tags, so
+// they follow the code.
+var componentTypes = map[string]reflect.Type{
+	"ErrorResponse":      reflect.TypeFor[respond.ErrorResponse](),
+	"Stack":              reflect.TypeFor[docker.Stack](),
+	"StackDetail":        reflect.TypeFor[docker.StackDetail](),
+	"ContainerInfo":      reflect.TypeFor[docker.ContainerInfo](),
+	"PortMapping":        reflect.TypeFor[docker.PortMapping](),
+	"ComposeFile":        reflect.TypeFor[docker.ComposeFile](),
+	"LogEntry":           reflect.TypeFor[docker.LogEntry](),
+	"ContainerStats":     reflect.TypeFor[docker.ContainerStatsSnapshot](),
+	"ExecResult":         reflect.TypeFor[docker.ExecResult](),
+	"ContainerProcesses": reflect.TypeFor[docker.ContainerProcesses](),
+	"ContainerLimits":    reflect.TypeFor[docker.ContainerLimits](),
+	"ImageInfo":          reflect.TypeFor[docker.ImageInfo](),
+	"VolumeInfo":         reflect.TypeFor[docker.VolumeInfo](),
+	"NetworkInfo":        reflect.TypeFor[docker.NetworkInfo](),
+	"PruneResult":        reflect.TypeFor[docker.PruneResult](),
+	"SystemMetrics":      reflect.TypeFor[metrics.SystemMetrics](),
+	"UpdateCheck":        reflect.TypeFor[update.UpdateCheck](),
+	"MaintenanceStatus":  reflect.TypeFor[maintenance.Status](),
+	"AgentConfig":        reflect.TypeFor[AgentConfig](),
+	"FSEntry":            reflect.TypeFor[fsEntry](),
+	"EnvEntry":           reflect.TypeFor[envEntry](),
+	"BulkActionResult":   reflect.TypeFor[bulkResult](),
+	"SearchResult":       reflect.TypeFor[searchResult](),
+}
+
+var actionResult = object("success", boolSchema, "message", strSchema, "error", strSchema, "command", strSchema)
+
+var logsQuery = []apiParam{
+	{"lines", "integer", "Number of lines from the end"},
+	{"since", "string", "RFC3339 time or Unix seconds"},
+	{"stream", "string", "stdout, stderr or both"},
+	{"grep", "string", "Only lines containing this text"},
+	{"regex", "boolean", "Treat grep as a regular expression"},
+}
+
+var dryRunQuery = []apiParam{{"dry_run", "boolean", "Report what would be removed without removing it"}}
+
+var pruneFilterQuery = append(dryRunQuery[:1:1],
+	apiParam{"until", "string", "Only prune items older than this Go duration, e.g. 24h"},
+	apiParam{"label", "string", "key or key=value; repeatable, all must match"})
+
+var forceQuery = []apiParam{{"force", "boolean", "Remove even when in use"}}
+
+// apiRoutes lists every route in NewRouter. TestOpenAPICoversRouter keeps
+// the two in step.
+var apiRoutes = []apiRoute{
+	// System
+	{method: "GET", path: "/api/v1/health", tag: "system", summary: "Liveness check", public: true,
+		query: []apiParam{{"deep", "boolean", "Also ping the Docker daemon"}}, response: object("status", strSchema)},
+	{method: "GET", path: "/api/v1/ready", tag: "system", summary: "Readiness check; 503 when Docker is unreachable", public: true,
+		response: object("status", strSchema, "docker", strSchema, "docker_version", strSchema)},
+	{method: "GET", path: "/api/v1/openapi.json", tag: "system", summary: "This document", public: true, response: anyObject},
+	{method: "GET", path: "/api/v1/agent/info", tag: "agent", summary: "Agent and host information",
+		query: []apiParam{{"refresh", "boolean", "Ask the daemon again instead of using the cached Docker details"}}, response: anyObject},
+	{method: "GET", path: "/api/v1/agent/version", tag: "agent", summary: "Agent version",
+		query: []apiParam{{"compare", "string", "Version to compare against"}}, response: anyObject},
+	{method: "GET", path: "/api/v1/agent/config", tag: "agent", summary: "Effective configuration, secrets redacted", response: ref("AgentConfig")},
+	{method: "GET", path: "/api/v1/agent/maintenance", tag: "agent", summary: "Maintenance mode status", response: ref("MaintenanceStatus")},
+	{method: "POST", path: "/api/v1/agent/maintenance", tag: "agent", summary: "Enable or disable maintenance mode",
+		body: object("enabled", boolSchema, "duration", strSchema, "reason", strSchema), response: ref("MaintenanceStatus")},
+	{method: "GET", path: "/api/v1/system/metrics", tag: "system", summary: "Host CPU, memory, disk and network metrics",
+		query: []apiParam{{"all", "boolean", "Include every mounted filesystem"}}, response: ref("SystemMetrics")},
+	{method: "GET", path: "/api/v1/system/metrics/prometheus", tag: "system", summary: "Metrics in Prometheus text format"},
+	{method: "GET", path: "/api/v1/agent/update", tag: "agent", summary: "Check for a newer release", response: ref("UpdateCheck")},
+	{method: "POST", path: "/api/v1/agent/update", tag: "agent", summary: "Download and install the latest release", stream: true},
+	{method: "GET", path: "/api/v1/agent/rollback", tag: "agent", summary: "Whether a previous binary is available", response: anyObject},
+	{method: "POST", path: "/api/v1/agent/rollback", tag: "agent", summary: "Restore the previous binary"},
+
+	// Filesystem
+	{method: "GET", path: "/api/v1/fs/browse", tag: "filesystem", summary: "List a directory",
+		query:    []apiParam{{"path", "string", "Absolute directory path"}, {"show_hidden", "boolean", "Include dot-files"}},
+		response: object("path", strSchema, "parent", strSchema, "entries", arrayOf(ref("FSEntry")))},
+	{method: "GET", path: "/api/v1/fs/read", tag: "filesystem", summary: "Read a text file",
+		query: []apiParam{{"path", "string", "Absolute file path"}}, response: object("path", strSchema, "content", strSchema, "size", intSchema)},
+	{method: "PUT", path: "/api/v1/fs/write", tag: "filesystem", summary: "Write a text file", body: object("path", strSchema, "content", strSchema)},
+	{method: "POST", path: "/api/v1/fs/mkdir", tag: "filesystem", summary: "Create a directory", body: object("path", strSchema)},
+	{method: "POST", path: "/api/v1/fs/rename", tag: "filesystem", summary: "Rename or move a path", body: object("old_path", strSchema, "new_path", strSchema)},
+	{method: "DELETE", path: "/api/v1/fs/delete", tag: "filesystem", summary: "Delete a file or directory",
+		query: []apiParam{{"path", "string", "Absolute path"}}},
+
+	// Stacks
+	{method: "GET", path: "/api/v1/stacks", tag: "stacks", summary: "List running and registered stacks",
+		query:    []apiParam{{"source", "string", "registry, running or all"}, {"status", "string", "running, stopped, partial or down; repeatable"}},
+		response: object("stacks", arrayOf(ref("Stack")))},
+	{method: "GET", path: "/api/v1/stacks/{name}", tag: "stacks", summary: "Stack details and containers", response: ref("StackDetail")},
+	{method: "GET", path: "/api/v1/search", tag: "stacks", summary: "Search stack, service and container names and image references",
+		query:    []apiParam{{"q", "string", "Case-insensitive substring, or a regular expression with regex=true"}, {"regex", "boolean", "Treat q as a regular expression"}},
+		response: ref("SearchResult")},
+	{method: "GET", path: "/api/v1/stacks/{name}/compose", tag: "stacks", summary: "Compose file content; sets ETag", response: ref("ComposeFile")},
+	{method: "PUT", path: "/api/v1/stacks/{name}/compose", tag: "stacks", summary: "Validate and save the compose file; honours If-Match",
+		body: object("content", strSchema)},
+	{method: "GET", path: "/api/v1/stacks/{name}/compose/backups", tag: "stacks", summary: "List compose file backups", response: anyObject},
+	{method: "POST", path: "/api/v1/stacks/{name}/compose/backups/{index}/restore", tag: "stacks", summary: "Restore a compose file backup"},
+	{method: "POST", path: "/api/v1/stacks/{name}/compose/restore", tag: "stacks", summary: "Restore the newest compose file backup"},
+	{method: "POST", path: "/api/v1/compose/validate", tag: "stacks", summary: "Validate compose content without saving it",
+		body:     object("content", strSchema),
+		response: object("valid", boolSchema, "error", strSchema, "warnings", arrayOf(strSchema), "normalized", strSchema)},
+	{method: "GET", path: "/api/v1/stacks/{name}/env", tag: "stacks", summary: "The stack's .env file; secrets masked",
+		query:    []apiParam{{"reveal", "boolean", "Return secret values unmasked"}},
+		response: object("path", strSchema, "exists", boolSchema, "entries", arrayOf(ref("EnvEntry")))},
+	{method: "PUT", path: "/api/v1/stacks/{name}/env", tag: "stacks", summary: "Write the stack's .env file",
+		body: object("entries", arrayOf(ref("EnvEntry")))},
+	{method: "GET", path: "/api/v1/stacks/{name}/logs", tag: "stacks", summary: "Merged logs of every container in the stack",
+		query:    logsQuery,
+		response: object("stack", strSchema, "containers", arrayOf(strSchema), "lines", arrayOf(ref("LogEntry")), "truncated", boolSchema)},
+	{method: "GET", path: "/api/v1/stacks/{name}/services/{service}/logs", tag: "stacks", summary: "Merged logs of a service's containers",
+		query:    logsQuery,
+		response: object("stack", strSchema, "service", strSchema, "containers", arrayOf(strSchema), "lines", arrayOf(ref("LogEntry")), "truncated", boolSchema)},
+	{method: "POST", path: "/api/v1/stacks/register", tag: "stacks", summary: "Register a stack directory",
+		body: object("path", strSchema, "name", strSchema, "force", boolSchema), response: anyObject},
+	{method: "DELETE", path: "/api/v1/stacks/{name}/unregister", tag: "stacks", summary: "Unregister a stack"},
+	{method: "POST", path: "/api/v1/stacks/prune-stale", tag: "stacks", summary: "Unregister stacks whose compose file is gone",
+		response: object("success", boolSchema, "removed", arrayOf(strSchema))},
+	{method: "POST", path: "/api/v1/stacks/actions", tag: "stacks", summary: "Run an action on several stacks",
+		body:     object("action", strSchema, "stacks", arrayOf(strSchema), "all", boolSchema),
+		response: object("success", boolSchema, "action", strSchema, "succeeded", intSchema, "failed", intSchema, "results", arrayOf(ref("BulkActionResult")))},
+	{method: "POST", path: "/api/v1/stacks/{name}/start", tag: "stacks", summary: "docker compose up -d", body: object("profiles", arrayOf(strSchema), "env_file", strSchema)},
+	{method: "POST", path: "/api/v1/stacks/{name}/stop", tag: "stacks", summary: "docker compose stop", body: object("profiles", arrayOf(strSchema), "env_file", strSchema)},
+	{method: "POST", path: "/api/v1/stacks/{name}/restart", tag: "stacks", summary: "docker compose restart",
+		query: []apiParam{{"ordered", "boolean", "Restart services one by one in dependency order"}, {"delay_seconds", "integer", "Pause between ordered restarts"}},
+		body:  object("profiles", arrayOf(strSchema), "env_file", strSchema)},
+	{method: "POST", path: "/api/v1/stacks/{name}/down", tag: "stacks", summary: "docker compose down", body: object("profiles", arrayOf(strSchema), "env_file", strSchema)},
+	{method: "POST", path: "/api/v1/stacks/{name}/pull", tag: "stacks", summary: "docker compose pull", body: object("profiles", arrayOf(strSchema), "env_file", strSchema)},
+	{method: "GET", path: "/api/v1/stacks/{name}/{action}/stream", tag: "stacks", summary: "Run a stack action, streaming its output as Server-Sent Events",
+		query: []apiParam{{"profile", "string", "Compose profile"}, {"env_file", "string", "Env file inside the stack directory"}}, stream: true},
+
+	// Containers
+	{method: "GET", path: "/api/v1/containers", tag: "containers", summary: "List containers",
+		query: []apiParam{
+			{"state", "string", "Only containers in this state"},
+			{"stack", "string", "Only containers of this stack"},
+			{"name", "string", "Case-insensitive name substring"},
+			{"limit", "integer", "Page size, default 100, at most 1000"},
+			{"offset", "integer", "Items to skip"},
+		},
+		response: object("containers", arrayOf(ref("ContainerInfo")), "total", intSchema, "limit", intSchema, "offset", intSchema)},
+	{method: "GET", path: "/api/v1/containers/{id}/inspect", tag: "containers", summary: "Raw Docker inspect result; secret-looking env values masked",
+		query: []apiParam{{"reveal", "boolean", "Return env values unmasked"}}, response: anyObject},
+	{method: "GET", path: "/api/v1/containers/{id}/top", tag: "containers", summary: "Processes running in the container; 409 NOT_RUNNING when stopped",
+		query: []apiParam{{"ps_args", "string", "Arguments passed to ps, default -ef"}}, response: ref("ContainerProcesses")},
+	{method: "GET", path: "/api/v1/containers/{id}/limits", tag: "containers", summary: "Configured CPU and memory limits; null means unlimited",
+		response: ref("ContainerLimits")},
+	{method: "GET", path: "/api/v1/containers/{id}/logs", tag: "containers", summary: "Container logs",
+		query: append(logsQuery[:len(logsQuery):len(logsQuery)],
+			apiParam{"context_before", "integer", "Lines to include before each grep match"},
+			apiParam{"context_after", "integer", "Lines to include after each grep match"},
+			apiParam{"max_bytes", "integer", "Keep only the newest lines within this many bytes, default and maximum 16 MiB"}),
+		response: object("container_id", strSchema, "container_name", strSchema, "lines", arrayOf(ref("LogEntry")), "truncated", boolSchema)},
+	{method: "GET", path: "/api/v1/containers/{id}/stats", tag: "containers", summary: "One-shot resource usage", response: ref("ContainerStats")},
+	{method: "DELETE", path: "/api/v1/containers/{id}", tag: "containers", summary: "Remove a container; 409 CONTAINER_RUNNING unless forced",
+		query: []apiParam{{"force", "boolean", "Kill and remove a running container"}, {"volumes", "boolean", "Also remove its anonymous volumes"}}},
+	{method: "POST", path: "/api/v1/containers/{id}/start", tag: "containers", summary: "Start a container"},
+	{method: "POST", path: "/api/v1/containers/{id}/stop", tag: "containers", summary: "Stop a container"},
+	{method: "POST", path: "/api/v1/containers/{id}/restart", tag: "containers", summary: "Restart a container"},
+	{method: "POST", path: "/api/v1/containers/{id}/exec", tag: "containers", summary: "Run a command in a container",
+		body: object("cmd", arrayOf(strSchema), "tty", boolSchema), response: ref("ExecResult")},
+
+	// Docker resources
+	{method: "GET", path: "/api/v1/docker/disk-usage", tag: "docker", summary: "Disk used by images, containers, volumes and build cache", response: anyObject},
+	{method: "GET", path: "/api/v1/docker/images", tag: "docker", summary: "List images",
+		query: []apiParam{
+			{"dangling", "boolean", "Only untagged (true) or tagged (false) images"},
+			{"in_use", "boolean", "Only images used (true) or unused (false) by a container"},
+			{"limit", "integer", "Page size, default 100, at most 1000; 0 returns every image"},
+			{"offset", "integer", "Items to skip"},
+		},
+		response: object("images", arrayOf(ref("ImageInfo")), "total", intSchema, "limit", intSchema, "offset", intSchema)},
+	{method: "DELETE", path: "/api/v1/docker/images/{id}", tag: "docker", summary: "Remove an image", query: forceQuery},
+	{method: "POST", path: "/api/v1/docker/images/pull", tag: "docker", summary: "Pull an image; credentials in X-Registry-Auth",
+		body: object("image", strSchema), stream: true},
+	{method: "POST", path: "/api/v1/docker/images/prune", tag: "docker", summary: "Remove unused images", query: pruneFilterQuery, response: ref("PruneResult")},
+	{method: "GET", path: "/api/v1/docker/volumes", tag: "docker", summary: "List volumes", response: object("volumes", arrayOf(ref("VolumeInfo")))},
+	{method: "POST", path: "/api/v1/docker/volumes", tag: "docker", summary: "Create a volume",
+		body: object("name", strSchema, "driver", strSchema, "labels", schema{"type": "object", "additionalProperties": strSchema}), response: ref("VolumeInfo")},
+	{method: "DELETE", path: "/api/v1/docker/volumes/{name}", tag: "docker", summary: "Remove a volume", query: forceQuery},
+	{method: "POST", path: "/api/v1/docker/volumes/prune", tag: "docker", summary: "Remove unused volumes", query: dryRunQuery, response: ref("PruneResult")},
+	{method: "GET", path: "/api/v1/docker/networks", tag: "docker", summary: "List networks", response: object("networks", arrayOf(ref("NetworkInfo")))},
+	{method: "POST", path: "/api/v1/docker/networks", tag: "docker", summary: "Create a network",
+		body: object("name", strSchema, "driver", strSchema, "internal", boolSchema, "labels", schema{"type": "object", "additionalProperties": strSchema}), response: ref("NetworkInfo")},
+	{method: "DELETE", path: "/api/v1/docker/networks/{id}", tag: "docker", summary: "Remove a network"},
+	{method: "POST", path: "/api/v1/docker/networks/prune", tag: "docker", summary: "Remove unused networks", query: dryRunQuery, response: ref("PruneResult")},
+	{method: "POST", path: "/api/v1/docker/buildcache/prune", tag: "docker", summary: "Remove build cache; label filters are rejected", query: pruneFilterQuery, response: ref("PruneResult")},
+	{method: "POST", path: "/api/v1/docker/prune", tag: "docker", summary: "Prune images, volumes, networks and build cache in one call",
+		body: object("images", boolSchema, "volumes", boolSchema, "networks", boolSchema, "build_cache", boolSchema, "dry_run", boolSchema), response: anyObject},
+
+	// WebSocket
+	{method: "GET", path: "/api/v1/ws", tag: "websocket", summary: "WebSocket for metrics, events, logs, stats and exec streams"},
+}
+
+var pathParamRe = regexp.MustCompile(`\{([a-z_]+)\}`)
+
+// openAPISpec builds the OpenAPI 3 document for the agent's API.
+func openAPISpec(version string) map[string]any {
+	paths := make(map[string]map[string]any)
+	for _, rt := range apiRoutes {
+		op := map[string]any{
+			"summary":   rt.summary,
+			"tags":      []string{rt.tag},
+			"responses": responsesFor(rt),
+		}
+		var params []map[string]any
+		for _, m := range pathParamRe.FindAllStringSubmatch(rt.path, -1) {
+			params = append(params, map[string]any{"name": m[1], "in": "path", "required": true, "schema": strSchema})
+		}
+		for _, q := range rt.query {
+			params = append(params, map[string]any{"name": q.name, "in": "query", "description": q.description, "schema": schema{"type": q.typ}})
+		}
+		if params != nil {
+			op["parameters"] = params
+		}
+		if rt.body != nil {
+			op["requestBody"] = map[string]any{"content": map[string]any{"application/json": map[string]any{"schema": rt.body}}}
+		}
+		if rt.public {
+			op["security"] = []any{}
+		}
+		if paths[rt.path] == nil {
+			paths[rt.path] = make(map[string]any)
+		}
+		paths[rt.path][strings.ToLower(rt.method)] = op
+	}
+
+	schemas := make(map[string]any, len(componentTypes))
+	for name, t := range componentTypes {
+		schemas[name] = schemaForType(t, true)
+	}
+
+	return map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":       "HoLA agent API",
+			"version":     version,
+			"description": "Errors are returned as ErrorResponse with a machine-readable code.",
+		},
+		"paths": paths,
+		"components": map[string]any{
+			"schemas": schemas,
+			"securitySchemes": map[string]any{
+				"bearerAuth": map[string]any{"type": "http", "scheme": "bearer"},
+			},
+		},
+		"security": []any{map[string]any{"bearerAuth": []string{}}},
+	}
+}
+
+func responsesFor(rt apiRoute) map[string]any {
+	okSchema := rt.response
+	if okSchema == nil {
+		okSchema = actionResult
+	}
+	content := map[string]any{"application/json": map[string]any{"schema": okSchema}}
+	if rt.stream {
+		content["text/event-stream"] = map[string]any{"schema": strSchema}
+	}
+	if rt.path == "/api/v1/system/metrics/prometheus" {
+		content = map[string]any{"text/plain": map[string]any{"schema": strSchema}}
+	}
+	ok := map[string]any{"description": "OK", "content": content}
+	if rt.path == "/api/v1/ws" {
+		return map[string]any{"101": map[string]any{"description": "Switching to the WebSocket protocol"}}
+	}
+	return map[string]any{
+		"200": ok,
+		"default": map[string]any{
+			"description": "Error",
+			"content":     map[string]any{"application/json": map[string]any{"schema": ref("ErrorResponse")}},
+		},
+	}
+}
+
+// schemaForType derives a schema from a Go type's json tags. Named struct
+// types listed in componentTypes are referenced rather than inlined, except
+// at the top level where they are being defined.
+func schemaForType(t reflect.Type, top bool) schema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if !top && t.Kind() == reflect.Struct {
+		for name, ct := range componentTypes {
+			if ct == t {
+				return ref(name)
+			}
+		}
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return strSchema
+	case reflect.Bool:
+		return boolSchema
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return intSchema
+	case reflect.Float32, reflect.Float64:
+		return schema{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return arrayOf(schemaForType(t.Elem(), false))
+	case reflect.Map:
+		return schema{"type": "object", "additionalProperties": schemaForType(t.Elem(), false)}
+	case reflect.Struct:
+		if t.PkgPath() == "time" && t.Name() == "Time" {
+			return schema{"type": "string", "format": "date-time"}
+		}
+		props := make(map[string]any)
+		addStructFields(t, props)
+		return schema{"type": "object", "properties": props}
+	}
+	return schema{}
+}
+
+func addStructFields(t reflect.Type, props map[string]any) {
+	for i := range t.NumField() {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				addStructFields(ft, props)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		props[name] = schemaForType(f.Type, false)
+	}
+}
+
+// openAPIHandler serves the spec, built once since it only depends on the
+// version.
+func openAPIHandler(version string) http.HandlerFunc {
+	doc, err := json.Marshal(openAPISpec(version))
+	if err != nil {
+		panic("openapi: " + err.Error())
+	}
+	return func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(doc)
+	}
+}
+
+// openAPIPaths returns the documented "METHOD path" pairs, sorted.
+func openAPIPaths() []string {
+	out := make([]string, 0, len(apiRoutes))
+	for _, rt := range apiRoutes {
+		out = append(out, rt.method+" "+rt.path)
+	}
+	sort.Strings(out)
+	return out
+}
@@ -0,0 +1,83 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"regexp"
+	"slices"
+	"sort"
+	"testing"
+)
+
+func TestOpenAPISpec(t *testing.T) {
+	rec := httptest.NewRecorder()
+	openAPIHandler("1.2.3")(rec, httptest.NewRequest(http.MethodGet, "/api/v1/openapi.json", nil))
+
+	var doc struct {
+		OpenAPI    string                               `json:"openapi"`
+		Info       struct{ Version string }             `json:"info"`
+		Paths      map[string]map[string]map[string]any `json:"paths"`
+		Components struct {
+			Schemas         map[string]any `json:"schemas"`
+			SecuritySchemes map[string]any `json:"securitySchemes"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("spec is not valid JSON: %v", err)
+	}
+	if doc.OpenAPI != "3.0.3" || doc.Info.Version != "1.2.3" {
+		t.Errorf("openapi = %q, version = %q", doc.OpenAPI, doc.Info.Version)
+	}
+	if doc.Components.SecuritySchemes["bearerAuth"] == nil {
+		t.Error("missing bearerAuth security scheme")
+	}
+	if doc.Components.Schemas["ErrorResponse"] == nil {
+		t.Error("missing ErrorResponse schema")
+	}
+
+	paths := make(map[string]bool)
+	for _, rt := range apiRoutes {
+		paths[rt.path] = true
+	}
+	if len(doc.Paths) != len(paths) {
+		t.Errorf("spec has %d paths, want %d", len(doc.Paths), len(paths))
+	}
+
+	for _, p := range []string{"/api/v1/health", "/api/v1/openapi.json"} {
+		if sec, ok := doc.Paths[p]["get"]["security"]; !ok || len(sec.([]any)) != 0 {
+			t.Errorf("%s is not marked public", p)
+		}
+	}
+	if _, ok := doc.Paths["/api/v1/stacks"]["get"]["security"]; ok {
+		t.Error("/api/v1/stacks should use the global bearer security")
+	}
+}
+
+// TestOpenAPICoversRouter fails when a route is added to or removed from
+// router.go without updating apiRoutes.
+func TestOpenAPICoversRouter(t *testing.T) {
+	src, err := os.ReadFile("router.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var registered []string
+	for _, m := range regexp.MustCompile(`mux\.Handle(?:Func)?\("([A-Z]+ /[^"]*)"`).FindAllStringSubmatch(string(src), -1) {
+		registered = append(registered, m[1])
+	}
+	sort.Strings(registered)
+
+	if documented := openAPIPaths(); !slices.Equal(registered, documented) {
+		for _, r := range registered {
+			if !slices.Contains(documented, r) {
+				t.Errorf("route %q is not in the OpenAPI spec", r)
+			}
+		}
+		for _, d := range documented {
+			if !slices.Contains(registered, d) {
+				t.Errorf("spec documents %q, which is not registered", d)
+			}
+		}
+	}
+}
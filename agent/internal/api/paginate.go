@@ -0,0 +1,74 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+const (
+	defaultPageLimit = 100
+	maxPageLimit     = 1000
+)
+
+// parsePagination reads ?limit= and ?offset=. Limit defaults to 100 and is
+// capped at 1000; both must be non-negative integers.
+func parsePagination(r *http.Request) (limit, offset int, err error) {
+	return parsePaging(r, false)
+}
+
+// parsePaginationOrAll is parsePagination for lists that used to be
+// returned whole: ?limit=0 selects every item and is returned as limit 0.
+func parsePaginationOrAll(r *http.Request) (limit, offset int, err error) {
+	return parsePaging(r, true)
+}
+
+func parsePaging(r *http.Request, zeroMeansAll bool) (limit, offset int, err error) {
+	limit = defaultPageLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		limit, err = strconv.Atoi(v)
+		switch {
+		case err == nil && limit == 0 && zeroMeansAll:
+		case err != nil || limit < 1:
+			return 0, 0, fmt.Errorf("limit must be a positive integer")
+		default:
+			limit = min(limit, maxPageLimit)
+		}
+	}
+	if v := r.URL.Query().Get("offset"); v != "" {
+		offset, err = strconv.Atoi(v)
+		if err != nil || offset < 0 {
+			return 0, 0, fmt.Errorf("offset must be a non-negative integer")
+		}
+	}
+	return limit, offset, nil
+}
+
+// paginate returns the window of items selected by limit and offset. A
+// limit of 0 selects everything from offset on.
+func paginate[T any](items []T, limit, offset int) []T {
+	if offset >= len(items) {
+		return []T{}
+	}
+	end := len(items)
+	if limit > 0 {
+		end = min(offset+limit, len(items))
+	}
+	return items[offset:end]
+}
+
+// parseBoolFilter reads an optional true/false query parameter. It returns
+// nil when the parameter is absent.
+func parseBoolFilter(r *http.Request, name string) (*bool, error) {
+	switch r.URL.Query().Get(name) {
+	case "":
+		return nil, nil
+	case "true":
+		v := true
+		return &v, nil
+	case "false":
+		v := false
+		return &v, nil
+	}
+	return nil, fmt.Errorf("%s must be true or false", name)
+}
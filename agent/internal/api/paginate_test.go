@@ -0,0 +1,60 @@
+package api
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestParsePagination(t *testing.T) {
+	tests := []struct {
+		query      string
+		limit, off int
+		wantErr    bool
+	}{
+		{"", defaultPageLimit, 0, false},
+		{"?limit=10&offset=20", 10, 20, false},
+		{"?limit=5000", maxPageLimit, 0, false},
+		{"?limit=0", 0, 0, true},
+		{"?offset=-1", 0, 0, true},
+		{"?limit=abc", 0, 0, true},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/x"+tt.query, nil)
+		limit, off, err := parsePagination(r)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q: err = %v, wantErr %v", tt.query, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && (limit != tt.limit || off != tt.off) {
+			t.Errorf("%q: got limit=%d offset=%d, want %d/%d", tt.query, limit, off, tt.limit, tt.off)
+		}
+	}
+}
+
+func TestParsePaginationOrAll(t *testing.T) {
+	r := httptest.NewRequest("GET", "/x?limit=0&offset=3", nil)
+	if limit, off, err := parsePaginationOrAll(r); err != nil || limit != 0 || off != 3 {
+		t.Errorf("limit=0: got %d/%d err %v, want 0/3", limit, off, err)
+	}
+	r = httptest.NewRequest("GET", "/x", nil)
+	if limit, _, err := parsePaginationOrAll(r); err != nil || limit != defaultPageLimit {
+		t.Errorf("default: got limit %d err %v", limit, err)
+	}
+}
+
+func TestPaginate(t *testing.T) {
+	items := []int{1, 2, 3, 4, 5}
+	if got := paginate(items, 2, 1); !reflect.DeepEqual(got, []int{2, 3}) {
+		t.Errorf("paginate(2,1) = %v", got)
+	}
+	if got := paginate(items, 10, 3); !reflect.DeepEqual(got, []int{4, 5}) {
+		t.Errorf("paginate(10,3) = %v", got)
+	}
+	if got := paginate(items, 0, 1); !reflect.DeepEqual(got, []int{2, 3, 4, 5}) {
+		t.Errorf("paginate(0,1) = %v", got)
+	}
+	if got := paginate(items, 2, 9); got == nil || len(got) != 0 {
+		t.Errorf("paginate past end = %#v, want empty slice", got)
+	}
+}
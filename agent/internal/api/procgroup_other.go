@@ -0,0 +1,15 @@
+//go:build !unix
+
+package api
+
+import (
+	"os/exec"
+	"time"
+)
+
+// setProcessGroup cannot kill descendants here; only cmd itself is killed
+// on cancellation, and waiting for output is cut short in case a child
+// keeps the pipes open.
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.WaitDelay = 5 * time.Second
+}
@@ -0,0 +1,18 @@
+//go:build unix
+
+package api
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// setProcessGroup starts cmd in its own process group and makes context
+// cancellation kill the whole group, so the plugins and helpers docker
+// compose spawns die with it instead of holding its output pipes open.
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+}
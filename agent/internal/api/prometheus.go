@@ -0,0 +1,132 @@
+package api
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/driversti/hola/internal/api/respond"
+	"github.com/driversti/hola/internal/metrics"
+)
+
+// promWriter renders metrics in the Prometheus text exposition format
+// (version 0.0.4), emitting HELP/TYPE headers once per metric family.
+type promWriter struct {
+	w    io.Writer
+	seen map[string]bool
+}
+
+func (p *promWriter) sample(name, typ, help string, labels map[string]string, value float64) {
+	if !p.seen[name] {
+		p.seen[name] = true
+		fmt.Fprintf(p.w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+	}
+	fmt.Fprintf(p.w, "%s%s %s\n", name, formatLabels(labels), strconv.FormatFloat(value, 'g', -1, 64))
+}
+
+func (p *promWriter) gauge(name, help string, labels map[string]string, value float64) {
+	p.sample(name, "gauge", help, labels, value)
+}
+
+func (p *promWriter) counter(name, help string, labels map[string]string, value float64) {
+	p.sample(name, "counter", help, labels, value)
+}
+
+func formatLabels(labels map[string]string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		parts = append(parts, k+`="`+labelValueEscaper.Replace(labels[k])+`"`)
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+// labelValueEscaper escapes exactly what the text exposition format requires
+// in label values: backslashes, double quotes and newlines.
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// writePrometheus renders system metrics and optional container state counts.
+func writePrometheus(w io.Writer, m *metrics.SystemMetrics, containers map[string]int) {
+	p := &promWriter{w: w, seen: make(map[string]bool)}
+
+	p.gauge("hola_uptime_seconds", "Host uptime in seconds.", nil, float64(m.UptimeSeconds))
+
+	p.gauge("hola_cpu_usage_percent", "Aggregate CPU usage percent.", nil, m.CPU.UsagePercent)
+	for i, v := range m.CPU.PerCore {
+		p.gauge("hola_cpu_core_usage_percent", "Per-core CPU usage percent.", map[string]string{"core": strconv.Itoa(i)}, v)
+	}
+	p.gauge("hola_cpu_cores", "Number of logical CPU cores.", nil, float64(m.CPU.Cores))
+	if m.CPU.TemperatureCelsius != nil {
+		p.gauge("hola_cpu_temperature_celsius", "CPU temperature in degrees Celsius.", nil, *m.CPU.TemperatureCelsius)
+	}
+	if m.LoadAvg != nil {
+		help := "System load average."
+		p.gauge("hola_load_average", help, map[string]string{"period": "1m"}, m.LoadAvg.Load1)
+		p.gauge("hola_load_average", help, map[string]string{"period": "5m"}, m.LoadAvg.Load5)
+		p.gauge("hola_load_average", help, map[string]string{"period": "15m"}, m.LoadAvg.Load15)
+	}
+
+	p.gauge("hola_mem_total_bytes", "Total physical memory in bytes.", nil, float64(m.Memory.TotalBytes))
+	p.gauge("hola_mem_used_bytes", "Used physical memory in bytes.", nil, float64(m.Memory.UsedBytes))
+	p.gauge("hola_mem_usage_percent", "Physical memory usage percent.", nil, m.Memory.UsagePercent)
+	p.gauge("hola_swap_total_bytes", "Total swap in bytes.", nil, float64(m.Memory.SwapTotalBytes))
+	p.gauge("hola_swap_used_bytes", "Used swap in bytes.", nil, float64(m.Memory.SwapUsedBytes))
+
+	for _, d := range m.Disk {
+		labels := map[string]string{"mount": d.MountPoint}
+		p.gauge("hola_disk_total_bytes", "Filesystem size in bytes.", labels, float64(d.TotalBytes))
+		p.gauge("hola_disk_used_bytes", "Filesystem used bytes.", labels, float64(d.UsedBytes))
+		p.gauge("hola_disk_usage_percent", "Filesystem usage percent.", labels, d.UsagePercent)
+	}
+
+	for _, n := range m.Network {
+		labels := map[string]string{"interface": n.Interface}
+		p.counter("hola_network_receive_bytes_total", "Bytes received per interface.", labels, float64(n.RxBytes))
+		p.counter("hola_network_transmit_bytes_total", "Bytes transmitted per interface.", labels, float64(n.TxBytes))
+	}
+
+	if containers != nil {
+		states := make([]string, 0, len(containers))
+		for state := range containers {
+			states = append(states, state)
+		}
+		sort.Strings(states)
+		for _, state := range states {
+			p.gauge("hola_containers", "Number of containers by state.", map[string]string{"state": state}, float64(containers[state]))
+		}
+	}
+}
+
+func (h *handlers) prometheusMetrics(w http.ResponseWriter, r *http.Request) {
+	m, err := h.promMetrics.Collect(r.Context())
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to collect metrics", "error", err)
+		respond.Error(w, http.StatusInternalServerError, "failed to collect system metrics", "METRICS_ERROR")
+		return
+	}
+
+	// Container gauges are best-effort: a Docker hiccup shouldn't fail the scrape.
+	var containers map[string]int
+	if h.docker != nil {
+		containers, err = h.docker.ContainerStateCounts(r.Context())
+		if err != nil {
+			slog.WarnContext(r.Context(), "failed to count containers for prometheus", "error", err)
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	writePrometheus(w, m, containers)
+}
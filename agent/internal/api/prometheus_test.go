@@ -0,0 +1,58 @@
+package api
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/driversti/hola/internal/metrics"
+)
+
+func TestWritePrometheus(t *testing.T) {
+	m := &metrics.SystemMetrics{
+		UptimeSeconds: 3600,
+		CPU:           metrics.CPUMetrics{UsagePercent: 12.5, Cores: 4},
+		Memory:        metrics.MemMetrics{TotalBytes: 8192, UsedBytes: 4096, UsagePercent: 50},
+		Disk: []metrics.DiskMetric{
+			{MountPoint: "/", TotalBytes: 100, UsedBytes: 40, UsagePercent: 40},
+			{MountPoint: "/data", TotalBytes: 200, UsedBytes: 50, UsagePercent: 25},
+		},
+	}
+
+	var sb strings.Builder
+	writePrometheus(&sb, m, map[string]int{"running": 3, "exited": 1})
+	out := sb.String()
+
+	for _, want := range []string{
+		"# TYPE hola_cpu_usage_percent gauge\nhola_cpu_usage_percent 12.5\n",
+		"hola_mem_used_bytes 4096\n",
+		`hola_disk_usage_percent{mount="/"} 40` + "\n",
+		`hola_disk_usage_percent{mount="/data"} 25` + "\n",
+		`hola_containers{state="exited"} 1` + "\n",
+		`hola_containers{state="running"} 3` + "\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q\n%s", want, out)
+		}
+	}
+
+	if n := strings.Count(out, "# TYPE hola_disk_usage_percent"); n != 1 {
+		t.Errorf("TYPE header for hola_disk_usage_percent emitted %d times, want 1", n)
+	}
+	if strings.Contains(out, "hola_load_average") {
+		t.Error("load average should be omitted when unavailable")
+	}
+}
+
+func TestFormatLabelsEscapes(t *testing.T) {
+	tests := []struct{ value, want string }{
+		{`/mnt/"odd"\dir`, `{mount="/mnt/\"odd\"\\dir"}`},
+		{"line\nbreak", `{mount="line\nbreak"}`},
+		// Only backslash, quote and newline are escaped; Go quoting must not leak in.
+		{"tab\there/médias", "{mount=\"tab\there/médias\"}"},
+	}
+	for _, tt := range tests {
+		if got := formatLabels(map[string]string{"mount": tt.value}); got != tt.want {
+			t.Errorf("formatLabels(%q) = %s, want %s", tt.value, got, tt.want)
+		}
+	}
+}
@@ -3,6 +3,9 @@ package respond
 import (
 	"encoding/json"
 	"net/http"
+	"time"
+
+	"github.com/driversti/hola/internal/requestid"
 )
 
 // JSON writes a JSON response with the given status code.
@@ -12,13 +15,29 @@ func JSON(w http.ResponseWriter, status int, data any) {
 	json.NewEncoder(w).Encode(data)
 }
 
-// ErrorResponse is the standard error format per SPEC.md.
+// ErrorResponse is the standard error format per SPEC.md. Error and Code
+// are the original envelope; the other fields were added later and clients
+// must not rely on RequestID being present.
 type ErrorResponse struct {
-	Error string `json:"error"`
-	Code  string `json:"code"`
+	Error     string `json:"error"`
+	Code      string `json:"code"`
+	RequestID string `json:"request_id,omitempty"`
+	Timestamp string `json:"timestamp"` // RFC3339, UTC
+}
+
+// NewError returns an ErrorResponse stamped with the current time.
+func NewError(message, code, requestID string) ErrorResponse {
+	return ErrorResponse{
+		Error:     message,
+		Code:      code,
+		RequestID: requestID,
+		Timestamp: time.Now().UTC().Format(time.RFC3339),
+	}
 }
 
-// Error writes a structured JSON error response.
+// Error writes a structured JSON error response. The request id is the one
+// the router's middleware took from the request context and echoed in the
+// response header, so users can quote it.
 func Error(w http.ResponseWriter, status int, message, code string) {
-	JSON(w, status, ErrorResponse{Error: message, Code: code})
+	JSON(w, status, NewError(message, code, w.Header().Get(requestid.Header)))
 }
@@ -0,0 +1,28 @@
+package respond
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// SSE starts a Server-Sent Events response and returns a function that
+// writes one named event with a JSON payload and flushes it to the client.
+func SSE(w http.ResponseWriter) func(event string, data any) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no") // Disable nginx proxy buffering.
+	w.WriteHeader(http.StatusOK)
+
+	flusher, _ := w.(http.Flusher)
+	return func(event string, data any) {
+		payload, err := json.Marshal(data)
+		if err != nil {
+			payload = []byte(`{}`)
+		}
+		fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, payload)
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+}
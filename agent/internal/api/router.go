@@ -2,26 +2,107 @@ package api
 
 import (
 	"net/http"
+	"time"
 
 	"github.com/driversti/hola/internal/auth"
 	"github.com/driversti/hola/internal/docker"
+	"github.com/driversti/hola/internal/maintenance"
 	"github.com/driversti/hola/internal/registry"
 	"github.com/driversti/hola/internal/update"
 	"github.com/driversti/hola/internal/ws"
 )
 
+// Options holds tunable settings for the API handlers.
+type Options struct {
+	// ComposeBackups is how many rotated .bak.N copies of a compose file are
+	// kept when it is edited through the API. Values below 1 are treated as 1.
+	ComposeBackups int
+
+	// Maintenance is the shared maintenance mode switch. A private one is
+	// created when nil.
+	Maintenance *maintenance.Mode
+
+	// MaintenanceDuration is how long maintenance mode lasts when enabled
+	// without an explicit duration. Defaults to one hour.
+	MaintenanceDuration time.Duration
+
+	// ExecTimeout bounds commands run through the container exec endpoint.
+	// Defaults to 30 seconds.
+	ExecTimeout time.Duration
+
+	// ActionTimeout bounds a single stack action (one docker compose run).
+	// Defaults to 5 minutes.
+	ActionTimeout time.Duration
+
+	// ExecMaxOutput is how many bytes of a command's output the exec
+	// endpoint returns; the rest is discarded. Defaults to 1 MiB.
+	ExecMaxOutput int
+
+	// BrowseRoots restricts the /fs endpoints and stack registration to
+	// these directories and their descendants. Empty means unrestricted.
+	BrowseRoots []string
+
+	// BulkConcurrency is how many stacks POST /api/v1/stacks/actions acts
+	// on at once. Defaults to 4.
+	BulkConcurrency int
+
+	// DockerArgs are docker CLI global flags, such as the daemon's TLS
+	// settings, put before every docker command the agent runs.
+	DockerArgs []string
+
+	// CORSOrigins are the browser origins (e.g. https://ui.example.com, or
+	// "*" for any) allowed to call the API cross-origin. Empty disables CORS.
+	CORSOrigins []string
+
+	// Config is the effective agent configuration reported by
+	// GET /api/v1/agent/config.
+	Config AgentConfig
+}
+
 // NewRouter creates the HTTP router with all API routes.
-func NewRouter(version string, authMw *auth.Middleware, dockerClient *docker.Client, wsHandler *ws.Handler, registryStore *registry.Store, updater *update.Updater) http.Handler {
+func NewRouter(version string, authMw *auth.Middleware, dockerClient *docker.Client, wsHandler *ws.Handler, registryStore *registry.Store, updater *update.Updater, opts Options) http.Handler {
 	mux := http.NewServeMux()
 
-	h := &handlers{version: version, docker: dockerClient, registry: registryStore, updater: updater}
+	if opts.ComposeBackups < 1 {
+		opts.ComposeBackups = 1
+	}
+	if opts.Maintenance == nil {
+		opts.Maintenance = maintenance.New()
+	}
+	if opts.MaintenanceDuration <= 0 {
+		opts.MaintenanceDuration = time.Hour
+	}
+	if opts.ExecTimeout <= 0 {
+		opts.ExecTimeout = 30 * time.Second
+	}
+	if opts.ActionTimeout <= 0 {
+		opts.ActionTimeout = 5 * time.Minute
+	}
+	if opts.ExecMaxOutput <= 0 {
+		opts.ExecMaxOutput = 1 << 20
+	}
+	if opts.BulkConcurrency <= 0 {
+		opts.BulkConcurrency = 4
+	}
+	opts.BrowseRoots = resolveRoots(opts.BrowseRoots)
+
+	h := &handlers{version: version, docker: dockerClient, registry: registryStore, updater: updater, host: newHostInfo(dockerClient), opts: opts}
 
 	// System
 	mux.HandleFunc("GET /api/v1/health", h.health)
+	mux.HandleFunc("GET /api/v1/ready", h.ready)
+	mux.HandleFunc("GET /api/v1/openapi.json", openAPIHandler(version))
 	mux.HandleFunc("GET /api/v1/agent/info", h.agentInfo)
+	mux.HandleFunc("GET /api/v1/agent/version", h.agentVersion)
+	mux.HandleFunc("GET /api/v1/agent/config", h.agentConfig)
+	mux.HandleFunc("GET /api/v1/agent/maintenance", h.maintenanceStatus)
+	mux.HandleFunc("POST /api/v1/agent/maintenance", h.setMaintenance)
 	mux.HandleFunc("GET /api/v1/system/metrics", h.systemMetrics)
+	mux.HandleFunc("GET /api/v1/system/metrics/prometheus", h.prometheusMetrics)
 	mux.HandleFunc("GET /api/v1/agent/update", h.checkUpdate)
 	mux.HandleFunc("POST /api/v1/agent/update", h.applyUpdate)
+	mux.HandleFunc("GET /api/v1/agent/rollback", h.rollbackStatus)
+	mux.HandleFunc("POST /api/v1/agent/rollback", h.rollback)
 
 	// Filesystem
 	mux.HandleFunc("GET /api/v1/fs/browse", h.browsePath)
@@ -35,38 +116,61 @@ func NewRouter(version string, authMw *auth.Middleware, dockerClient *docker.Cli
 	mux.HandleFunc("GET /api/v1/stacks", h.listStacks)
 	mux.HandleFunc("GET /api/v1/stacks/{name}", h.getStack)
 	mux.HandleFunc("GET /api/v1/stacks/{name}/compose", h.getComposeFile)
+	mux.HandleFunc("GET /api/v1/stacks/{name}/compose/backups", h.listComposeBackups)
+	mux.HandleFunc("GET /api/v1/stacks/{name}/env", h.getStackEnv)
+	mux.HandleFunc("GET /api/v1/stacks/{name}/logs", h.stackLogs)
+	mux.HandleFunc("GET /api/v1/stacks/{name}/services/{service}/logs", h.serviceLogs)
+	mux.HandleFunc("GET /api/v1/search", h.search)
+	mux.HandleFunc("POST /api/v1/compose/validate", h.validateComposeContent)
 
 	// Stacks — write
 	mux.HandleFunc("PUT /api/v1/stacks/{name}/compose", h.updateComposeFile)
+	mux.HandleFunc("PUT /api/v1/stacks/{name}/env", h.updateStackEnv)
+	mux.HandleFunc("POST /api/v1/stacks/{name}/compose/backups/{index}/restore", h.restoreComposeBackup)
+	mux.HandleFunc("POST /api/v1/stacks/{name}/compose/restore", h.restoreLatestComposeBackup)
 	mux.HandleFunc("POST /api/v1/stacks/register", h.registerStack)
 	mux.HandleFunc("POST /api/v1/stacks/{name}/start", h.stackAction)
 	mux.HandleFunc("POST /api/v1/stacks/{name}/stop", h.stackAction)
 	mux.HandleFunc("POST /api/v1/stacks/{name}/restart", h.stackAction)
 	mux.HandleFunc("POST /api/v1/stacks/{name}/down", h.stackAction)
 	mux.HandleFunc("POST /api/v1/stacks/{name}/pull", h.stackAction)
+	mux.HandleFunc("GET /api/v1/stacks/{name}/{action}/stream", h.stackActionStream)
 	mux.HandleFunc("DELETE /api/v1/stacks/{name}/unregister", h.unregisterStack)
+	mux.HandleFunc("POST /api/v1/stacks/prune-stale", h.pruneStaleStacks)
+	mux.HandleFunc("POST /api/v1/stacks/actions", h.bulkStackAction)
 
 	// Containers
+	mux.HandleFunc("GET /api/v1/containers", h.listContainers)
+	mux.HandleFunc("GET /api/v1/containers/{id}/inspect", h.containerInspect)
+	mux.HandleFunc("GET /api/v1/containers/{id}/top", h.containerTop)
+	mux.HandleFunc("GET /api/v1/containers/{id}/limits", h.containerLimits)
+	mux.HandleFunc("DELETE /api/v1/containers/{id}", h.removeContainer)
 	mux.HandleFunc("GET /api/v1/containers/{id}/logs", h.containerLogs)
+	mux.HandleFunc("GET /api/v1/containers/{id}/stats", h.containerStats)
 	mux.HandleFunc("POST /api/v1/containers/{id}/start", h.containerAction)
 	mux.HandleFunc("POST /api/v1/containers/{id}/stop", h.containerAction)
 	mux.HandleFunc("POST /api/v1/containers/{id}/restart", h.containerAction)
+	mux.HandleFunc("POST /api/v1/containers/{id}/exec", h.containerExec)
 
 	// Docker resources
 	mux.HandleFunc("GET /api/v1/docker/disk-usage", h.dockerDiskUsage)
 	mux.HandleFunc("GET /api/v1/docker/images", h.listImages)
 	mux.HandleFunc("DELETE /api/v1/docker/images/{id}", h.removeImage)
+	mux.HandleFunc("POST /api/v1/docker/images/pull", h.pullImage)
 	mux.HandleFunc("POST /api/v1/docker/images/prune", h.pruneImages)
 	mux.HandleFunc("GET /api/v1/docker/volumes", h.listVolumes)
+	mux.HandleFunc("POST /api/v1/docker/volumes", h.createVolume)
 	mux.HandleFunc("DELETE /api/v1/docker/volumes/{name}", h.removeVolume)
 	mux.HandleFunc("POST /api/v1/docker/volumes/prune", h.pruneVolumes)
 	mux.HandleFunc("GET /api/v1/docker/networks", h.listNetworks)
+	mux.HandleFunc("POST /api/v1/docker/networks", h.createNetwork)
 	mux.HandleFunc("DELETE /api/v1/docker/networks/{id}", h.removeNetwork)
 	mux.HandleFunc("POST /api/v1/docker/networks/prune", h.pruneNetworks)
 	mux.HandleFunc("POST /api/v1/docker/buildcache/prune", h.pruneBuildCache)
+	mux.HandleFunc("POST /api/v1/docker/prune", h.pruneAll)
 
 	// WebSocket
 	mux.Handle("GET /api/v1/ws", wsHandler)
 
-	return loggingMiddleware(authMw.Wrap(mux))
+	return requestIDMiddleware(loggingMiddleware(corsMiddleware(opts.CORSOrigins, gzipMiddleware(authMw.Wrap(mux)))))
 }
@@ -0,0 +1,175 @@
+package api
+
+import (
+	"log/slog"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/driversti/hola/internal/api/respond"
+	"github.com/driversti/hola/internal/docker"
+)
+
+// maxSearchResults caps each group of search results.
+const maxSearchResults = 100
+
+type searchStack struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+}
+
+type searchService struct {
+	Stack   string `json:"stack"`
+	Service string `json:"service"`
+}
+
+type searchContainer struct {
+	ID      string   `json:"id"`
+	Name    string   `json:"name"`
+	Stack   string   `json:"stack,omitempty"`
+	Service string   `json:"service,omitempty"`
+	Image   string   `json:"image"`
+	State   string   `json:"state"`
+	Matched []string `json:"matched"` // "name" and/or "image"
+}
+
+type searchImage struct {
+	Image      string   `json:"image"`
+	Stacks     []string `json:"stacks"`
+	Containers []string `json:"containers"`
+}
+
+// searchResult groups matches by what matched.
+type searchResult struct {
+	Stacks     []searchStack     `json:"stacks"`
+	Services   []searchService   `json:"services"`
+	Containers []searchContainer `json:"containers"`
+	Images     []searchImage     `json:"images"`
+	Truncated  bool              `json:"truncated"` // a group was cut at maxSearchResults
+}
+
+// search finds stacks, services, containers and images whose name or
+// reference matches q: a case-insensitive substring, or a regular
+// expression with ?regex=true.
+func (h *handlers) search(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query().Get("q")
+	if q == "" {
+		respond.Error(w, http.StatusBadRequest, "q is required", "BAD_REQUEST")
+		return
+	}
+	match, err := searchMatcher(q, r.URL.Query().Get("regex") == "true")
+	if err != nil {
+		respond.Error(w, http.StatusBadRequest, err.Error(), "BAD_FILTER")
+		return
+	}
+
+	stacks, err := h.docker.ListStacks(r.Context())
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to list stacks", "error", err)
+		respond.Error(w, http.StatusInternalServerError, "failed to list stacks", "DOCKER_ERROR")
+		return
+	}
+	// Registered stacks that are down have no containers but are still
+	// found by name, as in the stack list.
+	for _, rs := range h.registry.All() {
+		if !slices.ContainsFunc(stacks, func(st docker.Stack) bool { return st.Name == rs.Name }) {
+			stacks = append(stacks, docker.Stack{Name: rs.Name, Status: "down"})
+		}
+	}
+	containers, err := h.docker.ListContainers(r.Context())
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to list containers", "error", err)
+		respond.Error(w, http.StatusInternalServerError, "failed to list containers", "DOCKER_ERROR")
+		return
+	}
+
+	respond.JSON(w, http.StatusOK, searchAll(stacks, containers, match))
+}
+
+// searchMatcher returns a predicate for q.
+func searchMatcher(q string, regex bool) (func(string) bool, error) {
+	if regex {
+		re, err := regexp.Compile(q)
+		if err != nil {
+			return nil, err
+		}
+		return re.MatchString, nil
+	}
+	q = strings.ToLower(q)
+	return func(s string) bool { return strings.Contains(strings.ToLower(s), q) }, nil
+}
+
+// searchAll matches stacks and containers against match, grouping the
+// results. Results are ordered by name.
+func searchAll(stacks []docker.Stack, containers []docker.ContainerInfo, match func(string) bool) searchResult {
+	res := searchResult{
+		Stacks:     []searchStack{},
+		Services:   []searchService{},
+		Containers: []searchContainer{},
+		Images:     []searchImage{},
+	}
+
+	for _, st := range stacks {
+		if match(st.Name) {
+			res.Stacks = append(res.Stacks, searchStack{Name: st.Name, Status: st.Status})
+		}
+	}
+
+	services := make(map[searchService]bool)
+	images := make(map[string]*searchImage)
+	for _, c := range containers {
+		var matched []string
+		if match(c.Name) {
+			matched = append(matched, "name")
+		}
+		if match(c.Image) {
+			matched = append(matched, "image")
+			img := images[c.Image]
+			if img == nil {
+				img = &searchImage{Image: c.Image, Stacks: []string{}}
+				images[c.Image] = img
+			}
+			img.Containers = append(img.Containers, c.Name)
+			if c.Stack != "" && !slices.Contains(img.Stacks, c.Stack) {
+				img.Stacks = append(img.Stacks, c.Stack)
+			}
+		}
+		if matched != nil {
+			res.Containers = append(res.Containers, searchContainer{
+				ID: c.ID, Name: c.Name, Stack: c.Stack, Service: c.Service,
+				Image: c.Image, State: c.State, Matched: matched,
+			})
+		}
+		if svc := (searchService{Stack: c.Stack, Service: c.Service}); c.Service != "" && !services[svc] && match(c.Service) {
+			services[svc] = true
+			res.Services = append(res.Services, svc)
+		}
+	}
+	for _, img := range images {
+		slices.Sort(img.Stacks)
+		slices.Sort(img.Containers)
+		res.Images = append(res.Images, *img)
+	}
+
+	slices.SortFunc(res.Stacks, func(a, b searchStack) int { return strings.Compare(a.Name, b.Name) })
+	slices.SortFunc(res.Services, func(a, b searchService) int {
+		return strings.Compare(a.Stack+"/"+a.Service, b.Stack+"/"+b.Service)
+	})
+	slices.SortFunc(res.Containers, func(a, b searchContainer) int { return strings.Compare(a.Name, b.Name) })
+	slices.SortFunc(res.Images, func(a, b searchImage) int { return strings.Compare(a.Image, b.Image) })
+
+	res.Stacks, res.Truncated = capResults(res.Stacks, res.Truncated)
+	res.Services, res.Truncated = capResults(res.Services, res.Truncated)
+	res.Containers, res.Truncated = capResults(res.Containers, res.Truncated)
+	res.Images, res.Truncated = capResults(res.Images, res.Truncated)
+	return res
+}
+
+// capResults cuts s to maxSearchResults, setting truncated if it did.
+func capResults[T any](s []T, truncated bool) ([]T, bool) {
+	if len(s) > maxSearchResults {
+		return s[:maxSearchResults], true
+	}
+	return s, truncated
+}
@@ -0,0 +1,65 @@
+package api
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/driversti/hola/internal/docker"
+)
+
+func TestSearchAll(t *testing.T) {
+	stacks := []docker.Stack{{Name: "media", Status: "running"}, {Name: "monitoring", Status: "partial"}, {Name: "backup", Status: "down"}}
+	containers := []docker.ContainerInfo{
+		{ID: "a1", Name: "media-plex-1", Stack: "media", Service: "plex", Image: "plexinc/pms-docker:latest", State: "running"},
+		{ID: "a2", Name: "media-sonarr-1", Stack: "media", Service: "sonarr", Image: "linuxserver/sonarr", State: "running"},
+		{ID: "b1", Name: "monitoring-grafana-1", Stack: "monitoring", Service: "grafana", Image: "grafana/grafana", State: "exited"},
+		{ID: "c1", Name: "standalone", Image: "grafana/grafana", State: "running"},
+	}
+
+	match, _ := searchMatcher("GRAFANA", false)
+	res := searchAll(stacks, containers, match)
+	if len(res.Stacks) != 0 {
+		t.Errorf("stacks = %+v, want none", res.Stacks)
+	}
+	if want := []searchService{{Stack: "monitoring", Service: "grafana"}}; !slices.Equal(res.Services, want) {
+		t.Errorf("services = %+v, want %+v", res.Services, want)
+	}
+	if len(res.Containers) != 2 || res.Containers[0].Name != "monitoring-grafana-1" ||
+		!slices.Equal(res.Containers[0].Matched, []string{"name", "image"}) ||
+		!slices.Equal(res.Containers[1].Matched, []string{"image"}) {
+		t.Errorf("containers = %+v", res.Containers)
+	}
+	if len(res.Images) != 1 || res.Images[0].Image != "grafana/grafana" ||
+		!slices.Equal(res.Images[0].Stacks, []string{"monitoring"}) ||
+		!slices.Equal(res.Images[0].Containers, []string{"monitoring-grafana-1", "standalone"}) {
+		t.Errorf("images = %+v", res.Images)
+	}
+
+	match, _ = searchMatcher("^m", true)
+	res = searchAll(stacks, containers, match)
+	if want := []searchStack{{Name: "media", Status: "running"}, {Name: "monitoring", Status: "partial"}}; !slices.Equal(res.Stacks, want) {
+		t.Errorf("regex stacks = %+v, want %+v", res.Stacks, want)
+	}
+	if res.Truncated {
+		t.Error("unexpected truncation")
+	}
+}
+
+func TestSearchAllTruncates(t *testing.T) {
+	var containers []docker.ContainerInfo
+	for i := range maxSearchResults + 5 {
+		containers = append(containers, docker.ContainerInfo{ID: fmt.Sprint(i), Name: fmt.Sprintf("web-%03d", i), Image: "nginx"})
+	}
+	match, _ := searchMatcher("web", false)
+	res := searchAll(nil, containers, match)
+	if len(res.Containers) != maxSearchResults || !res.Truncated {
+		t.Errorf("got %d containers, truncated=%v; want %d, true", len(res.Containers), res.Truncated, maxSearchResults)
+	}
+}
+
+func TestSearchMatcherRejectsBadRegex(t *testing.T) {
+	if _, err := searchMatcher("(", true); err == nil {
+		t.Error("expected an error for an invalid regular expression")
+	}
+}
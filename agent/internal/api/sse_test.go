@@ -0,0 +1,213 @@
+package api_test
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/driversti/hola/internal/api"
+	"github.com/driversti/hola/internal/auth"
+	"github.com/driversti/hola/internal/registry"
+	"github.com/driversti/hola/internal/update"
+	"github.com/driversti/hola/internal/ws"
+)
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
+
+// openStream sends an authenticated request, with an optional JSON body,
+// asking for an event stream and returns a reader over the response body. The request is cancelled
+// when the test ends, or after a timeout so a stream that never flushes
+// fails the test instead of hanging it.
+func openStream(t *testing.T, srv *httptest.Server, method, path, body string) *bufio.Reader {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	t.Cleanup(cancel)
+	req, err := http.NewRequestWithContext(ctx, method, srv.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer test-token")
+	req.Header.Set("Accept", "text/event-stream")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("%s %s: want an event stream, got %d %s", method, path, resp.StatusCode, ct)
+	}
+	return bufio.NewReader(resp.Body)
+}
+
+// nextEvent reads the next Server-Sent Event from br.
+func nextEvent(t *testing.T, br *bufio.Reader) (event, data string) {
+	t.Helper()
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil {
+			t.Fatalf("reading event stream: %v", err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case line == "" && event != "":
+			return event, data
+		case strings.HasPrefix(line, "event: "):
+			event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			data = strings.TrimPrefix(line, "data: ")
+		}
+	}
+}
+
+func TestApplyUpdateStreamsProgress(t *testing.T) {
+	// The binary download sends a first chunk and then stalls until the
+	// test ends, so a progress event can only arrive if it is flushed
+	// through the middleware chain. The checksum never matches, so nothing
+	// is installed even if the download were to finish.
+	asset := fmt.Sprintf("hola-agent-%s-%s", runtime.GOOS, runtime.GOARCH)
+	stall := make(chan struct{})
+	gh := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/releases/latest"):
+			fmt.Fprintf(w, `{"tag_name":"v99.0.0","assets":[{"name":%q,"browser_download_url":"https://github.test/bin","size":1048576},`+
+				`{"name":"checksums.txt","browser_download_url":"https://github.test/checksums.txt","size":80}]}`, asset)
+		case r.URL.Path == "/checksums.txt":
+			fmt.Fprintf(w, "%064d  %s\n", 0, asset)
+		case r.URL.Path == "/bin":
+			w.Header().Set("Content-Length", strconv.Itoa(1<<20))
+			w.Write(make([]byte, 64<<10))
+			w.(http.Flusher).Flush()
+			select {
+			case <-stall:
+			case <-r.Context().Done():
+			}
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(gh.Close)
+	t.Cleanup(func() { close(stall) })
+
+	updater := update.New("0.1.0-test", "driversti/HoLA")
+	updater.SetTransport(roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		req.URL.Scheme = "http"
+		req.URL.Host = gh.Listener.Addr().String()
+		return http.DefaultTransport.RoundTrip(req)
+	}))
+	store, _ := registry.NewStore(t.TempDir())
+	srv := httptest.NewServer(api.NewRouter("0.1.0-test", auth.NewMiddleware("test-token"), nil, ws.NewHandler(nil, ws.Options{}), store, updater, api.Options{}))
+	t.Cleanup(srv.Close)
+
+	events := openStream(t, srv, http.MethodPost, "/api/v1/agent/update", "")
+	event, data := nextEvent(t, events)
+	if event != "progress" || !strings.Contains(data, `"total_bytes":1048576`) {
+		t.Fatalf("got %s %s, want a progress event", event, data)
+	}
+}
+
+func TestStackActionStreamFlushesOutput(t *testing.T) {
+	// The command keeps running after its first line, so that line can
+	// only arrive if it is flushed as it is written.
+	fakeDockerCLI(t, "echo pulling web; sleep 30")
+	srv, _ := newStackTestServer(t, "services: {}\n", nil, api.Options{})
+
+	events := openStream(t, srv, http.MethodGet, "/api/v1/stacks/app/pull/stream", "")
+	if event, data := nextEvent(t, events); event != "start" {
+		t.Fatalf("got %s %s, want start", event, data)
+	}
+	if event, data := nextEvent(t, events); event != "output" || !strings.Contains(data, `"line":"pulling web"`) {
+		t.Fatalf("got %s %s, want the first output line", event, data)
+	}
+}
+
+func TestStackActionStreamOversizedLine(t *testing.T) {
+	// A 2 MiB line overflows the scanner. The rest of stdout must still be
+	// drained, or the command blocks on a full pipe until the timeout.
+	fakeDockerCLI(t, "head -c 2097152 /dev/zero | tr '\\0' x; echo; echo more; echo warning >&2")
+	srv, _ := newStackTestServer(t, "services: {}\n", nil, api.Options{ActionTimeout: time.Minute})
+
+	events := openStream(t, srv, http.MethodGet, "/api/v1/stacks/app/pull/stream", "")
+	var discarded bool
+	for {
+		event, data := nextEvent(t, events)
+		switch event {
+		case "output":
+			discarded = discarded || strings.Contains(data, "rest of stdout discarded")
+			if strings.Contains(data, `"line":"more"`) {
+				t.Errorf("output after the oversized line was sent: %s", data)
+			}
+		case "done":
+			if !discarded {
+				t.Error("no output event reported the discarded stdout")
+			}
+			if !strings.Contains(data, `"success":true`) {
+				t.Errorf("done = %s, want success", data)
+			}
+			return
+		}
+	}
+}
+
+func TestPullImageStreamsProgress(t *testing.T) {
+	// The daemon reports one layer and then stalls until the test ends, so
+	// the progress event can only arrive if it is flushed.
+	stall := make(chan struct{})
+	srv := newDockerTestServer(t, map[string]http.HandlerFunc{
+		"/images/create": func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprintln(w, `{"id":"a1b2c3","status":"Downloading","progressDetail":{"current":512,"total":2048}}`)
+			w.(http.Flusher).Flush()
+			select {
+			case <-stall:
+			case <-r.Context().Done():
+			}
+		},
+	})
+	t.Cleanup(func() { close(stall) })
+
+	events := openStream(t, srv, http.MethodPost, "/api/v1/docker/images/pull", `{"image":"nginx:1.27"}`)
+	event, data := nextEvent(t, events)
+	if event != "progress" || !strings.Contains(data, `"layer":"a1b2c3"`) || !strings.Contains(data, `"current":512`) {
+		t.Fatalf("got %s %s, want the layer progress", event, data)
+	}
+}
+
+func TestGzipFlushThroughRouter(t *testing.T) {
+	// Without an event-stream Accept header the stream goes through the
+	// gzip writer. Its first flush must still reach the client while the
+	// command is running.
+	fakeDockerCLI(t, "echo pulling web; sleep 30")
+	srv, _ := newStackTestServer(t, "services: {}\n", nil, api.Options{CORSOrigins: []string{"*"}})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/api/v1/stacks/app/pull/stream", nil)
+	req.Header.Set("Authorization", "Bearer test-token")
+	req.Header.Set("Accept-Encoding", "gzip")
+	req.Header.Set("Origin", "https://ui.example.com")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if enc := resp.Header.Get("Content-Encoding"); enc != "" {
+		t.Fatalf("flushed stream sent with Content-Encoding %q", enc)
+	}
+
+	events := bufio.NewReader(resp.Body)
+	if event, data := nextEvent(t, events); event != "start" {
+		t.Fatalf("got %s %s, want start", event, data)
+	}
+	if event, data := nextEvent(t, events); event != "output" || !strings.Contains(data, `"line":"pulling web"`) {
+		t.Fatalf("got %s %s, want the first output line", event, data)
+	}
+}
@@ -0,0 +1,83 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// composeWarnKVRe and composeWarnTextRe match the warning lines compose
+// writes to stderr, in both the key=value form (level=warning msg="...")
+// and the older text form (WARN[0000] ...).
+var (
+	composeWarnKVRe   = regexp.MustCompile(`level=warn(?:ing)?\s+msg=("(?:[^"\\]|\\.)*")`)
+	composeWarnTextRe = regexp.MustCompile(`^WARN\[\d+\]\s*(.+)$`)
+)
+
+// validateCompose runs `docker compose config -q` against path from dir so
+// that the directory's .env is used for interpolation; dockerArgs are the
+// CLI's global flags. It returns any
+// warnings compose printed (e.g. unset variables), which are emitted even
+// when validation succeeds.
+func validateCompose(ctx context.Context, dockerArgs []string, dir, path string) ([]string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "docker", append(slices.Clone(dockerArgs), "compose", "-f", path, "config", "-q")...)
+	cmd.Dir = dir
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+	warnings := parseComposeWarnings(stderr.String())
+	if err != nil {
+		detail := strings.TrimSpace(stderr.String() + stdout.String())
+		if detail == "" {
+			detail = err.Error()
+		}
+		return warnings, fmt.Errorf("%s", detail)
+	}
+	return warnings, nil
+}
+
+// parseComposeWarnings extracts the warning messages from compose stderr.
+func parseComposeWarnings(stderr string) []string {
+	warnings := []string{}
+	for _, line := range strings.Split(stderr, "\n") {
+		line = strings.TrimSpace(line)
+		if m := composeWarnKVRe.FindStringSubmatch(line); m != nil {
+			msg, err := strconv.Unquote(m[1])
+			if err != nil {
+				msg = strings.Trim(m[1], `"`)
+			}
+			warnings = append(warnings, msg)
+			continue
+		}
+		if m := composeWarnTextRe.FindStringSubmatch(line); m != nil {
+			warnings = append(warnings, m[1])
+		}
+	}
+	return warnings
+}
+
+// renderCompose runs `docker compose config` against path from dir and
+// returns the fully resolved configuration it prints.
+func renderCompose(ctx context.Context, dockerArgs []string, dir, path string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "docker", append(slices.Clone(dockerArgs), "compose", "-f", path, "config")...)
+	cmd.Dir = dir
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		detail := strings.TrimSpace(stderr.String())
+		if detail == "" {
+			detail = err.Error()
+		}
+		return "", fmt.Errorf("%s", detail)
+	}
+	return stdout.String(), nil
+}
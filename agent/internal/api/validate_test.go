@@ -0,0 +1,28 @@
+package api
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseComposeWarnings(t *testing.T) {
+	stderr := `time="2024-05-01T10:00:00Z" level=warning msg="The \"NEWVAR\" variable is not set. Defaulting to a blank string."
+WARN[0000] The "OTHER" variable is not set. Defaulting to a blank string.
+some unrelated output
+`
+	got := parseComposeWarnings(stderr)
+	want := []string{
+		`The "NEWVAR" variable is not set. Defaulting to a blank string.`,
+		`The "OTHER" variable is not set. Defaulting to a blank string.`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseComposeWarnings = %q, want %q", got, want)
+	}
+}
+
+func TestParseComposeWarningsEmpty(t *testing.T) {
+	got := parseComposeWarnings("")
+	if got == nil || len(got) != 0 {
+		t.Errorf("want empty non-nil slice, got %#v", got)
+	}
+}
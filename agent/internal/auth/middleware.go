@@ -43,5 +43,5 @@ func (m *Middleware) Wrap(next http.Handler) http.Handler {
 }
 
 func (m *Middleware) isPublic(path string) bool {
-	return path == "/api/v1/health"
+	return path == "/api/v1/health" || path == "/api/v1/ready" || path == "/api/v1/openapi.json"
 }
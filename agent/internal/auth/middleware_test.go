@@ -22,6 +22,8 @@ func TestMiddleware(t *testing.T) {
 		wantStatus int
 	}{
 		{"health is public", "/api/v1/health", "", http.StatusOK},
+		{"ready is public", "/api/v1/ready", "", http.StatusOK},
+		{"openapi spec is public", "/api/v1/openapi.json", "", http.StatusOK},
 		{"missing header", "/api/v1/stacks", "", http.StatusUnauthorized},
 		{"invalid token", "/api/v1/stacks", "Bearer wrong", http.StatusUnauthorized},
 		{"valid token", "/api/v1/stacks", "Bearer test-token", http.StatusOK},
@@ -1,13 +1,18 @@
 package docker
 
 import (
+	"cmp"
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
-	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/build"
@@ -18,6 +23,8 @@ import (
 	"github.com/docker/docker/api/types/network"
 	"github.com/docker/docker/api/types/volume"
 	"github.com/docker/docker/client"
+
+	"github.com/driversti/hola/internal/registry"
 )
 
 const (
@@ -28,16 +35,92 @@ const (
 
 // Client wraps the Docker SDK client for stack/container operations.
 type Client struct {
-	cli *client.Client
+	cli         *client.Client
+	maxLogLines int
+}
+
+// DefaultMaxLogLines is the default cap on how many log lines one request
+// or log subscription may ask for.
+const DefaultMaxLogLines = 1000
+
+// Config selects the Docker daemon to connect to. Empty fields fall back to
+// the DOCKER_HOST, DOCKER_CERT_PATH and DOCKER_TLS_VERIFY environment, and
+// from there to the local socket.
+type Config struct {
+	Host    string // e.g. unix:///var/run/docker.sock or tcp://10.0.0.5:2376
+	TLSCert string // client certificate, for daemons that verify clients
+	TLSKey  string
+	TLSCA   string // CA that signed the daemon's certificate
+
+	// MaxLogLines caps the lines a log fetch or stream starts with; the
+	// lines are held in memory, so large values cost memory per request.
+	// Defaults to DefaultMaxLogLines.
+	MaxLogLines int
 }
 
-// NewClient creates a Docker client connected to the local socket.
-func NewClient() (*Client, error) {
-	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+// CLIArgs returns the docker CLI global flags that give the CLI the same
+// TLS settings as the client, for commands the agent shells out to.
+func (cfg Config) CLIArgs() []string {
+	if cfg.TLSCert == "" && cfg.TLSCA == "" {
+		return nil
+	}
+	args := []string{"--tlsverify"}
+	if cfg.TLSCA != "" {
+		args = append(args, "--tlscacert", cfg.TLSCA)
+	}
+	if cfg.TLSCert != "" {
+		args = append(args, "--tlscert", cfg.TLSCert, "--tlskey", cfg.TLSKey)
+	}
+	return args
+}
+
+// NewClient creates a Docker client for the daemon cfg selects. It doesn't
+// contact the daemon; use Ping for that.
+func NewClient(cfg Config) (*Client, error) {
+	if (cfg.TLSCert == "") != (cfg.TLSKey == "") {
+		return nil, errors.New("docker client: TLS certificate and key must be given together")
+	}
+
+	opts := []client.Opt{client.FromEnv, client.WithAPIVersionNegotiation()}
+	if cfg.Host != "" {
+		opts = append(opts, client.WithHost(cfg.Host))
+	}
+	if cfg.TLSCert != "" || cfg.TLSCA != "" {
+		opts = append(opts, client.WithTLSClientConfig(cfg.TLSCA, cfg.TLSCert, cfg.TLSKey))
+	}
+
+	cli, err := client.NewClientWithOpts(opts...)
 	if err != nil {
 		return nil, fmt.Errorf("docker client: %w", err)
 	}
-	return &Client{cli: cli}, nil
+	maxLogLines := cfg.MaxLogLines
+	if maxLogLines <= 0 {
+		maxLogLines = DefaultMaxLogLines
+	}
+	return &Client{cli: cli, maxLogLines: maxLogLines}, nil
+}
+
+// clampLogLines applies the default of 100 and the MaxLogLines cap to a
+// requested line count.
+func (c *Client) clampLogLines(lines int) int {
+	if lines <= 0 {
+		lines = 100
+	}
+	return min(lines, cmp.Or(c.maxLogLines, DefaultMaxLogLines))
+}
+
+// Endpoint returns the address of the daemon the client talks to.
+func (c *Client) Endpoint() string {
+	return c.cli.DaemonHost()
+}
+
+// ServerVersion returns the daemon's Docker version and API version.
+func (c *Client) ServerVersion(ctx context.Context) (version, apiVersion string, err error) {
+	v, err := c.cli.ServerVersion(ctx)
+	if err != nil {
+		return "", "", err
+	}
+	return v.Version, v.APIVersion, nil
 }
 
 // Close closes the underlying Docker client.
@@ -51,6 +134,22 @@ func (c *Client) Ping(ctx context.Context) error {
 	return err
 }
 
+// Rootless reports whether the daemon runs in rootless mode. The daemon's
+// security options are authoritative; if they can't be read, a socket under
+// /run/user/<uid> (the rootless default) is taken as the signal.
+func (c *Client) Rootless(ctx context.Context) bool {
+	info, err := c.cli.Info(ctx)
+	if err == nil {
+		for _, opt := range info.SecurityOptions {
+			if strings.Contains(opt, "name=rootless") {
+				return true
+			}
+		}
+		return false
+	}
+	return strings.Contains(c.cli.DaemonHost(), "/run/user/")
+}
+
 // Stack represents a Docker Compose stack discovered from container labels.
 type Stack struct {
 	Name         string `json:"name"`
@@ -59,6 +158,9 @@ type Stack struct {
 	RunningCount int    `json:"running_count"`
 	WorkingDir   string `json:"working_dir"`
 	Registered   bool   `json:"registered"`
+	Stale        bool   `json:"stale,omitempty"`         // registered, but the compose file is gone
+	RegisteredAt int64  `json:"registered_at,omitempty"` // Unix seconds; registered stacks only
+	UpdatedAt    int64  `json:"updated_at,omitempty"`
 }
 
 // StackDetail includes the container list for a stack.
@@ -71,13 +173,23 @@ type StackDetail struct {
 
 // ContainerInfo represents a container within a compose stack.
 type ContainerInfo struct {
-	ID        string `json:"id"`
-	Name      string `json:"name"`
-	Service   string `json:"service"`
-	Image     string `json:"image"`
-	Status    string `json:"status"`
-	State     string `json:"state"`
-	CreatedAt int64  `json:"created_at"`
+	ID        string        `json:"id"`
+	Name      string        `json:"name"`
+	Stack     string        `json:"stack,omitempty"`
+	Service   string        `json:"service"`
+	Image     string        `json:"image"`
+	Status    string        `json:"status"`
+	State     string        `json:"state"`
+	Health    string        `json:"health,omitempty"` // "healthy", "unhealthy" or "starting"; empty without a health check
+	Ports     []PortMapping `json:"ports"`
+	CreatedAt int64         `json:"created_at"`
+	OOMKilled bool          `json:"oom_killed"`
+	// RestartPolicy, StartedAt and UptimeSeconds are only filled in for
+	// stack details, as the container list API doesn't report them; the
+	// container list omits them.
+	RestartPolicy string `json:"restart_policy,omitempty"`
+	StartedAt     int64  `json:"started_at,omitempty"`     // Unix seconds of the last start; zero if never started
+	UptimeSeconds *int64 `json:"uptime_seconds,omitempty"` // zero unless running
 }
 
 // ListStacks discovers compose stacks by grouping containers by project label.
@@ -140,6 +252,36 @@ func (c *Client) ListStacks(ctx context.Context) ([]Stack, error) {
 	return result, nil
 }
 
+// StackStatus recomputes one stack's aggregate status the way ListStacks
+// does. A stack with no containers left (e.g. after down) is reported as
+// stopped with zero counts rather than as an error.
+func (c *Client) StackStatus(ctx context.Context, name string) (*Stack, error) {
+	containers, err := c.cli.ContainerList(ctx, container.ListOptions{
+		All:     true,
+		Filters: filters.NewArgs(filters.Arg("label", labelProject+"="+name)),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list containers: %w", err)
+	}
+
+	st := &Stack{Name: name}
+	services := make(map[string]bool)
+	for _, ctr := range containers {
+		if st.WorkingDir == "" {
+			st.WorkingDir = ctr.Labels[labelWorkingDir]
+		}
+		if service := ctr.Labels[labelService]; service != "" && !services[service] {
+			services[service] = true
+			st.ServiceCount++
+		}
+		if ctr.State == "running" {
+			st.RunningCount++
+		}
+	}
+	st.Status = stackStatus(st.ServiceCount, st.RunningCount)
+	return st, nil
+}
+
 // GetStack returns detailed info for a named stack including its containers.
 func (c *Client) GetStack(ctx context.Context, name string) (*StackDetail, error) {
 	containers, err := c.cli.ContainerList(ctx, container.ListOptions{All: true})
@@ -152,6 +294,7 @@ func (c *Client) GetStack(ctx context.Context, name string) (*StackDetail, error
 		Containers: []ContainerInfo{},
 	}
 	runningCount := 0
+	var ids []string // full IDs, parallel to detail.Containers
 
 	for _, ctr := range containers {
 		if ctr.Labels[labelProject] != name {
@@ -164,15 +307,19 @@ func (c *Client) GetStack(ctx context.Context, name string) (*StackDetail, error
 
 		containerName := strings.TrimPrefix(ctr.Names[0], "/")
 
-		detail.Containers = append(detail.Containers, ContainerInfo{
+		info := ContainerInfo{
 			ID:        ctr.ID[:12],
 			Name:      containerName,
 			Service:   ctr.Labels[labelService],
 			Image:     ctr.Image,
 			Status:    ctr.Status,
 			State:     ctr.State,
+			Health:    healthFromStatus(ctr.Status),
+			Ports:     portMappings(ctr.Ports),
 			CreatedAt: ctr.Created,
-		})
+		}
+		detail.Containers = append(detail.Containers, info)
+		ids = append(ids, ctr.ID)
 
 		if ctr.State == "running" {
 			runningCount++
@@ -185,6 +332,19 @@ func (c *Client) GetStack(ctx context.Context, name string) (*StackDetail, error
 
 	detail.Status = stackStatus(len(detail.Containers), runningCount)
 
+	sem := make(chan struct{}, inspectConcurrency)
+	var wg sync.WaitGroup
+	for i := range detail.Containers {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			c.inspectExtras(ctx, ids[i], &detail.Containers[i])
+		}()
+	}
+	wg.Wait()
+
 	sort.Slice(detail.Containers, func(i, j int) bool {
 		return detail.Containers[i].Service < detail.Containers[j].Service
 	})
@@ -192,10 +352,51 @@ func (c *Client) GetStack(ctx context.Context, name string) (*StackDetail, error
 	return detail, nil
 }
 
-// ComposeFile reads the compose file for a named stack.
+// inspectConcurrency bounds the container inspects GetStack runs at once.
+const inspectConcurrency = 8
+
+// inspectExtras fills in the fields the list API doesn't carry: the restart
+// policy, when the container last started and, if running, for how long,
+// and whether a stopped container was last killed by the OOM killer
+// (running containers can't have been). Inspect failures leave them unset.
+func (c *Client) inspectExtras(ctx context.Context, id string, info *ContainerInfo) {
+	resp, err := c.cli.ContainerInspect(ctx, id)
+	if err != nil {
+		return
+	}
+	if resp.HostConfig != nil {
+		info.RestartPolicy = string(resp.HostConfig.RestartPolicy.Name)
+	}
+	if resp.State != nil && (info.State == "exited" || info.State == "dead") {
+		info.OOMKilled = resp.State.OOMKilled
+	}
+	if resp.State != nil {
+		started, up := uptime(resp.State.StartedAt, resp.State.Running, time.Now())
+		info.StartedAt, info.UptimeSeconds = started, &up
+	}
+}
+
+// uptime converts inspect's StartedAt into Unix seconds and, for a running
+// container, the seconds it has been up at now. Docker reports
+// "0001-01-01T00:00:00Z" for containers that never started.
+func uptime(startedAt string, running bool, now time.Time) (started, up int64) {
+	t, err := time.Parse(time.RFC3339Nano, startedAt)
+	if err != nil || t.Year() <= 1 {
+		return 0, 0
+	}
+	if running {
+		up = max(int64(now.Sub(t)/time.Second), 0)
+	}
+	return t.Unix(), up
+}
+
+// ComposeFile is a stack's compose file. Overrides holds the further files
+// compose merges on top of it, such as docker-compose.override.yml or the
+// rest of a COMPOSE_FILE list.
 type ComposeFile struct {
-	Content string `json:"content"`
-	Path    string `json:"path"`
+	Content   string        `json:"content"`
+	Path      string        `json:"path"`
+	Overrides []ComposeFile `json:"overrides,omitempty"`
 }
 
 // GetComposeFile reads the compose file from the stack's working directory.
@@ -209,26 +410,7 @@ func (c *Client) GetComposeFile(ctx context.Context, stackName string) (*Compose
 		return nil, fmt.Errorf("no working directory found for stack %q", stackName)
 	}
 
-	// Try common compose file names
-	candidates := []string{
-		"docker-compose.yml",
-		"docker-compose.yaml",
-		"compose.yml",
-		"compose.yaml",
-	}
-
-	for _, name := range candidates {
-		path := filepath.Join(detail.WorkingDir, name)
-		data, err := os.ReadFile(path)
-		if err == nil {
-			return &ComposeFile{
-				Content: string(data),
-				Path:    path,
-			}, nil
-		}
-	}
-
-	return nil, fmt.Errorf("compose file not found in %s", detail.WorkingDir)
+	return c.GetComposeFileFromDir(detail.WorkingDir)
 }
 
 // GetComposeFileFromDir reads the compose file from a given directory
@@ -238,25 +420,26 @@ func (c *Client) GetComposeFileFromDir(workingDir string) (*ComposeFile, error)
 		return nil, fmt.Errorf("working directory is empty")
 	}
 
-	candidates := []string{
-		"docker-compose.yml",
-		"docker-compose.yaml",
-		"compose.yml",
-		"compose.yaml",
+	paths := registry.ComposeFiles(workingDir)
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("compose file not found in %s", workingDir)
 	}
 
-	for _, name := range candidates {
-		path := filepath.Join(workingDir, name)
+	files := make([]ComposeFile, 0, len(paths))
+	for _, path := range paths {
 		data, err := os.ReadFile(path)
-		if err == nil {
-			return &ComposeFile{
-				Content: string(data),
-				Path:    path,
-			}, nil
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil, fmt.Errorf("compose file not found: %s", path)
+			}
+			return nil, fmt.Errorf("read compose file: %w", err)
 		}
+		files = append(files, ComposeFile{Content: string(data), Path: path})
 	}
 
-	return nil, fmt.Errorf("compose file not found in %s", workingDir)
+	cf := files[0]
+	cf.Overrides = files[1:]
+	return &cf, nil
 }
 
 // ContainerLogs returns the last N lines of logs for a container.
@@ -264,16 +447,96 @@ type LogEntry struct {
 	Timestamp string `json:"timestamp"`
 	Stream    string `json:"stream"`
 	Message   string `json:"message"`
+	Kind      string `json:"kind,omitempty"`      // set when filtering: "match" or "context"
+	Container string `json:"container,omitempty"` // set when logs of several containers are merged
+	Service   string `json:"service,omitempty"`   // set when logs of a whole stack are merged
 }
 
-// GetContainerLogs retrieves logs from a container.
-func (c *Client) GetContainerLogs(ctx context.Context, containerID string, lines int, since string) ([]LogEntry, string, string, error) {
-	if lines <= 0 {
-		lines = 100
+// ListContainers returns every container on the host, running or not,
+// ordered by name. Stack and Service are empty for non-compose containers.
+func (c *Client) ListContainers(ctx context.Context) ([]ContainerInfo, error) {
+	containers, err := c.cli.ContainerList(ctx, container.ListOptions{All: true})
+	if err != nil {
+		return nil, fmt.Errorf("list containers: %w", err)
 	}
-	if lines > 1000 {
-		lines = 1000
+
+	result := make([]ContainerInfo, 0, len(containers))
+	for _, ctr := range containers {
+		result = append(result, ContainerInfo{
+			ID:        ctr.ID[:12],
+			Name:      strings.TrimPrefix(ctr.Names[0], "/"),
+			Stack:     ctr.Labels[labelProject],
+			Service:   ctr.Labels[labelService],
+			Image:     ctr.Image,
+			Status:    ctr.Status,
+			State:     ctr.State,
+			Health:    healthFromStatus(ctr.Status),
+			Ports:     portMappings(ctr.Ports),
+			CreatedAt: ctr.Created,
+		})
 	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result, nil
+}
+
+// ServiceContainers returns the containers (running or not) that belong to a
+// service of a compose stack, ordered by name.
+func (c *Client) ServiceContainers(ctx context.Context, stack, service string) ([]ContainerInfo, error) {
+	containers, err := c.cli.ContainerList(ctx, container.ListOptions{
+		All: true,
+		Filters: filters.NewArgs(
+			filters.Arg("label", labelProject+"="+stack),
+			filters.Arg("label", labelService+"="+service),
+		),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list containers: %w", err)
+	}
+
+	result := make([]ContainerInfo, 0, len(containers))
+	for _, ctr := range containers {
+		result = append(result, ContainerInfo{
+			ID:        ctr.ID[:12],
+			Name:      strings.TrimPrefix(ctr.Names[0], "/"),
+			Service:   service,
+			Image:     ctr.Image,
+			Status:    ctr.Status,
+			State:     ctr.State,
+			Health:    healthFromStatus(ctr.Status),
+			Ports:     portMappings(ctr.Ports),
+			CreatedAt: ctr.Created,
+		})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result, nil
+}
+
+// DefaultMaxLogBytes bounds how much log output GetContainerLogs keeps when
+// the caller sets no limit.
+const DefaultMaxLogBytes = 16 << 20
+
+// ContainerLogs is the result of a one-shot log fetch.
+type ContainerLogs struct {
+	ID      string
+	Name    string
+	Entries []LogEntry
+	// Truncated is set when older lines were dropped to stay within the
+	// byte limit, or the daemon's output ended partway through a frame.
+	Truncated bool
+}
+
+// GetContainerLogs retrieves logs from a container, keeping the newest lines
+// whose messages add up to at most maxBytes (DefaultMaxLogBytes when not
+// positive).
+func (c *Client) GetContainerLogs(ctx context.Context, containerID string, lines int, since string, maxBytes int) (*ContainerLogs, error) {
+	if maxBytes <= 0 {
+		maxBytes = DefaultMaxLogBytes
+	}
+	lines = c.clampLogLines(lines)
 
 	opts := container.LogsOptions{
 		ShowStdout: true,
@@ -285,72 +548,28 @@ func (c *Client) GetContainerLogs(ctx context.Context, containerID string, lines
 		opts.Since = since
 	}
 
+	// Resolve container name and ID for response, and whether it has a
+	// TTY, which changes the log format.
+	inspect, inspErr := c.cli.ContainerInspect(ctx, containerID)
+	result := &ContainerLogs{}
+	var tty bool
+	if inspErr == nil {
+		result.Name = strings.TrimPrefix(inspect.Name, "/")
+		result.ID = inspect.ID[:12]
+		tty = inspect.Config != nil && inspect.Config.Tty
+	}
+
 	reader, err := c.cli.ContainerLogs(ctx, containerID, opts)
 	if err != nil {
-		return nil, "", "", fmt.Errorf("get logs: %w", err)
+		return nil, fmt.Errorf("get logs: %w", err)
 	}
 	defer reader.Close()
 
-	// Docker log output has an 8-byte header per frame:
-	// [stream_type(1)][0(3)][size(4)][payload]
-	// stream_type: 1=stdout, 2=stderr
-	raw, err := io.ReadAll(reader)
+	result.Entries, result.Truncated, err = readLogTail(newLogStream(reader, tty), maxBytes)
 	if err != nil {
-		return nil, "", "", fmt.Errorf("read logs: %w", err)
-	}
-
-	// Resolve container name and ID for response
-	inspect, inspErr := c.cli.ContainerInspect(ctx, containerID)
-	var cName, cID string
-	if inspErr == nil {
-		cName = strings.TrimPrefix(inspect.Name, "/")
-		cID = inspect.ID[:12]
-	}
-
-	entries := parseLogFrames(raw)
-	return entries, cID, cName, nil
-}
-
-func parseLogFrames(raw []byte) []LogEntry {
-	var entries []LogEntry
-	pos := 0
-
-	for pos+8 <= len(raw) {
-		streamType := raw[pos]
-		frameSize := int(raw[pos+4])<<24 | int(raw[pos+5])<<16 | int(raw[pos+6])<<8 | int(raw[pos+7])
-		pos += 8
-
-		if pos+frameSize > len(raw) {
-			break
-		}
-
-		line := string(raw[pos : pos+frameSize])
-		pos += frameSize
-
-		line = strings.TrimRight(line, "\n")
-
-		stream := "stdout"
-		if streamType == 2 {
-			stream = "stderr"
-		}
-
-		// Timestamp is the first space-separated token
-		var timestamp, message string
-		if idx := strings.IndexByte(line, ' '); idx > 0 {
-			timestamp = line[:idx]
-			message = line[idx+1:]
-		} else {
-			message = line
-		}
-
-		entries = append(entries, LogEntry{
-			Timestamp: timestamp,
-			Stream:    stream,
-			Message:   message,
-		})
+		return nil, fmt.Errorf("read logs: %w", err)
 	}
-
-	return entries
+	return result, nil
 }
 
 // StartContainer starts a stopped container.
@@ -358,16 +577,6 @@ func (c *Client) StartContainer(ctx context.Context, containerID string) error {
 	return c.cli.ContainerStart(ctx, containerID, container.StartOptions{})
 }
 
-// StopContainer stops a running container.
-func (c *Client) StopContainer(ctx context.Context, containerID string) error {
-	return c.cli.ContainerStop(ctx, containerID, container.StopOptions{})
-}
-
-// RestartContainer restarts a container.
-func (c *Client) RestartContainer(ctx context.Context, containerID string) error {
-	return c.cli.ContainerRestart(ctx, containerID, container.StopOptions{})
-}
-
 // Events returns channels for Docker container events.
 func (c *Client) Events(ctx context.Context) (<-chan events.Message, <-chan error) {
 	return c.cli.Events(ctx, events.ListOptions{
@@ -376,19 +585,35 @@ func (c *Client) Events(ctx context.Context) (<-chan events.Message, <-chan erro
 }
 
 // StreamContainerLogs returns a streaming reader for a container's logs.
-// The caller is responsible for closing the returned reader.
-func (c *Client) StreamContainerLogs(ctx context.Context, containerID string, tail string) (io.ReadCloser, error) {
+// since takes the same forms as the Docker API (RFC3339 or Unix seconds);
+// when set, tail should normally be "all" so no lines after since are cut.
+// A numeric tail is capped at MaxLogLines.
+// The caller is responsible for closing the returned stream.
+func (c *Client) StreamContainerLogs(ctx context.Context, containerID string, tail, since string) (*LogStream, error) {
 	if tail == "" {
 		tail = "50"
+	} else if n, err := strconv.Atoi(tail); err == nil {
+		tail = strconv.Itoa(c.clampLogLines(n))
+	}
+
+	// TTY containers log a raw stream instead of multiplexed frames.
+	inspect, err := c.InspectContainer(ctx, containerID)
+	if err != nil {
+		return nil, err
 	}
 
-	return c.cli.ContainerLogs(ctx, containerID, container.LogsOptions{
+	rc, err := c.cli.ContainerLogs(ctx, containerID, container.LogsOptions{
 		ShowStdout: true,
 		ShowStderr: true,
 		Timestamps: true,
 		Follow:     true,
 		Tail:       tail,
+		Since:      since,
 	})
+	if err != nil {
+		return nil, err
+	}
+	return newLogStream(rc, inspect.Config != nil && inspect.Config.Tty), nil
 }
 
 // ContainerStats returns a streaming reader for a container's resource stats.
@@ -402,6 +627,21 @@ func (c *Client) ContainerStats(ctx context.Context, containerID string) (io.Rea
 	return resp.Body, nil
 }
 
+// ContainerStateCounts returns the number of containers in each state
+// (running, exited, paused, ...).
+func (c *Client) ContainerStateCounts(ctx context.Context) (map[string]int, error) {
+	containers, err := c.cli.ContainerList(ctx, container.ListOptions{All: true})
+	if err != nil {
+		return nil, fmt.Errorf("container list: %w", err)
+	}
+
+	counts := make(map[string]int)
+	for _, ctr := range containers {
+		counts[ctr.State]++
+	}
+	return counts, nil
+}
+
 // --- Docker resource management ---
 
 // DiskUsage returns an aggregated summary of Docker resource usage.
@@ -525,13 +765,13 @@ func (c *Client) RemoveImage(ctx context.Context, id string, force bool) error {
 	return nil
 }
 
-// PruneImages removes unused images. If dryRun is true, returns what would be removed.
-func (c *Client) PruneImages(ctx context.Context, dryRun bool) (*PruneResult, error) {
+// PruneImages removes unused images matching filter. If dryRun is true, returns what would be removed.
+func (c *Client) PruneImages(ctx context.Context, dryRun bool, filter PruneFilter) (*PruneResult, error) {
 	if dryRun {
-		return c.pruneImagesDryRun(ctx)
+		return c.pruneImagesDryRun(ctx, filter)
 	}
 
-	report, err := c.cli.ImagesPrune(ctx, filters.NewArgs(filters.Arg("dangling", "false")))
+	report, err := c.cli.ImagesPrune(ctx, filter.args(filters.Arg("dangling", "false")))
 	if err != nil {
 		return nil, fmt.Errorf("prune images: %w", err)
 	}
@@ -551,16 +791,30 @@ func (c *Client) PruneImages(ctx context.Context, dryRun bool) (*PruneResult, er
 	}, nil
 }
 
-func (c *Client) pruneImagesDryRun(ctx context.Context) (*PruneResult, error) {
+func (c *Client) pruneImagesDryRun(ctx context.Context, filter PruneFilter) (*PruneResult, error) {
 	images, err := c.ListImages(ctx)
 	if err != nil {
 		return nil, err
 	}
 
+	// ImageInfo doesn't carry labels, so take them from the raw list.
+	var labels map[string]map[string]string
+	if len(filter.Labels) > 0 {
+		raw, err := c.cli.ImageList(ctx, image.ListOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("image list: %w", err)
+		}
+		labels = make(map[string]map[string]string, len(raw))
+		for _, img := range raw {
+			labels[img.ID] = img.Labels
+		}
+	}
+
+	now := time.Now()
 	var items []string
 	var reclaimable int64
 	for _, img := range images {
-		if !img.InUse {
+		if !img.InUse && filter.matches(time.Unix(img.Created, 0), labels[img.ID], now) {
 			label := img.ID[:12]
 			if len(img.Tags) > 0 && img.Tags[0] != "<none>:<none>" {
 				label = img.Tags[0]
@@ -792,12 +1046,16 @@ func (c *Client) pruneNetworksDryRun(ctx context.Context) (*PruneResult, error)
 }
 
 // PruneBuildCache clears the Docker build cache. If dryRun is true, returns what would be removed.
-func (c *Client) PruneBuildCache(ctx context.Context, dryRun bool) (*PruneResult, error) {
+// Build cache records have no labels, so only filter.Until applies.
+func (c *Client) PruneBuildCache(ctx context.Context, dryRun bool, filter PruneFilter) (*PruneResult, error) {
+	if len(filter.Labels) > 0 {
+		return nil, fmt.Errorf("%w: build cache cannot be filtered by label", ErrInvalidSpec)
+	}
 	if dryRun {
-		return c.pruneBuildCacheDryRun(ctx)
+		return c.pruneBuildCacheDryRun(ctx, filter)
 	}
 
-	report, err := c.cli.BuildCachePrune(ctx, build.CachePruneOptions{All: true})
+	report, err := c.cli.BuildCachePrune(ctx, build.CachePruneOptions{All: true, Filters: filter.args()})
 	if err != nil {
 		return nil, fmt.Errorf("prune build cache: %w", err)
 	}
@@ -824,7 +1082,7 @@ func (c *Client) PruneBuildCache(ctx context.Context, dryRun bool) (*PruneResult
 	}, nil
 }
 
-func (c *Client) pruneBuildCacheDryRun(ctx context.Context) (*PruneResult, error) {
+func (c *Client) pruneBuildCacheDryRun(ctx context.Context, filter PruneFilter) (*PruneResult, error) {
 	du, err := c.cli.DiskUsage(ctx, types.DiskUsageOptions{
 		Types: []types.DiskUsageObject{types.BuildCacheObject},
 	})
@@ -832,10 +1090,15 @@ func (c *Client) pruneBuildCacheDryRun(ctx context.Context) (*PruneResult, error
 		return nil, fmt.Errorf("disk usage for build cache: %w", err)
 	}
 
+	now := time.Now()
 	var items []string
 	var totalSize int64
 	for _, bc := range du.BuildCache {
-		if !bc.InUse {
+		lastUsed := bc.CreatedAt
+		if bc.LastUsedAt != nil {
+			lastUsed = *bc.LastUsedAt
+		}
+		if !bc.InUse && filter.matches(lastUsed, nil, now) {
 			items = append(items, bc.Description)
 			totalSize += bc.Size
 		}
@@ -0,0 +1,69 @@
+package docker
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestClampLogLines(t *testing.T) {
+	tests := []struct {
+		max, lines, want int
+	}{
+		{0, 0, 100},
+		{0, 500, 500},
+		{0, 5000, DefaultMaxLogLines},
+		{5000, 5000, 5000},
+		{5000, 9000, 5000},
+		{50, 100, 50},
+		{50, 0, 50},
+	}
+	for _, tt := range tests {
+		c, err := NewClient(Config{Host: "tcp://127.0.0.1:2375", MaxLogLines: tt.max})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := c.clampLogLines(tt.lines); got != tt.want {
+			t.Errorf("max %d: clampLogLines(%d) = %d, want %d", tt.max, tt.lines, got, tt.want)
+		}
+	}
+}
+
+func TestUptime(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		startedAt   string
+		running     bool
+		wantStarted int64
+		wantUp      int64
+	}{
+		{"2024-05-01T11:00:00.123456789Z", true, now.Add(-time.Hour).Unix(), 3599},
+		{"2024-05-01T11:00:00Z", false, now.Add(-time.Hour).Unix(), 0},
+		{"0001-01-01T00:00:00Z", false, 0, 0},
+		{"", false, 0, 0},
+	}
+	for _, tt := range tests {
+		started, up := uptime(tt.startedAt, tt.running, now)
+		if started != tt.wantStarted || up != tt.wantUp {
+			t.Errorf("uptime(%q, %v) = %d, %d; want %d, %d", tt.startedAt, tt.running, started, up, tt.wantStarted, tt.wantUp)
+		}
+	}
+}
+
+func TestConfigCLIArgs(t *testing.T) {
+	tests := []struct {
+		cfg  Config
+		want []string
+	}{
+		{Config{Host: "tcp://10.0.0.5:2376"}, nil},
+		{Config{TLSCA: "/certs/ca.pem"}, []string{"--tlsverify", "--tlscacert", "/certs/ca.pem"}},
+		{Config{TLSCert: "/c.pem", TLSKey: "/k.pem"}, []string{"--tlsverify", "--tlscert", "/c.pem", "--tlskey", "/k.pem"}},
+		{Config{TLSCA: "/ca.pem", TLSCert: "/c.pem", TLSKey: "/k.pem"},
+			[]string{"--tlsverify", "--tlscacert", "/ca.pem", "--tlscert", "/c.pem", "--tlskey", "/k.pem"}},
+	}
+	for _, tt := range tests {
+		if got := tt.cfg.CLIArgs(); !slices.Equal(got, tt.want) {
+			t.Errorf("%+v: CLIArgs() = %q, want %q", tt.cfg, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package docker
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// composeServices is the subset of a compose file needed to order services.
+type composeServices struct {
+	Services map[string]struct {
+		DependsOn dependsOn `yaml:"depends_on"`
+	} `yaml:"services"`
+}
+
+// dependsOn accepts both the short list form and the long map form of
+// depends_on.
+type dependsOn []string
+
+func (d *dependsOn) UnmarshalYAML(node *yaml.Node) error {
+	switch node.Kind {
+	case yaml.SequenceNode:
+		var list []string
+		if err := node.Decode(&list); err != nil {
+			return err
+		}
+		*d = list
+	case yaml.MappingNode:
+		var m map[string]yaml.Node
+		if err := node.Decode(&m); err != nil {
+			return err
+		}
+		for name := range m {
+			*d = append(*d, name)
+		}
+	default:
+		return fmt.Errorf("depends_on: unexpected YAML kind %d", node.Kind)
+	}
+	return nil
+}
+
+// ServiceOrder returns the services of a compose project in dependency
+// order: every service appears after the services it depends on. Services
+// that become ready at the same time are ordered by name. When several
+// files are given they are merged as compose does, so an override file can
+// add services or dependencies.
+func ServiceOrder(contents ...[]byte) ([]string, error) {
+	services := make(map[string][]string)
+	for _, content := range contents {
+		var cf composeServices
+		if err := yaml.Unmarshal(content, &cf); err != nil {
+			return nil, fmt.Errorf("parse compose file: %w", err)
+		}
+		for name, svc := range cf.Services {
+			deps := services[name]
+			for _, dep := range svc.DependsOn {
+				if !slices.Contains(deps, dep) {
+					deps = append(deps, dep)
+				}
+			}
+			services[name] = deps
+		}
+	}
+
+	indegree := make(map[string]int, len(services))
+	dependents := make(map[string][]string)
+	for name, deps := range services {
+		if _, ok := indegree[name]; !ok {
+			indegree[name] = 0
+		}
+		for _, dep := range deps {
+			if _, ok := services[dep]; !ok {
+				return nil, fmt.Errorf("service %q depends on undefined service %q", name, dep)
+			}
+			indegree[name]++
+			dependents[dep] = append(dependents[dep], name)
+		}
+	}
+
+	var ready []string
+	for name, n := range indegree {
+		if n == 0 {
+			ready = append(ready, name)
+		}
+	}
+
+	order := make([]string, 0, len(indegree))
+	for len(ready) > 0 {
+		sort.Strings(ready)
+		name := ready[0]
+		ready = ready[1:]
+		order = append(order, name)
+		for _, dep := range dependents[name] {
+			indegree[dep]--
+			if indegree[dep] == 0 {
+				ready = append(ready, dep)
+			}
+		}
+	}
+
+	if len(order) != len(indegree) {
+		return nil, fmt.Errorf("dependency cycle between services")
+	}
+	return order, nil
+}
@@ -0,0 +1,83 @@
+package docker
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestServiceOrder(t *testing.T) {
+	content := []byte(`
+services:
+  web:
+    image: nginx
+    depends_on:
+      api:
+        condition: service_healthy
+  api:
+    image: api
+    depends_on: [db, cache]
+  db:
+    image: postgres
+  cache:
+    image: redis
+`)
+
+	got, err := ServiceOrder(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"cache", "db", "api", "web"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ServiceOrder = %v, want %v", got, want)
+	}
+}
+
+func TestServiceOrder_Cycle(t *testing.T) {
+	content := []byte(`
+services:
+  a:
+    depends_on: [b]
+  b:
+    depends_on: [a]
+`)
+	if _, err := ServiceOrder(content); err == nil {
+		t.Fatal("want error for dependency cycle")
+	}
+}
+
+func TestServiceOrder_UndefinedDependency(t *testing.T) {
+	content := []byte(`
+services:
+  a:
+    depends_on: [missing]
+`)
+	if _, err := ServiceOrder(content); err == nil {
+		t.Fatal("want error for undefined dependency")
+	}
+}
+
+func TestServiceOrder_Override(t *testing.T) {
+	base := []byte(`
+services:
+  web:
+    image: nginx
+  db:
+    image: postgres
+`)
+	override := []byte(`
+services:
+  web:
+    depends_on: [db, debug]
+  debug:
+    image: busybox
+`)
+
+	got, err := ServiceOrder(base, override)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"db", "debug", "web"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ServiceOrder = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,80 @@
+package docker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	cerrdefs "github.com/containerd/errdefs"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/api/types/volume"
+)
+
+var (
+	// ErrAlreadyExists is returned when creating a volume or network whose
+	// name is taken.
+	ErrAlreadyExists = errors.New("already exists")
+	// ErrBuiltinNetwork is returned when creating a network named like one
+	// of Docker's predefined networks.
+	ErrBuiltinNetwork = errors.New("reserved network name")
+	// ErrInvalidSpec is returned when the daemon rejects a create request's
+	// parameters, such as a malformed name or unknown driver.
+	ErrInvalidSpec = errors.New("invalid parameters")
+)
+
+// CreateVolume creates a named volume. Docker treats creating an existing
+// volume as a no-op, so the name is checked first to report the clash.
+func (c *Client) CreateVolume(ctx context.Context, name, driver string, labels map[string]string) (*VolumeInfo, error) {
+	if _, err := c.cli.VolumeInspect(ctx, name); err == nil {
+		return nil, fmt.Errorf("volume %q %w", name, ErrAlreadyExists)
+	} else if !cerrdefs.IsNotFound(err) {
+		return nil, fmt.Errorf("volume inspect: %w", err)
+	}
+
+	vol, err := c.cli.VolumeCreate(ctx, volume.CreateOptions{Name: name, Driver: driver, Labels: labels})
+	if err != nil {
+		return nil, createError("volume", name, err)
+	}
+	return &VolumeInfo{
+		Name:       vol.Name,
+		Driver:     vol.Driver,
+		Created:    vol.CreatedAt,
+		Containers: []string{},
+	}, nil
+}
+
+// CreateNetwork creates a network. Names of Docker's predefined networks
+// are refused up front.
+func (c *Client) CreateNetwork(ctx context.Context, name, driver string, internal bool, labels map[string]string) (*NetworkInfo, error) {
+	if isBuiltinNetwork(name) {
+		return nil, fmt.Errorf("%w: %q", ErrBuiltinNetwork, name)
+	}
+
+	resp, err := c.cli.NetworkCreate(ctx, name, network.CreateOptions{Driver: driver, Internal: internal, Labels: labels})
+	if err != nil {
+		return nil, createError("network", name, err)
+	}
+
+	n, err := c.cli.NetworkInspect(ctx, resp.ID, network.InspectOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("network inspect: %w", err)
+	}
+	return &NetworkInfo{
+		ID:         n.ID,
+		Name:       n.Name,
+		Driver:     n.Driver,
+		Scope:      n.Scope,
+		Internal:   n.Internal,
+		Containers: []string{},
+	}, nil
+}
+
+func createError(kind, name string, err error) error {
+	switch {
+	case cerrdefs.IsConflict(err):
+		return fmt.Errorf("%s %q %w", kind, name, ErrAlreadyExists)
+	case cerrdefs.IsInvalidArgument(err):
+		return fmt.Errorf("%w: %s", ErrInvalidSpec, err)
+	}
+	return fmt.Errorf("create %s: %w", kind, err)
+}
@@ -0,0 +1,162 @@
+package docker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	cerrdefs "github.com/containerd/errdefs"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+// ErrContainerNotRunning is returned when a command is executed in a
+// container that isn't running.
+var ErrContainerNotRunning = errors.New("container is not running")
+
+// ExecResult is the outcome of a command run to completion with Exec.
+type ExecResult struct {
+	ExitCode int    `json:"exit_code"`
+	Output   string `json:"output"` // stdout and stderr interleaved as produced
+	// Truncated is set when the command wrote more than the output limit;
+	// the rest was read and discarded so the command could finish.
+	Truncated bool `json:"truncated"`
+}
+
+// ExecSession is a command running inside a container with its standard
+// streams attached. Callers must Close it.
+type ExecSession struct {
+	cli  *client.Client
+	id   string
+	tty  bool
+	resp types.HijackedResponse
+}
+
+// StartExec starts cmd in a running container and attaches to its output,
+// and to its stdin when stdin is set. With tty the command gets a
+// pseudo-terminal and its stdout and stderr arrive as one stream.
+func (c *Client) StartExec(ctx context.Context, containerID string, cmd []string, tty, stdin bool) (*ExecSession, error) {
+	created, err := c.cli.ContainerExecCreate(ctx, containerID, container.ExecOptions{
+		Cmd:          cmd,
+		Tty:          tty,
+		AttachStdin:  stdin,
+		AttachStdout: true,
+		AttachStderr: true,
+	})
+	if err != nil {
+		switch {
+		case cerrdefs.IsNotFound(err):
+			return nil, fmt.Errorf("%w: %s", ErrContainerNotFound, containerID)
+		case cerrdefs.IsConflict(err):
+			return nil, fmt.Errorf("%w: %s", ErrContainerNotRunning, containerID)
+		}
+		return nil, fmt.Errorf("exec create: %w", err)
+	}
+
+	resp, err := c.cli.ContainerExecAttach(ctx, created.ID, container.ExecAttachOptions{Tty: tty})
+	if err != nil {
+		return nil, fmt.Errorf("exec attach: %w", err)
+	}
+	return &ExecSession{cli: c.cli, id: created.ID, tty: tty, resp: resp}, nil
+}
+
+// Write sends p to the command's stdin.
+func (s *ExecSession) Write(p []byte) (int, error) {
+	return s.resp.Conn.Write(p)
+}
+
+// CloseStdin signals end of input to the command.
+func (s *ExecSession) CloseStdin() error {
+	return s.resp.CloseWrite()
+}
+
+// Copy copies the command's output until it exits or the session is
+// closed. Without a tty Docker multiplexes stdout and stderr into frames,
+// which are split back out here; with a tty everything goes to stdout.
+func (s *ExecSession) Copy(stdout, stderr io.Writer) error {
+	if s.tty {
+		_, err := io.Copy(stdout, s.resp.Reader)
+		return err
+	}
+	_, err := stdcopy.StdCopy(stdout, stderr, s.resp.Reader)
+	return err
+}
+
+// ExitCode returns the command's exit status once its output has ended.
+// The daemon can report the exec as running for a moment after the
+// stream closes, so it is polled until the status settles.
+func (s *ExecSession) ExitCode(ctx context.Context) (int, error) {
+	for {
+		info, err := s.cli.ContainerExecInspect(ctx, s.id)
+		if err != nil {
+			return 0, fmt.Errorf("exec inspect: %w", err)
+		}
+		if !info.Running {
+			return info.ExitCode, nil
+		}
+		select {
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+}
+
+// Close detaches from the command. Docker has no way to kill an exec, so
+// a command that is still running keeps running without its streams.
+func (s *ExecSession) Close() {
+	s.resp.Close()
+}
+
+// Exec runs cmd in a container, waits for it to finish and returns its
+// combined output, of which at most maxOutput bytes are kept. If ctx ends
+// first the session is closed and ctx's error returned.
+func (c *Client) Exec(ctx context.Context, containerID string, cmd []string, tty bool, maxOutput int) (*ExecResult, error) {
+	sess, err := c.StartExec(ctx, containerID, cmd, tty, false)
+	if err != nil {
+		return nil, err
+	}
+	defer sess.Close()
+
+	// Reads on the hijacked connection don't observe ctx, so closing the
+	// session is what unblocks Copy on timeout.
+	stop := context.AfterFunc(ctx, sess.Close)
+	defer stop()
+
+	out := &cappedBuffer{max: maxOutput}
+	if err := sess.Copy(out, out); err != nil && ctx.Err() == nil {
+		return nil, fmt.Errorf("exec output: %w", err)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	code, err := sess.ExitCode(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &ExecResult{ExitCode: code, Output: string(out.buf), Truncated: out.truncated}, nil
+}
+
+// cappedBuffer keeps the first max bytes written to it and silently drops
+// the rest, so a chatty command is drained instead of blocked.
+type cappedBuffer struct {
+	buf       []byte
+	max       int
+	truncated bool
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	room := b.max - len(b.buf)
+	if len(p) > room {
+		b.truncated = true
+		b.buf = append(b.buf, p[:max(room, 0)]...)
+		return len(p), nil
+	}
+	b.buf = append(b.buf, p...)
+	return len(p), nil
+}
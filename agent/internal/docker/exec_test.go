@@ -0,0 +1,29 @@
+package docker
+
+import "testing"
+
+func TestCappedBuffer(t *testing.T) {
+	b := &cappedBuffer{max: 8}
+
+	for _, chunk := range []string{"hello", " world", "!"} {
+		n, err := b.Write([]byte(chunk))
+		if err != nil || n != len(chunk) {
+			t.Fatalf("Write(%q) = %d, %v; want %d, nil", chunk, n, err, len(chunk))
+		}
+	}
+
+	if got := string(b.buf); got != "hello wo" {
+		t.Errorf("buf = %q, want %q", got, "hello wo")
+	}
+	if !b.truncated {
+		t.Error("truncated should be set once the limit is exceeded")
+	}
+}
+
+func TestCappedBufferWithinLimit(t *testing.T) {
+	b := &cappedBuffer{max: 5}
+	_, _ = b.Write([]byte("hello"))
+	if string(b.buf) != "hello" || b.truncated {
+		t.Errorf("got %q truncated=%v, want exact fit without truncation", b.buf, b.truncated)
+	}
+}
@@ -0,0 +1,23 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+
+	cerrdefs "github.com/containerd/errdefs"
+	"github.com/docker/docker/api/types/container"
+)
+
+// InspectContainer returns the daemon's full inspect result for a
+// container, untrimmed, for troubleshooting views that need more than
+// ContainerInfo carries.
+func (c *Client) InspectContainer(ctx context.Context, containerID string) (*container.InspectResponse, error) {
+	resp, err := c.cli.ContainerInspect(ctx, containerID)
+	if err != nil {
+		if cerrdefs.IsNotFound(err) {
+			return nil, fmt.Errorf("%w: %s", ErrContainerNotFound, containerID)
+		}
+		return nil, fmt.Errorf("container inspect: %w", err)
+	}
+	return &resp, nil
+}
@@ -0,0 +1,68 @@
+package docker
+
+import (
+	"context"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+// ContainerLimits are the resource limits configured on a container. A
+// nil field means that limit is unset, i.e. unlimited.
+type ContainerLimits struct {
+	NanoCPUs   *int64 `json:"nano_cpus"`   // --cpus, in billionths of a CPU
+	CPUQuota   *int64 `json:"cpu_quota"`   // microseconds per CPUPeriod
+	CPUPeriod  *int64 `json:"cpu_period"`  // microseconds; daemon default 100000 when unset
+	Memory     *int64 `json:"memory"`      // bytes
+	MemorySwap *int64 `json:"memory_swap"` // memory plus swap, bytes
+	// CPUs is the effective CPU cap from NanoCPUs, or else from the
+	// quota and period, comparable to the stats stream's CPU percent / 100.
+	CPUs *float64 `json:"cpus"`
+}
+
+// defaultCPUPeriod is the CFS period the daemon uses when none is set.
+const defaultCPUPeriod = 100000
+
+// ContainerLimits returns the resource limits from a container's host
+// config.
+func (c *Client) ContainerLimits(ctx context.Context, containerID string) (*ContainerLimits, error) {
+	resp, err := c.InspectContainer(ctx, containerID)
+	if err != nil {
+		return nil, err
+	}
+	if resp.ContainerJSONBase == nil || resp.HostConfig == nil {
+		return &ContainerLimits{}, nil
+	}
+	return limitsFromHostConfig(resp.HostConfig), nil
+}
+
+func limitsFromHostConfig(hc *container.HostConfig) *ContainerLimits {
+	l := &ContainerLimits{
+		NanoCPUs:   positive(hc.NanoCPUs),
+		CPUQuota:   positive(hc.CPUQuota),
+		CPUPeriod:  positive(hc.CPUPeriod),
+		Memory:     positive(hc.Memory),
+		MemorySwap: positive(hc.MemorySwap), // -1 means unlimited swap
+	}
+	switch {
+	case l.NanoCPUs != nil:
+		cpus := float64(*l.NanoCPUs) / 1e9
+		l.CPUs = &cpus
+	case l.CPUQuota != nil:
+		period := int64(defaultCPUPeriod)
+		if l.CPUPeriod != nil {
+			period = *l.CPUPeriod
+		}
+		cpus := float64(*l.CPUQuota) / float64(period)
+		l.CPUs = &cpus
+	}
+	return l
+}
+
+// positive returns &v when v is a set limit, or nil for zero and the
+// negative "unlimited" sentinels.
+func positive(v int64) *int64 {
+	if v <= 0 {
+		return nil
+	}
+	return &v
+}
@@ -0,0 +1,40 @@
+package docker
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+func TestLimitsFromHostConfig(t *testing.T) {
+	var unset container.HostConfig
+	unset.MemorySwap = -1
+	got, _ := json.Marshal(limitsFromHostConfig(&unset))
+	want := `{"nano_cpus":null,"cpu_quota":null,"cpu_period":null,"memory":null,"memory_swap":null,"cpus":null}`
+	if string(got) != want {
+		t.Errorf("unset limits = %s, want %s", got, want)
+	}
+
+	var nano container.HostConfig
+	nano.NanoCPUs = 1_500_000_000
+	nano.Memory = 512 << 20
+	nano.MemorySwap = 1 << 30
+	l := limitsFromHostConfig(&nano)
+	if l.CPUs == nil || *l.CPUs != 1.5 {
+		t.Errorf("cpus = %v, want 1.5", l.CPUs)
+	}
+	if l.Memory == nil || *l.Memory != 512<<20 || l.MemorySwap == nil || *l.MemorySwap != 1<<30 {
+		t.Errorf("memory = %v, swap = %v", l.Memory, l.MemorySwap)
+	}
+
+	var quota container.HostConfig
+	quota.CPUQuota = 50000
+	if l := limitsFromHostConfig(&quota); l.CPUs == nil || *l.CPUs != 0.5 {
+		t.Errorf("cpus from quota with default period = %v, want 0.5", l.CPUs)
+	}
+	quota.CPUPeriod = 25000
+	if l := limitsFromHostConfig(&quota); l.CPUs == nil || *l.CPUs != 2 {
+		t.Errorf("cpus from quota/period = %v, want 2", l.CPUs)
+	}
+}
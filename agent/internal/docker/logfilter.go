@@ -0,0 +1,171 @@
+package docker
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Log entry kinds set by FilterLogs.
+const (
+	LogKindMatch   = "match"
+	LogKindContext = "context"
+)
+
+// FilterLogs keeps the entries accepted by match, plus up to before/after
+// surrounding entries of context like `grep -B/-A`. Overlapping context
+// windows are merged so every entry appears at most once. Returned entries
+// have Kind set to LogKindMatch or LogKindContext.
+func FilterLogs(entries []LogEntry, match func(LogEntry) bool, before, after int) []LogEntry {
+	if before < 0 {
+		before = 0
+	}
+	if after < 0 {
+		after = 0
+	}
+
+	kinds := make([]string, len(entries))
+	for i, e := range entries {
+		if !match(e) {
+			continue
+		}
+		kinds[i] = LogKindMatch
+		for j := max(0, i-before); j <= min(len(entries)-1, i+after); j++ {
+			if kinds[j] == "" {
+				kinds[j] = LogKindContext
+			}
+		}
+	}
+
+	out := make([]LogEntry, 0)
+	for i, e := range entries {
+		if kinds[i] == "" {
+			continue
+		}
+		e.Kind = kinds[i]
+		out = append(out, e)
+	}
+	return out
+}
+
+// MergeLogs interleaves the logs of several containers in timestamp order.
+// Entries whose timestamps don't parse keep their position relative to the
+// other entries of the same container.
+func MergeLogs(sets ...[]LogEntry) []LogEntry {
+	type keyed struct {
+		entry LogEntry
+		at    time.Time
+	}
+	var all []keyed
+	for _, set := range sets {
+		var last time.Time
+		for _, e := range set {
+			if t, err := time.Parse(time.RFC3339Nano, e.Timestamp); err == nil {
+				last = t
+			}
+			all = append(all, keyed{entry: e, at: last})
+		}
+	}
+	sort.SliceStable(all, func(i, j int) bool {
+		return all[i].at.Before(all[j].at)
+	})
+
+	merged := make([]LogEntry, len(all))
+	for i, k := range all {
+		merged[i] = k.entry
+	}
+	return merged
+}
+
+// TailLogsBySize returns the newest entries whose messages add up to at
+// most maxBytes, and whether older entries had to be dropped.
+func TailLogsBySize(entries []LogEntry, maxBytes int) ([]LogEntry, bool) {
+	size := 0
+	for i := len(entries) - 1; i >= 0; i-- {
+		size += len(entries[i].Message)
+		if size > maxBytes {
+			return entries[i+1:], true
+		}
+	}
+	return entries, false
+}
+
+// ErrBadFilter is returned by NewLogFilter for an invalid stream or regex.
+var ErrBadFilter = errors.New("invalid log filter")
+
+// LogFilter selects log lines by output stream and message content.
+// The zero value and nil both accept everything.
+type LogFilter struct {
+	stream string // "stdout", "stderr" or "" for both
+	substr string
+	re     *regexp.Regexp
+}
+
+// NewLogFilter builds a filter. grep is matched as a substring, or as a
+// regular expression when regex is set. stream is "stdout", "stderr", or
+// "both"/"" for either. Invalid input yields an error wrapping ErrBadFilter.
+func NewLogFilter(grep string, regex bool, stream string) (*LogFilter, error) {
+	f := &LogFilter{}
+	switch stream {
+	case "", "both":
+	case "stdout", "stderr":
+		f.stream = stream
+	default:
+		return nil, fmt.Errorf("%w: stream must be stdout, stderr or both", ErrBadFilter)
+	}
+
+	if regex && grep != "" {
+		re, err := regexp.Compile(grep)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrBadFilter, err)
+		}
+		f.re = re
+	} else {
+		f.substr = grep
+	}
+	return f, nil
+}
+
+// HasGrep reports whether the filter restricts message content.
+func (f *LogFilter) HasGrep() bool {
+	return f != nil && (f.re != nil || f.substr != "")
+}
+
+// MatchStream reports whether lines from stream pass the stream filter.
+func (f *LogFilter) MatchStream(stream string) bool {
+	return f == nil || f.stream == "" || f.stream == stream
+}
+
+// MatchMessage reports whether message passes the content filter.
+func (f *LogFilter) MatchMessage(message string) bool {
+	switch {
+	case f == nil:
+		return true
+	case f.re != nil:
+		return f.re.MatchString(message)
+	default:
+		return strings.Contains(message, f.substr)
+	}
+}
+
+// Match reports whether a line passes both the stream and content filters.
+func (f *LogFilter) Match(stream, message string) bool {
+	return f.MatchStream(stream) && f.MatchMessage(message)
+}
+
+// FilterStream keeps the entries whose stream passes the filter.
+func (f *LogFilter) FilterStream(entries []LogEntry) []LogEntry {
+	if f == nil || f.stream == "" {
+		return entries
+	}
+	kept := entries[:0]
+	for _, e := range entries {
+		if e.Stream == f.stream {
+			kept = append(kept, e)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,146 @@
+package docker
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestFilterLogs(t *testing.T) {
+	var entries []LogEntry
+	for _, msg := range []string{"a", "b", "ERROR one", "c", "d", "ERROR two", "e", "f", "g", "h"} {
+		entries = append(entries, LogEntry{Message: msg})
+	}
+	isError := func(e LogEntry) bool { return strings.HasPrefix(e.Message, "ERROR") }
+
+	tests := []struct {
+		name          string
+		before, after int
+		want          []string // "kind:message"
+	}{
+		{
+			name: "matches only",
+			want: []string{"match:ERROR one", "match:ERROR two"},
+		},
+		{
+			name:   "context around each match",
+			before: 1, after: 1,
+			want: []string{
+				"context:b", "match:ERROR one", "context:c",
+				"context:d", "match:ERROR two", "context:e",
+			},
+		},
+		{
+			name:   "overlapping windows are merged",
+			before: 2, after: 2,
+			want: []string{
+				"context:a", "context:b", "match:ERROR one", "context:c", "context:d",
+				"match:ERROR two", "context:e", "context:f",
+			},
+		},
+		{
+			name:   "match inside another match's context stays a match",
+			before: 0, after: 3,
+			want: []string{
+				"match:ERROR one", "context:c", "context:d", "match:ERROR two",
+				"context:e", "context:f", "context:g",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := FilterLogs(entries, isError, tt.before, tt.after)
+			var gotStr []string
+			for _, e := range got {
+				gotStr = append(gotStr, e.Kind+":"+e.Message)
+			}
+			if strings.Join(gotStr, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("FilterLogs() =\n  %v\nwant\n  %v", gotStr, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilterLogs_NoMatches(t *testing.T) {
+	got := FilterLogs([]LogEntry{{Message: "x"}}, func(LogEntry) bool { return false }, 5, 5)
+	if got == nil || len(got) != 0 {
+		t.Errorf("expected empty non-nil slice, got %#v", got)
+	}
+}
+
+func TestMergeLogs(t *testing.T) {
+	a := []LogEntry{
+		{Timestamp: "2024-01-01T00:00:00.5Z", Message: "a1", Container: "web-1"},
+		{Timestamp: "2024-01-01T00:00:02Z", Message: "a2", Container: "web-1"},
+	}
+	b := []LogEntry{
+		{Timestamp: "2024-01-01T00:00:00.51Z", Message: "b1", Container: "web-2"},
+		{Timestamp: "", Message: "b1-cont", Container: "web-2"},
+		{Timestamp: "2024-01-01T00:00:01Z", Message: "b2", Container: "web-2"},
+	}
+
+	got := MergeLogs(a, b)
+
+	want := []string{"a1", "b1", "b1-cont", "b2", "a2"}
+	if len(got) != len(want) {
+		t.Fatalf("got %d entries, want %d", len(got), len(want))
+	}
+	for i, e := range got {
+		if e.Message != want[i] {
+			t.Errorf("entry %d = %q, want %q", i, e.Message, want[i])
+		}
+	}
+}
+
+func TestLogFilter(t *testing.T) {
+	f, err := NewLogFilter(`err(or)?\b`, true, "stderr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !f.Match("stderr", "an error occurred") {
+		t.Error("want regex match on stderr")
+	}
+	if f.Match("stdout", "an error occurred") {
+		t.Error("stdout line should be filtered out")
+	}
+	if f.Match("stderr", "all good") {
+		t.Error("non-matching line should be filtered out")
+	}
+
+	sub, err := NewLogFilter("a.b", false, "both")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sub.MatchMessage("axb") || !sub.MatchMessage("a.b") {
+		t.Error("substring filter must not treat grep as a regex")
+	}
+
+	var none *LogFilter
+	if !none.Match("stdout", "anything") {
+		t.Error("nil filter should accept everything")
+	}
+}
+
+func TestNewLogFilter_Invalid(t *testing.T) {
+	if _, err := NewLogFilter("(", true, ""); !errors.Is(err, ErrBadFilter) {
+		t.Errorf("invalid regex: want ErrBadFilter, got %v", err)
+	}
+	if _, err := NewLogFilter("", false, "stdin"); !errors.Is(err, ErrBadFilter) {
+		t.Errorf("invalid stream: want ErrBadFilter, got %v", err)
+	}
+}
+
+func TestTailLogsBySize(t *testing.T) {
+	entries := []LogEntry{{Message: "aaaa"}, {Message: "bbb"}, {Message: "cc"}, {Message: "d"}}
+
+	got, truncated := TailLogsBySize(entries, 6)
+	if !truncated || len(got) != 3 || got[0].Message != "bbb" {
+		t.Errorf("TailLogsBySize(6) = %v, %v; want the last 3 entries, truncated", got, truncated)
+	}
+
+	got, truncated = TailLogsBySize(entries, 10)
+	if truncated || len(got) != 4 {
+		t.Errorf("TailLogsBySize(10) = %v, %v; want all entries", got, truncated)
+	}
+}
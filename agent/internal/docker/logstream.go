@@ -0,0 +1,149 @@
+package docker
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// maxLogLineSize bounds a single log line or frame read from a stream.
+const maxLogLineSize = 1 << 20
+
+// LogStream reads a followed container log stream one entry at a time. It
+// handles both Docker's multiplexed format, where each frame carries an
+// 8-byte header, and the raw stream of containers with a TTY, which has no
+// headers and only stdout.
+type LogStream struct {
+	rc      io.ReadCloser
+	tty     bool
+	lines   *bufio.Reader // TTY streams
+	header  [8]byte       // multiplexed streams
+	payload []byte
+}
+
+func newLogStream(rc io.ReadCloser, tty bool) *LogStream {
+	s := &LogStream{rc: rc, tty: tty}
+	if tty {
+		s.lines = bufio.NewReaderSize(rc, 64<<10)
+	}
+	return s
+}
+
+// Next returns the next log entry. It returns io.EOF when the stream ends.
+// Lines and frames over maxLogLineSize are skipped.
+func (s *LogStream) Next() (LogEntry, error) {
+	if s.tty {
+		line, err := s.nextLine()
+		if err != nil {
+			return LogEntry{}, err
+		}
+		return newLogEntry("stdout", line), nil
+	}
+
+	for {
+		// Docker logs use an 8-byte header per frame:
+		// [stream_type(1)][0(3)][size(4)][payload]
+		if _, err := io.ReadFull(s.rc, s.header[:]); err != nil {
+			return LogEntry{}, err
+		}
+		size := int(binary.BigEndian.Uint32(s.header[4:8]))
+		if size == 0 {
+			continue
+		}
+		if size > maxLogLineSize {
+			if _, err := io.CopyN(io.Discard, s.rc, int64(size)); err != nil {
+				return LogEntry{}, err
+			}
+			continue
+		}
+		if cap(s.payload) < size {
+			s.payload = make([]byte, size)
+		}
+		payload := s.payload[:size]
+		if _, err := io.ReadFull(s.rc, payload); err != nil {
+			return LogEntry{}, fmt.Errorf("read log frame: %w", err)
+		}
+		return newLogEntry(frameStream(s.header[0]), strings.TrimRight(string(payload), "\n")), nil
+	}
+}
+
+// nextLine returns the next line of a TTY stream without its line ending.
+// A final line without one is returned too.
+func (s *LogStream) nextLine() (string, error) {
+	var line []byte
+	skip := false
+	for {
+		chunk, err := s.lines.ReadSlice('\n')
+		if !skip && len(line)+len(chunk) > maxLogLineSize {
+			skip, line = true, nil
+		}
+		if !skip {
+			line = append(line, chunk...)
+		}
+		switch {
+		case err == bufio.ErrBufferFull:
+			continue
+		case err == nil && skip:
+			skip = false
+			continue
+		case err != nil && (err != io.EOF || len(line) == 0):
+			return "", err
+		}
+		return strings.TrimRight(string(line), "\r\n"), nil
+	}
+}
+
+// Close closes the underlying stream.
+func (s *LogStream) Close() error {
+	return s.rc.Close()
+}
+
+// readLogTail reads s to the end and returns the newest entries whose
+// messages add up to at most maxBytes. Only that window is held in memory.
+// truncated reports that older entries were dropped or that the output
+// ended partway through a frame.
+func readLogTail(s *LogStream, maxBytes int) (entries []LogEntry, truncated bool, err error) {
+	size := 0
+	for {
+		e, err := s.Next()
+		if errors.Is(err, io.EOF) {
+			return entries, truncated, nil
+		}
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return entries, true, nil
+		}
+		if err != nil {
+			return entries, truncated, err
+		}
+		entries = append(entries, e)
+		size += len(e.Message)
+		for size > maxBytes {
+			size -= len(entries[0].Message)
+			entries = entries[1:]
+			truncated = true
+		}
+	}
+}
+
+// frameStream names the stream of a multiplexed frame's type byte.
+func frameStream(streamType byte) string {
+	if streamType == 2 {
+		return "stderr"
+	}
+	return "stdout"
+}
+
+// newLogEntry splits the timestamp Docker prefixes to each line off line.
+func newLogEntry(stream, line string) LogEntry {
+	var timestamp, message string
+	if idx := strings.IndexByte(line, ' '); idx > 0 {
+		timestamp = line[:idx]
+		message = line[idx+1:]
+	} else {
+		message = line
+	}
+	return LogEntry{Timestamp: timestamp, Stream: stream, Message: message}
+}
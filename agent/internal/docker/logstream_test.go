@@ -0,0 +1,119 @@
+package docker
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// ttyOutput is what the daemon sends for a TTY container: raw lines ending
+// in "\r\n", each prefixed with its timestamp, and no frame headers.
+const ttyOutput = "2024-01-15T10:00:00.000000001Z starting up\r\n" +
+	"2024-01-15T10:00:01.000000001Z ready on :8080\r\n"
+
+// frame encodes payload as one multiplexed log frame.
+func frame(streamType byte, payload string) string {
+	var h [8]byte
+	h[0] = streamType
+	binary.BigEndian.PutUint32(h[4:], uint32(len(payload)))
+	return string(h[:]) + payload
+}
+
+func TestReadLogTail(t *testing.T) {
+	first := frame(1, "2024-01-15T10:00:00Z first\n")
+	second := frame(2, "2024-01-15T10:00:01Z second\n")
+	want := []LogEntry{{Timestamp: "2024-01-15T10:00:00Z", Stream: "stdout", Message: "first"}}
+	both := append(want, LogEntry{Timestamp: "2024-01-15T10:00:01Z", Stream: "stderr", Message: "second"})
+
+	tests := []struct {
+		name          string
+		raw           string
+		tty           bool
+		maxBytes      int
+		want          []LogEntry
+		wantTruncated bool
+	}{
+		{"complete", first + second, false, 1024, both, false},
+		{"partial payload", first + second[:len(second)-4], false, 1024, want, true},
+		{"partial header", first + second[:5], false, 1024, want, true},
+		{"empty", "", false, 1024, nil, false},
+		// "first" and "second" take 11 bytes; the oldest goes first.
+		{"keeps newest", first + second, false, 10, both[1:], true},
+		{"exactly at limit", first + second, false, 11, both, false},
+		{"tty", ttyOutput, true, 1024, []LogEntry{
+			{Timestamp: "2024-01-15T10:00:00.000000001Z", Stream: "stdout", Message: "starting up"},
+			{Timestamp: "2024-01-15T10:00:01.000000001Z", Stream: "stdout", Message: "ready on :8080"},
+		}, false},
+		{"tty keeps newest", ttyOutput, true, 14, []LogEntry{
+			{Timestamp: "2024-01-15T10:00:01.000000001Z", Stream: "stdout", Message: "ready on :8080"},
+		}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newLogStream(io.NopCloser(strings.NewReader(tt.raw)), tt.tty)
+			got, truncated, err := readLogTail(s, tt.maxBytes)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(got, tt.want) || truncated != tt.wantTruncated {
+				t.Errorf("got %+v, truncated=%v; want %+v, truncated=%v", got, truncated, tt.want, tt.wantTruncated)
+			}
+		})
+	}
+}
+
+func TestLogStream(t *testing.T) {
+	huge := strings.Repeat("x", maxLogLineSize+1)
+	muxed := frame(1, "2024-01-15T10:00:00Z out line\n") + frame(2, "2024-01-15T10:00:01Z err line\n")
+
+	tests := []struct {
+		name string
+		raw  string
+		tty  bool
+		want []LogEntry
+	}{
+		{"multiplexed", muxed, false, []LogEntry{
+			{Timestamp: "2024-01-15T10:00:00Z", Stream: "stdout", Message: "out line"},
+			{Timestamp: "2024-01-15T10:00:01Z", Stream: "stderr", Message: "err line"},
+		}},
+		{"tty", ttyOutput, true, []LogEntry{
+			{Timestamp: "2024-01-15T10:00:00.000000001Z", Stream: "stdout", Message: "starting up"},
+			{Timestamp: "2024-01-15T10:00:01.000000001Z", Stream: "stdout", Message: "ready on :8080"},
+		}},
+		// Oversized lines and frames are skipped without ending the stream.
+		{"multiplexed oversized frame", frame(1, huge+"\n") + muxed, false, []LogEntry{
+			{Timestamp: "2024-01-15T10:00:00Z", Stream: "stdout", Message: "out line"},
+			{Timestamp: "2024-01-15T10:00:01Z", Stream: "stderr", Message: "err line"},
+		}},
+		{"tty oversized line", huge + "\r\n" + ttyOutput, true, []LogEntry{
+			{Timestamp: "2024-01-15T10:00:00.000000001Z", Stream: "stdout", Message: "starting up"},
+			{Timestamp: "2024-01-15T10:00:01.000000001Z", Stream: "stdout", Message: "ready on :8080"},
+		}},
+		{"tty unterminated last line", "2024-01-15T10:00:00Z one\r\n2024-01-15T10:00:01Z two", true, []LogEntry{
+			{Timestamp: "2024-01-15T10:00:00Z", Stream: "stdout", Message: "one"},
+			{Timestamp: "2024-01-15T10:00:01Z", Stream: "stdout", Message: "two"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newLogStream(io.NopCloser(strings.NewReader(tt.raw)), tt.tty)
+			var got []LogEntry
+			for {
+				e, err := s.Next()
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				got = append(got, e)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package docker
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+// PortMapping is a container port, published on the host when PublicPort
+// is set.
+type PortMapping struct {
+	IP          string `json:"ip,omitempty"`
+	PrivatePort uint16 `json:"private_port"`
+	PublicPort  uint16 `json:"public_port,omitempty"`
+	Protocol    string `json:"protocol"`
+}
+
+// portMappings converts the ports of a container list entry, ordered by
+// container port. Docker reports a port published on all interfaces once
+// for 0.0.0.0 and again for ::; the pair is collapsed into one entry.
+func portMappings(ports []container.Port) []PortMapping {
+	result := make([]PortMapping, 0, len(ports))
+	for _, p := range ports {
+		if p.IP == "::" && slices.ContainsFunc(ports, func(q container.Port) bool {
+			return q.IP == "0.0.0.0" && q.PrivatePort == p.PrivatePort && q.PublicPort == p.PublicPort && q.Type == p.Type
+		}) {
+			continue
+		}
+		result = append(result, PortMapping{IP: p.IP, PrivatePort: p.PrivatePort, PublicPort: p.PublicPort, Protocol: p.Type})
+	}
+	slices.SortFunc(result, func(a, b PortMapping) int {
+		return cmp.Or(
+			cmp.Compare(a.PrivatePort, b.PrivatePort),
+			cmp.Compare(a.Protocol, b.Protocol),
+			cmp.Compare(a.PublicPort, b.PublicPort),
+			cmp.Compare(a.IP, b.IP),
+		)
+	})
+	return result
+}
+
+// healthFromStatus extracts the health check state from a container list
+// status such as "Up 5 minutes (healthy)", sparing an inspect per
+// container. It is empty when the container defines no health check.
+func healthFromStatus(status string) string {
+	switch {
+	case strings.HasSuffix(status, "(healthy)"):
+		return "healthy"
+	case strings.HasSuffix(status, "(unhealthy)"):
+		return "unhealthy"
+	case strings.HasSuffix(status, "(health: starting)"):
+		return "starting"
+	}
+	return ""
+}
@@ -0,0 +1,41 @@
+package docker
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+func TestPortMappings(t *testing.T) {
+	got := portMappings([]container.Port{
+		{IP: "::", PrivatePort: 80, PublicPort: 8080, Type: "tcp"},
+		{PrivatePort: 5432, Type: "tcp"},
+		{IP: "0.0.0.0", PrivatePort: 80, PublicPort: 8080, Type: "tcp"},
+		{IP: "127.0.0.1", PrivatePort: 53, PublicPort: 5353, Type: "udp"},
+	})
+	want := []PortMapping{
+		{IP: "127.0.0.1", PrivatePort: 53, PublicPort: 5353, Protocol: "udp"},
+		{IP: "0.0.0.0", PrivatePort: 80, PublicPort: 8080, Protocol: "tcp"},
+		{PrivatePort: 5432, Protocol: "tcp"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("portMappings =\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestHealthFromStatus(t *testing.T) {
+	tests := map[string]string{
+		"Up 5 minutes (healthy)":          "healthy",
+		"Up 2 seconds (health: starting)": "starting",
+		"Up About an hour (unhealthy)":    "unhealthy",
+		"Up 3 days":                       "",
+		"Exited (0) 2 hours ago":          "",
+		"Exited (137) 5 seconds ago":      "",
+	}
+	for status, want := range tests {
+		if got := healthFromStatus(status); got != want {
+			t.Errorf("healthFromStatus(%q) = %q, want %q", status, got, want)
+		}
+	}
+}
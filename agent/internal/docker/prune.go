@@ -0,0 +1,46 @@
+package docker
+
+import (
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/filters"
+)
+
+// PruneFilter narrows a prune the way docker's --filter until= and
+// --filter label= do. The zero value filters nothing.
+type PruneFilter struct {
+	// Until keeps items created within this duration; only older ones are
+	// pruned. Zero means no age limit.
+	Until time.Duration
+	// Labels are "key" or "key=value" selectors; an item must match all.
+	Labels []string
+}
+
+// args returns the daemon filter arguments for f on top of base.
+func (f PruneFilter) args(base ...filters.KeyValuePair) filters.Args {
+	args := filters.NewArgs(base...)
+	if f.Until > 0 {
+		args.Add("until", f.Until.String())
+	}
+	for _, l := range f.Labels {
+		args.Add("label", l)
+	}
+	return args
+}
+
+// matches reports whether an item created at created with labels would be
+// selected by f at now. Dry runs use it to mirror the daemon's filtering.
+func (f PruneFilter) matches(created time.Time, labels map[string]string, now time.Time) bool {
+	if f.Until > 0 && created.After(now.Add(-f.Until)) {
+		return false
+	}
+	for _, l := range f.Labels {
+		key, want, hasValue := strings.Cut(l, "=")
+		got, ok := labels[key]
+		if !ok || (hasValue && got != want) {
+			return false
+		}
+	}
+	return true
+}
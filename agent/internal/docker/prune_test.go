@@ -0,0 +1,57 @@
+package docker
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/filters"
+)
+
+func TestPruneFilterArgs(t *testing.T) {
+	f := PruneFilter{Until: 24 * time.Hour, Labels: []string{"env=dev", "temp"}}
+	args := f.args(filters.Arg("dangling", "false"))
+
+	if got := args.Get("dangling"); !slices.Equal(got, []string{"false"}) {
+		t.Errorf("dangling = %v", got)
+	}
+	if got := args.Get("until"); !slices.Equal(got, []string{"24h0m0s"}) {
+		t.Errorf("until = %v", got)
+	}
+	labels := args.Get("label")
+	slices.Sort(labels)
+	if !slices.Equal(labels, []string{"env=dev", "temp"}) {
+		t.Errorf("label = %v", labels)
+	}
+
+	if n := (PruneFilter{}).args().Len(); n != 0 {
+		t.Errorf("zero filter produced %d args", n)
+	}
+}
+
+func TestPruneFilterMatches(t *testing.T) {
+	now := time.Date(2026, 1, 2, 12, 0, 0, 0, time.UTC)
+	old, recent := now.Add(-48*time.Hour), now.Add(-time.Hour)
+	labels := map[string]string{"env": "dev", "temp": ""}
+
+	tests := []struct {
+		name    string
+		filter  PruneFilter
+		created time.Time
+		want    bool
+	}{
+		{"no filter", PruneFilter{}, recent, true},
+		{"older than until", PruneFilter{Until: 24 * time.Hour}, old, true},
+		{"newer than until", PruneFilter{Until: 24 * time.Hour}, recent, false},
+		{"label value matches", PruneFilter{Labels: []string{"env=dev"}}, recent, true},
+		{"label value differs", PruneFilter{Labels: []string{"env=prod"}}, recent, false},
+		{"label key present", PruneFilter{Labels: []string{"temp"}}, recent, true},
+		{"label key missing", PruneFilter{Labels: []string{"keep"}}, recent, false},
+		{"all must match", PruneFilter{Until: 24 * time.Hour, Labels: []string{"env=dev"}}, recent, false},
+	}
+	for _, tt := range tests {
+		if got := tt.filter.matches(tt.created, labels, now); got != tt.want {
+			t.Errorf("%s: matches = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
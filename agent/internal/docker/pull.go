@@ -0,0 +1,125 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	cerrdefs "github.com/containerd/errdefs"
+	"github.com/distribution/reference"
+	"github.com/docker/docker/api/types/image"
+)
+
+var (
+	// ErrInvalidReference is returned for a malformed image reference.
+	ErrInvalidReference = errors.New("invalid image reference")
+	// ErrImageNotFound is returned when the registry has no such image or
+	// refuses access to it.
+	ErrImageNotFound = errors.New("image not found")
+)
+
+// PullProgress is one status update from an image pull. Layer is empty for
+// messages about the image as a whole.
+type PullProgress struct {
+	Layer   string `json:"layer,omitempty"`
+	Status  string `json:"status"`
+	Current int64  `json:"current,omitempty"`
+	Total   int64  `json:"total,omitempty"`
+}
+
+// PullResult summarizes a completed image pull.
+type PullResult struct {
+	Image  string `json:"image"` // normalized reference, e.g. docker.io/library/nginx:1.27
+	Digest string `json:"digest,omitempty"`
+	Status string `json:"status"` // e.g. "Downloaded newer image for nginx:1.27"
+	Layers int    `json:"layers"`
+}
+
+// pullMessage is a line of the JSON stream returned by the pull API.
+type pullMessage struct {
+	ID             string `json:"id"`
+	Status         string `json:"status"`
+	Error          string `json:"error"`
+	ProgressDetail struct {
+		Current int64 `json:"current"`
+		Total   int64 `json:"total"`
+	} `json:"progressDetail"`
+}
+
+// NormalizeImageRef validates an image reference and expands it to its
+// fully qualified form, adding the "latest" tag when none is given.
+func NormalizeImageRef(ref string) (string, error) {
+	named, err := reference.ParseNormalizedNamed(strings.TrimSpace(ref))
+	if err != nil {
+		return "", fmt.Errorf("%w %q: %s", ErrInvalidReference, ref, err)
+	}
+	return reference.TagNameOnly(named).String(), nil
+}
+
+// PullImage pulls an image, calling progress (if non-nil) for each status
+// update. registryAuth is the base64-encoded credential Docker expects in
+// X-Registry-Auth; leave it empty for public images.
+func (c *Client) PullImage(ctx context.Context, ref, registryAuth string, progress func(PullProgress)) (*PullResult, error) {
+	normalized, err := NormalizeImageRef(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	rc, err := c.cli.ImagePull(ctx, normalized, image.PullOptions{RegistryAuth: registryAuth})
+	if err != nil {
+		if cerrdefs.IsNotFound(err) || cerrdefs.IsUnauthorized(err) || cerrdefs.IsPermissionDenied(err) {
+			return nil, fmt.Errorf("%w: %s", ErrImageNotFound, err)
+		}
+		return nil, fmt.Errorf("image pull: %w", err)
+	}
+	defer rc.Close()
+
+	return readPullStream(rc, normalized, progress)
+}
+
+// readPullStream drains the pull API's message stream into a summary. The
+// daemon reports failures that happen mid-pull as a message, not an HTTP
+// status, so those are turned into errors here.
+func readPullStream(r io.Reader, ref string, progress func(PullProgress)) (*PullResult, error) {
+	result := &PullResult{Image: ref}
+	layers := make(map[string]bool)
+
+	dec := json.NewDecoder(r)
+	for {
+		var msg pullMessage
+		if err := dec.Decode(&msg); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, fmt.Errorf("reading pull progress: %w", err)
+		}
+		if msg.Error != "" {
+			return nil, fmt.Errorf("image pull: %s", msg.Error)
+		}
+
+		switch {
+		case strings.HasPrefix(msg.Status, "Digest: "):
+			result.Digest = strings.TrimPrefix(msg.Status, "Digest: ")
+		case strings.HasPrefix(msg.Status, "Status: "):
+			result.Status = strings.TrimPrefix(msg.Status, "Status: ")
+		}
+		// The first message carries the tag being pulled as its id.
+		if msg.ID != "" && !strings.HasPrefix(msg.Status, "Pulling from") {
+			layers[msg.ID] = true
+		}
+
+		if progress != nil {
+			progress(PullProgress{
+				Layer:   msg.ID,
+				Status:  msg.Status,
+				Current: msg.ProgressDetail.Current,
+				Total:   msg.ProgressDetail.Total,
+			})
+		}
+	}
+	result.Layers = len(layers)
+	return result, nil
+}
@@ -0,0 +1,60 @@
+package docker
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeImageRef(t *testing.T) {
+	tests := map[string]string{
+		"nginx":                   "docker.io/library/nginx:latest",
+		"nginx:1.27":              "docker.io/library/nginx:1.27",
+		"ghcr.io/owner/app:v2":    "ghcr.io/owner/app:v2",
+		"registry.local:5000/app": "registry.local:5000/app:latest",
+	}
+	for in, want := range tests {
+		got, err := NormalizeImageRef(in)
+		if err != nil || got != want {
+			t.Errorf("NormalizeImageRef(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+
+	for _, bad := range []string{"", "Nginx", "nginx:", "nginx@sha256:xyz"} {
+		if _, err := NormalizeImageRef(bad); !errors.Is(err, ErrInvalidReference) {
+			t.Errorf("NormalizeImageRef(%q) err = %v, want ErrInvalidReference", bad, err)
+		}
+	}
+}
+
+func TestReadPullStream(t *testing.T) {
+	stream := `{"status":"Pulling from library/nginx","id":"1.27"}
+{"status":"Pulling fs layer","progressDetail":{},"id":"a1"}
+{"status":"Pulling fs layer","progressDetail":{},"id":"b2"}
+{"status":"Downloading","progressDetail":{"current":512,"total":1024},"id":"a1"}
+{"status":"Pull complete","progressDetail":{},"id":"a1"}
+{"status":"Pull complete","progressDetail":{},"id":"b2"}
+{"status":"Digest: sha256:abc"}
+{"status":"Status: Downloaded newer image for nginx:1.27"}
+`
+	var updates []PullProgress
+	res, err := readPullStream(strings.NewReader(stream), "docker.io/library/nginx:1.27", func(p PullProgress) {
+		updates = append(updates, p)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Digest != "sha256:abc" || res.Status != "Downloaded newer image for nginx:1.27" || res.Layers != 2 {
+		t.Errorf("unexpected result: %+v", res)
+	}
+	if len(updates) != 8 || updates[3].Current != 512 || updates[3].Total != 1024 {
+		t.Errorf("unexpected progress updates: %+v", updates)
+	}
+
+	_, err = readPullStream(strings.NewReader(`{"status":"Pulling fs layer","id":"a1"}
+{"errorDetail":{"message":"unauthorized"},"error":"unauthorized: authentication required"}
+`), "x", nil)
+	if err == nil || !strings.Contains(err.Error(), "authentication required") {
+		t.Errorf("mid-stream error not reported, got %v", err)
+	}
+}
@@ -0,0 +1,45 @@
+package docker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	cerrdefs "github.com/containerd/errdefs"
+	"github.com/docker/docker/api/types/container"
+)
+
+// ErrContainerRunning is returned when removing a running container
+// without force.
+var ErrContainerRunning = errors.New("container is running")
+
+// RemoveContainer removes a container. A running container is refused
+// unless force is set, in which case it is killed first; one started after
+// the check is refused by the daemon, which is reported the same way.
+// removeVolumes also removes the anonymous volumes attached to it.
+func (c *Client) RemoveContainer(ctx context.Context, containerID string, force, removeVolumes bool) error {
+	if !force {
+		info, err := c.cli.ContainerInspect(ctx, containerID)
+		if err != nil {
+			if cerrdefs.IsNotFound(err) {
+				return fmt.Errorf("%w: %s", ErrContainerNotFound, containerID)
+			}
+			return fmt.Errorf("container inspect: %w", err)
+		}
+		if info.State != nil && info.State.Running {
+			return fmt.Errorf("%w: %s", ErrContainerRunning, containerID)
+		}
+	}
+
+	err := c.cli.ContainerRemove(ctx, containerID, container.RemoveOptions{Force: force, RemoveVolumes: removeVolumes})
+	if err != nil {
+		switch {
+		case cerrdefs.IsNotFound(err):
+			return fmt.Errorf("%w: %s", ErrContainerNotFound, containerID)
+		case cerrdefs.IsConflict(err):
+			return fmt.Errorf("%w: %s", ErrContainerRunning, containerID)
+		}
+		return fmt.Errorf("remove container: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	cerrdefs "github.com/containerd/errdefs"
+	"github.com/docker/docker/api/types/container"
+)
+
+// ErrContainerNotFound is returned when a container id or name does not resolve.
+var ErrContainerNotFound = errors.New("container not found")
+
+// ContainerStatsSnapshot is a point-in-time resource usage summary for a container.
+type ContainerStatsSnapshot struct {
+	ContainerID     string  `json:"container_id"`
+	CPUPercent      float64 `json:"cpu_percent"`
+	MemUsedBytes    uint64  `json:"mem_used_bytes"`
+	MemLimitBytes   uint64  `json:"mem_limit_bytes"`
+	MemPercent      float64 `json:"mem_percent"`
+	NetRxBytes      uint64  `json:"net_rx_bytes"`
+	NetTxBytes      uint64  `json:"net_tx_bytes"`
+	BlockReadBytes  uint64  `json:"block_read_bytes"`
+	BlockWriteBytes uint64  `json:"block_write_bytes"`
+}
+
+// NewStatsSnapshot summarises a raw Docker stats response.
+func NewStatsSnapshot(containerID string, stats *container.StatsResponse) ContainerStatsSnapshot {
+	memUsed := stats.MemoryStats.Usage
+	if cache, ok := stats.MemoryStats.Stats["cache"]; ok {
+		memUsed -= cache
+	}
+	memLimit := stats.MemoryStats.Limit
+	var memPercent float64
+	if memLimit > 0 {
+		memPercent = float64(memUsed) / float64(memLimit) * 100.0
+	}
+
+	snap := ContainerStatsSnapshot{
+		ContainerID:   containerID,
+		CPUPercent:    CalculateCPUPercent(stats),
+		MemUsedBytes:  memUsed,
+		MemLimitBytes: memLimit,
+		MemPercent:    memPercent,
+	}
+	for _, n := range stats.Networks {
+		snap.NetRxBytes += n.RxBytes
+		snap.NetTxBytes += n.TxBytes
+	}
+	for _, e := range stats.BlkioStats.IoServiceBytesRecursive {
+		switch strings.ToLower(e.Op) {
+		case "read":
+			snap.BlockReadBytes += e.Value
+		case "write":
+			snap.BlockWriteBytes += e.Value
+		}
+	}
+	return snap
+}
+
+// CalculateCPUPercent derives CPU usage from the delta between the current
+// and previous CPU readings in a stats response, scaled to the number of
+// online CPUs (so a container saturating two cores reports 200%).
+func CalculateCPUPercent(stats *container.StatsResponse) float64 {
+	cpuDelta := float64(stats.CPUStats.CPUUsage.TotalUsage - stats.PreCPUStats.CPUUsage.TotalUsage)
+	systemDelta := float64(stats.CPUStats.SystemUsage - stats.PreCPUStats.SystemUsage)
+	if systemDelta <= 0 || cpuDelta < 0 {
+		return 0.0
+	}
+	numCPUs := float64(stats.CPUStats.OnlineCPUs)
+	if numCPUs == 0 {
+		numCPUs = float64(len(stats.CPUStats.CPUUsage.PercpuUsage))
+	}
+	if numCPUs == 0 {
+		numCPUs = 1
+	}
+	return (cpuDelta / systemDelta) * numCPUs * 100.0
+}
+
+// ContainerStatsOnce takes a single stats reading for a container. The
+// daemon includes the previous sample, so CPU usage can still be computed.
+func (c *Client) ContainerStatsOnce(ctx context.Context, containerID string) (*ContainerStatsSnapshot, error) {
+	resp, err := c.cli.ContainerStats(ctx, containerID, false)
+	if err != nil {
+		if cerrdefs.IsNotFound(err) {
+			return nil, fmt.Errorf("%w: %s", ErrContainerNotFound, containerID)
+		}
+		return nil, fmt.Errorf("container stats: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var stats container.StatsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&stats); err != nil {
+		return nil, fmt.Errorf("decode container stats: %w", err)
+	}
+
+	snap := NewStatsSnapshot(containerID, &stats)
+	return &snap, nil
+}
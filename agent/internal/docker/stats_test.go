@@ -0,0 +1,45 @@
+package docker
+
+import (
+	"math"
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+func TestNewStatsSnapshot(t *testing.T) {
+	stats := &container.StatsResponse{}
+	stats.CPUStats.CPUUsage.TotalUsage = 300
+	stats.PreCPUStats.CPUUsage.TotalUsage = 100
+	stats.CPUStats.SystemUsage = 2000
+	stats.PreCPUStats.SystemUsage = 1000
+	stats.CPUStats.OnlineCPUs = 2
+	stats.MemoryStats.Usage = 600
+	stats.MemoryStats.Limit = 1000
+	stats.MemoryStats.Stats = map[string]uint64{"cache": 100}
+	stats.Networks = map[string]container.NetworkStats{
+		"eth0": {RxBytes: 10, TxBytes: 20},
+		"eth1": {RxBytes: 1, TxBytes: 2},
+	}
+	stats.BlkioStats.IoServiceBytesRecursive = []container.BlkioStatEntry{
+		{Op: "Read", Value: 4096},
+		{Op: "Write", Value: 512},
+		{Op: "read", Value: 4},
+		{Op: "Total", Value: 9999},
+	}
+
+	got := NewStatsSnapshot("abc", stats)
+
+	if math.Abs(got.CPUPercent-40) > 1e-9 {
+		t.Errorf("CPUPercent = %v, want 40", got.CPUPercent)
+	}
+	if got.MemUsedBytes != 500 || got.MemPercent != 50 {
+		t.Errorf("mem = %d (%v%%), want 500 (50%%)", got.MemUsedBytes, got.MemPercent)
+	}
+	if got.NetRxBytes != 11 || got.NetTxBytes != 22 {
+		t.Errorf("net = %d/%d, want 11/22", got.NetRxBytes, got.NetTxBytes)
+	}
+	if got.BlockReadBytes != 4100 || got.BlockWriteBytes != 512 {
+		t.Errorf("blkio = %d/%d, want 4100/512", got.BlockReadBytes, got.BlockWriteBytes)
+	}
+}
@@ -0,0 +1,77 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+// defaultStopTimeout is Docker's grace period between the stop signal and
+// SIGKILL when the container doesn't configure its own.
+const defaultStopTimeout = 10 * time.Second
+
+// sigkillExitCode is the exit status of a process terminated by SIGKILL.
+const sigkillExitCode = 128 + 9
+
+// StopResult describes how a container went down when it was stopped.
+type StopResult struct {
+	ExitCode           int `json:"exit_code"`
+	GracePeriodSeconds int `json:"grace_period_seconds"`
+	// ForceKilled is set when the container outlived its grace period and
+	// Docker escalated to SIGKILL, i.e. it doesn't handle its stop signal.
+	ForceKilled bool `json:"force_killed"`
+}
+
+// StopContainer stops a running container and reports whether it exited on
+// its own within the grace period or had to be killed.
+func (c *Client) StopContainer(ctx context.Context, containerID string) (*StopResult, error) {
+	before, err := c.cli.ContainerInspect(ctx, containerID)
+	if err != nil {
+		return nil, err
+	}
+	grace := defaultStopTimeout
+	if before.Config != nil && before.Config.StopTimeout != nil {
+		grace = time.Duration(*before.Config.StopTimeout) * time.Second
+	}
+
+	start := time.Now()
+	if err := c.cli.ContainerStop(ctx, containerID, container.StopOptions{}); err != nil {
+		return nil, err
+	}
+	elapsed := time.Since(start)
+
+	after, err := c.cli.ContainerInspect(ctx, containerID)
+	if err != nil {
+		return nil, fmt.Errorf("inspecting stopped container: %w", err)
+	}
+	result := &StopResult{GracePeriodSeconds: int(grace / time.Second)}
+	if after.State != nil {
+		result.ExitCode = after.State.ExitCode
+		result.ForceKilled = forceKilled(after.State.ExitCode, after.State.OOMKilled, elapsed, grace)
+	}
+	return result, nil
+}
+
+// RestartContainer restarts a container. It stops and starts the container
+// in two steps so the stop outcome can be reported, which a single restart
+// call would discard once the new process is running.
+func (c *Client) RestartContainer(ctx context.Context, containerID string) (*StopResult, error) {
+	result, err := c.StopContainer(ctx, containerID)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.StartContainer(ctx, containerID); err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+// forceKilled decides whether a stop escalated to SIGKILL: the process died
+// of SIGKILL (and not at the hands of the OOM killer) only after the grace
+// period had run out. The small slack absorbs clock and API latency.
+func forceKilled(exitCode int, oomKilled bool, elapsed, grace time.Duration) bool {
+	const slack = 250 * time.Millisecond
+	return exitCode == sigkillExitCode && !oomKilled && elapsed+slack >= grace
+}
@@ -0,0 +1,31 @@
+package docker
+
+import (
+	"testing"
+	"time"
+)
+
+func TestForceKilled(t *testing.T) {
+	grace := 10 * time.Second
+	tests := []struct {
+		name     string
+		exitCode int
+		oom      bool
+		elapsed  time.Duration
+		want     bool
+	}{
+		{"clean exit", 0, false, time.Second, false},
+		{"exited with error on SIGTERM", 143, false, time.Second, false},
+		{"killed after grace period", 137, false, 10 * time.Second, true},
+		{"killed just under grace period", 137, false, 9900 * time.Millisecond, true},
+		{"SIGKILL before grace period ran out", 137, false, 2 * time.Second, false},
+		{"OOM killed", 137, true, 10 * time.Second, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := forceKilled(tt.exitCode, tt.oom, tt.elapsed, grace); got != tt.want {
+				t.Errorf("forceKilled = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
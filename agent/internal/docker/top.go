@@ -0,0 +1,41 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	cerrdefs "github.com/containerd/errdefs"
+)
+
+// ContainerProcesses is the process list of a running container, as
+// reported by ps on the host: Titles are the column headers and each entry
+// of Processes holds one value per title.
+type ContainerProcesses struct {
+	Titles    []string   `json:"titles"`
+	Processes [][]string `json:"processes"`
+}
+
+// ContainerTop lists the processes running in a container. psArgs are
+// passed to ps; empty means the daemon's default ("-ef").
+func (c *Client) ContainerTop(ctx context.Context, containerID, psArgs string) (*ContainerProcesses, error) {
+	var args []string
+	if psArgs != "" {
+		args = strings.Fields(psArgs)
+	}
+	resp, err := c.cli.ContainerTop(ctx, containerID, args)
+	if err != nil {
+		switch {
+		case cerrdefs.IsNotFound(err):
+			return nil, fmt.Errorf("%w: %s", ErrContainerNotFound, containerID)
+		case cerrdefs.IsConflict(err):
+			return nil, fmt.Errorf("%w: %s", ErrContainerNotRunning, containerID)
+		}
+		return nil, fmt.Errorf("container top: %w", err)
+	}
+	procs := resp.Processes
+	if procs == nil {
+		procs = [][]string{}
+	}
+	return &ContainerProcesses{Titles: resp.Titles, Processes: procs}, nil
+}
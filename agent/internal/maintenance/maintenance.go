@@ -0,0 +1,82 @@
+// Package maintenance tracks the agent's maintenance mode, during which
+// background activity such as resource alerts stands down while the API
+// keeps serving requests.
+package maintenance
+
+import (
+	"sync"
+	"time"
+)
+
+// Status is a snapshot of the maintenance mode state.
+type Status struct {
+	Enabled bool       `json:"enabled"`
+	Since   *time.Time `json:"since,omitempty"`
+	Until   *time.Time `json:"until,omitempty"` // automatic resume time
+	Reason  string     `json:"reason,omitempty"`
+}
+
+// Mode is a thread-safe maintenance mode switch. Every enable carries a
+// deadline after which the mode lapses on its own, so a forgotten toggle
+// can't silence the agent indefinitely.
+type Mode struct {
+	mu     sync.Mutex
+	since  time.Time
+	until  time.Time
+	reason string
+	now    func() time.Time
+}
+
+// New creates a Mode that starts disabled.
+func New() *Mode {
+	return &Mode{now: time.Now}
+}
+
+// Enable turns maintenance mode on for d, replacing any earlier deadline.
+func (m *Mode) Enable(d time.Duration, reason string) Status {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	if !m.activeLocked(now) {
+		m.since = now
+	}
+	m.until = now.Add(d)
+	m.reason = reason
+	return m.statusLocked(now)
+}
+
+// Disable turns maintenance mode off.
+func (m *Mode) Disable() Status {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.since, m.until, m.reason = time.Time{}, time.Time{}, ""
+	return m.statusLocked(m.now())
+}
+
+// Active reports whether maintenance mode is on.
+func (m *Mode) Active() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.activeLocked(m.now())
+}
+
+// Status returns the current state.
+func (m *Mode) Status() Status {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.statusLocked(m.now())
+}
+
+func (m *Mode) activeLocked(now time.Time) bool {
+	return now.Before(m.until)
+}
+
+func (m *Mode) statusLocked(now time.Time) Status {
+	if !m.activeLocked(now) {
+		return Status{}
+	}
+	since, until := m.since, m.until
+	return Status{Enabled: true, Since: &since, Until: &until, Reason: m.reason}
+}
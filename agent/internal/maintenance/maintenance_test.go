@@ -0,0 +1,56 @@
+package maintenance
+
+import (
+	"testing"
+	"time"
+)
+
+func TestModeLapsesAfterDuration(t *testing.T) {
+	now := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	m := New()
+	m.now = func() time.Time { return now }
+
+	if m.Active() {
+		t.Fatal("mode should start disabled")
+	}
+
+	st := m.Enable(30*time.Minute, "disk swap")
+	if !st.Enabled || st.Reason != "disk swap" || !st.Until.Equal(now.Add(30*time.Minute)) {
+		t.Fatalf("unexpected status after enable: %+v", st)
+	}
+
+	now = now.Add(29 * time.Minute)
+	if !m.Active() {
+		t.Error("mode should still be active before the deadline")
+	}
+
+	now = now.Add(time.Minute)
+	if m.Active() {
+		t.Error("mode should lapse at the deadline")
+	}
+	if st := m.Status(); st.Enabled || st.Until != nil {
+		t.Errorf("lapsed status should be empty, got %+v", st)
+	}
+}
+
+func TestModeExtendKeepsSince(t *testing.T) {
+	start := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	now := start
+	m := New()
+	m.now = func() time.Time { return now }
+
+	m.Enable(time.Hour, "")
+	now = now.Add(10 * time.Minute)
+	st := m.Enable(time.Hour, "")
+
+	if !st.Since.Equal(start) {
+		t.Errorf("since = %v, want %v", st.Since, start)
+	}
+	if !st.Until.Equal(now.Add(time.Hour)) {
+		t.Errorf("until = %v, want %v", st.Until, now.Add(time.Hour))
+	}
+
+	if st := m.Disable(); st.Enabled || m.Active() {
+		t.Error("mode should be off after Disable")
+	}
+}
@@ -0,0 +1,86 @@
+package metrics
+
+import (
+	"context"
+
+	"github.com/shirou/gopsutil/v4/disk"
+)
+
+// pseudoFSTypes are filesystem types that never represent real storage:
+// container layers, in-memory mounts and kernel interfaces.
+var pseudoFSTypes = map[string]bool{
+	"overlay":     true,
+	"aufs":        true,
+	"tmpfs":       true,
+	"devtmpfs":    true,
+	"ramfs":       true,
+	"squashfs":    true,
+	"proc":        true,
+	"sysfs":       true,
+	"cgroup":      true,
+	"cgroup2":     true,
+	"devpts":      true,
+	"mqueue":      true,
+	"debugfs":     true,
+	"tracefs":     true,
+	"securityfs":  true,
+	"pstore":      true,
+	"bpf":         true,
+	"autofs":      true,
+	"fusectl":     true,
+	"configfs":    true,
+	"hugetlbfs":   true,
+	"nsfs":        true,
+	"efivarfs":    true,
+	"binfmt_misc": true,
+	"rpc_pipefs":  true,
+	"nfsd":        true,
+}
+
+// filterPartitions drops pseudo filesystems and repeated mounts of the same
+// device (bind mounts), keeping the first mount seen for each device.
+func filterPartitions(partitions []disk.PartitionStat) []disk.PartitionStat {
+	seen := make(map[string]bool)
+	var out []disk.PartitionStat
+	for _, p := range partitions {
+		if pseudoFSTypes[p.Fstype] {
+			continue
+		}
+		if p.Device != "" && p.Device != "none" {
+			if seen[p.Device] {
+				continue
+			}
+			seen[p.Device] = true
+		}
+		out = append(out, p)
+	}
+	return out
+}
+
+// diskMetrics reports usage for mounted filesystems. Unless all is set,
+// pseudo filesystems and bind mounts are skipped.
+func diskMetrics(ctx context.Context, all bool) ([]DiskMetric, error) {
+	partitions, err := disk.PartitionsWithContext(ctx, all)
+	if err != nil {
+		return nil, err
+	}
+	if !all {
+		partitions = filterPartitions(partitions)
+	}
+
+	var disks []DiskMetric
+	for _, p := range partitions {
+		usage, err := disk.UsageWithContext(ctx, p.Mountpoint)
+		if err != nil || usage.Total == 0 {
+			continue
+		}
+		disks = append(disks, DiskMetric{
+			MountPoint:   p.Mountpoint,
+			FSType:       p.Fstype,
+			TotalBytes:   usage.Total,
+			UsedBytes:    usage.Used,
+			UsagePercent: usage.UsedPercent,
+		})
+	}
+	return disks, nil
+}
@@ -0,0 +1,30 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/shirou/gopsutil/v4/disk"
+)
+
+func TestFilterPartitions(t *testing.T) {
+	in := []disk.PartitionStat{
+		{Device: "/dev/sda1", Mountpoint: "/", Fstype: "ext4"},
+		{Device: "overlay", Mountpoint: "/var/lib/docker/overlay2/abc/merged", Fstype: "overlay"},
+		{Device: "tmpfs", Mountpoint: "/run", Fstype: "tmpfs"},
+		{Device: "/dev/sda1", Mountpoint: "/var/lib/docker/containers/x/hosts", Fstype: "ext4"},
+		{Device: "/dev/sdb1", Mountpoint: "/data", Fstype: "xfs"},
+		{Device: "/dev/loop0", Mountpoint: "/snap/core/1", Fstype: "squashfs"},
+	}
+
+	got := filterPartitions(in)
+
+	want := []string{"/", "/data"}
+	if len(got) != len(want) {
+		t.Fatalf("got %d partitions, want %d: %+v", len(got), len(want), got)
+	}
+	for i, p := range got {
+		if p.Mountpoint != want[i] {
+			t.Errorf("partition %d = %q, want %q", i, p.Mountpoint, want[i])
+		}
+	}
+}
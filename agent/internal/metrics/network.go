@@ -0,0 +1,110 @@
+package metrics
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/shirou/gopsutil/v4/net"
+)
+
+type NetworkMetric struct {
+	Interface     string  `json:"interface"`
+	RxBytes       uint64  `json:"rx_bytes"`
+	TxBytes       uint64  `json:"tx_bytes"`
+	RxBytesPerSec float64 `json:"rx_bytes_per_sec"`
+	TxBytesPerSec float64 `json:"tx_bytes_per_sec"`
+}
+
+// netSample is a point-in-time reading of an interface's byte counters.
+type netSample struct {
+	rx, tx uint64
+}
+
+// netRates holds the previous counter readings so consecutive collections
+// can derive rates without sampling twice.
+type netRates struct {
+	mu      sync.Mutex
+	at      time.Time
+	samples map[string]netSample
+}
+
+// computeNetRates derives per-second rates between two samples of the same
+// interfaces. Interfaces missing from prev, or whose counters went backwards
+// (e.g. after an interface restart), report a zero rate.
+func computeNetRates(prev, curr map[string]netSample, elapsed time.Duration) []NetworkMetric {
+	names := make([]string, 0, len(curr))
+	for name := range curr {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	secs := elapsed.Seconds()
+	out := make([]NetworkMetric, 0, len(names))
+	for _, name := range names {
+		c := curr[name]
+		m := NetworkMetric{Interface: name, RxBytes: c.rx, TxBytes: c.tx}
+		if p, ok := prev[name]; ok && secs > 0 {
+			m.RxBytesPerSec = counterRate(p.rx, c.rx, secs)
+			m.TxBytesPerSec = counterRate(p.tx, c.tx, secs)
+		}
+		out = append(out, m)
+	}
+	return out
+}
+
+// counterRate returns the per-second increase of a counter, clamped at zero.
+func counterRate(prev, curr uint64, secs float64) float64 {
+	if curr < prev {
+		return 0
+	}
+	return float64(curr-prev) / secs
+}
+
+// activeInterfaces returns the names of interfaces that are up and not loopback.
+func activeInterfaces(ctx context.Context) (map[string]bool, error) {
+	ifaces, err := net.InterfacesWithContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	active := make(map[string]bool, len(ifaces))
+	for _, iface := range ifaces {
+		if slices.Contains(iface.Flags, "up") && !slices.Contains(iface.Flags, "loopback") {
+			active[iface.Name] = true
+		}
+	}
+	return active, nil
+}
+
+// networkMetrics reads the active interfaces' counters, with rates over the
+// time since the previous call on n.
+func (n *netRates) networkMetrics(ctx context.Context) ([]NetworkMetric, error) {
+	active, err := activeInterfaces(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	counters, err := net.IOCountersWithContext(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+
+	curr := make(map[string]netSample, len(counters))
+	for _, c := range counters {
+		if active[c.Name] {
+			curr[c.Name] = netSample{rx: c.BytesRecv, tx: c.BytesSent}
+		}
+	}
+
+	now := time.Now()
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	result := computeNetRates(n.samples, curr, now.Sub(n.at))
+	n.samples = curr
+	n.at = now
+	return result, nil
+}
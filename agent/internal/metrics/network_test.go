@@ -0,0 +1,70 @@
+package metrics
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestComputeNetRates(t *testing.T) {
+	prev := map[string]netSample{
+		"eth0": {rx: 1000, tx: 500},
+		"eth1": {rx: 9000, tx: 9000},
+	}
+	curr := map[string]netSample{
+		"eth0":  {rx: 3000, tx: 1500},
+		"eth1":  {rx: 100, tx: 9200}, // rx counter reset
+		"wlan0": {rx: 50, tx: 50},    // new interface
+	}
+
+	got := computeNetRates(prev, curr, 2*time.Second)
+	if len(got) != 3 {
+		t.Fatalf("expected 3 interfaces, got %d", len(got))
+	}
+
+	want := []NetworkMetric{
+		{Interface: "eth0", RxBytes: 3000, TxBytes: 1500, RxBytesPerSec: 1000, TxBytesPerSec: 500},
+		{Interface: "eth1", RxBytes: 100, TxBytes: 9200, RxBytesPerSec: 0, TxBytesPerSec: 100},
+		{Interface: "wlan0", RxBytes: 50, TxBytes: 50},
+	}
+	for i, w := range want {
+		if got[i] != w {
+			t.Errorf("interface %d: got %+v, want %+v", i, got[i], w)
+		}
+	}
+}
+
+func TestComputeNetRates_NoPreviousSample(t *testing.T) {
+	curr := map[string]netSample{"eth0": {rx: 1000, tx: 1000}}
+
+	got := computeNetRates(nil, curr, 0)
+	if len(got) != 1 {
+		t.Fatalf("expected 1 interface, got %d", len(got))
+	}
+	if got[0].RxBytesPerSec != 0 || got[0].TxBytesPerSec != 0 {
+		t.Errorf("expected zero rates without a previous sample, got %+v", got[0])
+	}
+}
+
+func TestNetRatesArePerCollector(t *testing.T) {
+	var a, b Collector
+	if _, err := a.net.networkMetrics(context.Background()); err != nil {
+		t.Skipf("network counters unavailable: %v", err)
+	}
+	prevAt := a.net.at
+
+	// b has no previous sample of its own, so it reports no rates, and
+	// sampling through it leaves a's window alone.
+	got, err := b.net.networkMetrics(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range got {
+		if m.RxBytesPerSec != 0 || m.TxBytesPerSec != 0 {
+			t.Errorf("first collection reported rates: %+v", m)
+		}
+	}
+	if !a.net.at.Equal(prevAt) {
+		t.Error("collecting through one collector moved another's previous sample")
+	}
+}
@@ -3,38 +3,57 @@ package metrics
 import (
 	"context"
 	"log/slog"
+	"runtime"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/shirou/gopsutil/v4/cpu"
-	"github.com/shirou/gopsutil/v4/disk"
 	"github.com/shirou/gopsutil/v4/host"
+	"github.com/shirou/gopsutil/v4/load"
 	"github.com/shirou/gopsutil/v4/mem"
 	"github.com/shirou/gopsutil/v4/sensors"
 )
 
 type SystemMetrics struct {
-	Hostname      string       `json:"hostname"`
-	UptimeSeconds uint64       `json:"uptime_seconds"`
-	CPU           CPUMetrics   `json:"cpu"`
-	Memory        MemMetrics   `json:"memory"`
-	Disk          []DiskMetric `json:"disk"`
+	Hostname      string          `json:"hostname"`
+	UptimeSeconds uint64          `json:"uptime_seconds"`
+	CPU           CPUMetrics      `json:"cpu"`
+	Memory        MemMetrics      `json:"memory"`
+	Disk          []DiskMetric    `json:"disk"`
+	Network       []NetworkMetric `json:"network"`
+	LoadAvg       *LoadAvg        `json:"load_avg,omitempty"`
 }
 
 type CPUMetrics struct {
-	UsagePercent       float64  `json:"usage_percent"`
-	Cores              int      `json:"cores"`
-	TemperatureCelsius *float64 `json:"temperature_celsius,omitempty"`
+	UsagePercent       float64   `json:"usage_percent"`
+	PerCore            []float64 `json:"per_core,omitempty"`
+	Cores              int       `json:"cores"`
+	ModelName          string    `json:"model_name,omitempty"`
+	MHz                float64   `json:"mhz,omitempty"`
+	TemperatureCelsius *float64  `json:"temperature_celsius,omitempty"`
 }
 
 type MemMetrics struct {
-	TotalBytes   uint64  `json:"total_bytes"`
-	UsedBytes    uint64  `json:"used_bytes"`
-	UsagePercent float64 `json:"usage_percent"`
+	TotalBytes       uint64  `json:"total_bytes"`
+	UsedBytes        uint64  `json:"used_bytes"`
+	UsagePercent     float64 `json:"usage_percent"`
+	SwapTotalBytes   uint64  `json:"swap_total_bytes"`
+	SwapUsedBytes    uint64  `json:"swap_used_bytes"`
+	SwapUsagePercent float64 `json:"swap_usage_percent"`
+}
+
+// LoadAvg holds the 1/5/15-minute load averages. It is nil on platforms
+// without a native load average (Windows).
+type LoadAvg struct {
+	Load1  float64 `json:"load1"`
+	Load5  float64 `json:"load5"`
+	Load15 float64 `json:"load15"`
 }
 
 type DiskMetric struct {
 	MountPoint   string  `json:"mount_point"`
+	FSType       string  `json:"fstype,omitempty"`
 	TotalBytes   uint64  `json:"total_bytes"`
 	UsedBytes    uint64  `json:"used_bytes"`
 	UsagePercent float64 `json:"usage_percent"`
@@ -83,6 +102,61 @@ func selectCPUTemperature(temps []sensors.TemperatureStat) *float64 {
 	return &bestTemp
 }
 
+// averagePercent returns the mean of per-core busy percentages. Every core
+// accrues time at the same rate, so this equals the aggregate busy percentage.
+func averagePercent(perCore []float64) float64 {
+	if len(perCore) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, p := range perCore {
+		sum += p
+	}
+	return sum / float64(len(perCore))
+}
+
+func loadAverage(ctx context.Context) *LoadAvg {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	avg, err := load.AvgWithContext(ctx)
+	if err != nil {
+		slog.Debug("failed to read load average", "error", err)
+		return nil
+	}
+	return &LoadAvg{Load1: avg.Load1, Load5: avg.Load5, Load15: avg.Load15}
+}
+
+// cpuStatic holds CPU details that don't change while the agent runs.
+// cpu.Info parses /proc/cpuinfo (or shells out on some platforms), so it is
+// read once rather than on every Collect. Only a successful read is kept;
+// one that failed, e.g. because its context was cancelled, is retried.
+var cpuStatic struct {
+	mu        sync.Mutex
+	loaded    bool
+	modelName string
+	mhz       float64
+}
+
+// cpuInfo is cpu.InfoWithContext, replaced in tests.
+var cpuInfo = cpu.InfoWithContext
+
+func cpuModel(ctx context.Context) (string, float64) {
+	cpuStatic.mu.Lock()
+	defer cpuStatic.mu.Unlock()
+	if !cpuStatic.loaded {
+		infos, err := cpuInfo(ctx)
+		if err != nil || len(infos) == 0 {
+			slog.Debug("failed to read CPU info", "error", err)
+			return "", 0
+		}
+		cpuStatic.modelName = strings.TrimSpace(infos[0].ModelName)
+		cpuStatic.mhz = infos[0].Mhz
+		cpuStatic.loaded = true
+	}
+	return cpuStatic.modelName, cpuStatic.mhz
+}
+
 func cpuTemperature(ctx context.Context) *float64 {
 	temps, err := sensors.TemperaturesWithContext(ctx)
 	if err != nil {
@@ -92,14 +166,36 @@ func cpuTemperature(ctx context.Context) *float64 {
 	return selectCPUTemperature(temps)
 }
 
-// Collect gathers current system metrics.
-func Collect(ctx context.Context) (*SystemMetrics, error) {
+// Options controls what Collect reports.
+type Options struct {
+	// AllDisks includes pseudo filesystems and bind mounts in Disk.
+	AllDisks bool
+}
+
+// Collector gathers system metrics. It remembers the network counters from
+// its previous collection, so the network rates it reports cover the time
+// since then; each periodic consumer should own one, or the rates would
+// span whatever happened to pass since another consumer's last call. The
+// zero value is ready to use.
+type Collector struct {
+	net netRates
+}
+
+// Collect gathers current system metrics with default options.
+func (c *Collector) Collect(ctx context.Context) (*SystemMetrics, error) {
+	return c.CollectWithOptions(ctx, Options{})
+}
+
+// CollectWithOptions gathers current system metrics.
+func (c *Collector) CollectWithOptions(ctx context.Context, opts Options) (*SystemMetrics, error) {
 	info, err := host.InfoWithContext(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	cpuPercent, err := cpu.PercentWithContext(ctx, 500*time.Millisecond, false)
+	// Sample per-core usage once and derive the aggregate from it, so the
+	// per-core breakdown costs no extra sampling window.
+	perCore, err := cpu.PercentWithContext(ctx, 500*time.Millisecond, true)
 	if err != nil {
 		return nil, err
 	}
@@ -113,28 +209,30 @@ func Collect(ctx context.Context) (*SystemMetrics, error) {
 		return nil, err
 	}
 
-	partitions, err := disk.PartitionsWithContext(ctx, false)
+	// Swap is optional: hosts without swap report zeros rather than failing.
+	var swapTotal, swapUsed uint64
+	var swapPercent float64
+	if swap, err := mem.SwapMemoryWithContext(ctx); err != nil {
+		slog.Debug("failed to read swap usage", "error", err)
+	} else if swap.Total > 0 {
+		swapTotal, swapUsed, swapPercent = swap.Total, swap.Used, swap.UsedPercent
+	}
+
+	disks, err := diskMetrics(ctx, opts.AllDisks)
 	if err != nil {
 		return nil, err
 	}
 
-	var disks []DiskMetric
-	for _, p := range partitions {
-		usage, err := disk.UsageWithContext(ctx, p.Mountpoint)
-		if err != nil || usage.Total == 0 {
-			continue
-		}
-		disks = append(disks, DiskMetric{
-			MountPoint:   p.Mountpoint,
-			TotalBytes:   usage.Total,
-			UsedBytes:    usage.Used,
-			UsagePercent: usage.UsedPercent,
-		})
+	netMetrics, err := c.net.networkMetrics(ctx)
+	if err != nil {
+		slog.Debug("failed to read network counters", "error", err)
 	}
 
-	var cpuUsage float64
-	if len(cpuPercent) > 0 {
-		cpuUsage = cpuPercent[0]
+	modelName, mhz := cpuModel(ctx)
+
+	cpuUsage := averagePercent(perCore)
+	if len(perCore) < 2 {
+		perCore = nil // Nothing to break down on single-core hosts.
 	}
 
 	return &SystemMetrics{
@@ -142,14 +240,22 @@ func Collect(ctx context.Context) (*SystemMetrics, error) {
 		UptimeSeconds: info.Uptime,
 		CPU: CPUMetrics{
 			UsagePercent:       cpuUsage,
+			PerCore:            perCore,
 			Cores:              cores,
+			ModelName:          modelName,
+			MHz:                mhz,
 			TemperatureCelsius: cpuTemperature(ctx),
 		},
 		Memory: MemMetrics{
-			TotalBytes:   vmem.Total,
-			UsedBytes:    vmem.Used,
-			UsagePercent: vmem.UsedPercent,
+			TotalBytes:       vmem.Total,
+			UsedBytes:        vmem.Used,
+			UsagePercent:     vmem.UsedPercent,
+			SwapTotalBytes:   swapTotal,
+			SwapUsedBytes:    swapUsed,
+			SwapUsagePercent: swapPercent,
 		},
-		Disk: disks,
+		Disk:    disks,
+		Network: netMetrics,
+		LoadAvg: loadAverage(ctx),
 	}, nil
 }
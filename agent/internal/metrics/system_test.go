@@ -1,9 +1,11 @@
 package metrics
 
 import (
+	"context"
 	"encoding/json"
 	"testing"
 
+	"github.com/shirou/gopsutil/v4/cpu"
 	"github.com/shirou/gopsutil/v4/sensors"
 )
 
@@ -157,6 +159,96 @@ func TestCPUMetrics_JSON_Omitempty(t *testing.T) {
 			t.Errorf("expected temperature_celsius in JSON, got: %s", s)
 		}
 	})
+
+	t.Run("zero frequency omitted from JSON", func(t *testing.T) {
+		m := CPUMetrics{UsagePercent: 42.5, Cores: 4, ModelName: "Cortex-A72"}
+		data, err := json.Marshal(m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s := string(data)
+		if contains(s, "mhz") {
+			t.Errorf("expected mhz to be omitted, got: %s", s)
+		}
+		if !contains(s, `"model_name":"Cortex-A72"`) {
+			t.Errorf("expected model_name in JSON, got: %s", s)
+		}
+	})
+}
+
+func TestCPUMetrics_PerCore_JSON(t *testing.T) {
+	t.Run("nil per_core omitted from JSON", func(t *testing.T) {
+		data, err := json.Marshal(CPUMetrics{UsagePercent: 10, Cores: 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if contains(string(data), "per_core") {
+			t.Errorf("expected per_core to be omitted, got: %s", data)
+		}
+	})
+
+	t.Run("per_core present in JSON", func(t *testing.T) {
+		data, err := json.Marshal(CPUMetrics{UsagePercent: 50, PerCore: []float64{25, 75}, Cores: 2})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !contains(string(data), `"per_core":[25,75]`) {
+			t.Errorf("expected per_core in JSON, got: %s", data)
+		}
+	})
+}
+
+func TestSystemMetrics_LoadAvg_JSON(t *testing.T) {
+	data, err := json.Marshal(SystemMetrics{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if contains(string(data), "load_avg") {
+		t.Errorf("expected load_avg to be omitted when nil, got: %s", data)
+	}
+
+	data, err = json.Marshal(SystemMetrics{LoadAvg: &LoadAvg{Load1: 1.5, Load5: 1, Load15: 0.5}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !contains(string(data), `"load_avg":{"load1":1.5,"load5":1,"load15":0.5}`) {
+		t.Errorf("expected load_avg in JSON, got: %s", data)
+	}
+}
+
+func TestMemMetrics_Swap_JSON(t *testing.T) {
+	t.Run("swap disabled reports zeros", func(t *testing.T) {
+		data, err := json.Marshal(MemMetrics{TotalBytes: 1024, UsedBytes: 512, UsagePercent: 50})
+		if err != nil {
+			t.Fatal(err)
+		}
+		s := string(data)
+		for _, want := range []string{`"swap_total_bytes":0`, `"swap_used_bytes":0`, `"swap_usage_percent":0`} {
+			if !contains(s, want) {
+				t.Errorf("expected %s in JSON, got: %s", want, s)
+			}
+		}
+	})
+
+	t.Run("swap values present in JSON", func(t *testing.T) {
+		m := MemMetrics{SwapTotalBytes: 2048, SwapUsedBytes: 512, SwapUsagePercent: 25}
+		data, err := json.Marshal(m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !contains(string(data), `"swap_total_bytes":2048,"swap_used_bytes":512,"swap_usage_percent":25`) {
+			t.Errorf("expected swap fields in JSON, got: %s", data)
+		}
+	})
+}
+
+func TestAveragePercent(t *testing.T) {
+	if got := averagePercent(nil); got != 0 {
+		t.Errorf("averagePercent(nil) = %v, want 0", got)
+	}
+	if got := averagePercent([]float64{10, 20, 30, 40}); got != 25 {
+		t.Errorf("averagePercent() = %v, want 25", got)
+	}
 }
 
 func contains(s, substr string) bool {
@@ -171,3 +263,35 @@ func searchString(s, substr string) bool {
 	}
 	return false
 }
+
+func TestCPUModelRetriesFailedLookup(t *testing.T) {
+	orig := cpuInfo
+	t.Cleanup(func() {
+		cpuInfo = orig
+		cpuStatic.loaded = false
+	})
+	cpuStatic.loaded = false
+
+	calls := 0
+	cpuInfo = func(ctx context.Context) ([]cpu.InfoStat, error) {
+		calls++
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		return []cpu.InfoStat{{ModelName: " Test CPU ", Mhz: 2400}}, nil
+	}
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if model, _ := cpuModel(cancelled); model != "" {
+		t.Fatalf("cancelled lookup returned %q", model)
+	}
+	for range 2 {
+		if model, mhz := cpuModel(context.Background()); model != "Test CPU" || mhz != 2400 {
+			t.Fatalf("cpuModel = %q, %v; want Test CPU, 2400", model, mhz)
+		}
+	}
+	if calls != 2 {
+		t.Errorf("cpu info read %d times, want 2 (the failure, then once)", calls)
+	}
+}
@@ -0,0 +1,83 @@
+package registry
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ComposeFiles returns the compose files docker compose loads for a project
+// in dir, in merge order. A COMPOSE_FILE entry in dir/.env takes precedence,
+// split on COMPOSE_PATH_SEPARATOR (":" by default) with relative paths
+// resolved against dir. Otherwise it is the file FindComposeFile picks,
+// followed by its override sibling (compose.override.yaml next to
+// compose.yaml, and so on) when there is one. It returns nil if dir holds
+// no compose file.
+func ComposeFiles(dir string) []string {
+	env := readDotEnv(filepath.Join(dir, ".env"))
+	if list := env["COMPOSE_FILE"]; list != "" {
+		sep := env["COMPOSE_PATH_SEPARATOR"]
+		if sep == "" {
+			sep = string(os.PathListSeparator)
+		}
+		var files []string
+		for _, f := range strings.Split(list, sep) {
+			if f = strings.TrimSpace(f); f == "" {
+				continue
+			}
+			if !filepath.IsAbs(f) {
+				f = filepath.Join(dir, f)
+			}
+			files = append(files, filepath.Clean(f))
+		}
+		if len(files) > 0 {
+			return files
+		}
+	}
+
+	base := FindComposeFile(dir)
+	if base == "" {
+		return nil
+	}
+	files := []string{base}
+	stem := strings.TrimSuffix(base, filepath.Ext(base))
+	for _, ext := range []string{".yml", ".yaml"} {
+		override := stem + ".override" + ext
+		if _, err := os.Stat(override); err == nil {
+			files = append(files, override)
+			break
+		}
+	}
+	return files
+}
+
+// readDotEnv parses the KEY=VALUE lines of a compose .env file, ignoring
+// blank lines, comments and an "export " prefix, and stripping matching
+// quotes around values. A missing or unreadable file yields no entries.
+func readDotEnv(path string) map[string]string {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	env := make(map[string]string)
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(strings.TrimPrefix(line, "export "), "=")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+		env[strings.TrimSpace(key)] = value
+	}
+	return env
+}
@@ -0,0 +1,58 @@
+package registry
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestComposeFilesWithOverride(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, "docker-compose.yml"), "services: {}\n")
+	writeTestFile(t, filepath.Join(dir, "docker-compose.override.yml"), "services: {}\n")
+	// An override for a different base name is not picked up.
+	writeTestFile(t, filepath.Join(dir, "compose.override.yaml"), "services: {}\n")
+
+	got := ComposeFiles(dir)
+	want := []string{filepath.Join(dir, "docker-compose.yml"), filepath.Join(dir, "docker-compose.override.yml")}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ComposeFiles = %v, want %v", got, want)
+	}
+}
+
+func TestComposeFilesWithoutOverride(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, "compose.yaml"), "services: {}\n")
+
+	got := ComposeFiles(dir)
+	if want := []string{filepath.Join(dir, "compose.yaml")}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ComposeFiles = %v, want %v", got, want)
+	}
+	if got := ComposeFiles(t.TempDir()); got != nil {
+		t.Errorf("ComposeFiles of an empty dir = %v, want nil", got)
+	}
+}
+
+func TestComposeFilesFromDotEnv(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, "compose.yaml"), "services: {}\n")
+	writeTestFile(t, filepath.Join(dir, ".env"), "# stack settings\nTZ=UTC\nCOMPOSE_FILE=\"compose.yaml;prod/compose.prod.yaml;/etc/shared.yaml\"\nCOMPOSE_PATH_SEPARATOR=;\n")
+
+	got := ComposeFiles(dir)
+	want := []string{
+		filepath.Join(dir, "compose.yaml"),
+		filepath.Join(dir, "prod", "compose.prod.yaml"),
+		"/etc/shared.yaml",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ComposeFiles = %v, want %v", got, want)
+	}
+}
+
+func writeTestFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
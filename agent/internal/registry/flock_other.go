@@ -0,0 +1,11 @@
+//go:build !unix
+
+package registry
+
+import "os"
+
+// tryLock is a no-op where flock is unavailable; the registry then relies
+// on only one agent using a data directory.
+func tryLock(*os.File) (bool, error) {
+	return true, nil
+}
@@ -0,0 +1,113 @@
+package registry
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// composeFileNames are the file names docker compose looks for by default,
+// in the order they are checked.
+var composeFileNames = []string{
+	"docker-compose.yml",
+	"docker-compose.yaml",
+	"compose.yml",
+	"compose.yaml",
+}
+
+// FindComposeFile returns the path of the compose file in dir, or "" if
+// dir contains none.
+func FindComposeFile(dir string) string {
+	for _, name := range composeFileNames {
+		path := filepath.Join(dir, name)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+// ScanResult summarises a Scan.
+type ScanResult struct {
+	Discovered int // subdirectories with a compose file
+	Registered int // of those, stacks that weren't registered before
+	Stale      int // registered stacks under the roots whose compose file is gone, kept registered
+}
+
+// Scan registers every immediate subdirectory of roots that contains a
+// compose file, named after the subdirectory as with manual registration.
+// Registered stacks inside a root whose compose file has disappeared are
+// counted as stale but kept: staleness isn't stored, since the file may
+// come back. A discovered directory whose name is
+// already registered for a different path is skipped so that manual
+// registrations are never overwritten. Unreadable roots are logged and
+// skipped; the returned error only reports failure to persist.
+func (s *Store) Scan(roots []string) (ScanResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var res ScanResult
+	err := s.update(func() error {
+		now := time.Now().Unix()
+		scanned := make(map[string]bool, len(roots))
+		for _, root := range roots {
+			root = filepath.Clean(root)
+			entries, err := os.ReadDir(root)
+			if err != nil {
+				// Leave the root out of the stale check: an unreadable or
+				// unmounted directory says nothing about its stacks.
+				slog.Warn("scan: cannot read directory", "path", root, "error", err)
+				continue
+			}
+			scanned[root] = true
+
+			for _, e := range entries {
+				if !e.IsDir() {
+					continue
+				}
+				dir := filepath.Join(root, e.Name())
+				composePath := FindComposeFile(dir)
+				if composePath == "" {
+					continue
+				}
+				res.Discovered++
+
+				name := e.Name()
+				existing, ok := s.stacks[name]
+				if ok && existing.WorkingDir != dir {
+					slog.Warn("scan: stack name already registered for another path, skipping",
+						"name", name, "path", dir, "registered_path", existing.WorkingDir)
+					continue
+				}
+				rs := RegisteredStack{
+					Name:         name,
+					WorkingDir:   dir,
+					ComposePath:  composePath,
+					RegisteredAt: existing.RegisteredAt,
+					UpdatedAt:    existing.UpdatedAt,
+				}
+				switch {
+				case !ok:
+					res.Registered++
+					rs.RegisteredAt, rs.UpdatedAt = now, now
+				case existing.ComposePath != composePath:
+					rs.UpdatedAt = now
+				}
+				s.stacks[name] = rs
+			}
+		}
+
+		for _, rs := range s.stacks {
+			if scanned[filepath.Dir(rs.WorkingDir)] && FindComposeFile(rs.WorkingDir) == "" {
+				res.Stale++
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return res, fmt.Errorf("registry: save scan results: %w", err)
+	}
+	return res, nil
+}
@@ -0,0 +1,63 @@
+package registry
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestScan(t *testing.T) {
+	root := t.TempDir()
+	mkStack := func(name, file string) string {
+		dir := filepath.Join(root, name)
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if file != "" {
+			if err := os.WriteFile(filepath.Join(dir, file), []byte("services: {}\n"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return dir
+	}
+	mkStack("web", "compose.yaml")
+	mkStack("db", "docker-compose.yml")
+	mkStack("notes", "") // no compose file
+	gone := mkStack("old", "compose.yml")
+
+	s, err := NewStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Register("old", gone, filepath.Join(gone, "compose.yml")); err != nil {
+		t.Fatal(err)
+	}
+	// A manual registration with a clashing name must not be overwritten.
+	if err := s.Register("db", "/elsewhere/db", "/elsewhere/db/compose.yaml"); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(gone, "compose.yml")); err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := s.Scan([]string{root, filepath.Join(root, "missing")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Discovered != 2 || res.Registered != 1 || res.Stale != 1 {
+		t.Errorf("unexpected result %+v", res)
+	}
+
+	if web := s.Get("web"); web == nil || web.ComposePath != filepath.Join(root, "web", "compose.yaml") {
+		t.Errorf("web not registered correctly: %+v", web)
+	}
+	if db := s.Get("db"); db == nil || db.WorkingDir != "/elsewhere/db" {
+		t.Errorf("manual db registration overwritten: %+v", db)
+	}
+	if old := s.Get("old"); old == nil {
+		t.Error("stale stack old should be kept")
+	}
+	if s.Get("notes") != nil {
+		t.Error("directory without a compose file should not be registered")
+	}
+}
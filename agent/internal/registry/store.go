@@ -1,25 +1,63 @@
 package registry
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"sort"
 	"sync"
+	"time"
 )
 
+// lockTimeout is how long an operation waits for another process to
+// release the registry file before giving up.
+const lockTimeout = 2 * time.Second
+
+// ErrLocked is returned when the registry file stays locked by another
+// process, typically a second agent sharing the data directory.
+var ErrLocked = errors.New("registry: data file is locked by another process")
+
 // RegisteredStack holds persistent metadata for a user-registered compose stack.
 type RegisteredStack struct {
 	Name        string `json:"name"`
 	WorkingDir  string `json:"working_dir"`
 	ComposePath string `json:"compose_path"`
+	// RegisteredAt is when the name was first registered and UpdatedAt when
+	// its registration last changed, both Unix seconds. Zero for stacks
+	// saved before they were recorded.
+	RegisteredAt int64 `json:"registered_at,omitempty"`
+	UpdatedAt    int64 `json:"updated_at,omitempty"`
 }
 
-// Store is a thread-safe, file-backed registry of compose stacks.
+// Store is a thread-safe, file-backed registry of compose stacks. Changes
+// are made under an advisory lock on the data file and re-read the file
+// first, so agents accidentally sharing a data directory don't lose each
+// other's writes.
 type Store struct {
 	mu     sync.RWMutex
 	path   string
 	stacks map[string]RegisteredStack
+	// seen identifies the file version last loaded or saved, so Watch can
+	// tell external edits from the store's own writes.
+	seen fileVersion
+}
+
+// fileVersion is the modification time and size of the data file.
+type fileVersion struct {
+	modTime time.Time
+	size    int64
+}
+
+func statVersion(path string) fileVersion {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileVersion{}
+	}
+	return fileVersion{modTime: info.ModTime(), size: info.Size()}
 }
 
 // NewStore creates a Store backed by stacks.json in dataDir.
@@ -42,23 +80,43 @@ func NewStore(dataDir string) (*Store, error) {
 		stacks: make(map[string]RegisteredStack),
 	}
 
+	unlock, err := s.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
 	if err := s.load(); err != nil {
 		return nil, err
 	}
 	return s, nil
 }
 
+// Path returns the file the registry is persisted to.
+func (s *Store) Path() string {
+	return s.path
+}
+
 // Register adds or updates a stack in the registry and persists to disk.
+// Re-registering a name keeps its RegisteredAt and bumps UpdatedAt.
 func (s *Store) Register(name, workingDir, composePath string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	s.stacks[name] = RegisteredStack{
-		Name:        name,
-		WorkingDir:  workingDir,
-		ComposePath: composePath,
-	}
-	return s.save()
+	return s.update(func() error {
+		now := time.Now().Unix()
+		registeredAt := now
+		if existing, ok := s.stacks[name]; ok && existing.RegisteredAt != 0 {
+			registeredAt = existing.RegisteredAt
+		}
+		s.stacks[name] = RegisteredStack{
+			Name:         name,
+			WorkingDir:   workingDir,
+			ComposePath:  composePath,
+			RegisteredAt: registeredAt,
+			UpdatedAt:    now,
+		}
+		return nil
+	})
 }
 
 // Unregister removes a stack from the registry and persists to disk.
@@ -66,8 +124,10 @@ func (s *Store) Unregister(name string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	delete(s.stacks, name)
-	return s.save()
+	return s.update(func() error {
+		delete(s.stacks, name)
+		return nil
+	})
 }
 
 // Get returns a registered stack by name, or nil if not found.
@@ -81,6 +141,40 @@ func (s *Store) Get(name string) *RegisteredStack {
 	return nil
 }
 
+// PruneStale unregisters every stack whose compose file can no longer be
+// found and whose directory is definitely gone, and returns their names.
+// A stack on an unmounted or unreadable volume is kept.
+func (s *Store) PruneStale() ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var removed []string
+	err := s.update(func() error {
+		for name, rs := range s.stacks {
+			if FindComposeFile(rs.WorkingDir) == "" && dirGone(rs.WorkingDir) {
+				delete(s.stacks, name)
+				removed = append(removed, name)
+			}
+		}
+		return nil
+	})
+	sort.Strings(removed)
+	return removed, err
+}
+
+// dirGone reports whether dir is missing from a readable parent, or exists
+// and is empty. Any other failure says nothing about the stack.
+func dirGone(dir string) bool {
+	if _, err := os.ReadDir(filepath.Dir(dir)); err != nil {
+		return false
+	}
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return true
+	}
+	return err == nil && len(entries) == 0
+}
+
 // All returns a copy of every registered stack.
 func (s *Store) All() []RegisteredStack {
 	s.mu.RLock()
@@ -93,23 +187,122 @@ func (s *Store) All() []RegisteredStack {
 	return out
 }
 
-func (s *Store) load() error {
-	data, err := os.ReadFile(s.path)
+// update applies fn to the stacks as currently persisted and saves the
+// result, holding the file lock throughout. The caller must hold s.mu.
+func (s *Store) update(fn func() error) error {
+	unlock, err := s.lock()
 	if err != nil {
-		if os.IsNotExist(err) {
-			return nil // first run — no file yet
+		return err
+	}
+	defer unlock()
+
+	// Another process may have saved since we last read the file.
+	if err := s.load(); err != nil {
+		return err
+	}
+	if err := fn(); err != nil {
+		return err
+	}
+	return s.save()
+}
+
+// lock takes the advisory lock on the data file, retrying until
+// lockTimeout if another process holds it. Saves replace the file by
+// rename, so a lock taken on an inode that has since been replaced guards
+// nothing; it is dropped and taken again on the current file.
+func (s *Store) lock() (unlock func(), err error) {
+	deadline := time.Now().Add(lockTimeout)
+	for {
+		f, err := os.OpenFile(s.path, os.O_RDWR|os.O_CREATE, 0o644)
+		if err != nil {
+			return nil, fmt.Errorf("registry: open %s: %w", s.path, err)
 		}
-		return fmt.Errorf("registry: read %s: %w", s.path, err)
+		locked, err := tryLock(f)
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("registry: lock %s: %w", s.path, err)
+		}
+		if locked {
+			held, herr := f.Stat()
+			current, cerr := os.Stat(s.path)
+			if herr == nil && cerr == nil && os.SameFile(held, current) {
+				return func() { f.Close() }, nil
+			}
+		}
+		f.Close()
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("%w: %s", ErrLocked, s.path)
+		}
+		time.Sleep(25 * time.Millisecond)
 	}
+}
+
+// Reload replaces the in-memory stacks with the contents of the data file,
+// picking up edits made by hand or by another process. If the file doesn't
+// parse (say, an editor is halfway through saving it) the current stacks
+// are kept and the error returned.
+func (s *Store) Reload() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	var list []RegisteredStack
-	if err := json.Unmarshal(data, &list); err != nil {
-		return fmt.Errorf("registry: parse %s: %w", s.path, err)
+	unlock, err := s.lock()
+	if err != nil {
+		return err
 	}
+	defer unlock()
+	return s.load()
+}
+
+// Watch polls the data file every interval and reloads the store when it
+// changes on disk, until ctx is done.
+func (s *Store) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
 
-	for _, rs := range list {
-		s.stacks[rs.Name] = rs
+		s.mu.RLock()
+		seen := s.seen
+		s.mu.RUnlock()
+		if statVersion(s.path) == seen {
+			continue
+		}
+		if err := s.Reload(); err != nil {
+			slog.Warn("registry: reload failed, keeping current stacks", "path", s.path, "error", err)
+			continue
+		}
+		slog.Info("registry: reloaded after external change", "path", s.path)
+	}
+}
+
+// load replaces s.stacks with the file's contents. The map is only swapped
+// once the whole file has parsed.
+func (s *Store) load() error {
+	version := statVersion(s.path)
+	data, err := os.ReadFile(s.path)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("registry: read %s: %w", s.path, err)
 	}
+
+	stacks := make(map[string]RegisteredStack)
+	// A missing file is a first run; an empty one was just created by lock.
+	if len(data) > 0 {
+		var list []RegisteredStack
+		if err := json.Unmarshal(data, &list); err != nil {
+			return fmt.Errorf("registry: parse %s: %w", s.path, err)
+		}
+		for _, rs := range list {
+			stacks[rs.Name] = rs
+		}
+	}
+
+	s.stacks = stacks
+	s.seen = version
 	return nil
 }
 
@@ -123,5 +316,53 @@ func (s *Store) save() error {
 	if err != nil {
 		return fmt.Errorf("registry: marshal: %w", err)
 	}
-	return os.WriteFile(s.path, data, 0o644)
+	if err := writeFileAtomic(s.path, data, 0o644); err != nil {
+		return err
+	}
+	s.seen = statVersion(s.path)
+	return nil
+}
+
+// rename is os.Rename; tests swap it to fail a save after the temp file is written.
+var rename = os.Rename
+
+// writeFileAtomic replaces path with data so that readers see either the
+// old or the new contents, never a truncated file: the data is written and
+// fsynced to a temp file in the same directory, which is then renamed over
+// path. A crash or full disk mid-write leaves only a stray temp file.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("registry: create temp file: %w", err)
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath) // No-op once renamed.
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("registry: write temp file: %w", err)
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return fmt.Errorf("registry: chmod temp file: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("registry: sync temp file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("registry: close temp file: %w", err)
+	}
+
+	if err := rename(tmpPath, path); err != nil {
+		return fmt.Errorf("registry: replace %s: %w", path, err)
+	}
+
+	// Persist the rename itself; failure here only weakens durability.
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+	return nil
 }
@@ -0,0 +1,237 @@
+package registry
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestSaveLeavesNoTempFiles(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Register("web", "/srv/web", "/srv/web/compose.yaml"); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 || entries[0].Name() != "stacks.json" {
+		var names []string
+		for _, e := range entries {
+			names = append(names, e.Name())
+		}
+		t.Errorf("expected only stacks.json, got %v", names)
+	}
+}
+
+func TestInterruptedSaveKeepsPriorContents(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Register("web", "/srv/web", "/srv/web/compose.yaml"); err != nil {
+		t.Fatal(err)
+	}
+
+	before, err := os.ReadFile(filepath.Join(dir, "stacks.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Fail the save after the new contents are written but before they
+	// replace the registry.
+	rename = func(string, string) error { return errors.New("injected rename failure") }
+	t.Cleanup(func() { rename = os.Rename })
+	if err := s.Register("api", "/srv/api", "/srv/api/compose.yaml"); err == nil {
+		t.Fatal("expected the save to fail")
+	}
+
+	after, err := os.ReadFile(filepath.Join(dir, "stacks.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(after) != string(before) {
+		t.Errorf("registry changed by a failed save:\n%s\nwant:\n%s", after, before)
+	}
+	if matches, _ := filepath.Glob(filepath.Join(dir, ".stacks.json.tmp-*")); len(matches) != 0 {
+		t.Errorf("temp files left behind: %v", matches)
+	}
+
+	// A save killed mid-write can also leave a truncated temp file; it must
+	// not be mistaken for the registry.
+	if err := os.WriteFile(filepath.Join(dir, ".stacks.json.tmp-123"), []byte(`[{"name": "web", "work`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	reopened, err := NewStore(dir)
+	if err != nil {
+		t.Fatalf("reopening store after failed save: %v", err)
+	}
+	if got := reopened.Get("web"); got == nil || got.WorkingDir != "/srv/web" {
+		t.Fatalf("expected prior registration to survive, got %+v", got)
+	}
+	if got := reopened.Get("api"); got != nil {
+		t.Errorf("failed registration was persisted: %+v", got)
+	}
+}
+
+func TestWriteFileAtomicCleansUpOnFailure(t *testing.T) {
+	dir := t.TempDir()
+
+	// Renaming onto a non-empty directory fails after the temp file has
+	// been written, standing in for a failure partway through the save.
+	target := filepath.Join(dir, "stacks.json")
+	if err := os.MkdirAll(filepath.Join(target, "child"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeFileAtomic(target, []byte(`[{"name":"x"}]`), 0o644); err == nil {
+		t.Fatal("expected replacing a directory to fail")
+	}
+
+	matches, _ := filepath.Glob(filepath.Join(dir, ".stacks.json.tmp-*"))
+	if len(matches) != 0 {
+		t.Errorf("temp file not cleaned up: %v", matches)
+	}
+}
+
+func TestStoresSharingDataDirKeepEachOthersWrites(t *testing.T) {
+	dir := t.TempDir()
+	a, err := NewStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := NewStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := a.Register("web", "/srv/web", "/srv/web/compose.yaml"); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Register("db", "/srv/db", "/srv/db/compose.yaml"); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := NewStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Get("web") == nil || c.Get("db") == nil {
+		t.Fatalf("want both stacks persisted, got %+v", c.All())
+	}
+}
+
+func TestReload(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Register("web", "/srv/web", "/srv/web/compose.yaml"); err != nil {
+		t.Fatal(err)
+	}
+
+	edited := `[{"name": "api", "working_dir": "/srv/api", "compose_path": "/srv/api/compose.yaml"}]`
+	if err := os.WriteFile(s.Path(), []byte(edited), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if s.Get("api") == nil || s.Get("web") != nil {
+		t.Fatalf("after reload got %+v, want only api", s.All())
+	}
+
+	if err := os.WriteFile(s.Path(), []byte(`[{"name": "half`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Reload(); err == nil {
+		t.Fatal("want error reloading truncated file")
+	}
+	if s.Get("api") == nil {
+		t.Fatalf("failed reload must keep current stacks, got %+v", s.All())
+	}
+}
+
+func TestRegisterTimestamps(t *testing.T) {
+	dir := t.TempDir()
+	// A file written before timestamps existed still loads.
+	legacy := `[{"name": "web", "working_dir": "/srv/web", "compose_path": "/srv/web/compose.yaml"}]`
+	if err := os.WriteFile(filepath.Join(dir, "stacks.json"), []byte(legacy), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rs := s.Get("web"); rs == nil || rs.RegisteredAt != 0 || rs.UpdatedAt != 0 {
+		t.Fatalf("legacy stack: got %+v", rs)
+	}
+
+	if err := s.Register("api", "/srv/api", "/srv/api/compose.yaml"); err != nil {
+		t.Fatal(err)
+	}
+	first := *s.Get("api")
+	if first.RegisteredAt == 0 || first.UpdatedAt != first.RegisteredAt {
+		t.Fatalf("new stack timestamps: %+v", first)
+	}
+
+	// Pretend the registration is old so the bump is observable.
+	s.stacks["api"] = RegisteredStack{Name: "api", WorkingDir: "/srv/api", ComposePath: "/srv/api/compose.yaml", RegisteredAt: 1000, UpdatedAt: 1000}
+	if err := s.save(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Register("api", "/srv/api2", "/srv/api2/compose.yaml"); err != nil {
+		t.Fatal(err)
+	}
+	if rs := s.Get("api"); rs.RegisteredAt != 1000 || rs.UpdatedAt <= 1000 {
+		t.Fatalf("re-registered stack: got %+v, want RegisteredAt kept and UpdatedAt bumped", rs)
+	}
+}
+
+func TestPruneStale(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	live := t.TempDir()
+	if err := os.WriteFile(filepath.Join(live, "compose.yaml"), []byte("services: {}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// A directory with other files but no compose file, and one whose
+	// parent is missing (an unmounted volume), are not known to be gone.
+	renamed := t.TempDir()
+	if err := os.WriteFile(filepath.Join(renamed, "docker-compose.old"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	stacks := map[string]string{
+		"live":      live,
+		"gone":      filepath.Join(t.TempDir(), "deleted"),
+		"empty":     t.TempDir(),
+		"renamed":   renamed,
+		"unmounted": filepath.Join(t.TempDir(), "mnt", "app"),
+	}
+	for name, dir := range stacks {
+		if err := s.Register(name, dir, filepath.Join(dir, "compose.yaml")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	removed, err := s.PruneStale()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(removed, []string{"empty", "gone"}) {
+		t.Fatalf("removed = %v, want [empty gone]", removed)
+	}
+	for _, name := range []string{"live", "renamed", "unmounted"} {
+		if s.Get(name) == nil {
+			t.Errorf("%s was pruned", name)
+		}
+	}
+}
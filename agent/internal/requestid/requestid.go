@@ -0,0 +1,77 @@
+// Package requestid carries a per-request trace identifier through the
+// request context and into log records.
+package requestid
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"log/slog"
+)
+
+// Header is the HTTP header the id is read from and echoed in.
+const Header = "X-Request-ID"
+
+// maxLen bounds client-supplied ids so they can't bloat logs.
+const maxLen = 128
+
+type ctxKey struct{}
+
+// NewContext returns a copy of ctx carrying id.
+func NewContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, ctxKey{}, id)
+}
+
+// FromContext returns the id stored in ctx, or "" if there is none.
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(ctxKey{}).(string)
+	return id
+}
+
+// New returns a random version 4 UUID.
+func New() string {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+// Valid reports whether a client-supplied id is safe to adopt: non-empty,
+// at most 128 characters, and printable ASCII without spaces, so it can't
+// forge log lines or headers.
+func Valid(id string) bool {
+	if id == "" || len(id) > maxLen {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// LogHandler wraps an slog.Handler, adding a request_id attribute to every
+// record logged with a context that carries one.
+type LogHandler struct {
+	slog.Handler
+}
+
+// Handle implements slog.Handler.
+func (h LogHandler) Handle(ctx context.Context, rec slog.Record) error {
+	if id := FromContext(ctx); id != "" {
+		rec.AddAttrs(slog.String("request_id", id))
+	}
+	return h.Handler.Handle(ctx, rec)
+}
+
+// WithAttrs implements slog.Handler.
+func (h LogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return LogHandler{h.Handler.WithAttrs(attrs)}
+}
+
+// WithGroup implements slog.Handler.
+func (h LogHandler) WithGroup(name string) slog.Handler {
+	return LogHandler{h.Handler.WithGroup(name)}
+}
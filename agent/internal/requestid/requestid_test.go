@@ -0,0 +1,54 @@
+package requestid
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	uuidRe := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	a, b := New(), New()
+	if !uuidRe.MatchString(a) {
+		t.Errorf("New() = %q, not a v4 UUID", a)
+	}
+	if a == b {
+		t.Errorf("New() returned %q twice", a)
+	}
+}
+
+func TestValid(t *testing.T) {
+	for id, want := range map[string]bool{
+		"abc-123":                true,
+		"":                       false,
+		"has space":              false,
+		"line\nbreak":            false,
+		strings.Repeat("x", 129): false,
+	} {
+		if got := Valid(id); got != want {
+			t.Errorf("Valid(%q) = %v, want %v", id, got, want)
+		}
+	}
+}
+
+func TestLogHandler(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(LogHandler{slog.NewTextHandler(&buf, nil)})
+
+	logger.InfoContext(NewContext(context.Background(), "req-1"), "with id")
+	logger.InfoContext(context.Background(), "without id")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d log lines, want 2", len(lines))
+	}
+	if !strings.Contains(lines[0], "request_id=req-1") {
+		t.Errorf("first line lacks request_id: %s", lines[0])
+	}
+	if strings.Contains(lines[1], "request_id") {
+		t.Errorf("second line has request_id: %s", lines[1])
+	}
+}
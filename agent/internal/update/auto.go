@@ -0,0 +1,75 @@
+package update
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"math/rand/v2"
+	"time"
+)
+
+// MinAutoUpdateInterval is the shortest interval AutoUpdate accepts. Each
+// round costs two GitHub API requests, and unauthenticated clients get 60
+// an hour.
+const MinAutoUpdateInterval = 10 * time.Minute
+
+// AutoUpdate checks for a newer release every interval and installs it. The
+// first check happens after a random delay of up to one interval so a fleet
+// started together doesn't update in lockstep. It returns true once an
+// update has been installed and the agent should restart into it, or false
+// when ctx is done. Rounds are skipped while paused (which may be nil)
+// returns true, e.g. during maintenance mode.
+func (u *Updater) AutoUpdate(ctx context.Context, interval time.Duration, paused func() bool) bool {
+	wait := rand.N(interval)
+	slog.InfoContext(ctx, "auto-update scheduled", "first_check_in", wait.Round(time.Second).String(),
+		"interval", interval.String(), "channel", u.channel)
+	for {
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return false
+		case <-timer.C:
+		}
+		if u.autoUpdateOnce(ctx, paused) {
+			return true
+		}
+		wait = interval
+	}
+}
+
+// autoUpdateOnce runs one auto-update round and reports whether an update
+// was installed.
+func (u *Updater) autoUpdateOnce(ctx context.Context, paused func() bool) bool {
+	if paused != nil && paused() {
+		slog.InfoContext(ctx, "auto-update skipped, maintenance mode active")
+		return false
+	}
+	check, err := u.CheckLatest(ctx)
+	if err != nil {
+		if ctx.Err() == nil {
+			slog.WarnContext(ctx, "auto-update check failed", "error", err)
+		}
+		return false
+	}
+	slog.InfoContext(ctx, "auto-update checked", "current", check.CurrentVersion,
+		"latest", check.LatestVersion, "update_available", check.UpdateAvailable)
+	if !check.UpdateAvailable {
+		slog.InfoContext(ctx, "auto-update skipped, already running the latest version", "version", check.CurrentVersion)
+		return false
+	}
+
+	err = u.Apply(ctx)
+	switch {
+	case errors.Is(err, ErrAlreadyLatest):
+		slog.InfoContext(ctx, "auto-update skipped, already running the latest version", "version", check.CurrentVersion)
+		return false
+	case err != nil:
+		if ctx.Err() == nil {
+			slog.ErrorContext(ctx, "auto-update failed", "version", check.LatestVersion, "error", err)
+		}
+		return false
+	}
+	slog.InfoContext(ctx, "auto-update applied", "from", check.CurrentVersion, "to", check.LatestVersion)
+	return true
+}
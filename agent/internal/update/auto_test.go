@@ -0,0 +1,77 @@
+package update
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestAutoUpdateOnce_SkipsWhenLatest(t *testing.T) {
+	var requests int
+	apiSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(testRelease("v0.2.0"))
+	}))
+	defer apiSrv.Close()
+
+	u := New("0.2.0", "test/repo")
+	u.httpClient = &http.Client{Transport: redirectTransport(apiSrv)}
+
+	if u.autoUpdateOnce(context.Background(), nil) {
+		t.Fatal("expected no update to be applied")
+	}
+	if requests != 1 {
+		t.Errorf("expected only the release check, got %d requests", requests)
+	}
+}
+
+func TestAutoUpdateOnce_SkipsWhilePaused(t *testing.T) {
+	var requests int
+	apiSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		json.NewEncoder(w).Encode(testRelease("v0.3.0"))
+	}))
+	defer apiSrv.Close()
+
+	u := New("0.2.0", "test/repo")
+	u.httpClient = &http.Client{Transport: redirectTransport(apiSrv)}
+
+	if u.autoUpdateOnce(context.Background(), func() bool { return true }) {
+		t.Fatal("expected no update while paused")
+	}
+	if requests != 0 {
+		t.Errorf("expected no requests while paused, got %d", requests)
+	}
+}
+
+func TestAutoUpdateOnce_CheckFailure(t *testing.T) {
+	srv := newTestServer(t, nil, http.StatusInternalServerError)
+	defer srv.Close()
+
+	u := New("0.2.0", "test/repo")
+	u.httpClient = &http.Client{Transport: redirectTransport(srv)}
+
+	if u.autoUpdateOnce(context.Background(), nil) {
+		t.Fatal("expected no update to be applied")
+	}
+}
+
+func TestAutoUpdate_StopsOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan bool)
+	go func() { done <- New("0.2.0", "test/repo").AutoUpdate(ctx, time.Hour, nil) }()
+	cancel()
+
+	select {
+	case applied := <-done:
+		if applied {
+			t.Error("expected false after cancellation")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("AutoUpdate did not return after cancellation")
+	}
+}
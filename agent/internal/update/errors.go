@@ -1,6 +1,9 @@
 package update
 
-import "errors"
+import (
+	"errors"
+	"time"
+)
 
 var (
 	// ErrAlreadyLatest means the current version is already the latest.
@@ -20,4 +23,41 @@ var (
 
 	// ErrChecksumMismatch means the downloaded binary failed verification.
 	ErrChecksumMismatch = errors.New("checksum verification failed")
+
+	// ErrReleaseNotFound means no release exists with the requested tag.
+	ErrReleaseNotFound = errors.New("release not found")
+
+	// ErrSameVersion means the requested version is the one already running.
+	ErrSameVersion = errors.New("already running this version")
+
+	// ErrDowngradeRefused means the requested version is older than the
+	// running one and the caller did not explicitly allow a downgrade.
+	ErrDowngradeRefused = errors.New("refusing to downgrade without allow_downgrade")
+
+	// ErrUnknownChannel means an update channel other than stable or
+	// prerelease was requested.
+	ErrUnknownChannel = errors.New("unknown update channel")
+
+	// ErrNoBackup means there is no previous binary to roll back to.
+	ErrNoBackup = errors.New("no backup binary found")
+
+	// ErrSignatureInvalid means a public key is configured and the release's
+	// checksums.txt signature is missing or does not verify.
+	ErrSignatureInvalid = errors.New("checksums signature verification failed")
 )
+
+// RateLimitError is returned when GitHub rejects a request for exceeding a
+// rate limit. It wraps ErrRateLimited; ResetAt is when requests will be
+// accepted again, or zero if GitHub didn't say.
+type RateLimitError struct {
+	ResetAt time.Time
+}
+
+func (e *RateLimitError) Error() string {
+	if e.ResetAt.IsZero() {
+		return ErrRateLimited.Error()
+	}
+	return ErrRateLimited.Error() + ", resets at " + e.ResetAt.UTC().Format(time.RFC3339)
+}
+
+func (e *RateLimitError) Unwrap() error { return ErrRateLimited }
@@ -0,0 +1,86 @@
+package update
+
+import (
+	"context"
+	"io"
+	"sync/atomic"
+	"time"
+)
+
+// progressInterval throttles progress callbacks during a download.
+const progressInterval = 250 * time.Millisecond
+
+// Progress reports how much of the update binary has been downloaded.
+type Progress struct {
+	Downloaded int64 `json:"downloaded_bytes"`
+	Total      int64 `json:"total_bytes"` // 0 if unknown
+}
+
+// ProgressFunc receives download progress. It is called from the
+// downloading goroutine and should return quickly.
+type ProgressFunc func(Progress)
+
+type progressKey struct{}
+
+// WithProgress returns a context that makes Apply and ApplyVersion report
+// binary download progress to fn.
+func WithProgress(ctx context.Context, fn ProgressFunc) context.Context {
+	return context.WithValue(ctx, progressKey{}, fn)
+}
+
+func progressFromContext(ctx context.Context) ProgressFunc {
+	fn, _ := ctx.Value(progressKey{}).(ProgressFunc)
+	return fn
+}
+
+// progressReader counts bytes read from a download body, reports progress,
+// and calls abort when no data arrives within idle.
+type progressReader struct {
+	r        io.Reader
+	total    int64
+	read     int64
+	notify   ProgressFunc
+	last     time.Time
+	idle     time.Duration
+	watchdog *time.Timer
+	expired  atomic.Bool
+}
+
+func newProgressReader(r io.Reader, total int64, notify ProgressFunc, idle time.Duration, abort func()) *progressReader {
+	p := &progressReader{r: r, total: total, notify: notify, idle: idle}
+	p.watchdog = time.AfterFunc(idle, func() {
+		p.expired.Store(true)
+		abort()
+	})
+	return p
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	if n > 0 {
+		p.watchdog.Reset(p.idle)
+		p.read += int64(n)
+		if p.notify != nil && time.Since(p.last) >= progressInterval {
+			p.last = time.Now()
+			p.notify(Progress{Downloaded: p.read, Total: p.total})
+		}
+	}
+	return n, err
+}
+
+// finish sends a final progress report once the body is fully read.
+func (p *progressReader) finish() {
+	if p.notify != nil {
+		p.notify(Progress{Downloaded: p.read, Total: p.total})
+	}
+}
+
+// stop disarms the idle watchdog.
+func (p *progressReader) stop() {
+	p.watchdog.Stop()
+}
+
+// timedOut reports whether the download was aborted for being idle.
+func (p *progressReader) timedOut() bool {
+	return p.expired.Load()
+}
@@ -0,0 +1,58 @@
+package update
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestProgressReaderReportsBytes(t *testing.T) {
+	var reports []Progress
+	data := bytes.Repeat([]byte("x"), 10_000)
+	p := newProgressReader(bytes.NewReader(data), int64(len(data)), func(pr Progress) {
+		reports = append(reports, pr)
+	}, time.Minute, func() {})
+	defer p.stop()
+
+	if _, err := io.Copy(io.Discard, p); err != nil {
+		t.Fatal(err)
+	}
+	p.finish()
+
+	if len(reports) == 0 {
+		t.Fatal("expected progress reports")
+	}
+	last := reports[len(reports)-1]
+	if last.Downloaded != int64(len(data)) || last.Total != int64(len(data)) {
+		t.Errorf("final progress = %+v, want %d/%d", last, len(data), len(data))
+	}
+}
+
+func TestProgressReaderAbortsWhenIdle(t *testing.T) {
+	pr, pw := io.Pipe()
+	defer pw.Close()
+
+	aborted := make(chan struct{})
+	p := newProgressReader(pr, 0, nil, 50*time.Millisecond, func() {
+		pr.CloseWithError(io.ErrUnexpectedEOF)
+		close(aborted)
+	})
+	defer p.stop()
+
+	go pw.Write([]byte("first chunk"))
+
+	buf := make([]byte, 64)
+	if _, err := p.Read(buf); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-aborted:
+	case <-time.After(2 * time.Second):
+		t.Fatal("idle download was never aborted")
+	}
+	if !p.timedOut() {
+		t.Error("timedOut should report the idle abort")
+	}
+}
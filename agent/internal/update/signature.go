@@ -0,0 +1,86 @@
+package update
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// signatureAsset is the release asset holding the minisign signature of
+// checksums.txt.
+const signatureAsset = "checksums.txt.minisig"
+
+// publicKey is a minisign Ed25519 public key.
+type publicKey struct {
+	id  [8]byte
+	key ed25519.PublicKey
+}
+
+// parsePublicKey accepts a minisign public key, either the bare base64 line
+// or the whole .pub file including its untrusted comment.
+func parsePublicKey(s string) (*publicKey, error) {
+	var line string
+	for l := range strings.Lines(s) {
+		l = strings.TrimSpace(l)
+		if l != "" && !strings.HasPrefix(l, "untrusted comment:") {
+			line = l
+			break
+		}
+	}
+	raw, err := base64.StdEncoding.DecodeString(line)
+	if err != nil || len(raw) != 2+8+ed25519.PublicKeySize || string(raw[:2]) != "Ed" {
+		return nil, fmt.Errorf("not a minisign Ed25519 public key")
+	}
+	pk := &publicKey{key: ed25519.PublicKey(raw[10:])}
+	copy(pk.id[:], raw[2:10])
+	return pk, nil
+}
+
+// verifyMinisign checks a minisign signature file over message: the
+// signature itself and the global signature binding its trusted comment.
+// Only legacy (non-prehashed, "Ed") signatures are supported, as produced
+// by `minisign -S -l`; prehashed ones need BLAKE2b, which the standard
+// library lacks.
+func verifyMinisign(pk *publicKey, message, sigFile []byte) error {
+	lines := strings.Split(strings.TrimRight(string(sigFile), "\r\n"), "\n")
+	if len(lines) < 4 {
+		return fmt.Errorf("%w: malformed signature file", ErrSignatureInvalid)
+	}
+	for i := range lines {
+		lines[i] = strings.TrimRight(lines[i], "\r")
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(lines[1])
+	if err != nil || len(sig) != 2+8+ed25519.SignatureSize {
+		return fmt.Errorf("%w: malformed signature", ErrSignatureInvalid)
+	}
+	switch string(sig[:2]) {
+	case "Ed":
+	case "ED":
+		return fmt.Errorf("%w: prehashed signatures are not supported, sign with minisign -l", ErrSignatureInvalid)
+	default:
+		return fmt.Errorf("%w: unknown signature algorithm", ErrSignatureInvalid)
+	}
+	if !bytes.Equal(sig[2:10], pk.id[:]) {
+		return fmt.Errorf("%w: signed with a different key", ErrSignatureInvalid)
+	}
+	if !ed25519.Verify(pk.key, message, sig[10:]) {
+		return fmt.Errorf("%w: signature does not match checksums.txt", ErrSignatureInvalid)
+	}
+
+	trusted, ok := strings.CutPrefix(lines[2], "trusted comment: ")
+	if !ok {
+		return fmt.Errorf("%w: missing trusted comment", ErrSignatureInvalid)
+	}
+	global, err := base64.StdEncoding.DecodeString(lines[3])
+	if err != nil || len(global) != ed25519.SignatureSize {
+		return fmt.Errorf("%w: malformed global signature", ErrSignatureInvalid)
+	}
+	if !ed25519.Verify(pk.key, slices.Concat(sig[10:], []byte(trusted)), global) {
+		return fmt.Errorf("%w: trusted comment was tampered with", ErrSignatureInvalid)
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package update
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// testMinisign returns a minisign public key line for a fresh key and a
+// function that signs a message with it, trusted comment included.
+func testMinisign(t *testing.T) (string, func(msg []byte, trusted string) []byte) {
+	t.Helper()
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := []byte{1, 2, 3, 4, 5, 6, 7, 8}
+	key := base64.StdEncoding.EncodeToString(slices.Concat([]byte("Ed"), id, pub))
+	sign := func(msg []byte, trusted string) []byte {
+		sig := ed25519.Sign(priv, msg)
+		global := ed25519.Sign(priv, slices.Concat(sig, []byte(trusted)))
+		return []byte("untrusted comment: signature from minisign secret key\n" +
+			base64.StdEncoding.EncodeToString(slices.Concat([]byte("Ed"), id, sig)) + "\n" +
+			"trusted comment: " + trusted + "\n" +
+			base64.StdEncoding.EncodeToString(global) + "\n")
+	}
+	return key, sign
+}
+
+func TestVerifyMinisign(t *testing.T) {
+	key, sign := testMinisign(t)
+	pk, err := parsePublicKey("untrusted comment: minisign public key 0102\n" + key + "\n")
+	if err != nil {
+		t.Fatalf("parsePublicKey: %v", err)
+	}
+	msg := []byte("abc123  hola-agent-linux-amd64\n")
+	sig := sign(msg, "timestamp:1700000000\tfile:checksums.txt")
+
+	if err := verifyMinisign(pk, msg, sig); err != nil {
+		t.Fatalf("valid signature rejected: %v", err)
+	}
+
+	otherKey, _ := testMinisign(t)
+	other, _ := parsePublicKey(otherKey)
+	other.id = [8]byte{9, 9, 9, 9, 9, 9, 9, 9}
+
+	tamperedComment := strings.Replace(string(sig), "file:checksums.txt", "file:evil.txt", 1)
+	prehashed := strings.Split(string(sig), "\n")
+	raw, _ := base64.StdEncoding.DecodeString(prehashed[1])
+	raw[1] = 'D'
+	prehashed[1] = base64.StdEncoding.EncodeToString(raw)
+
+	tests := []struct {
+		name string
+		pk   *publicKey
+		msg  []byte
+		sig  []byte
+	}{
+		{"tampered message", pk, []byte("evil  hola-agent-linux-amd64\n"), sig},
+		{"different key", other, msg, sig},
+		{"tampered trusted comment", pk, msg, []byte(tamperedComment)},
+		{"prehashed", pk, msg, []byte(strings.Join(prehashed, "\n"))},
+		{"truncated", pk, msg, sig[:40]},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := verifyMinisign(tt.pk, tt.msg, tt.sig)
+			if !errors.Is(err, ErrSignatureInvalid) {
+				t.Errorf("expected ErrSignatureInvalid, got %v", err)
+			}
+		})
+	}
+}
+
+func TestParsePublicKey_Invalid(t *testing.T) {
+	for _, s := range []string{"", "not base64!", base64.StdEncoding.EncodeToString([]byte("Ed short"))} {
+		if _, err := parsePublicKey(s); err == nil {
+			t.Errorf("parsePublicKey(%q) accepted an invalid key", s)
+		}
+	}
+}
@@ -5,23 +5,46 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"log/slog"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
 	"runtime"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
 const githubAPI = "https://api.github.com"
 
+const (
+	// requestTimeout bounds small GitHub API and checksums requests.
+	requestTimeout = 30 * time.Second
+
+	// idleTimeout aborts a binary download that receives no data for this
+	// long. Downloads have no overall deadline so slow links can finish.
+	idleTimeout = 30 * time.Second
+)
+
+// Update channels select which releases CheckLatest and Apply consider.
+const (
+	ChannelStable     = "stable"     // published releases only
+	ChannelPrerelease = "prerelease" // pre-releases as well
+)
+
 // Updater checks for and applies agent updates from GitHub Releases.
 type Updater struct {
 	currentVersion string
 	repo           string
+	token          string
+	channel        string
+	publicKey      *publicKey // when set, checksums.txt must carry a valid signature
 	httpClient     *http.Client
 }
 
@@ -30,14 +53,98 @@ func New(currentVersion, repo string) *Updater {
 	return &Updater{
 		currentVersion: currentVersion,
 		repo:           repo,
-		httpClient:     &http.Client{Timeout: 30 * time.Second},
+		channel:        ChannelStable,
+		httpClient:     &http.Client{Transport: newTransport()},
+	}
+}
+
+// ValidateRepo checks that repo is a GitHub "owner/name" pair.
+func ValidateRepo(repo string) error {
+	owner, name, ok := strings.Cut(repo, "/")
+	if !ok || !validRepoPart(owner) || !validRepoPart(name) {
+		return fmt.Errorf("invalid repository %q (want owner/name)", repo)
+	}
+	return nil
+}
+
+// validRepoPart reports whether s can be a GitHub owner or repository name.
+func validRepoPart(s string) bool {
+	if s == "" || s == "." || s == ".." {
+		return false
+	}
+	for _, r := range s {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' || r == '.') {
+			return false
+		}
+	}
+	return true
+}
+
+// newTransport returns an HTTP transport that bounds connection setup and
+// the wait for response headers without limiting how long a body may take.
+func newTransport() *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.ResponseHeaderTimeout = requestTimeout
+	return t
+}
+
+// SetToken configures a GitHub token sent with release and download
+// requests, raising the API rate limit from 60 to 5000 requests per hour.
+// An empty token keeps requests unauthenticated.
+func (u *Updater) SetToken(token string) {
+	u.token = token
+}
+
+// SetTransport sends release lookups and downloads through rt instead of
+// the default transport, for example to reach GitHub through a mirror.
+func (u *Updater) SetTransport(rt http.RoundTripper) {
+	u.httpClient.Transport = rt
+}
+
+// SetPublicKey requires every update's checksums.txt to be signed with the
+// given minisign public key, so a compromised release host can't swap in a
+// binary together with matching checksums. An empty key disables the
+// check.
+func (u *Updater) SetPublicKey(key string) error {
+	if key == "" {
+		u.publicKey = nil
+		return nil
+	}
+	pk, err := parsePublicKey(key)
+	if err != nil {
+		return err
+	}
+	u.publicKey = pk
+	return nil
+}
+
+// SetChannel selects the update channel, ChannelStable or
+// ChannelPrerelease. Any other value returns ErrUnknownChannel.
+func (u *Updater) SetChannel(channel string) error {
+	switch channel {
+	case ChannelStable, ChannelPrerelease:
+		u.channel = channel
+		return nil
+	}
+	return fmt.Errorf("%w: %q (want %s or %s)", ErrUnknownChannel, channel, ChannelStable, ChannelPrerelease)
+}
+
+// setHeaders applies the headers common to every GitHub request.
+func (u *Updater) setHeaders(req *http.Request) {
+	req.Header.Set("User-Agent", "hola-agent/"+u.currentVersion)
+	if u.token != "" {
+		// net/http drops this header when a download redirects to another
+		// host, so the token never reaches the asset CDN.
+		req.Header.Set("Authorization", "Bearer "+u.token)
 	}
 }
 
 // releaseInfo holds information about the latest GitHub release.
 type releaseInfo struct {
-	TagName string  `json:"tag_name"`
-	Assets  []asset `json:"assets"`
+	TagName    string  `json:"tag_name"`
+	Draft      bool    `json:"draft"`
+	Prerelease bool    `json:"prerelease"`
+	Assets     []asset `json:"assets"`
 }
 
 // asset represents a single file attached to a GitHub release.
@@ -109,15 +216,58 @@ func (u *Updater) Apply(ctx context.Context) error {
 		return ErrAlreadyLatest
 	}
 
+	return u.install(ctx, rel)
+}
+
+// ApplyVersion installs the release with the given tag (e.g. "v0.4.1")
+// through the same checksum-verified path as Apply. Installing an older
+// version than the running one returns ErrDowngradeRefused unless
+// allowDowngrade is set.
+func (u *Updater) ApplyVersion(ctx context.Context, tag string, allowDowngrade bool) error {
+	rel, err := u.fetchReleaseByTag(ctx, tag)
+	if err != nil {
+		return err
+	}
+
+	cmp, err := compareVersions(u.currentVersion, stripVPrefix(rel.TagName))
+	if err != nil {
+		return fmt.Errorf("comparing versions: %w", err)
+	}
+	switch {
+	case cmp == 0:
+		return ErrSameVersion
+	case cmp > 0 && !allowDowngrade:
+		return ErrDowngradeRefused
+	}
+
+	return u.install(ctx, rel)
+}
+
+// binaryMu serializes replacing the running binary and its backup. It is
+// package-level because the auto-updater and manual updates run on
+// separate Updaters.
+var binaryMu sync.Mutex
+
+// install downloads rel's binary for this platform, verifies it against
+// the release's checksums.txt and swaps it in for the running binary.
+func (u *Updater) install(ctx context.Context, rel *releaseInfo) (err error) {
+	binaryMu.Lock()
+	defer binaryMu.Unlock()
+
+	version := stripVPrefix(rel.TagName)
 	name := assetName()
 	var binaryURL string
-	var checksumsURL string
+	var binarySize int64
+	var checksumsURL, signatureURL string
 	for _, a := range rel.Assets {
 		switch a.Name {
 		case name:
 			binaryURL = a.BrowserDownloadURL
+			binarySize = int64(a.Size)
 		case "checksums.txt":
 			checksumsURL = a.BrowserDownloadURL
+		case signatureAsset:
+			signatureURL = a.BrowserDownloadURL
 		}
 	}
 	if binaryURL == "" {
@@ -128,18 +278,35 @@ func (u *Updater) Apply(ctx context.Context) error {
 	}
 
 	slog.Info("downloading checksums", "url", checksumsURL)
-	checksums, err := u.downloadChecksums(ctx, checksumsURL)
+	checksumsText, err := u.downloadSmall(ctx, checksumsURL)
 	if err != nil {
 		return fmt.Errorf("downloading checksums: %w", err)
 	}
 
+	// The signature is the first layer: it proves checksums.txt came from
+	// the key holder. The checksum below then ties the binary to it.
+	if u.publicKey != nil {
+		if signatureURL == "" {
+			return fmt.Errorf("%w: release has no %s", ErrSignatureInvalid, signatureAsset)
+		}
+		sig, err := u.downloadSmall(ctx, signatureURL)
+		if err != nil {
+			return fmt.Errorf("downloading signature: %w", err)
+		}
+		if err := verifyMinisign(u.publicKey, checksumsText, sig); err != nil {
+			return err
+		}
+		slog.Info("checksums signature verified")
+	}
+	checksums := parseChecksums(string(checksumsText))
+
 	expectedHash, ok := checksums[name]
 	if !ok {
 		return fmt.Errorf("%w: no entry for %s in checksums.txt", ErrChecksumMismatch, name)
 	}
 
-	slog.Info("downloading binary", "asset", name, "version", latestVersion)
-	tmpPath, err := u.downloadAsset(ctx, binaryURL)
+	slog.Info("downloading binary", "asset", name, "version", version)
+	tmpPath, err := u.downloadAsset(ctx, binaryURL, binarySize)
 	if err != nil {
 		return fmt.Errorf("downloading binary: %w", err)
 	}
@@ -154,28 +321,88 @@ func (u *Updater) Apply(ctx context.Context) error {
 		return err
 	}
 
-	slog.Info("replacing binary", "version", latestVersion)
+	slog.Info("replacing binary", "version", version)
 	if err = replaceBinary(tmpPath); err != nil {
 		return fmt.Errorf("replacing binary: %w", err)
 	}
 
-	slog.Info("agent updated successfully", "from", u.currentVersion, "to", latestVersion)
+	slog.Info("agent updated successfully", "from", u.currentVersion, "to", version)
 	return nil
 }
 
-// fetchLatestRelease calls the GitHub API for the latest release.
+// fetchLatestRelease calls the GitHub API for the newest release on the
+// configured channel.
 func (u *Updater) fetchLatestRelease(ctx context.Context) (*releaseInfo, error) {
-	url := fmt.Sprintf("%s/repos/%s/releases/latest", githubAPI, u.repo)
+	if u.channel == ChannelPrerelease {
+		return u.fetchNewestRelease(ctx)
+	}
+	var rel releaseInfo
+	if err := u.getReleases(ctx, "/latest", ErrNoReleases, &rel); err != nil {
+		return nil, err
+	}
+	return &rel, nil
+}
+
+// fetchReleaseByTag calls the GitHub API for the release with the given tag.
+func (u *Updater) fetchReleaseByTag(ctx context.Context, tag string) (*releaseInfo, error) {
+	var rel releaseInfo
+	notFound := fmt.Errorf("%w: %s", ErrReleaseNotFound, tag)
+	if err := u.getReleases(ctx, "/tags/"+url.PathEscape(tag), notFound, &rel); err != nil {
+		return nil, err
+	}
+	return &rel, nil
+}
+
+// fetchNewestRelease lists recent releases, pre-releases included, and
+// returns the one with the highest version. GitHub's /releases/latest
+// skips pre-releases, so it can't serve the prerelease channel.
+func (u *Updater) fetchNewestRelease(ctx context.Context) (*releaseInfo, error) {
+	var rels []releaseInfo
+	if err := u.getReleases(ctx, "?per_page=100", ErrNoReleases, &rels); err != nil {
+		return nil, err
+	}
+
+	var newest *releaseInfo
+	for i := range rels {
+		rel := &rels[i]
+		if rel.Draft {
+			continue
+		}
+		if _, err := parseVersion(stripVPrefix(rel.TagName)); err != nil {
+			slog.Debug("skipping release with unparseable tag", "tag", rel.TagName)
+			continue
+		}
+		if newest == nil {
+			newest = rel
+			continue
+		}
+		if cmp, _ := compareVersions(stripVPrefix(rel.TagName), stripVPrefix(newest.TagName)); cmp > 0 {
+			newest = rel
+		}
+	}
+	if newest == nil {
+		return nil, ErrNoReleases
+	}
+	return newest, nil
+}
+
+// getReleases calls the GitHub releases API at the given suffix and decodes
+// the response into v, returning notFound when GitHub answers 404.
+func (u *Updater) getReleases(ctx context.Context, suffix string, notFound error, v any) error {
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	url := fmt.Sprintf("%s/repos/%s/releases%s", githubAPI, u.repo, suffix)
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
-		return nil, fmt.Errorf("creating request: %w", err)
+		return fmt.Errorf("creating request: %w", err)
 	}
 	req.Header.Set("Accept", "application/vnd.github+json")
-	req.Header.Set("User-Agent", "hola-agent/"+u.currentVersion)
+	u.setHeaders(req)
 
 	resp, err := u.httpClient.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("fetching latest release: %w", err)
+		return fmt.Errorf("fetching release: %w", err)
 	}
 	defer resp.Body.Close()
 
@@ -183,38 +410,56 @@ func (u *Updater) fetchLatestRelease(ctx context.Context) (*releaseInfo, error)
 	case http.StatusOK:
 		// continue below
 	case http.StatusNotFound:
-		return nil, ErrNoReleases
-	case http.StatusForbidden:
-		if resp.Header.Get("X-RateLimit-Remaining") == "0" {
-			return nil, ErrRateLimited
+		return notFound
+	case http.StatusForbidden, http.StatusTooManyRequests:
+		if resp.Header.Get("X-RateLimit-Remaining") == "0" || resp.Header.Get("Retry-After") != "" {
+			return &RateLimitError{ResetAt: rateLimitReset(resp.Header, time.Now())}
 		}
-		return nil, fmt.Errorf("GitHub API returned 403")
+		return fmt.Errorf("GitHub API returned %d", resp.StatusCode)
 	default:
-		return nil, fmt.Errorf("GitHub API returned %d", resp.StatusCode)
+		return fmt.Errorf("GitHub API returned %d", resp.StatusCode)
 	}
 
-	var rel releaseInfo
-	if err := json.NewDecoder(resp.Body).Decode(&rel); err != nil {
-		return nil, fmt.Errorf("decoding release: %w", err)
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("decoding release: %w", err)
 	}
-	return &rel, nil
+	return nil
+}
+
+// rateLimitReset reads when a rate limit lifts: Retry-After (seconds, sent
+// for secondary rate limits) wins over X-RateLimit-Reset (Unix seconds, the
+// primary limit's window). It returns zero when neither header is usable.
+func rateLimitReset(h http.Header, now time.Time) time.Time {
+	if secs, err := strconv.Atoi(h.Get("Retry-After")); err == nil && secs >= 0 {
+		return now.Add(time.Duration(secs) * time.Second).Truncate(time.Second)
+	}
+	if unix, err := strconv.ParseInt(h.Get("X-RateLimit-Reset"), 10, 64); err == nil && unix > 0 {
+		return time.Unix(unix, 0)
+	}
+	return time.Time{}
 }
 
 // downloadAsset downloads a URL to a temp file. It first tries the binary's
 // directory (ideal for same-filesystem rename), then falls back to os.TempDir()
 // if the binary directory is not writable (e.g. /usr/local/bin owned by root).
-func (u *Updater) downloadAsset(ctx context.Context, url string) (string, error) {
+// size is the expected length from the release metadata, used for progress
+// reporting when the server sends no Content-Length.
+func (u *Updater) downloadAsset(ctx context.Context, url string, size int64) (string, error) {
 	execPath, err := executablePath()
 	if err != nil {
 		return "", err
 	}
 	dir := filepath.Dir(execPath)
 
+	// Cancelled by the idle watchdog below when the body stalls.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return "", fmt.Errorf("creating request: %w", err)
 	}
-	req.Header.Set("User-Agent", "hola-agent/"+u.currentVersion)
+	u.setHeaders(req)
 
 	resp, err := u.httpClient.Do(req)
 	if err != nil {
@@ -237,38 +482,51 @@ func (u *Updater) downloadAsset(ctx context.Context, url string) (string, error)
 	}
 	defer tmp.Close()
 
-	if _, err := io.Copy(tmp, resp.Body); err != nil {
+	if resp.ContentLength > 0 {
+		size = resp.ContentLength
+	}
+	body := newProgressReader(resp.Body, size, progressFromContext(ctx), idleTimeout, cancel)
+	defer body.stop()
+
+	if _, err := io.Copy(tmp, body); err != nil {
 		os.Remove(tmp.Name())
+		if body.timedOut() {
+			return "", fmt.Errorf("writing download: no data received for %s", idleTimeout)
+		}
 		return "", fmt.Errorf("writing download: %w", err)
 	}
+	body.finish()
 
 	return tmp.Name(), nil
 }
 
-// downloadChecksums fetches checksums.txt and parses it into a map[filename]hash.
-func (u *Updater) downloadChecksums(ctx context.Context, url string) (map[string]string, error) {
+// downloadSmall fetches a small release asset such as checksums.txt or its
+// signature into memory.
+func (u *Updater) downloadSmall(ctx context.Context, url string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return nil, fmt.Errorf("creating request: %w", err)
 	}
-	req.Header.Set("User-Agent", "hola-agent/"+u.currentVersion)
+	u.setHeaders(req)
 
 	resp, err := u.httpClient.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("downloading checksums: %w", err)
+		return nil, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("checksums download returned %d", resp.StatusCode)
+		return nil, fmt.Errorf("download returned %d", resp.StatusCode)
 	}
 
-	body, err := io.ReadAll(resp.Body)
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
 	if err != nil {
-		return nil, fmt.Errorf("reading checksums: %w", err)
+		return nil, fmt.Errorf("reading response: %w", err)
 	}
-
-	return parseChecksums(string(body)), nil
+	return body, nil
 }
 
 // parseChecksums parses sha256sum-format text into a map[filename]hash.
@@ -341,6 +599,76 @@ func replaceBinary(newBinaryPath string) error {
 	return nil
 }
 
+// Rollback restores the binary that the last update backed up. The
+// current binary takes its place as the new backup, so a second rollback
+// undoes the first. Returns ErrNoBackup if there is nothing to restore.
+func (u *Updater) Rollback(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	binaryMu.Lock()
+	defer binaryMu.Unlock()
+
+	execPath, err := executablePath()
+	if err != nil {
+		return err
+	}
+
+	slog.Info("rolling back binary", "current", u.currentVersion)
+	if err := swapWithBackup(execPath); err != nil {
+		return fmt.Errorf("rolling back binary: %w", err)
+	}
+
+	slog.Info("agent rolled back successfully", "from", u.currentVersion)
+	return nil
+}
+
+// RollbackAvailable reports whether a backup binary exists to roll back to.
+func (u *Updater) RollbackAvailable() bool {
+	execPath, err := executablePath()
+	if err != nil {
+		return false
+	}
+	info, err := os.Stat(execPath + ".bak")
+	return err == nil && info.Mode().IsRegular()
+}
+
+// swapWithBackup exchanges execPath and execPath+".bak". The restored
+// binary is given the current binary's permissions.
+func swapWithBackup(execPath string) error {
+	backup := execPath + ".bak"
+	if _, err := os.Stat(backup); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return ErrNoBackup
+		}
+		return fmt.Errorf("stat backup binary: %w", err)
+	}
+
+	info, err := os.Stat(execPath)
+	if err != nil {
+		return fmt.Errorf("stat current binary: %w", err)
+	}
+	if err := os.Chmod(backup, info.Mode()); err != nil {
+		return fmt.Errorf("chmod backup binary: %w", err)
+	}
+
+	tmp := execPath + ".rollback"
+	if err := os.Rename(execPath, tmp); err != nil {
+		return fmt.Errorf("move current binary aside: %w", err)
+	}
+	if err := os.Rename(backup, execPath); err != nil {
+		if rbErr := os.Rename(tmp, execPath); rbErr != nil {
+			slog.Error("restoring current binary failed", "error", rbErr)
+		}
+		return fmt.Errorf("restore backup binary: %w", err)
+	}
+	if err := os.Rename(tmp, backup); err != nil {
+		// The rollback itself succeeded; only the new backup is missing.
+		slog.Warn("keeping previous binary as backup failed", "error", err)
+	}
+	return nil
+}
+
 // executablePath resolves the real path to the running binary.
 func executablePath() (string, error) {
 	exe, err := os.Executable()
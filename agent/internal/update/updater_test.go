@@ -12,7 +12,9 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"strconv"
 	"testing"
+	"time"
 )
 
 // newTestServer creates a mock GitHub API server returning the given release.
@@ -161,6 +163,48 @@ func TestCheckLatest_RateLimited(t *testing.T) {
 	}
 }
 
+func TestCheckLatest_RateLimitReset(t *testing.T) {
+	reset := time.Now().Add(10 * time.Minute).Truncate(time.Second)
+	apiSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(reset.Unix(), 10))
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer apiSrv.Close()
+
+	u := New("0.2.0", "test/repo")
+	u.httpClient = &http.Client{Transport: redirectTransport(apiSrv)}
+
+	_, err := u.CheckLatest(context.Background())
+	var rle *RateLimitError
+	if !errors.As(err, &rle) || !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("expected a RateLimitError wrapping ErrRateLimited, got: %v", err)
+	}
+	if !rle.ResetAt.Equal(reset) {
+		t.Errorf("ResetAt = %v, want %v", rle.ResetAt, reset)
+	}
+}
+
+func TestRateLimitReset(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	tests := []struct {
+		name   string
+		header http.Header
+		want   time.Time
+	}{
+		{"retry-after", http.Header{"Retry-After": {"60"}}, now.Add(time.Minute)},
+		{"reset", http.Header{"X-Ratelimit-Reset": {"1700000300"}}, time.Unix(1_700_000_300, 0)},
+		{"retry-after wins", http.Header{"Retry-After": {"5"}, "X-Ratelimit-Reset": {"1700000300"}}, now.Add(5 * time.Second)},
+		{"neither", http.Header{}, time.Time{}},
+		{"garbage", http.Header{"Retry-After": {"soon"}}, time.Time{}},
+	}
+	for _, tt := range tests {
+		if got := rateLimitReset(tt.header, now); !got.Equal(tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
 func TestCheckLatest_PlatformNotAvailable(t *testing.T) {
 	// Release with a different platform's binary only.
 	rel := &releaseInfo{
@@ -184,6 +228,137 @@ func TestCheckLatest_PlatformNotAvailable(t *testing.T) {
 	}
 }
 
+func TestApplyVersion_FetchesTag(t *testing.T) {
+	var gotPath string
+	apiSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(testRelease("v0.2.0"))
+	}))
+	defer apiSrv.Close()
+
+	u := New("0.2.0", "test/repo")
+	u.httpClient = &http.Client{Transport: redirectTransport(apiSrv)}
+
+	err := u.ApplyVersion(context.Background(), "v0.2.0", false)
+	if !errors.Is(err, ErrSameVersion) {
+		t.Fatalf("expected ErrSameVersion, got %v", err)
+	}
+	if gotPath != "/repos/test/repo/releases/tags/v0.2.0" {
+		t.Errorf("unexpected request path %q", gotPath)
+	}
+}
+
+func TestApplyVersion_DowngradeRefused(t *testing.T) {
+	apiSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(testRelease("v0.1.0"))
+	}))
+	defer apiSrv.Close()
+
+	u := New("0.2.0", "test/repo")
+	u.httpClient = &http.Client{Transport: redirectTransport(apiSrv)}
+
+	err := u.ApplyVersion(context.Background(), "v0.1.0", false)
+	if !errors.Is(err, ErrDowngradeRefused) {
+		t.Fatalf("expected ErrDowngradeRefused, got %v", err)
+	}
+}
+
+func TestApplyVersion_ReleaseNotFound(t *testing.T) {
+	apiSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer apiSrv.Close()
+
+	u := New("0.2.0", "test/repo")
+	u.httpClient = &http.Client{Transport: redirectTransport(apiSrv)}
+
+	err := u.ApplyVersion(context.Background(), "v9.9.9", false)
+	if !errors.Is(err, ErrReleaseNotFound) {
+		t.Fatalf("expected ErrReleaseNotFound, got %v", err)
+	}
+}
+
+func TestGitHubTokenSentWhenConfigured(t *testing.T) {
+	for _, token := range []string{"", "ghp_secret"} {
+		var gotAuth string
+		apiSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			gotAuth = r.Header.Get("Authorization")
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(testRelease("v0.2.0"))
+		}))
+
+		u := New("0.2.0", "test/repo")
+		u.SetToken(token)
+		u.httpClient = &http.Client{Transport: redirectTransport(apiSrv)}
+
+		if _, err := u.CheckLatest(context.Background()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		apiSrv.Close()
+
+		want := ""
+		if token != "" {
+			want = "Bearer " + token
+		}
+		if gotAuth != want {
+			t.Errorf("token %q: Authorization = %q, want %q", token, gotAuth, want)
+		}
+	}
+}
+
+func TestCheckLatest_PrereleaseChannel(t *testing.T) {
+	var gotPath string
+	apiSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		draft := testRelease("v0.5.0")
+		draft.Draft = true
+		rc := testRelease("v0.4.0-rc.2")
+		rc.Prerelease = true
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode([]*releaseInfo{testRelease("v0.3.0"), draft, rc, testRelease("nightly")})
+	}))
+	defer apiSrv.Close()
+
+	u := New("0.3.0", "test/repo")
+	if err := u.SetChannel(ChannelPrerelease); err != nil {
+		t.Fatal(err)
+	}
+	u.httpClient = &http.Client{Transport: redirectTransport(apiSrv)}
+
+	check, err := u.CheckLatest(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if gotPath != "/repos/test/repo/releases" {
+		t.Errorf("unexpected request path %q", gotPath)
+	}
+	if check.LatestVersion != "0.4.0-rc.2" || !check.UpdateAvailable {
+		t.Errorf("expected update to 0.4.0-rc.2, got %+v", check)
+	}
+}
+
+func TestSetChannel_Unknown(t *testing.T) {
+	u := New("0.3.0", "test/repo")
+	if err := u.SetChannel("nightly"); !errors.Is(err, ErrUnknownChannel) {
+		t.Fatalf("expected ErrUnknownChannel, got %v", err)
+	}
+}
+
+func TestValidateRepo(t *testing.T) {
+	for _, repo := range []string{"driversti/HoLA", "my-org/hola.fork", "a_b/c-d"} {
+		if err := ValidateRepo(repo); err != nil {
+			t.Errorf("ValidateRepo(%q) = %v, want nil", repo, err)
+		}
+	}
+	for _, repo := range []string{"", "HoLA", "owner/", "/name", "a/b/c", "../x", "own er/name", "https://github.com/a/b"} {
+		if err := ValidateRepo(repo); err == nil {
+			t.Errorf("ValidateRepo(%q) accepted an invalid repository", repo)
+		}
+	}
+}
+
 func TestParseChecksums(t *testing.T) {
 	input := "abc123  hola-agent-linux-amd64\ndef456  hola-agent-darwin-arm64\n"
 	m := parseChecksums(input)
@@ -280,6 +455,57 @@ func TestReplaceBinary(t *testing.T) {
 	}
 }
 
+func TestSwapWithBackup(t *testing.T) {
+	dir := t.TempDir()
+
+	currentPath := filepath.Join(dir, "hola-agent")
+	if err := os.WriteFile(currentPath, []byte("new-binary"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	backup := currentPath + ".bak"
+	if err := os.WriteFile(backup, []byte("old-binary"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := swapWithBackup(currentPath); err != nil {
+		t.Fatalf("swapWithBackup: %v", err)
+	}
+
+	content, _ := os.ReadFile(currentPath)
+	if string(content) != "old-binary" {
+		t.Errorf("expected old-binary content, got %q", string(content))
+	}
+	info, _ := os.Stat(currentPath)
+	if info.Mode().Perm() != 0o755 {
+		t.Errorf("permissions not preserved: got %v, want %v", info.Mode().Perm(), os.FileMode(0o755))
+	}
+
+	// The replaced binary becomes the backup, so a second swap undoes the first.
+	backupContent, _ := os.ReadFile(backup)
+	if string(backupContent) != "new-binary" {
+		t.Errorf("expected new-binary in backup, got %q", string(backupContent))
+	}
+	if _, err := os.Stat(currentPath + ".rollback"); !os.IsNotExist(err) {
+		t.Error("temporary rollback file should not remain")
+	}
+}
+
+func TestSwapWithBackup_NoBackup(t *testing.T) {
+	dir := t.TempDir()
+	currentPath := filepath.Join(dir, "hola-agent")
+	if err := os.WriteFile(currentPath, []byte("binary"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := swapWithBackup(currentPath); !errors.Is(err, ErrNoBackup) {
+		t.Fatalf("expected ErrNoBackup, got %v", err)
+	}
+	content, _ := os.ReadFile(currentPath)
+	if string(content) != "binary" {
+		t.Errorf("current binary should be untouched, got %q", string(content))
+	}
+}
+
 func TestAssetName(t *testing.T) {
 	expected := fmt.Sprintf("hola-agent-%s-%s", runtime.GOOS, runtime.GOARCH)
 	if got := assetName(); got != expected {
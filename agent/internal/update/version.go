@@ -1,32 +1,40 @@
 package update
 
 import (
+	"cmp"
 	"fmt"
 	"strconv"
 	"strings"
 )
 
-// compareVersions compares two semver strings (without "v" prefix).
+// CompareVersions compares two version strings, ignoring a leading "v".
 // Returns -1 if a < b, 0 if a == b, +1 if a > b.
+func CompareVersions(a, b string) (int, error) {
+	return compareVersions(stripVPrefix(a), stripVPrefix(b))
+}
+
+// compareVersions compares two semver strings (without "v" prefix).
+// Returns -1 if a < b, 0 if a == b, +1 if a > b. Pre-release versions sort
+// below the release they precede and build metadata is ignored.
 func compareVersions(a, b string) (int, error) {
-	aParts, err := parseVersion(a)
+	aVer, err := parseVersion(a)
 	if err != nil {
 		return 0, fmt.Errorf("invalid version %q: %w", a, err)
 	}
-	bParts, err := parseVersion(b)
+	bVer, err := parseVersion(b)
 	if err != nil {
 		return 0, fmt.Errorf("invalid version %q: %w", b, err)
 	}
 
 	// Compare segment by segment; missing segments treated as 0.
-	maxLen := max(len(aParts), len(bParts))
+	maxLen := max(len(aVer.core), len(bVer.core))
 	for i := range maxLen {
 		av, bv := 0, 0
-		if i < len(aParts) {
-			av = aParts[i]
+		if i < len(aVer.core) {
+			av = aVer.core[i]
 		}
-		if i < len(bParts) {
-			bv = bParts[i]
+		if i < len(bVer.core) {
+			bv = bVer.core[i]
 		}
 		if av < bv {
 			return -1, nil
@@ -35,24 +43,80 @@ func compareVersions(a, b string) (int, error) {
 			return 1, nil
 		}
 	}
-	return 0, nil
+	return comparePrerelease(aVer.pre, bVer.pre), nil
+}
+
+// version is a parsed semver string.
+type version struct {
+	core []int    // dot-separated numeric segments, e.g. 0.3.0
+	pre  []string // pre-release identifiers, e.g. ["rc", "1"]; nil for a release
 }
 
-// parseVersion splits a version string on "." and parses each segment as int.
-func parseVersion(v string) ([]int, error) {
-	parts := strings.Split(v, ".")
+// parseVersion splits a version string into its numeric core and
+// pre-release identifiers, discarding any "+build" metadata.
+func parseVersion(v string) (version, error) {
+	v, _, _ = strings.Cut(v, "+")
+	core, pre, hasPre := strings.Cut(v, "-")
+
+	parts := strings.Split(core, ".")
 	nums := make([]int, len(parts))
 	for i, p := range parts {
 		n, err := strconv.Atoi(p)
 		if err != nil {
-			return nil, fmt.Errorf("segment %q: %w", p, err)
+			return version{}, fmt.Errorf("segment %q: %w", p, err)
 		}
 		if n < 0 {
-			return nil, fmt.Errorf("segment %q: negative number", p)
+			return version{}, fmt.Errorf("segment %q: negative number", p)
 		}
 		nums[i] = n
 	}
-	return nums, nil
+
+	var ids []string
+	if hasPre {
+		ids = strings.Split(pre, ".")
+		for _, id := range ids {
+			if id == "" {
+				return version{}, fmt.Errorf("empty pre-release identifier in %q", pre)
+			}
+		}
+	}
+	return version{core: nums, pre: ids}, nil
+}
+
+// comparePrerelease orders pre-release identifier lists per semver: a
+// release (no identifiers) outranks any pre-release, numeric identifiers
+// compare numerically and sort below alphanumeric ones, and when one list
+// is a prefix of the other the shorter one is lower.
+func comparePrerelease(a, b []string) int {
+	switch {
+	case len(a) == 0 && len(b) == 0:
+		return 0
+	case len(a) == 0:
+		return 1
+	case len(b) == 0:
+		return -1
+	}
+
+	for i := range min(len(a), len(b)) {
+		if c := compareIdentifier(a[i], b[i]); c != 0 {
+			return c
+		}
+	}
+	return cmp.Compare(len(a), len(b))
+}
+
+func compareIdentifier(a, b string) int {
+	an, aErr := strconv.Atoi(a)
+	bn, bErr := strconv.Atoi(b)
+	switch {
+	case aErr == nil && bErr == nil:
+		return cmp.Compare(an, bn)
+	case aErr == nil:
+		return -1
+	case bErr == nil:
+		return 1
+	}
+	return strings.Compare(a, b)
 }
 
 // stripVPrefix removes a leading "v" or "V" from a version string.
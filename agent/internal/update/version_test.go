@@ -18,6 +18,17 @@ func TestCompareVersions(t *testing.T) {
 		{"missing patch treated as zero", "0.2", "0.2.0", 0},
 		{"three vs two segments", "1.0.0", "1.0", 0},
 		{"single segment", "1", "2", -1},
+		{"pre-release below release", "0.3.0-rc.1", "0.3.0", -1},
+		{"release above pre-release", "0.3.0", "0.3.0-rc.1", 1},
+		{"pre-release above older release", "0.3.0-rc.1", "0.2.9", 1},
+		{"pre-release numeric identifiers", "0.3.0-rc.2", "0.3.0-rc.10", -1},
+		{"pre-release alphanumeric order", "0.3.0-alpha", "0.3.0-beta", -1},
+		{"numeric identifier below alphanumeric", "0.3.0-1", "0.3.0-alpha", -1},
+		{"shorter pre-release set is lower", "0.3.0-alpha", "0.3.0-alpha.1", -1},
+		{"equal pre-releases", "0.3.0-rc.1", "0.3.0-rc.1", 0},
+		{"build metadata ignored", "0.3.0+build5", "0.3.0", 0},
+		{"build metadata differs", "0.3.0+build5", "0.3.0+build6", 0},
+		{"pre-release with build metadata", "0.3.0-rc.1+build5", "0.3.0-rc.1", 0},
 	}
 
 	for _, tt := range tests {
@@ -41,6 +52,8 @@ func TestCompareVersions_Invalid(t *testing.T) {
 		{"empty string", "", "0.2.0"},
 		{"non-numeric", "abc", "0.2.0"},
 		{"partial non-numeric", "0.2.x", "0.2.0"},
+		{"empty pre-release", "0.3.0-", "0.2.0"},
+		{"empty pre-release identifier", "0.3.0-rc..1", "0.2.0"},
 	}
 
 	for _, tt := range tests {
@@ -73,3 +86,13 @@ func TestStripVPrefix(t *testing.T) {
 		})
 	}
 }
+
+func TestCompareVersionsExported_StripsPrefix(t *testing.T) {
+	got, err := CompareVersions("v0.4.0", "0.5.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != -1 {
+		t.Errorf("CompareVersions(v0.4.0, 0.5.0) = %d, want -1", got)
+	}
+}
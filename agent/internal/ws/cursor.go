@@ -0,0 +1,88 @@
+package ws
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// errBadCursor is returned for log cursors the agent did not issue.
+var errBadCursor = errors.New("invalid log cursor")
+
+// logCursor is a position in a container's log stream: the timestamp of the
+// last delivered line and how many lines carrying exactly that timestamp had
+// been read up to and including it. Timestamps alone are ambiguous because a
+// bursty logger can write many lines within the same instant.
+type logCursor struct {
+	Timestamp time.Time
+	Seq       int
+}
+
+// String encodes the cursor as the opaque token sent to clients.
+func (c logCursor) String() string {
+	raw := c.Timestamp.UTC().Format(time.RFC3339Nano) + "|" + strconv.Itoa(c.Seq)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// since returns the cursor's timestamp in the Unix seconds.nanoseconds form
+// Docker accepts for the logs since parameter. Docker treats since as
+// inclusive, so lines sharing the timestamp are re-read and skipped by seq.
+func (c logCursor) since() string {
+	return fmt.Sprintf("%d.%09d", c.Timestamp.Unix(), c.Timestamp.Nanosecond())
+}
+
+// parseLogCursor decodes a token produced by logCursor.String.
+func parseLogCursor(s string) (logCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return logCursor{}, errBadCursor
+	}
+	ts, seq, ok := strings.Cut(string(raw), "|")
+	if !ok {
+		return logCursor{}, errBadCursor
+	}
+	t, err := time.Parse(time.RFC3339Nano, ts)
+	if err != nil {
+		return logCursor{}, errBadCursor
+	}
+	n, err := strconv.Atoi(seq)
+	if err != nil || n < 1 {
+		return logCursor{}, errBadCursor
+	}
+	return logCursor{Timestamp: t, Seq: n}, nil
+}
+
+// logTracker assigns a cursor to every line read from a log stream and,
+// when resuming, reports which lines the client has already seen.
+type logTracker struct {
+	pos    logCursor
+	resume *logCursor
+}
+
+// advance records a line with the given Docker timestamp and returns its
+// cursor, plus whether the line was already delivered before the resume
+// point. Lines whose timestamp cannot be parsed keep the previous position.
+func (t *logTracker) advance(timestamp string) (cur logCursor, seen bool) {
+	ts, err := time.Parse(time.RFC3339Nano, timestamp)
+	if err != nil {
+		return t.pos, false
+	}
+	if ts.Equal(t.pos.Timestamp) {
+		t.pos.Seq++
+	} else {
+		t.pos = logCursor{Timestamp: ts, Seq: 1}
+	}
+
+	if t.resume != nil {
+		r := t.resume
+		if t.pos.Timestamp.Before(r.Timestamp) ||
+			(t.pos.Timestamp.Equal(r.Timestamp) && t.pos.Seq <= r.Seq) {
+			return t.pos, true
+		}
+		t.resume = nil // Past the resume point; everything from here is new.
+	}
+	return t.pos, false
+}
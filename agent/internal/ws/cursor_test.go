@@ -0,0 +1,58 @@
+package ws
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLogCursorRoundTrip(t *testing.T) {
+	want := logCursor{Timestamp: time.Date(2024, 5, 1, 10, 0, 1, 123456789, time.UTC), Seq: 3}
+
+	got, err := parseLogCursor(want.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.Timestamp.Equal(want.Timestamp) || got.Seq != want.Seq {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+	if s := want.since(); s != "1714557601.123456789" {
+		t.Errorf("since = %q", s)
+	}
+}
+
+func TestParseLogCursorRejectsGarbage(t *testing.T) {
+	for _, s := range []string{"not base64!", "aGVsbG8", logCursor{Timestamp: time.Now()}.String()} {
+		if _, err := parseLogCursor(s); err == nil {
+			t.Errorf("parseLogCursor(%q) should fail", s)
+		}
+	}
+}
+
+func TestLogTrackerResumeSkipsSeenLines(t *testing.T) {
+	// Three lines share a timestamp; the client saw the first two.
+	stream := []string{
+		"2024-05-01T10:00:00.5Z",
+		"2024-05-01T10:00:01Z",
+		"2024-05-01T10:00:01Z",
+		"2024-05-01T10:00:01Z",
+		"2024-05-01T10:00:02Z",
+	}
+
+	var first logTracker
+	var cursor logCursor
+	for _, ts := range stream[:3] {
+		cursor, _ = first.advance(ts)
+	}
+
+	resumed := logTracker{resume: &cursor}
+	var delivered []int
+	for i, ts := range stream {
+		if _, seen := resumed.advance(ts); !seen {
+			delivered = append(delivered, i)
+		}
+	}
+
+	if len(delivered) != 2 || delivered[0] != 3 || delivered[1] != 4 {
+		t.Errorf("delivered lines %v, want [3 4]", delivered)
+	}
+}
@@ -0,0 +1,118 @@
+package ws
+
+import (
+	"encoding/json"
+	"math"
+)
+
+// deltaAbsThreshold and deltaRelThreshold define how much a numeric field
+// must move (relative to the value last sent) before it is included in a delta.
+// A change is reported only when it exceeds both bounds: the absolute one
+// filters jitter in small values such as percentages, the relative one in
+// large byte counters.
+const (
+	deltaAbsThreshold = 0.5   // e.g. half a percentage point
+	deltaRelThreshold = 0.005 // 0.5% of the previous value, for byte counters
+)
+
+// toJSONMap round-trips v through JSON so it can be diffed field by field.
+func toJSONMap(v any) (map[string]any, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var m map[string]any
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// diffMetrics returns a JSON merge patch (RFC 7386) that turns prev into next,
+// ignoring numeric changes below the delta thresholds. Nested objects are
+// diffed recursively, arrays are replaced wholesale when any element changed,
+// and fields missing from next are reported as null. The result is empty
+// when nothing changed meaningfully.
+func diffMetrics(prev, next map[string]any) map[string]any {
+	delta := make(map[string]any)
+	for key, nv := range next {
+		pv, ok := prev[key]
+		if !ok {
+			delta[key] = nv
+			continue
+		}
+		switch n := nv.(type) {
+		case map[string]any:
+			p, isMap := pv.(map[string]any)
+			if !isMap {
+				delta[key] = nv
+				continue
+			}
+			if sub := diffMetrics(p, n); len(sub) > 0 {
+				delta[key] = sub
+			}
+		default:
+			if valueChanged(pv, nv) {
+				delta[key] = nv
+			}
+		}
+	}
+	for key := range prev {
+		if _, ok := next[key]; !ok {
+			delta[key] = nil
+		}
+	}
+	return delta
+}
+
+// valueChanged reports whether two decoded JSON values differ meaningfully.
+func valueChanged(a, b any) bool {
+	switch bv := b.(type) {
+	case float64:
+		av, ok := a.(float64)
+		if !ok {
+			return true
+		}
+		diff := math.Abs(av - bv)
+		return diff > deltaAbsThreshold && diff > math.Abs(av)*deltaRelThreshold
+	case []any:
+		av, ok := a.([]any)
+		if !ok || len(av) != len(bv) {
+			return true
+		}
+		for i := range bv {
+			am, aIsMap := av[i].(map[string]any)
+			bm, bIsMap := bv[i].(map[string]any)
+			if aIsMap && bIsMap {
+				if len(diffMetrics(am, bm)) > 0 {
+					return true
+				}
+				continue
+			}
+			if valueChanged(av[i], bv[i]) {
+				return true
+			}
+		}
+		return false
+	default:
+		return a != b
+	}
+}
+
+// applyDelta merges a patch produced by diffMetrics into base, mirroring what
+// a client does on receipt. Null values delete the corresponding field.
+func applyDelta(base, delta map[string]any) {
+	for key, dv := range delta {
+		if dv == nil {
+			delete(base, key)
+			continue
+		}
+		if dm, ok := dv.(map[string]any); ok {
+			if bm, ok := base[key].(map[string]any); ok {
+				applyDelta(bm, dm)
+				continue
+			}
+		}
+		base[key] = dv
+	}
+}
@@ -0,0 +1,81 @@
+package ws
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func decodeMap(t *testing.T, s string) map[string]any {
+	t.Helper()
+	var m map[string]any
+	if err := json.Unmarshal([]byte(s), &m); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestDiffMetrics(t *testing.T) {
+	tests := []struct {
+		name string
+		prev string
+		next string
+		want string
+	}{
+		{
+			name: "no change",
+			prev: `{"cpu":{"usage_percent":10,"cores":4}}`,
+			next: `{"cpu":{"usage_percent":10,"cores":4}}`,
+			want: `{}`,
+		},
+		{
+			name: "change below threshold ignored",
+			prev: `{"cpu":{"usage_percent":10,"cores":4}}`,
+			next: `{"cpu":{"usage_percent":10.3,"cores":4}}`,
+			want: `{}`,
+		},
+		{
+			name: "nested change reported",
+			prev: `{"cpu":{"usage_percent":10,"cores":4},"hostname":"a"}`,
+			next: `{"cpu":{"usage_percent":25,"cores":4},"hostname":"a"}`,
+			want: `{"cpu":{"usage_percent":25}}`,
+		},
+		{
+			name: "byte counters use relative threshold",
+			prev: `{"memory":{"used_bytes":1000000}}`,
+			next: `{"memory":{"used_bytes":1001000}}`,
+			want: `{}`,
+		},
+		{
+			name: "removed field becomes null",
+			prev: `{"cpu":{"usage_percent":10,"temperature_celsius":50}}`,
+			next: `{"cpu":{"usage_percent":10}}`,
+			want: `{"cpu":{"temperature_celsius":null}}`,
+		},
+		{
+			name: "array replaced when an element changes",
+			prev: `{"disk":[{"mount_point":"/","usage_percent":40}]}`,
+			next: `{"disk":[{"mount_point":"/","usage_percent":60}]}`,
+			want: `{"disk":[{"mount_point":"/","usage_percent":60}]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := diffMetrics(decodeMap(t, tt.prev), decodeMap(t, tt.next))
+			if want := decodeMap(t, tt.want); !reflect.DeepEqual(got, want) {
+				t.Errorf("diffMetrics() = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestApplyDelta(t *testing.T) {
+	base := decodeMap(t, `{"cpu":{"usage_percent":10,"temperature_celsius":50},"hostname":"a"}`)
+	applyDelta(base, decodeMap(t, `{"cpu":{"usage_percent":25,"temperature_celsius":null}}`))
+
+	want := decodeMap(t, `{"cpu":{"usage_percent":25},"hostname":"a"}`)
+	if !reflect.DeepEqual(base, want) {
+		t.Errorf("applyDelta() = %v, want %v", base, want)
+	}
+}
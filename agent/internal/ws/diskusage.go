@@ -0,0 +1,152 @@
+package ws
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/driversti/hola/internal/docker"
+)
+
+// Disk usage interval bounds in seconds. Collecting it makes the daemon
+// walk every image layer and volume, so it is polled far less often than
+// metrics.
+const (
+	defaultDiskUsageInterval = 30
+	minDiskUsageInterval     = 10
+	maxDiskUsageInterval     = 300
+	diskUsageTimeout         = time.Minute
+)
+
+// diskUsageHub serves every disk_usage subscriber from one collector
+// goroutine, so at most one collection runs at a time however many clients
+// are subscribed. Each subscriber keeps its own interval; a collection is
+// sent to every subscriber that is due when it starts.
+type diskUsageHub struct {
+	collect func(ctx context.Context) (*docker.DiskUsageSummary, error)
+
+	mu      sync.Mutex
+	subs    map[*subscription]*diskUsageSub
+	running bool
+	wake    chan struct{}
+}
+
+type diskUsageSub struct {
+	ctx      context.Context
+	interval time.Duration
+	due      time.Time
+}
+
+func newDiskUsageHub(collect func(ctx context.Context) (*docker.DiskUsageSummary, error)) *diskUsageHub {
+	return &diskUsageHub{
+		collect: collect,
+		subs:    make(map[*subscription]*diskUsageSub),
+		wake:    make(chan struct{}, 1),
+	}
+}
+
+// clampDiskUsageInterval applies the default and bounds to a requested
+// interval.
+func clampDiskUsageInterval(seconds int) time.Duration {
+	if seconds == 0 {
+		seconds = defaultDiskUsageInterval
+	}
+	return time.Duration(min(max(seconds, minDiskUsageInterval), maxDiskUsageInterval)) * time.Second
+}
+
+// subscribe adds sub until ctx is done. Its first summary is collected right
+// away.
+func (d *diskUsageHub) subscribe(ctx context.Context, sub *subscription, intervalSeconds int) {
+	d.mu.Lock()
+	d.subs[sub] = &diskUsageSub{ctx: ctx, interval: clampDiskUsageInterval(intervalSeconds)}
+	if !d.running {
+		d.running = true
+		go d.run()
+	}
+	d.mu.Unlock()
+	d.poke()
+
+	go func() {
+		<-ctx.Done()
+		d.mu.Lock()
+		delete(d.subs, sub)
+		d.mu.Unlock()
+		d.poke()
+	}()
+}
+
+// poke wakes the collector to re-evaluate its schedule.
+func (d *diskUsageHub) poke() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// run is the collector loop. It exits once the last subscriber is gone and
+// is restarted by the next subscribe.
+func (d *diskUsageHub) run() {
+	for {
+		d.mu.Lock()
+		if len(d.subs) == 0 {
+			d.running = false
+			d.mu.Unlock()
+			return
+		}
+		var next time.Time
+		for _, s := range d.subs {
+			if next.IsZero() || s.due.Before(next) {
+				next = s.due
+			}
+		}
+		d.mu.Unlock()
+
+		if wait := time.Until(next); wait > 0 {
+			timer := time.NewTimer(wait)
+			select {
+			case <-timer.C:
+			case <-d.wake:
+				timer.Stop()
+				continue
+			}
+		}
+
+		d.collectAndSend()
+	}
+}
+
+func (d *diskUsageHub) collectAndSend() {
+	started := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), diskUsageTimeout)
+	summary, err := d.collect(ctx)
+	cancel()
+
+	d.mu.Lock()
+	var due []*subscription
+	var ctxs []context.Context
+	for sub, s := range d.subs {
+		if s.due.After(started) {
+			continue
+		}
+		// Reschedule even on failure so a broken daemon isn't hammered.
+		s.due = time.Now().Add(s.interval)
+		due = append(due, sub)
+		ctxs = append(ctxs, s.ctx)
+	}
+	d.mu.Unlock()
+
+	if err != nil {
+		slog.Warn("disk usage collect failed", "error", err)
+		return
+	}
+	msg := Message{Type: "disk_usage", Payload: mustMarshal(summary)}
+	for i, sub := range due {
+		if ctxs[i].Err() != nil {
+			continue
+		}
+		if err := sub.send(ctxs[i], msg); err != nil {
+			slog.Debug("disk usage send failed", "error", err)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package ws
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/driversti/hola/internal/docker"
+)
+
+func TestClampDiskUsageInterval(t *testing.T) {
+	for in, want := range map[int]time.Duration{
+		0:    30 * time.Second,
+		1:    10 * time.Second,
+		60:   60 * time.Second,
+		3600: 300 * time.Second,
+	} {
+		if got := clampDiskUsageInterval(in); got != want {
+			t.Errorf("clampDiskUsageInterval(%d) = %v, want %v", in, got, want)
+		}
+	}
+}
+
+func TestDiskUsageHubCollectsOneAtATime(t *testing.T) {
+	var inFlight, maxInFlight, calls atomic.Int32
+	hub := newDiskUsageHub(func(context.Context) (*docker.DiskUsageSummary, error) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		if n > maxInFlight.Load() {
+			maxInFlight.Store(n)
+		}
+		calls.Add(1)
+		time.Sleep(20 * time.Millisecond)
+		return &docker.DiskUsageSummary{}, nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	clients := make([]*client, 3)
+	for i := range clients {
+		clients[i] = &client{out: make(chan Message, 4), done: make(chan struct{})}
+		hub.subscribe(ctx, &subscription{c: clients[i]}, 10)
+	}
+
+	for i, c := range clients {
+		select {
+		case msg := <-c.out:
+			if msg.Type != "disk_usage" {
+				t.Errorf("client %d got %q", i, msg.Type)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("client %d got no disk_usage message", i)
+		}
+	}
+	if n := maxInFlight.Load(); n != 1 {
+		t.Errorf("%d collections ran concurrently, want 1", n)
+	}
+	if n := calls.Load(); n > 3 {
+		t.Errorf("%d collections for 3 subscribers, want at most 3", n)
+	}
+
+	cancel()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		hub.mu.Lock()
+		running := hub.running
+		hub.mu.Unlock()
+		if !running {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("collector kept running after the last subscriber left")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
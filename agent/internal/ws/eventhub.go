@@ -20,41 +20,131 @@ type ContainerEvent struct {
 	Stack         string `json:"stack"`
 	Status        string `json:"status"`
 	Time          int64  `json:"time"`
+	Replayed      bool   `json:"replayed,omitempty"` // sent from history on subscribe, not live
 }
 
-// subscriber wraps a client with its cancellation context.
+// StackStatusEvent is the payload of a stack_status message: a stack's
+// aggregate status recomputed after its containers changed state.
+type StackStatusEvent struct {
+	Stack        string `json:"stack"`
+	Status       string `json:"status"` // running, partial or stopped
+	RunningCount int    `json:"running_count"`
+	ServiceCount int    `json:"service_count"`
+}
+
+// stackStatusDebounce is how long container events for one stack are
+// coalesced before its status is recomputed; an up or down touches every
+// container in quick succession.
+const stackStatusDebounce = 500 * time.Millisecond
+
+// subscriber wraps a subscription with its cancellation context.
 type subscriber struct {
-	client *client
-	ctx    context.Context
+	sub *subscription
+	ctx context.Context
+}
+
+// DefaultEventHistory is how many container events an EventHub keeps for
+// replay to new subscribers.
+const DefaultEventHistory = 100
+
+// historyEvent is a container event kept for replay.
+type historyEvent struct {
+	msgType string
+	event   ContainerEvent
 }
 
 // EventHub listens to Docker events and fans out container events to subscribers.
 type EventHub struct {
 	dockerClient *docker.Client
 	mu           sync.RWMutex
-	subscribers  map[*client]subscriber
+	subscribers  map[*subscription]subscriber
+
+	// history is a ring of the most recent container events; next is the
+	// slot the following event goes into.
+	history []historyEvent
+	next    int
+	size    int
+
+	// stackSubs receive stack_status messages. pending marks stacks with a
+	// recompute already scheduled, so a burst of events yields one message.
+	stackSubs   map[*subscription]subscriber
+	pending     map[string]bool
+	debounce    time.Duration
+	stackStatus func(ctx context.Context, name string) (*docker.Stack, error)
 }
 
 // NewEventHub creates an EventHub.
 func NewEventHub(dockerClient *docker.Client) *EventHub {
 	return &EventHub{
 		dockerClient: dockerClient,
-		subscribers:  make(map[*client]subscriber),
+		subscribers:  make(map[*subscription]subscriber),
+		size:         DefaultEventHistory,
+		stackSubs:    make(map[*subscription]subscriber),
+		pending:      make(map[string]bool),
+		debounce:     stackStatusDebounce,
+		stackStatus:  dockerClient.StackStatus,
 	}
 }
 
-// Subscribe adds a client to receive container events.
-func (h *EventHub) Subscribe(ctx context.Context, c *client) {
+// SetHistorySize sets how many container events are kept for replay; zero
+// disables replay. Call it before Run.
+func (h *EventHub) SetHistorySize(n int) {
 	h.mu.Lock()
 	defer h.mu.Unlock()
-	h.subscribers[c] = subscriber{client: c, ctx: ctx}
+	h.size = max(n, 0)
+	h.history, h.next = nil, 0
 }
 
-// Unsubscribe removes a client from the event hub.
-func (h *EventHub) Unsubscribe(c *client) {
+// Subscribe adds sub to receive container events until ctx is done,
+// at which point it is removed automatically. The buffered history is
+// replayed first, so a client connecting just after a crash still sees the
+// die event. Replay happens under the same lock that records new events,
+// so every event reaches the client exactly once, either replayed or live.
+// Only the newest events that fit in half the client's free send queue are
+// replayed, so a long history can't get the client dropped as a slow
+// consumer before live events arrive.
+func (h *EventHub) Subscribe(ctx context.Context, sub *subscription) {
+	h.mu.Lock()
+	h.subscribers[sub] = subscriber{sub: sub, ctx: ctx}
+	recent := h.recentLocked()
+	if room := (cap(sub.c.out) - len(sub.c.out)) / 2; len(recent) > room {
+		recent = recent[len(recent)-room:]
+	}
+	for _, he := range recent {
+		he.event.Replayed = true
+		if err := sub.send(ctx, Message{Type: he.msgType, Payload: mustMarshal(he.event)}); err != nil {
+			slog.Debug("event replay failed", "error", err)
+			break
+		}
+	}
+	h.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		h.Unsubscribe(sub)
+	}()
+}
+
+// Unsubscribe removes a subscription from the event hub.
+func (h *EventHub) Unsubscribe(sub *subscription) {
 	h.mu.Lock()
 	defer h.mu.Unlock()
-	delete(h.subscribers, c)
+	delete(h.subscribers, sub)
+}
+
+// SubscribeStackStatus adds sub to receive stack_status messages until ctx
+// is done.
+func (h *EventHub) SubscribeStackStatus(ctx context.Context, sub *subscription) {
+	h.mu.Lock()
+	h.stackSubs[sub] = subscriber{sub: sub, ctx: ctx}
+	h.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		h.mu.Lock()
+		delete(h.stackSubs, sub)
+		h.mu.Unlock()
+	}()
 }
 
 // Run starts listening for Docker events. It blocks until ctx is cancelled.
@@ -107,6 +197,7 @@ var allowedActions = map[string]bool{
 	"restart": true,
 	"create":  true,
 	"destroy": true,
+	"oom":     true,
 }
 
 func (h *EventHub) broadcast(ctx context.Context, msg events.Message) {
@@ -125,18 +216,114 @@ func (h *EventHub) broadcast(ctx context.Context, msg events.Message) {
 		Time:          msg.Time,
 	}
 
-	payload := mustMarshal(evt)
+	// OOM kills are sent as their own message type so clients can flag them
+	// without inferring the cause from the die event that follows.
+	msgType := "container_event"
+	if action == "oom" {
+		msgType = "oom_event"
+	}
+
+	h.mu.Lock()
+	h.recordLocked(historyEvent{msgType: msgType, event: evt})
+	subs := subscribersOf(h.subscribers)
+	if evt.Stack != "" && action != "oom" {
+		h.scheduleStackStatusLocked(ctx, evt.Stack)
+	}
+	h.mu.Unlock()
+
+	h.sendAll(ctx, subs, Message{Type: msgType, Payload: mustMarshal(evt)})
+}
+
+// scheduleStackStatusLocked arranges for stack's status to be recomputed
+// once the debounce window has passed, unless that is already scheduled or
+// nobody is listening.
+func (h *EventHub) scheduleStackStatusLocked(ctx context.Context, stack string) {
+	if len(h.stackSubs) == 0 || h.pending[stack] {
+		return
+	}
+	h.pending[stack] = true
+	time.AfterFunc(h.debounce, func() { h.emitStackStatus(ctx, stack) })
+}
+
+// emitStackStatus recomputes stack's status and sends it to stack_status
+// subscribers. The pending mark is cleared first, so an event arriving
+// while the status is being computed schedules a fresh recompute.
+func (h *EventHub) emitStackStatus(ctx context.Context, stack string) {
+	h.mu.Lock()
+	delete(h.pending, stack)
+	subs := subscribersOf(h.stackSubs)
+	h.mu.Unlock()
+	if len(subs) == 0 || ctx.Err() != nil {
+		return
+	}
+
+	st, err := h.stackStatus(ctx, stack)
+	if err != nil {
+		slog.Warn("stack status recompute failed", "stack", stack, "error", err)
+		return
+	}
+	h.sendAll(ctx, subs, Message{Type: "stack_status", Payload: mustMarshal(StackStatusEvent{
+		Stack:        st.Name,
+		Status:       st.Status,
+		RunningCount: st.RunningCount,
+		ServiceCount: st.ServiceCount,
+	})})
+}
 
+// recordLocked adds an event to the history ring, overwriting the oldest
+// once it is full.
+func (h *EventHub) recordLocked(he historyEvent) {
+	if h.size == 0 {
+		return
+	}
+	if len(h.history) < h.size {
+		h.history = append(h.history, he)
+	} else {
+		h.history[h.next] = he
+	}
+	h.next = (h.next + 1) % h.size
+}
+
+// recentLocked returns the buffered events, oldest first.
+func (h *EventHub) recentLocked() []historyEvent {
+	if len(h.history) < h.size {
+		return h.history
+	}
+	return append(h.history[h.next:h.size:h.size], h.history[:h.next]...)
+}
+
+// Publish sends a non-Docker message (e.g. a resource alert) to every
+// events subscriber.
+func (h *EventHub) Publish(ctx context.Context, msgType string, payload any) {
+	h.fanOut(ctx, Message{Type: msgType, Payload: mustMarshal(payload)})
+}
+
+// fanOut queues msg on every subscriber. send never blocks (a client whose
+// queue is full is disconnected instead), and the lock is released before
+// sending, so one slow subscriber cannot delay the others or Subscribe.
+func (h *EventHub) fanOut(ctx context.Context, msg Message) {
 	h.mu.RLock()
-	defer h.mu.RUnlock()
+	subs := subscribersOf(h.subscribers)
+	h.mu.RUnlock()
 
-	for _, sub := range h.subscribers {
-		select {
-		case <-sub.ctx.Done():
+	h.sendAll(ctx, subs, msg)
+}
+
+// subscribersOf snapshots a subscriber set; the caller holds h.mu.
+func subscribersOf(m map[*subscription]subscriber) []subscriber {
+	subs := make([]subscriber, 0, len(m))
+	for _, s := range m {
+		subs = append(subs, s)
+	}
+	return subs
+}
+
+func (h *EventHub) sendAll(ctx context.Context, subs []subscriber, msg Message) {
+	for _, s := range subs {
+		if s.ctx.Err() != nil {
 			continue
-		default:
 		}
-		if err := sub.client.send(ctx, Message{Type: "container_event", Payload: payload}); err != nil {
+		if err := s.sub.send(ctx, msg); err != nil {
 			slog.Debug("event send failed", "error", err)
 		}
 	}
@@ -0,0 +1,179 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/events"
+
+	"github.com/driversti/hola/internal/docker"
+)
+
+func TestEventHubRemovesCancelledSubscribers(t *testing.T) {
+	hub := NewEventHub(nil)
+	c := &client{out: make(chan Message, 4), done: make(chan struct{})}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	hub.Subscribe(ctx, &subscription{c: c})
+
+	hub.Publish(context.Background(), "test", "first")
+	if len(c.out) != 1 {
+		t.Fatalf("want 1 queued message, got %d", len(c.out))
+	}
+
+	cancel()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		hub.mu.RLock()
+		n := len(hub.subscribers)
+		hub.mu.RUnlock()
+		if n == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("cancelled subscriber was not removed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	hub.Publish(context.Background(), "test", "second")
+	if len(c.out) != 1 {
+		t.Errorf("cancelled subscriber received a message after removal")
+	}
+}
+
+func TestEventHubReplaysHistory(t *testing.T) {
+	hub := NewEventHub(nil)
+	hub.SetHistorySize(2)
+
+	for i, action := range []string{"start", "die", "oom"} {
+		hub.broadcast(context.Background(), events.Message{
+			Type:   events.ContainerEventType,
+			Action: events.Action(action),
+			Actor:  events.Actor{ID: "0123456789abcdef", Attributes: map[string]string{"name": "web"}},
+			Time:   int64(i),
+		})
+	}
+
+	c := &client{out: make(chan Message, 4), done: make(chan struct{})}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	hub.Subscribe(ctx, &subscription{c: c})
+
+	// Only the two newest events fit, oldest first.
+	wantTypes := []string{"container_event", "oom_event"}
+	wantActions := []string{"die", "oom"}
+	if len(c.out) != len(wantTypes) {
+		t.Fatalf("want %d replayed messages, got %d", len(wantTypes), len(c.out))
+	}
+	for i := range wantTypes {
+		msg := <-c.out
+		var evt ContainerEvent
+		if err := json.Unmarshal(msg.Payload, &evt); err != nil {
+			t.Fatal(err)
+		}
+		if msg.Type != wantTypes[i] || evt.Action != wantActions[i] || !evt.Replayed {
+			t.Errorf("replay %d = %s %+v, want %s %q replayed", i, msg.Type, evt, wantTypes[i], wantActions[i])
+		}
+	}
+
+	// Live events after subscribing are not marked as replayed.
+	hub.broadcast(context.Background(), events.Message{
+		Type:   events.ContainerEventType,
+		Action: "restart",
+		Actor:  events.Actor{ID: "0123456789abcdef"},
+	})
+	var evt ContainerEvent
+	if err := json.Unmarshal((<-c.out).Payload, &evt); err != nil {
+		t.Fatal(err)
+	}
+	if evt.Action != "restart" || evt.Replayed {
+		t.Errorf("live event = %+v, want a non-replayed restart", evt)
+	}
+}
+
+func TestEventHubReplayFitsSendQueue(t *testing.T) {
+	hub := NewEventHub(nil)
+	hub.SetHistorySize(DefaultEventHistory)
+	for i := range 20 {
+		hub.broadcast(context.Background(), events.Message{
+			Type:   events.ContainerEventType,
+			Action: "start",
+			Actor:  events.Actor{ID: "0123456789abcdef", Attributes: map[string]string{"name": "web"}},
+			Time:   int64(i),
+		})
+	}
+
+	// One message is already queued, leaving room for 7: half of that
+	// is replayed and the client stays connected for live events.
+	c := &client{out: make(chan Message, 8), done: make(chan struct{})}
+	c.out <- Message{Type: "subscribed"}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	hub.Subscribe(ctx, &subscription{c: c})
+
+	if n := len(c.out) - 1; n != 3 {
+		t.Fatalf("replayed %d events, want 3", n)
+	}
+	<-c.out
+	var evt ContainerEvent
+	if err := json.Unmarshal((<-c.out).Payload, &evt); err != nil {
+		t.Fatal(err)
+	}
+	if evt.Time != 17 {
+		t.Errorf("first replayed event has time %d, want 17 (the newest three)", evt.Time)
+	}
+	select {
+	case <-c.done:
+		t.Fatal("client was dropped during replay")
+	default:
+	}
+}
+
+func TestEventHubCoalescesStackStatus(t *testing.T) {
+	hub := NewEventHub(nil)
+	hub.debounce = 50 * time.Millisecond
+	var calls atomic.Int32
+	hub.stackStatus = func(_ context.Context, name string) (*docker.Stack, error) {
+		calls.Add(1)
+		return &docker.Stack{Name: name, Status: "partial", ServiceCount: 3, RunningCount: 2}, nil
+	}
+
+	c := &client{out: make(chan Message, 8), done: make(chan struct{})}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	hub.SubscribeStackStatus(ctx, &subscription{c: c})
+
+	for _, action := range []string{"die", "start", "start"} {
+		hub.broadcast(context.Background(), events.Message{
+			Type:   events.ContainerEventType,
+			Action: events.Action(action),
+			Actor:  events.Actor{ID: "0123456789abcdef", Attributes: map[string]string{"com.docker.compose.project": "app"}},
+		})
+	}
+
+	select {
+	case msg := <-c.out:
+		var st StackStatusEvent
+		if err := json.Unmarshal(msg.Payload, &st); err != nil {
+			t.Fatal(err)
+		}
+		want := StackStatusEvent{Stack: "app", Status: "partial", RunningCount: 2, ServiceCount: 3}
+		if msg.Type != "stack_status" || st != want {
+			t.Errorf("got %s %+v, want stack_status %+v", msg.Type, st, want)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no stack_status message")
+	}
+
+	time.Sleep(150 * time.Millisecond)
+	if n := calls.Load(); n != 1 {
+		t.Errorf("status recomputed %d times for one burst, want 1", n)
+	}
+	if len(c.out) != 0 {
+		t.Errorf("%d extra messages queued", len(c.out))
+	}
+}
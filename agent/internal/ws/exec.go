@@ -0,0 +1,190 @@
+package ws
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"log/slog"
+
+	"github.com/driversti/hola/internal/docker"
+)
+
+// ExecInputPayload is sent by the client in "exec_input" messages to feed
+// the stdin of an exec stream.
+type ExecInputPayload struct {
+	ContainerID string `json:"container_id"`
+	Data        string `json:"data,omitempty"`
+	EOF         bool   `json:"eof,omitempty"` // close stdin after writing data
+}
+
+// ExecOutput is the payload of "exec_output" messages.
+type ExecOutput struct {
+	ContainerID string `json:"container_id"`
+	Stream      string `json:"stream"` // "stdout" or "stderr"; always "stdout" with a tty
+	Data        string `json:"data"`
+}
+
+// ExecExit is the payload of the "exec_exit" message that ends an exec
+// stream.
+type ExecExit struct {
+	ContainerID string `json:"container_id"`
+	ExitCode    int    `json:"exit_code"`
+	Error       string `json:"error,omitempty"`
+}
+
+// execStream is an interactive exec attached to a subscription. done is closed
+// once the command's output has ended.
+type execStream struct {
+	sess *docker.ExecSession
+	done chan struct{}
+}
+
+func (e *execStream) finished() bool {
+	select {
+	case <-e.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// streamExec forwards an exec session's output to the client until the
+// command exits, then reports its exit code. Cancelling ctx detaches the
+// session.
+func streamExec(ctx context.Context, sub *subscription, containerID string, ex *execStream) {
+	defer close(ex.done)
+	defer ex.sess.Close()
+	stop := context.AfterFunc(ctx, ex.sess.Close)
+	defer stop()
+
+	err := ex.sess.Copy(
+		execWriter{ctx: ctx, sub: sub, containerID: containerID, stream: "stdout"},
+		execWriter{ctx: ctx, sub: sub, containerID: containerID, stream: "stderr"},
+	)
+	if ctx.Err() != nil {
+		return
+	}
+
+	exit := ExecExit{ContainerID: containerID}
+	if err != nil {
+		exit.Error = err.Error()
+	} else if exit.ExitCode, err = ex.sess.ExitCode(ctx); err != nil {
+		exit.Error = err.Error()
+	}
+	_ = sub.send(ctx, Message{Type: "exec_exit", Payload: mustMarshal(exit)})
+}
+
+// execWriter sends each chunk of exec output as an "exec_output" message.
+type execWriter struct {
+	ctx         context.Context
+	sub         *subscription
+	containerID string
+	stream      string
+}
+
+func (w execWriter) Write(p []byte) (int, error) {
+	err := w.sub.send(w.ctx, Message{
+		Type:    "exec_output",
+		Payload: mustMarshal(ExecOutput{ContainerID: w.containerID, Stream: w.stream, Data: string(p)}),
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (h *Handler) subscribeExec(ctx context.Context, c *client, msg Message, payload SubscribePayload) {
+	if payload.ContainerID == "" {
+		_ = c.send(ctx, Message{
+			Type:    "error",
+			ID:      msg.ID,
+			Payload: mustMarshal(ErrorPayload{Error: "container_id required for exec stream", Code: "MISSING_CONTAINER_ID"}),
+		})
+		return
+	}
+	if len(payload.Cmd) == 0 {
+		_ = c.send(ctx, Message{
+			Type:    "error",
+			ID:      msg.ID,
+			Payload: mustMarshal(ErrorPayload{Error: "cmd required for exec stream", Code: "MISSING_CMD"}),
+		})
+		return
+	}
+	if h.eventHub == nil {
+		_ = c.send(ctx, Message{
+			Type:    "error",
+			ID:      msg.ID,
+			Payload: mustMarshal(ErrorPayload{Error: "docker not available", Code: "NOT_AVAILABLE"}),
+		})
+		return
+	}
+
+	subKey := subscriptionKey(msg.ID, "exec", payload.ContainerID)
+	if prev, exists := c.subscriptions[subKey]; exists && prev.exec != nil && prev.exec.finished() {
+		// The previous command exited without an unsubscribe; replace it.
+		prev.cancel()
+		delete(c.subscriptions, subKey)
+	}
+	sub, subCtx := c.subscribe(ctx, msg, "exec", payload.ContainerID)
+	if sub == nil {
+		return
+	}
+
+	sess, err := h.eventHub.dockerClient.StartExec(subCtx, payload.ContainerID, payload.Cmd, payload.TTY, true)
+	if err != nil {
+		sub.cancel()
+		delete(c.subscriptions, subKey)
+		slog.Warn("exec start failed", "container", payload.ContainerID, "error", err)
+		_ = c.send(ctx, Message{
+			Type:    "error",
+			ID:      msg.ID,
+			Payload: mustMarshal(ErrorPayload{Error: "failed to start exec: " + err.Error(), Code: "EXEC_ERROR"}),
+		})
+		return
+	}
+
+	sub.exec = &execStream{sess: sess, done: make(chan struct{})}
+	go streamExec(subCtx, sub, payload.ContainerID, sub.exec)
+
+	_ = c.send(ctx, Message{
+		Type:    "subscribed",
+		ID:      msg.ID,
+		Payload: mustMarshal(SubscribePayload{Stream: "exec", ContainerID: payload.ContainerID, TTY: payload.TTY}),
+	})
+}
+
+// handleExecInput feeds stdin of the exec subscription named by the message
+// id or, failing that, of the container's only exec.
+func (h *Handler) handleExecInput(ctx context.Context, c *client, msg Message) {
+	var payload ExecInputPayload
+	if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+		_ = c.send(ctx, Message{
+			Type:    "error",
+			ID:      msg.ID,
+			Payload: mustMarshal(ErrorPayload{Error: "invalid exec_input payload", Code: "BAD_PAYLOAD"}),
+		})
+		return
+	}
+
+	key, _ := c.findSubscription(msg.ID, "exec", payload.ContainerID)
+	sub, exists := c.subscriptions[key]
+	if !exists || sub.exec == nil || sub.exec.finished() {
+		_ = c.send(ctx, Message{
+			Type:    "error",
+			ID:      msg.ID,
+			Payload: mustMarshal(ErrorPayload{Error: "no exec running for " + cmp.Or(msg.ID, "container "+payload.ContainerID), Code: "NOT_SUBSCRIBED"}),
+		})
+		return
+	}
+	ex := sub.exec
+
+	if payload.Data != "" {
+		if _, err := ex.sess.Write([]byte(payload.Data)); err != nil {
+			slog.Debug("exec stdin write failed", "container", sub.containerID, "error", err)
+			return
+		}
+	}
+	if payload.EOF {
+		_ = ex.sess.CloseStdin()
+	}
+}
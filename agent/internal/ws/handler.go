@@ -1,15 +1,23 @@
 package ws
 
 import (
+	"cmp"
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log/slog"
 	"net/http"
-	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"nhooyr.io/websocket"
 	"nhooyr.io/websocket/wsjson"
+
+	"github.com/driversti/hola/internal/api/respond"
+	"github.com/driversti/hola/internal/docker"
+	"github.com/driversti/hola/internal/metrics"
 )
 
 // Message is the envelope for all WebSocket messages.
@@ -21,61 +29,409 @@ type Message struct {
 
 // SubscribePayload is sent by the client to start/stop a stream.
 type SubscribePayload struct {
-	Stream          string `json:"stream"`
-	ContainerID     string `json:"container_id,omitempty"`
-	IntervalSeconds int    `json:"interval_seconds,omitempty"`
+	Stream          string   `json:"stream"`
+	ContainerID     string   `json:"container_id,omitempty"`
+	IntervalSeconds int      `json:"interval_seconds,omitempty"`
+	Delta           bool     `json:"delta,omitempty"`
+	Lines           int      `json:"lines,omitempty"`      // logs: lines to start with, default 50, capped by --max-log-lines
+	Since           string   `json:"since,omitempty"`      // logs: RFC3339 or Unix seconds, as for the HTTP logs endpoint
+	Grep            string   `json:"grep,omitempty"`       // logs: only lines containing this text
+	Regex           bool     `json:"regex,omitempty"`      // logs: treat grep as a regular expression
+	LogStream       string   `json:"log_stream,omitempty"` // logs: "stdout", "stderr" or "both"
+	Cursor          string   `json:"cursor,omitempty"`     // logs: resume after the line that carried this cursor; overrides since
+	Cmd             []string `json:"cmd,omitempty"`        // exec: command and arguments
+	TTY             bool     `json:"tty,omitempty"`        // exec: allocate a pseudo-terminal
 }
 
+// Outbound messages go through a bounded per-client queue drained by a
+// dedicated writer goroutine, so producers (streams, the event hub) never
+// block on a slow socket. When a client's queue overflows the client is
+// disconnected instead of having messages dropped: silently losing log lines
+// or metrics deltas would leave it with a corrupt view, while a disconnect
+// is visible and it can resubscribe.
+const (
+	defaultSendQueueSize = 256
+	writeTimeout         = 10 * time.Second
+)
+
+var (
+	errClientClosed  = errors.New("client closed")
+	errSendQueueFull = errors.New("send queue full")
+)
+
 // client represents a single WebSocket connection.
 type client struct {
 	conn          *websocket.Conn
-	mu            sync.Mutex
-	subscriptions map[string]context.CancelFunc // key: "metrics", "events", "logs:<container_id>"
+	out           chan Message
+	done          chan struct{}
+	stopOnce      sync.Once
+	subscriptions map[string]*subscription // key: see subscriptionKey
+}
+
+// subscription is one stream opened by a client. Everything the stream
+// sends carries the id the client subscribed with, so a client can run
+// several streams of the same type and tell their messages apart.
+type subscription struct {
+	c           *client
+	id          string // client-supplied; empty for subscriptions made without one
+	stream      string
+	containerID string
+	cancel      context.CancelFunc
+	exec        *execStream // exec streams only
+}
+
+// send queues msg for the client, tagged with the subscription's id.
+func (s *subscription) send(ctx context.Context, msg Message) error {
+	msg.ID = s.id
+	return s.c.send(ctx, msg)
+}
+
+// maxSubscriptions bounds the subscriptions one client may hold at once.
+const maxSubscriptions = 16
+
+// subscriptionKey is the key a subscription is stored under: "id:" plus the
+// id the client subscribed with or, for clients that send none, the stream
+// type qualified by container, which allows one such subscription per
+// stream. The prefix keeps ids such as "metrics" from colliding with the
+// latter.
+func subscriptionKey(id, stream, containerID string) string {
+	if id != "" {
+		return "id:" + id
+	}
+	switch stream {
+	case "logs", "container_stats", "exec":
+		return stream + ":" + containerID
+	}
+	return stream
+}
+
+func newClient(conn *websocket.Conn, queueSize int) *client {
+	if queueSize <= 0 {
+		queueSize = defaultSendQueueSize
+	}
+	return &client{
+		conn:          conn,
+		out:           make(chan Message, queueSize),
+		done:          make(chan struct{}),
+		subscriptions: make(map[string]*subscription),
+	}
+}
+
+// send queues msg for delivery without blocking. If the queue is full the
+// connection is closed and errSendQueueFull returned.
+func (c *client) send(_ context.Context, msg Message) error {
+	select {
+	case <-c.done:
+		return errClientClosed
+	default:
+	}
+
+	select {
+	case c.out <- msg:
+		return nil
+	case <-c.done:
+		return errClientClosed
+	default:
+		slog.Warn("websocket send queue full, disconnecting client", "queue_size", cap(c.out))
+		c.stop()
+		_ = c.conn.CloseNow()
+		return errSendQueueFull
+	}
 }
 
-func (c *client) send(ctx context.Context, msg Message) error {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-	return wsjson.Write(ctx, c.conn, msg)
+// stop marks the client closed so further sends fail fast.
+func (c *client) stop() {
+	c.stopOnce.Do(func() { close(c.done) })
+}
+
+// writeLoop delivers queued messages until the client stops or ctx ends.
+func (c *client) writeLoop(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-c.done:
+			return
+		case msg := <-c.out:
+			writeCtx, cancel := context.WithTimeout(ctx, writeTimeout)
+			err := wsjson.Write(writeCtx, c.conn, msg)
+			cancel()
+			if err != nil {
+				slog.Debug("websocket write failed", "error", err)
+				c.stop()
+				_ = c.conn.CloseNow()
+				return
+			}
+		}
+	}
 }
 
 func (c *client) cancelAll() {
-	for key, cancel := range c.subscriptions {
-		cancel()
+	for key, sub := range c.subscriptions {
+		sub.cancel()
 		delete(c.subscriptions, key)
 	}
 }
 
+// subscribe registers a subscription of msg for stream and returns it with
+// its context, or reports ALREADY_SUBSCRIBED and returns nil if its key is
+// taken.
+func (c *client) subscribe(ctx context.Context, msg Message, stream, containerID string) (*subscription, context.Context) {
+	key := subscriptionKey(msg.ID, stream, containerID)
+	var errPayload ErrorPayload
+	switch _, exists := c.subscriptions[key]; {
+	case exists && msg.ID != "":
+		errPayload = ErrorPayload{Error: "already subscribed with id " + msg.ID, Code: "ALREADY_SUBSCRIBED"}
+	case exists:
+		errPayload = ErrorPayload{Error: "already subscribed to " + key, Code: "ALREADY_SUBSCRIBED"}
+	case len(c.subscriptions) >= maxSubscriptions:
+		errPayload = ErrorPayload{Error: fmt.Sprintf("max %d concurrent subscriptions", maxSubscriptions), Code: "LIMIT_EXCEEDED"}
+	}
+	if errPayload.Code != "" {
+		_ = c.send(ctx, Message{Type: "error", ID: msg.ID, Payload: mustMarshal(errPayload)})
+		return nil, nil
+	}
+	subCtx, cancel := context.WithCancel(ctx)
+	sub := &subscription{c: c, id: msg.ID, stream: stream, containerID: containerID, cancel: cancel}
+	c.subscriptions[key] = sub
+	return sub, subCtx
+}
+
+// findSubscription returns the key of the subscription a message refers
+// to: the one subscribed with id or, failing that, the only one of stream
+// on containerID, which is how clients that don't track their ids address
+// a subscription. It returns an error code when there is no such
+// subscription or several match.
+func (c *client) findSubscription(id, stream, containerID string) (key, code string) {
+	if id != "" {
+		if _, exists := c.subscriptions[subscriptionKey(id, "", "")]; exists {
+			return subscriptionKey(id, "", ""), ""
+		}
+	}
+	if stream == "" {
+		return "", "NOT_SUBSCRIBED"
+	}
+	if _, exists := c.subscriptions[subscriptionKey("", stream, containerID)]; exists {
+		return subscriptionKey("", stream, containerID), ""
+	}
+	for k, sub := range c.subscriptions {
+		if sub.stream != stream || sub.containerID != containerID {
+			continue
+		}
+		if key != "" {
+			return "", "AMBIGUOUS_SUBSCRIPTION"
+		}
+		key = k
+	}
+	if key == "" {
+		return "", "NOT_SUBSCRIBED"
+	}
+	return key, ""
+}
+
+// perContainerCount counts the client's logs and container_stats
+// subscriptions, which share a limit.
+func (c *client) perContainerCount() int {
+	n := 0
+	for _, sub := range c.subscriptions {
+		if sub.stream == "logs" || sub.stream == "container_stats" {
+			n++
+		}
+	}
+	return n
+}
+
+// Options configures a Handler.
+type Options struct {
+	// AllowedOrigins are host patterns (path.Match syntax, e.g.
+	// "*.example.com") that browser clients may connect from. When empty,
+	// only pages served from the agent's own host are accepted.
+	AllowedOrigins []string
+
+	// AllowAllOrigins disables origin checks entirely. Only safe when no
+	// browser on the network can be tricked into opening a socket.
+	AllowAllOrigins bool
+
+	// PingInterval is how often the server pings each client. A client that
+	// hasn't answered within two intervals is disconnected. Zero means 30s.
+	PingInterval time.Duration
+
+	// SendQueueSize bounds the outbound messages buffered per client.
+	// Zero means 256.
+	SendQueueSize int
+
+	// MaxConnections bounds concurrent connections; further upgrades are
+	// refused with 503. Zero means DefaultMaxConnections.
+	MaxConnections int
+
+	// MaxMessageSize bounds an inbound message in bytes. A client sending a
+	// larger one is disconnected with StatusMessageTooBig. Zero means
+	// DefaultMaxMessageSize.
+	MaxMessageSize int64
+}
+
+const defaultPingInterval = 30 * time.Second
+
+// DefaultMaxConnections is how many WebSocket clients may be connected at
+// once unless Options say otherwise.
+const DefaultMaxConnections = 64
+
+// DefaultMaxMessageSize is the inbound message limit unless Options say
+// otherwise. Client messages are small control messages and exec input.
+const DefaultMaxMessageSize = 32 << 10
+
 // Handler accepts WebSocket connections and manages subscriptions.
 type Handler struct {
-	eventHub *EventHub
+	eventHub  *EventHub
+	metrics   *metricsHub
+	diskUsage *diskUsageHub // nil without an event hub, which owns the Docker client
+	opts      Options
+
+	conns atomic.Int64 // connections being served, for MaxConnections
+
+	mu      sync.Mutex
+	clients map[*client]struct{} // live connections, for CloseAll
+	closing bool                 // set by CloseAll; new connections are refused
 }
 
 // NewHandler creates a WebSocket handler.
-func NewHandler(eventHub *EventHub) *Handler {
-	return &Handler{eventHub: eventHub}
+func NewHandler(eventHub *EventHub, opts Options) *Handler {
+	h := &Handler{
+		eventHub: eventHub,
+		metrics:  newMetricsHub(new(metrics.Collector).Collect),
+		opts:     opts,
+		clients:  make(map[*client]struct{}),
+	}
+	if eventHub != nil {
+		h.diskUsage = newDiskUsageHub(eventHub.dockerClient.DiskUsage)
+	}
+	return h
+}
+
+// CloseAll sends every connected client a normal-closure close frame with
+// reason and waits for the close handshakes, which end each connection's
+// read loop and so cancel its streams. Connections arriving afterwards are
+// closed straight away. It is meant for server shutdown: http.Server's
+// Shutdown does not wait for hijacked connections such as WebSockets.
+func (h *Handler) CloseAll(reason string) {
+	h.mu.Lock()
+	h.closing = true
+	clients := make([]*client, 0, len(h.clients))
+	for c := range h.clients {
+		clients = append(clients, c)
+	}
+	h.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, c := range clients {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.stop()
+			_ = c.conn.Close(websocket.StatusNormalClosure, reason)
+		}()
+	}
+	wg.Wait()
+}
+
+// track registers a connection for CloseAll, reporting false once the
+// handler is closing.
+func (h *Handler) track(c *client) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closing {
+		return false
+	}
+	h.clients[c] = struct{}{}
+	return true
+}
+
+func (h *Handler) untrack(c *client) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.clients, c)
+}
+
+// acceptOptions builds the origin policy. With no patterns configured the
+// library compares the Origin host against the request Host, so only
+// same-host pages (and non-browser clients, which send no Origin) connect.
+func (h *Handler) acceptOptions() *websocket.AcceptOptions {
+	if h.opts.AllowAllOrigins {
+		return &websocket.AcceptOptions{InsecureSkipVerify: true}
+	}
+	return &websocket.AcceptOptions{OriginPatterns: h.opts.AllowedOrigins}
 }
 
 func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	conn, err := websocket.Accept(w, r, &websocket.AcceptOptions{
-		// Allow all origins — agent runs on a trusted network.
-		InsecureSkipVerify: true,
-	})
+	// Count before accepting so a client reconnecting in a loop is turned
+	// away without a goroutine or stream being started for it.
+	limit := cmp.Or(h.opts.MaxConnections, DefaultMaxConnections)
+	defer h.conns.Add(-1)
+	if h.conns.Add(1) > int64(limit) {
+		slog.WarnContext(r.Context(), "websocket connection refused, limit reached", "remote", r.RemoteAddr, "max", limit)
+		respond.Error(w, http.StatusServiceUnavailable, "too many WebSocket connections", "TOO_MANY_CONNECTIONS")
+		return
+	}
+
+	conn, err := websocket.Accept(w, r, h.acceptOptions())
 	if err != nil {
-		slog.Error("websocket accept failed", "error", err)
+		slog.ErrorContext(r.Context(), "websocket accept failed", "error", err)
 		return
 	}
 	defer conn.Close(websocket.StatusNormalClosure, "bye")
+	conn.SetReadLimit(cmp.Or(h.opts.MaxMessageSize, DefaultMaxMessageSize))
 
-	slog.Info("websocket client connected", "remote", r.RemoteAddr)
+	slog.InfoContext(r.Context(), "websocket client connected", "remote", r.RemoteAddr)
 
-	c := &client{
-		conn:          conn,
-		subscriptions: make(map[string]context.CancelFunc),
+	c := newClient(conn, h.opts.SendQueueSize)
+	if !h.track(c) {
+		conn.Close(websocket.StatusGoingAway, "server shutting down")
+		return
 	}
+	defer h.untrack(c)
 	defer c.cancelAll()
+	defer c.stop()
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	go c.writeLoop(ctx)
+	go h.heartbeat(ctx, cancel, conn, r.RemoteAddr)
 
-	h.readLoop(r.Context(), c)
+	h.readLoop(ctx, c)
+}
+
+// heartbeat pings the client every interval. If a pong doesn't arrive within
+// two intervals the peer is treated as gone (closed tab, sleeping laptop):
+// the connection is dropped and ctx cancelled, which ends readLoop and, via
+// cancelAll, every stream the client had open.
+func (h *Handler) heartbeat(ctx context.Context, cancel context.CancelFunc, conn *websocket.Conn, remote string) {
+	interval := h.opts.PingInterval
+	if interval <= 0 {
+		interval = defaultPingInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		pingCtx, pingCancel := context.WithTimeout(ctx, 2*interval)
+		err := conn.Ping(pingCtx)
+		pingCancel()
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			slog.Info("websocket client unresponsive, closing", "remote", remote, "error", err)
+			cancel()
+			_ = conn.CloseNow()
+			return
+		}
+	}
 }
 
 func (h *Handler) readLoop(ctx context.Context, c *client) {
@@ -96,6 +452,8 @@ func (h *Handler) readLoop(ctx context.Context, c *client) {
 			h.handleSubscribe(ctx, c, msg)
 		case "unsubscribe":
 			h.handleUnsubscribe(ctx, c, msg)
+		case "exec_input":
+			h.handleExecInput(ctx, c, msg)
 		case "ping":
 			_ = c.send(ctx, Message{Type: "pong"})
 		default:
@@ -112,141 +470,151 @@ func (h *Handler) handleSubscribe(ctx context.Context, c *client, msg Message) {
 	if err := json.Unmarshal(msg.Payload, &payload); err != nil {
 		_ = c.send(ctx, Message{
 			Type:    "error",
+			ID:      msg.ID,
 			Payload: mustMarshal(ErrorPayload{Error: "invalid subscribe payload", Code: "BAD_PAYLOAD"}),
 		})
 		return
 	}
 
+	// "stats" is accepted as shorthand for "container_stats".
+	if payload.Stream == "stats" {
+		payload.Stream = "container_stats"
+	}
+
 	switch payload.Stream {
 	case "metrics":
-		subKey := "metrics"
-		if _, exists := c.subscriptions[subKey]; exists {
-			_ = c.send(ctx, Message{
-				Type:    "error",
-				Payload: mustMarshal(ErrorPayload{Error: "already subscribed to metrics", Code: "ALREADY_SUBSCRIBED"}),
-			})
+		sub, subCtx := c.subscribe(ctx, msg, "metrics", "")
+		if sub == nil {
 			return
 		}
 
-		subCtx, cancel := context.WithCancel(ctx)
-		c.subscriptions[subKey] = cancel
-		go streamMetrics(subCtx, c, payload.IntervalSeconds)
-
+		// Ack first: the first sample is collected straight away.
 		_ = c.send(ctx, Message{
 			Type:    "subscribed",
 			ID:      msg.ID,
-			Payload: mustMarshal(SubscribePayload{Stream: "metrics"}),
+			Payload: mustMarshal(SubscribePayload{Stream: "metrics", Delta: payload.Delta}),
 		})
+		h.metrics.subscribe(subCtx, sub, payload.IntervalSeconds, payload.Delta)
 
 	case "events":
-		subKey := "events"
-		if _, exists := c.subscriptions[subKey]; exists {
-			_ = c.send(ctx, Message{
-				Type:    "error",
-				Payload: mustMarshal(ErrorPayload{Error: "already subscribed to events", Code: "ALREADY_SUBSCRIBED"}),
-			})
-			return
-		}
-
 		if h.eventHub == nil {
 			_ = c.send(ctx, Message{
 				Type:    "error",
+				ID:      msg.ID,
 				Payload: mustMarshal(ErrorPayload{Error: "event hub not available", Code: "NOT_AVAILABLE"}),
 			})
 			return
 		}
 
-		subCtx, cancel := context.WithCancel(ctx)
-		c.subscriptions[subKey] = cancel
-		h.eventHub.Subscribe(subCtx, c)
+		sub, subCtx := c.subscribe(ctx, msg, "events", "")
+		if sub == nil {
+			return
+		}
 
+		// Ack first: Subscribe replays recent events straight away.
 		_ = c.send(ctx, Message{
 			Type:    "subscribed",
 			ID:      msg.ID,
 			Payload: mustMarshal(SubscribePayload{Stream: "events"}),
 		})
+		h.eventHub.Subscribe(subCtx, sub)
 
-	case "logs":
-		if payload.ContainerID == "" {
+	case "disk_usage":
+		if h.diskUsage == nil {
 			_ = c.send(ctx, Message{
 				Type:    "error",
-				Payload: mustMarshal(ErrorPayload{Error: "container_id required for logs stream", Code: "MISSING_CONTAINER_ID"}),
+				ID:      msg.ID,
+				Payload: mustMarshal(ErrorPayload{Error: "docker client not available", Code: "NOT_AVAILABLE"}),
 			})
 			return
 		}
 
-		subKey := "logs:" + payload.ContainerID
-
-		// Shared limit: count logs + container_stats subscriptions.
-		perContainerCount := 0
-		for key := range c.subscriptions {
-			if strings.HasPrefix(key, "logs:") || strings.HasPrefix(key, "container_stats:") {
-				perContainerCount++
-			}
-		}
-		if perContainerCount >= 3 {
-			_ = c.send(ctx, Message{
-				Type:    "error",
-				Payload: mustMarshal(ErrorPayload{Error: "max 3 concurrent per-container subscriptions", Code: "LIMIT_EXCEEDED"}),
-			})
+		sub, subCtx := c.subscribe(ctx, msg, "disk_usage", "")
+		if sub == nil {
 			return
 		}
 
-		if _, exists := c.subscriptions[subKey]; exists {
+		// Ack first: the first summary is collected straight away.
+		_ = c.send(ctx, Message{
+			Type:    "subscribed",
+			ID:      msg.ID,
+			Payload: mustMarshal(SubscribePayload{Stream: "disk_usage", IntervalSeconds: int(clampDiskUsageInterval(payload.IntervalSeconds) / time.Second)}),
+		})
+		h.diskUsage.subscribe(subCtx, sub, payload.IntervalSeconds)
+
+	case "stack_status":
+		if h.eventHub == nil {
 			_ = c.send(ctx, Message{
 				Type:    "error",
-				Payload: mustMarshal(ErrorPayload{Error: "already subscribed to logs for this container", Code: "ALREADY_SUBSCRIBED"}),
+				ID:      msg.ID,
+				Payload: mustMarshal(ErrorPayload{Error: "event hub not available", Code: "NOT_AVAILABLE"}),
 			})
 			return
 		}
 
-		subCtx, cancel := context.WithCancel(ctx)
-		c.subscriptions[subKey] = cancel
-		go streamLogs(subCtx, c, h.eventHub.dockerClient, payload.ContainerID)
+		sub, subCtx := c.subscribe(ctx, msg, "stack_status", "")
+		if sub == nil {
+			return
+		}
+		h.eventHub.SubscribeStackStatus(subCtx, sub)
 
 		_ = c.send(ctx, Message{
 			Type:    "subscribed",
 			ID:      msg.ID,
-			Payload: mustMarshal(SubscribePayload{Stream: "logs", ContainerID: payload.ContainerID}),
+			Payload: mustMarshal(SubscribePayload{Stream: "stack_status"}),
 		})
 
-	case "container_stats":
-		if payload.ContainerID == "" {
+	case "logs":
+		if !h.checkContainerStream(ctx, c, msg, payload) {
+			return
+		}
+
+		filter, err := docker.NewLogFilter(payload.Grep, payload.Regex, payload.LogStream)
+		if err != nil {
 			_ = c.send(ctx, Message{
 				Type:    "error",
-				Payload: mustMarshal(ErrorPayload{Error: "container_id required for container_stats stream", Code: "MISSING_CONTAINER_ID"}),
+				ID:      msg.ID,
+				Payload: mustMarshal(ErrorPayload{Error: err.Error(), Code: "BAD_FILTER"}),
 			})
 			return
 		}
 
-		subKey := "container_stats:" + payload.ContainerID
-
-		// Shared limit: count logs + container_stats subscriptions.
-		perContainerCount := 0
-		for key := range c.subscriptions {
-			if strings.HasPrefix(key, "logs:") || strings.HasPrefix(key, "container_stats:") {
-				perContainerCount++
+		var resume *logCursor
+		if payload.Cursor != "" {
+			cur, err := parseLogCursor(payload.Cursor)
+			if err != nil {
+				_ = c.send(ctx, Message{
+					Type:    "error",
+					ID:      msg.ID,
+					Payload: mustMarshal(ErrorPayload{Error: err.Error(), Code: "BAD_CURSOR"}),
+				})
+				return
 			}
+			resume = &cur
 		}
-		if perContainerCount >= 3 {
-			_ = c.send(ctx, Message{
-				Type:    "error",
-				Payload: mustMarshal(ErrorPayload{Error: "max 3 concurrent per-container subscriptions", Code: "LIMIT_EXCEEDED"}),
-			})
+
+		sub, subCtx := c.subscribe(ctx, msg, "logs", payload.ContainerID)
+		if sub == nil {
 			return
 		}
+		go streamLogs(subCtx, sub, h.eventHub.dockerClient, payload.ContainerID, payload.Lines, payload.Since, resume, filter)
 
-		if _, exists := c.subscriptions[subKey]; exists {
-			_ = c.send(ctx, Message{
-				Type:    "error",
-				Payload: mustMarshal(ErrorPayload{Error: "already subscribed to container_stats for this container", Code: "ALREADY_SUBSCRIBED"}),
-			})
+		_ = c.send(ctx, Message{
+			Type:    "subscribed",
+			ID:      msg.ID,
+			Payload: mustMarshal(SubscribePayload{Stream: "logs", ContainerID: payload.ContainerID}),
+		})
+
+	case "container_stats":
+		if !h.checkContainerStream(ctx, c, msg, payload) {
 			return
 		}
 
-		subCtx, cancel := context.WithCancel(ctx)
-		c.subscriptions[subKey] = cancel
-		go streamContainerStats(subCtx, c, h.eventHub.dockerClient, payload.ContainerID, payload.IntervalSeconds)
+		sub, subCtx := c.subscribe(ctx, msg, "container_stats", payload.ContainerID)
+		if sub == nil {
+			return
+		}
+		go streamContainerStats(subCtx, sub, h.eventHub.dockerClient, payload.ContainerID, payload.IntervalSeconds)
 
 		_ = c.send(ctx, Message{
 			Type:    "subscribed",
@@ -254,50 +622,77 @@ func (h *Handler) handleSubscribe(ctx context.Context, c *client, msg Message) {
 			Payload: mustMarshal(SubscribePayload{Stream: "container_stats", ContainerID: payload.ContainerID}),
 		})
 
+	case "exec":
+		h.subscribeExec(ctx, c, msg, payload)
+
 	default:
 		_ = c.send(ctx, Message{
 			Type:    "error",
+			ID:      msg.ID,
 			Payload: mustMarshal(ErrorPayload{Error: "unknown stream: " + payload.Stream, Code: "UNKNOWN_STREAM"}),
 		})
 	}
 }
 
-func (h *Handler) handleUnsubscribe(ctx context.Context, c *client, msg Message) {
-	var payload SubscribePayload
-	if err := json.Unmarshal(msg.Payload, &payload); err != nil {
-		_ = c.send(ctx, Message{
-			Type:    "error",
-			Payload: mustMarshal(ErrorPayload{Error: "invalid unsubscribe payload", Code: "BAD_PAYLOAD"}),
-		})
-		return
+// checkContainerStream validates a logs or container_stats subscription:
+// it needs a container_id, a Docker client and room under the shared limit
+// of 3 per-container subscriptions. On failure the client is sent an error.
+func (h *Handler) checkContainerStream(ctx context.Context, c *client, msg Message, payload SubscribePayload) bool {
+	var errPayload ErrorPayload
+	switch {
+	case payload.ContainerID == "":
+		errPayload = ErrorPayload{Error: "container_id required for " + payload.Stream + " stream", Code: "MISSING_CONTAINER_ID"}
+	case c.perContainerCount() >= 3:
+		errPayload = ErrorPayload{Error: "max 3 concurrent per-container subscriptions", Code: "LIMIT_EXCEEDED"}
+	case h.eventHub == nil:
+		errPayload = ErrorPayload{Error: "docker not available", Code: "NOT_AVAILABLE"}
+	default:
+		return true
 	}
+	_ = c.send(ctx, Message{Type: "error", ID: msg.ID, Payload: mustMarshal(errPayload)})
+	return false
+}
 
-	subKey := payload.Stream
-	if payload.ContainerID != "" {
-		switch payload.Stream {
-		case "logs":
-			subKey = "logs:" + payload.ContainerID
-		case "container_stats":
-			subKey = "container_stats:" + payload.ContainerID
+// handleUnsubscribe stops the subscription named by the message id or,
+// failing that, by the payload's stream and container_id.
+func (h *Handler) handleUnsubscribe(ctx context.Context, c *client, msg Message) {
+	var payload SubscribePayload
+	if msg.ID == "" || len(msg.Payload) > 0 {
+		if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+			_ = c.send(ctx, Message{
+				Type:    "error",
+				ID:      msg.ID,
+				Payload: mustMarshal(ErrorPayload{Error: "invalid unsubscribe payload", Code: "BAD_PAYLOAD"}),
+			})
+			return
 		}
 	}
+	if payload.Stream == "stats" {
+		payload.Stream = "container_stats"
+	}
 
-	cancel, exists := c.subscriptions[subKey]
-	if !exists {
+	subKey, code := c.findSubscription(msg.ID, payload.Stream, payload.ContainerID)
+	if code != "" {
+		errMsg := "no such subscription"
+		if code == "AMBIGUOUS_SUBSCRIPTION" {
+			errMsg = "several " + payload.Stream + " subscriptions match; unsubscribe by id"
+		}
 		_ = c.send(ctx, Message{
 			Type:    "error",
-			Payload: mustMarshal(ErrorPayload{Error: "not subscribed to " + subKey, Code: "NOT_SUBSCRIBED"}),
+			ID:      msg.ID,
+			Payload: mustMarshal(ErrorPayload{Error: errMsg, Code: code}),
 		})
 		return
 	}
 
-	cancel()
+	sub := c.subscriptions[subKey]
+	sub.cancel()
 	delete(c.subscriptions, subKey)
 
 	_ = c.send(ctx, Message{
 		Type:    "subscribed", // reuse as ack
 		ID:      msg.ID,
-		Payload: mustMarshal(map[string]string{"stream": payload.Stream, "status": "unsubscribed"}),
+		Payload: mustMarshal(map[string]string{"stream": sub.stream, "status": "unsubscribed"}),
 	})
 }
 
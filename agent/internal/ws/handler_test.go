@@ -3,17 +3,24 @@ package ws
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
 	"nhooyr.io/websocket"
 	"nhooyr.io/websocket/wsjson"
+
+	"github.com/driversti/hola/internal/docker"
 )
 
 func TestPingPong(t *testing.T) {
-	h := NewHandler(nil)
+	h := NewHandler(nil, Options{})
 	srv := httptest.NewServer(h)
 	defer srv.Close()
 
@@ -43,7 +50,7 @@ func TestPingPong(t *testing.T) {
 }
 
 func TestUnknownMessageType(t *testing.T) {
-	h := NewHandler(nil)
+	h := NewHandler(nil, Options{})
 	srv := httptest.NewServer(h)
 	defer srv.Close()
 
@@ -79,7 +86,7 @@ func TestUnknownMessageType(t *testing.T) {
 }
 
 func TestSubscribeMetrics(t *testing.T) {
-	h := NewHandler(nil)
+	h := NewHandler(nil, Options{})
 	srv := httptest.NewServer(h)
 	defer srv.Close()
 
@@ -131,7 +138,7 @@ func TestSubscribeMetrics(t *testing.T) {
 }
 
 func TestSubscribeDuplicate(t *testing.T) {
-	h := NewHandler(nil)
+	h := NewHandler(nil, Options{})
 	srv := httptest.NewServer(h)
 	defer srv.Close()
 
@@ -177,8 +184,80 @@ func TestSubscribeDuplicate(t *testing.T) {
 	}
 }
 
+func TestSubscriptionsRoutedByID(t *testing.T) {
+	h := NewHandler(nil, Options{})
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	conn, _, err := websocket.Dial(ctx, "ws"+srv.URL[4:], nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close(websocket.StatusNormalClosure, "done")
+
+	// Each subscription acks and sends its first snapshot straight away.
+	seen := map[string][]string{}
+	for _, id := range []string{"fast", "slow"} {
+		sub := Message{
+			Type:    "subscribe",
+			ID:      id,
+			Payload: mustMarshal(SubscribePayload{Stream: "metrics", IntervalSeconds: 30}),
+		}
+		if err := wsjson.Write(ctx, conn, sub); err != nil {
+			t.Fatal(err)
+		}
+		for range 2 {
+			var msg Message
+			if err := wsjson.Read(ctx, conn, &msg); err != nil {
+				t.Fatal(err)
+			}
+			seen[msg.ID] = append(seen[msg.ID], msg.Type)
+		}
+	}
+	for _, id := range []string{"fast", "slow"} {
+		if !slices.Equal(seen[id], []string{"subscribed", "metrics"}) {
+			t.Errorf("messages for %s = %v, want [subscribed metrics]", id, seen[id])
+		}
+	}
+
+	// Reusing a live id is rejected.
+	dup := Message{Type: "subscribe", ID: "fast", Payload: mustMarshal(SubscribePayload{Stream: "metrics"})}
+	if err := wsjson.Write(ctx, conn, dup); err != nil {
+		t.Fatal(err)
+	}
+	var errMsg Message
+	if err := wsjson.Read(ctx, conn, &errMsg); err != nil {
+		t.Fatal(err)
+	}
+	var errPayload ErrorPayload
+	json.Unmarshal(errMsg.Payload, &errPayload)
+	if errMsg.ID != "fast" || errPayload.Code != "ALREADY_SUBSCRIBED" {
+		t.Fatalf("want ALREADY_SUBSCRIBED for fast, got %q %s", errMsg.ID, errMsg.Payload)
+	}
+
+	// Unsubscribing one leaves the other in place.
+	for _, id := range []string{"fast", "fast", "slow"} {
+		if err := wsjson.Write(ctx, conn, Message{Type: "unsubscribe", ID: id}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := []string{"subscribed", "error", "subscribed"}
+	for i, typ := range want {
+		var resp Message
+		if err := wsjson.Read(ctx, conn, &resp); err != nil {
+			t.Fatal(err)
+		}
+		if resp.Type != typ {
+			t.Fatalf("unsubscribe %d: want %s, got %q: %s", i, typ, resp.Type, resp.Payload)
+		}
+	}
+}
+
 func TestSubscribeUnknownStream(t *testing.T) {
-	h := NewHandler(nil)
+	h := NewHandler(nil, Options{})
 	srv := httptest.NewServer(h)
 	defer srv.Close()
 
@@ -215,7 +294,7 @@ func TestSubscribeUnknownStream(t *testing.T) {
 }
 
 func TestLogsRequiresContainerID(t *testing.T) {
-	h := NewHandler(nil)
+	h := NewHandler(nil, Options{})
 	srv := httptest.NewServer(h)
 	defer srv.Close()
 
@@ -252,7 +331,7 @@ func TestLogsRequiresContainerID(t *testing.T) {
 }
 
 func TestInvalidPayload(t *testing.T) {
-	h := NewHandler(nil)
+	h := NewHandler(nil, Options{})
 	srv := httptest.NewServer(h)
 	defer srv.Close()
 
@@ -287,7 +366,7 @@ func TestInvalidPayload(t *testing.T) {
 }
 
 func TestUnsubscribeNotSubscribed(t *testing.T) {
-	h := NewHandler(nil)
+	h := NewHandler(nil, Options{})
 	srv := httptest.NewServer(h)
 	defer srv.Close()
 
@@ -324,7 +403,7 @@ func TestUnsubscribeNotSubscribed(t *testing.T) {
 }
 
 func TestSubscribeEventsWithNilHub(t *testing.T) {
-	h := NewHandler(nil)
+	h := NewHandler(nil, Options{})
 	srv := httptest.NewServer(h)
 	defer srv.Close()
 
@@ -375,3 +454,665 @@ func testServer(h http.Handler) (*httptest.Server, *websocket.Conn, func()) {
 		srv.Close()
 	}
 }
+
+func TestAllowedOrigins(t *testing.T) {
+	h := NewHandler(nil, Options{AllowedOrigins: []string{"hola.example.com"}})
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	dial := func(origin string) error {
+		conn, _, err := websocket.Dial(ctx, "ws"+srv.URL[4:], &websocket.DialOptions{
+			HTTPHeader: http.Header{"Origin": []string{origin}},
+		})
+		if err == nil {
+			conn.Close(websocket.StatusNormalClosure, "done")
+		}
+		return err
+	}
+
+	if err := dial("https://hola.example.com"); err != nil {
+		t.Fatalf("allowed origin rejected: %v", err)
+	}
+	if err := dial("https://evil.example.net"); err == nil {
+		t.Fatal("want disallowed origin to be rejected")
+	}
+}
+
+// newFakeDocker starts a minimal Docker Engine API that serves inspect
+// data, one stats sample and one log line for any container, and returns a docker.Client
+// pointed at it. Query parameters of log requests are sent to logQueries
+// when it is non-nil.
+func newFakeDocker(t *testing.T, logQueries chan<- url.Values) *docker.Client {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Api-Version", "1.45")
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/_ping"):
+			w.Write([]byte("OK"))
+		case strings.HasSuffix(r.URL.Path, "/stats"):
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]any{
+				"cpu_stats":    map[string]any{"cpu_usage": map[string]any{"total_usage": 200}, "system_cpu_usage": 2000, "online_cpus": 1},
+				"precpu_stats": map[string]any{"cpu_usage": map[string]any{"total_usage": 100}, "system_cpu_usage": 1000},
+				"memory_stats": map[string]any{"usage": 512, "limit": 1024},
+			})
+		case strings.HasSuffix(r.URL.Path, "/json"):
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]any{
+				"Id": "abc123abc123abc123", "Name": "/app", "Config": map[string]any{"Tty": false},
+			})
+		case strings.HasSuffix(r.URL.Path, "/logs"):
+			if logQueries != nil {
+				logQueries <- r.URL.Query()
+			}
+			line := []byte("2024-05-01T10:00:01Z hello\n")
+			header := []byte{1, 0, 0, 0, 0, 0, 0, byte(len(line))}
+			w.Write(append(header, line...))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	dc, err := docker.NewClient(docker.Config{Host: "tcp://" + srv.Listener.Addr().String()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { dc.Close() })
+	return dc
+}
+
+func TestSubscribeStats(t *testing.T) {
+	h := NewHandler(NewEventHub(newFakeDocker(t, nil)), Options{})
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	conn, _, err := websocket.Dial(ctx, "ws"+srv.URL[4:], nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close(websocket.StatusNormalClosure, "done")
+
+	sub := Message{
+		Type:    "subscribe",
+		ID:      "sub-1",
+		Payload: mustMarshal(SubscribePayload{Stream: "stats", ContainerID: "abc123", IntervalSeconds: 1}),
+	}
+	if err := wsjson.Write(ctx, conn, sub); err != nil {
+		t.Fatal(err)
+	}
+
+	var ack Message
+	if err := wsjson.Read(ctx, conn, &ack); err != nil {
+		t.Fatal(err)
+	}
+	if ack.Type != "subscribed" || ack.ID != "sub-1" {
+		t.Fatalf("want subscribed ack for sub-1, got %q (%q)", ack.Type, ack.ID)
+	}
+
+	var msg Message
+	if err := wsjson.Read(ctx, conn, &msg); err != nil {
+		t.Fatal(err)
+	}
+	if msg.Type != "container_stats" {
+		t.Fatalf("want type container_stats, got %q", msg.Type)
+	}
+	var stats ContainerStatsPayload
+	if err := json.Unmarshal(msg.Payload, &stats); err != nil {
+		t.Fatal(err)
+	}
+	if stats.ContainerID != "abc123" || stats.MemUsedBytes != 512 || stats.CPUPercent != 10 {
+		t.Errorf("unexpected stats payload: %+v", stats)
+	}
+
+	// Unsubscribing via the alias must find the same subscription.
+	unsub := Message{
+		Type:    "unsubscribe",
+		ID:      "unsub-1",
+		Payload: mustMarshal(SubscribePayload{Stream: "stats", ContainerID: "abc123"}),
+	}
+	if err := wsjson.Write(ctx, conn, unsub); err != nil {
+		t.Fatal(err)
+	}
+	for {
+		var resp Message
+		if err := wsjson.Read(ctx, conn, &resp); err != nil {
+			t.Fatal(err)
+		}
+		if resp.Type == "container_stats" {
+			continue
+		}
+		if resp.Type != "subscribed" || resp.ID != "unsub-1" {
+			t.Fatalf("want unsubscribe ack, got %q: %s", resp.Type, resp.Payload)
+		}
+		break
+	}
+}
+
+func TestSubscribeStatsByID(t *testing.T) {
+	h := NewHandler(NewEventHub(newFakeDocker(t, nil)), Options{})
+	_, conn, cleanup := testServer(h)
+	defer cleanup()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// next reads past stats messages to the next reply of another type,
+	// checking that every stats message is routed to a live subscription.
+	live := map[string]bool{"a": true, "b": true}
+	next := func() Message {
+		t.Helper()
+		for {
+			var msg Message
+			if err := wsjson.Read(ctx, conn, &msg); err != nil {
+				t.Fatal(err)
+			}
+			if msg.Type != "container_stats" {
+				return msg
+			}
+			if !live[msg.ID] {
+				t.Fatalf("container_stats routed to %q", msg.ID)
+			}
+		}
+	}
+
+	for id := range live {
+		sub := Message{
+			Type:    "subscribe",
+			ID:      id,
+			Payload: mustMarshal(SubscribePayload{Stream: "stats", ContainerID: "abc123", IntervalSeconds: 1}),
+		}
+		if err := wsjson.Write(ctx, conn, sub); err != nil {
+			t.Fatal(err)
+		}
+		if ack := next(); ack.Type != "subscribed" || ack.ID != id {
+			t.Fatalf("want subscribed ack for %s, got %q (%q)", id, ack.Type, ack.ID)
+		}
+	}
+
+	// With two matches, the stream alone doesn't say which to stop.
+	unsub := Message{Type: "unsubscribe", Payload: mustMarshal(SubscribePayload{Stream: "stats", ContainerID: "abc123"})}
+	if err := wsjson.Write(ctx, conn, unsub); err != nil {
+		t.Fatal(err)
+	}
+	resp := next()
+	var errPayload ErrorPayload
+	json.Unmarshal(resp.Payload, &errPayload)
+	if resp.Type != "error" || errPayload.Code != "AMBIGUOUS_SUBSCRIPTION" {
+		t.Fatalf("want AMBIGUOUS_SUBSCRIPTION, got %q: %s", resp.Type, resp.Payload)
+	}
+
+	if err := wsjson.Write(ctx, conn, Message{Type: "unsubscribe", ID: "a"}); err != nil {
+		t.Fatal(err)
+	}
+	if resp := next(); resp.Type != "subscribed" || resp.ID != "a" {
+		t.Fatalf("want unsubscribe ack for a, got %q (%q): %s", resp.Type, resp.ID, resp.Payload)
+	}
+	delete(live, "a")
+
+	// Now only b matches, so the stream alone finds it.
+	if err := wsjson.Write(ctx, conn, unsub); err != nil {
+		t.Fatal(err)
+	}
+	if resp := next(); resp.Type != "subscribed" {
+		t.Fatalf("want unsubscribe ack, got %q: %s", resp.Type, resp.Payload)
+	}
+}
+
+func TestSubscriptionIDsDoNotCollideWithStreams(t *testing.T) {
+	h := NewHandler(nil, Options{})
+	_, conn, cleanup := testServer(h)
+	defer cleanup()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	send := func(msg Message) Message {
+		t.Helper()
+		if err := wsjson.Write(ctx, conn, msg); err != nil {
+			t.Fatal(err)
+		}
+		for {
+			var resp Message
+			if err := wsjson.Read(ctx, conn, &resp); err != nil {
+				t.Fatal(err)
+			}
+			if resp.Type != "metrics" {
+				return resp
+			}
+		}
+	}
+	metricsSub := mustMarshal(SubscribePayload{Stream: "metrics", IntervalSeconds: 30})
+
+	// An id spelled like a stream leaves room for the id-less subscription.
+	if resp := send(Message{Type: "subscribe", ID: "metrics", Payload: metricsSub}); resp.Type != "subscribed" {
+		t.Fatalf("subscribe with id metrics: got %q: %s", resp.Type, resp.Payload)
+	}
+	if resp := send(Message{Type: "subscribe", Payload: metricsSub}); resp.Type != "subscribed" {
+		t.Fatalf("subscribe without id: got %q: %s", resp.Type, resp.Payload)
+	}
+
+	// An id-less unsubscribe stops the id-less subscription, not the other.
+	if resp := send(Message{Type: "unsubscribe", Payload: mustMarshal(SubscribePayload{Stream: "metrics"})}); resp.Type != "subscribed" {
+		t.Fatalf("unsubscribe without id: got %q: %s", resp.Type, resp.Payload)
+	}
+	if resp := send(Message{Type: "unsubscribe", ID: "metrics"}); resp.Type != "subscribed" || resp.ID != "metrics" {
+		t.Fatalf("unsubscribe id metrics: got %q (%q): %s", resp.Type, resp.ID, resp.Payload)
+	}
+}
+
+func TestSubscriptionLimit(t *testing.T) {
+	h := NewHandler(nil, Options{})
+	_, conn, cleanup := testServer(h)
+	defer cleanup()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	for i := range maxSubscriptions + 1 {
+		sub := Message{
+			Type:    "subscribe",
+			ID:      strconv.Itoa(i),
+			Payload: mustMarshal(SubscribePayload{Stream: "metrics", IntervalSeconds: 30}),
+		}
+		if err := wsjson.Write(ctx, conn, sub); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for {
+		var resp Message
+		if err := wsjson.Read(ctx, conn, &resp); err != nil {
+			t.Fatal(err)
+		}
+		if resp.Type != "error" {
+			continue
+		}
+		var errPayload ErrorPayload
+		json.Unmarshal(resp.Payload, &errPayload)
+		if resp.ID != strconv.Itoa(maxSubscriptions) || errPayload.Code != "LIMIT_EXCEEDED" {
+			t.Fatalf("want LIMIT_EXCEEDED for subscription %d, got %q: %s", maxSubscriptions, resp.ID, resp.Payload)
+		}
+		return
+	}
+}
+
+func TestHeartbeatClosesUnresponsiveClient(t *testing.T) {
+	h := NewHandler(nil, Options{PingInterval: 50 * time.Millisecond})
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	conn, _, err := websocket.Dial(ctx, "ws"+srv.URL[4:], nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.CloseNow()
+
+	// Without a reader the client never answers pings, so the server should
+	// give up after two intervals.
+	time.Sleep(400 * time.Millisecond)
+
+	readCtx, readCancel := context.WithTimeout(ctx, 2*time.Second)
+	defer readCancel()
+	var msg Message
+	err = wsjson.Read(readCtx, conn, &msg)
+	if err == nil {
+		t.Fatalf("want connection closed, got message %q", msg.Type)
+	}
+	if readCtx.Err() != nil {
+		t.Fatal("connection was not closed by the server")
+	}
+}
+
+func TestSlowClientDoesNotStallEventHub(t *testing.T) {
+	hub := NewEventHub(nil)
+	h := NewHandler(hub, Options{SendQueueSize: 8})
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	subscribe := func() *websocket.Conn {
+		conn, _, err := websocket.Dial(ctx, "ws"+srv.URL[4:], nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn.SetReadLimit(1 << 20)
+		sub := Message{Type: "subscribe", Payload: mustMarshal(SubscribePayload{Stream: "events"})}
+		if err := wsjson.Write(ctx, conn, sub); err != nil {
+			t.Fatal(err)
+		}
+		var ack Message
+		if err := wsjson.Read(ctx, conn, &ack); err != nil || ack.Type != "subscribed" {
+			t.Fatalf("subscribe failed: %v %q", err, ack.Type)
+		}
+		return conn
+	}
+
+	fast := subscribe()
+	defer fast.CloseNow()
+	stalled := subscribe() // never read from again
+	defer stalled.CloseNow()
+
+	received := make(chan struct{}, 1)
+	go func() {
+		for {
+			var msg Message
+			if err := wsjson.Read(ctx, fast, &msg); err != nil {
+				return
+			}
+			received <- struct{}{}
+		}
+	}()
+
+	// Far more data than the stalled client's socket buffers can absorb.
+	big := strings.Repeat("x", 64<<10)
+	for i := 0; i < 400; i++ {
+		hub.Publish(ctx, "test", big)
+		select {
+		case <-received:
+		case <-ctx.Done():
+			t.Fatalf("fast client stalled after %d messages", i)
+		}
+	}
+
+	// The stalled client must have been disconnected: once it drains what
+	// was buffered, reads fail.
+	for {
+		var msg Message
+		if err := wsjson.Read(ctx, stalled, &msg); err != nil {
+			if ctx.Err() != nil {
+				t.Fatal("stalled client was never disconnected")
+			}
+			break
+		}
+	}
+}
+
+func TestOriginPolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    Options
+		origin  func(srvHost string) string
+		allowed bool
+	}{
+		{"same host by default", Options{}, func(h string) string { return "http://" + h }, true},
+		{"foreign origin rejected by default", Options{}, func(string) string { return "https://evil.example.net" }, false},
+		{"allow all", Options{AllowAllOrigins: true}, func(string) string { return "https://evil.example.net" }, true},
+		{"pattern match", Options{AllowedOrigins: []string{"*.example.com"}}, func(string) string { return "https://ui.example.com" }, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(NewHandler(nil, tt.opts))
+			defer srv.Close()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			conn, _, err := websocket.Dial(ctx, "ws"+srv.URL[4:], &websocket.DialOptions{
+				HTTPHeader: http.Header{"Origin": []string{tt.origin(srv.Listener.Addr().String())}},
+			})
+			if err == nil {
+				conn.Close(websocket.StatusNormalClosure, "done")
+			}
+			if got := err == nil; got != tt.allowed {
+				t.Fatalf("allowed = %v, want %v (err: %v)", got, tt.allowed, err)
+			}
+		})
+	}
+}
+
+func TestSubscribeLogsSince(t *testing.T) {
+	queries := make(chan url.Values, 1)
+	h := NewHandler(NewEventHub(newFakeDocker(t, queries)), Options{})
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	conn, _, err := websocket.Dial(ctx, "ws"+srv.URL[4:], nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close(websocket.StatusNormalClosure, "done")
+
+	sub := Message{
+		Type:    "subscribe",
+		Payload: mustMarshal(SubscribePayload{Stream: "logs", ContainerID: "abc123", Since: "1714557600"}),
+	}
+	if err := wsjson.Write(ctx, conn, sub); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case q := <-queries:
+		if q.Get("since") != "1714557600" || q.Get("tail") != "all" {
+			t.Errorf("want since=1714557600 tail=all, got since=%q tail=%q", q.Get("since"), q.Get("tail"))
+		}
+	case <-ctx.Done():
+		t.Fatal("log stream was never opened")
+	}
+
+	for {
+		var msg Message
+		if err := wsjson.Read(ctx, conn, &msg); err != nil {
+			t.Fatal(err)
+		}
+		if msg.Type != "log_line" {
+			continue
+		}
+		var line LogLine
+		json.Unmarshal(msg.Payload, &line)
+		if line.Message != "hello" {
+			t.Errorf("want message hello, got %q", line.Message)
+		}
+		if _, err := parseLogCursor(line.Cursor); err != nil {
+			t.Errorf("log line carries unusable cursor %q: %v", line.Cursor, err)
+		}
+		break
+	}
+}
+
+func TestSubscribeLogsBadFilter(t *testing.T) {
+	queries := make(chan url.Values, 1)
+	h := NewHandler(NewEventHub(newFakeDocker(t, queries)), Options{})
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	conn, _, err := websocket.Dial(ctx, "ws"+srv.URL[4:], nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close(websocket.StatusNormalClosure, "done")
+
+	sub := Message{
+		Type:    "subscribe",
+		ID:      "sub-1",
+		Payload: mustMarshal(SubscribePayload{Stream: "logs", ContainerID: "abc123", Grep: "(", Regex: true}),
+	}
+	if err := wsjson.Write(ctx, conn, sub); err != nil {
+		t.Fatal(err)
+	}
+
+	var resp Message
+	if err := wsjson.Read(ctx, conn, &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Type != "error" {
+		t.Fatalf("want error, got %q", resp.Type)
+	}
+	var errPayload ErrorPayload
+	json.Unmarshal(resp.Payload, &errPayload)
+	if errPayload.Code != "BAD_FILTER" {
+		t.Errorf("want code BAD_FILTER, got %q", errPayload.Code)
+	}
+
+	select {
+	case <-queries:
+		t.Error("log stream should not be opened for an invalid filter")
+	default:
+	}
+}
+
+func TestExecRequiresCommandAndSession(t *testing.T) {
+	h := NewHandler(nil, Options{})
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	conn, _, err := websocket.Dial(ctx, "ws"+srv.URL[4:], nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close(websocket.StatusNormalClosure, "done")
+
+	tests := []struct {
+		msg  Message
+		code string
+	}{
+		{Message{Type: "subscribe", Payload: mustMarshal(SubscribePayload{Stream: "exec", ContainerID: "abc"})}, "MISSING_CMD"},
+		{Message{Type: "exec_input", Payload: mustMarshal(ExecInputPayload{ContainerID: "abc", Data: "ls\n"})}, "NOT_SUBSCRIBED"},
+	}
+	for _, tt := range tests {
+		if err := wsjson.Write(ctx, conn, tt.msg); err != nil {
+			t.Fatal(err)
+		}
+		var resp Message
+		if err := wsjson.Read(ctx, conn, &resp); err != nil {
+			t.Fatal(err)
+		}
+		var errPayload ErrorPayload
+		json.Unmarshal(resp.Payload, &errPayload)
+		if resp.Type != "error" || errPayload.Code != tt.code {
+			t.Errorf("%s: got %s %q, want error %q", tt.msg.Type, resp.Type, errPayload.Code, tt.code)
+		}
+	}
+}
+
+func TestCloseAll(t *testing.T) {
+	h := NewHandler(nil, Options{})
+	srv, conn, cleanup := testServer(h)
+	defer cleanup()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// A round trip guarantees the connection is registered.
+	if err := wsjson.Write(ctx, conn, Message{Type: "ping"}); err != nil {
+		t.Fatal(err)
+	}
+	var resp Message
+	if err := wsjson.Read(ctx, conn, &resp); err != nil {
+		t.Fatal(err)
+	}
+
+	closed := make(chan struct{})
+	go func() {
+		h.CloseAll("server shutting down")
+		close(closed)
+	}()
+
+	_, _, err := conn.Read(ctx)
+	var ce websocket.CloseError
+	if !errors.As(err, &ce) {
+		t.Fatalf("want close error, got %v", err)
+	}
+	if ce.Code != websocket.StatusNormalClosure || ce.Reason != "server shutting down" {
+		t.Fatalf("got close %d %q, want normal closure with shutdown reason", ce.Code, ce.Reason)
+	}
+	select {
+	case <-closed:
+	case <-ctx.Done():
+		t.Fatal("CloseAll did not return")
+	}
+
+	// Connections after CloseAll are turned away.
+	late, _, err := websocket.Dial(ctx, "ws"+srv.URL[4:], nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer late.CloseNow()
+	if _, _, err := late.Read(ctx); websocket.CloseStatus(err) != websocket.StatusGoingAway {
+		t.Fatalf("late connection: want going-away close, got %v", err)
+	}
+}
+
+func TestMaxConnections(t *testing.T) {
+	h := NewHandler(nil, Options{MaxConnections: 2})
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var conns []*websocket.Conn
+	for range 2 {
+		conn, _, err := websocket.Dial(ctx, "ws"+srv.URL[4:], nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.CloseNow()
+		conns = append(conns, conn)
+	}
+
+	_, resp, err := websocket.Dial(ctx, "ws"+srv.URL[4:], nil)
+	if err == nil {
+		t.Fatal("third connection accepted, want refusal")
+	}
+	if resp == nil || resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("want 503, got %v (%v)", resp, err)
+	}
+
+	// A disconnect frees a slot once its handler has returned.
+	conns[0].Close(websocket.StatusNormalClosure, "done")
+	for {
+		conn, _, err := websocket.Dial(ctx, "ws"+srv.URL[4:], nil)
+		if err == nil {
+			conn.CloseNow()
+			break
+		}
+		if ctx.Err() != nil {
+			t.Fatal("slot not freed after disconnect")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestOversizedMessageClosesConnection(t *testing.T) {
+	h := NewHandler(nil, Options{MaxMessageSize: 1024})
+	_, conn, cleanup := testServer(h)
+	defer cleanup()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	big := Message{Type: "exec_input", Payload: mustMarshal(ExecInputPayload{Data: strings.Repeat("x", 4096)})}
+	if err := wsjson.Write(ctx, conn, big); err != nil {
+		t.Fatal(err)
+	}
+
+	_, _, err := conn.Read(ctx)
+	var ce websocket.CloseError
+	if !errors.As(err, &ce) {
+		t.Fatalf("want close error, got %v", err)
+	}
+	if ce.Code != websocket.StatusMessageTooBig {
+		t.Fatalf("got close %d, want %d (message too big)", ce.Code, websocket.StatusMessageTooBig)
+	}
+}
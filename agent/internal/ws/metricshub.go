@@ -0,0 +1,196 @@
+package ws
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/driversti/hola/internal/metrics"
+)
+
+// Metrics interval bounds in seconds.
+const (
+	defaultMetricsInterval = 3
+	maxMetricsInterval     = 30
+	metricsTimeout         = 10 * time.Second
+)
+
+// metricsHub serves every metrics subscriber from one collector goroutine.
+// Collecting samples CPU usage over half a second, so instead of each
+// subscriber collecting on its own the hub ticks at the shortest interval
+// any subscriber asked for and hands each sample to the subscribers whose
+// interval has elapsed; one with a longer interval gets every few ticks.
+// A new subscriber is sent a sample straight away.
+type metricsHub struct {
+	collect func(ctx context.Context) (*metrics.SystemMetrics, error)
+
+	mu      sync.Mutex
+	subs    map[*subscription]*metricsSub
+	running bool
+	wake    chan struct{}
+}
+
+type metricsSub struct {
+	ctx      context.Context
+	interval time.Duration
+	last     time.Time // when the subscriber was last sent a sample; zero for a new one
+
+	// delta state, touched only by the collector goroutine. sent mirrors
+	// the client's view of the metrics.
+	delta bool
+	sent  map[string]any
+}
+
+func newMetricsHub(collect func(ctx context.Context) (*metrics.SystemMetrics, error)) *metricsHub {
+	return &metricsHub{
+		collect: collect,
+		subs:    make(map[*subscription]*metricsSub),
+		wake:    make(chan struct{}, 1),
+	}
+}
+
+// clampMetricsInterval applies the default and bounds to a requested
+// interval.
+func clampMetricsInterval(seconds int) time.Duration {
+	if seconds < 1 {
+		seconds = defaultMetricsInterval
+	}
+	return time.Duration(min(seconds, maxMetricsInterval)) * time.Second
+}
+
+// subscribe adds sub until ctx is done. In delta mode only the first
+// message carries a full snapshot; later ones carry a merge patch against
+// the state the client has accumulated so far.
+func (m *metricsHub) subscribe(ctx context.Context, sub *subscription, intervalSeconds int, delta bool) {
+	m.mu.Lock()
+	m.subs[sub] = &metricsSub{ctx: ctx, interval: clampMetricsInterval(intervalSeconds), delta: delta}
+	if !m.running {
+		m.running = true
+		go m.run()
+	}
+	m.mu.Unlock()
+	m.poke()
+
+	go func() {
+		<-ctx.Done()
+		m.mu.Lock()
+		delete(m.subs, sub)
+		m.mu.Unlock()
+		m.poke()
+	}()
+}
+
+// poke wakes the collector to re-evaluate its schedule.
+func (m *metricsHub) poke() {
+	select {
+	case m.wake <- struct{}{}:
+	default:
+	}
+}
+
+// run is the collector loop. It exits once the last subscriber is gone and
+// is restarted by the next subscribe.
+func (m *metricsHub) run() {
+	var tick time.Duration
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for {
+		m.mu.Lock()
+		if len(m.subs) == 0 {
+			m.running = false
+			m.mu.Unlock()
+			return
+		}
+		var shortest time.Duration
+		fresh := false
+		for _, s := range m.subs {
+			if shortest == 0 || s.interval < shortest {
+				shortest = s.interval
+			}
+			fresh = fresh || s.last.IsZero()
+		}
+		m.mu.Unlock()
+
+		if shortest != tick {
+			tick = shortest
+			ticker.Reset(tick)
+		}
+		if !fresh {
+			select {
+			case <-ticker.C:
+			case <-m.wake:
+				continue
+			}
+		}
+
+		m.collectAndSend(tick)
+	}
+}
+
+// collectAndSend takes one sample and sends it to every subscriber that is
+// new or whose interval has elapsed, allowing half a tick of slack so a
+// subscriber's interval is rounded to whole ticks.
+func (m *metricsHub) collectAndSend(tick time.Duration) {
+	started := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), metricsTimeout)
+	sample, err := m.collect(ctx)
+	cancel()
+
+	m.mu.Lock()
+	due := make(map[*subscription]*metricsSub)
+	for sub, s := range m.subs {
+		if !s.last.IsZero() && started.Sub(s.last) < s.interval-tick/2 {
+			continue
+		}
+		// Reschedule even on failure so a failing collect isn't retried
+		// in a tight loop for new subscribers.
+		s.last = started
+		due[sub] = s
+	}
+	m.mu.Unlock()
+
+	if err != nil {
+		slog.Warn("metrics collect failed", "error", err)
+		return
+	}
+	full := Message{Type: "metrics", Payload: mustMarshal(sample)}
+	for sub, s := range due {
+		if s.ctx.Err() != nil {
+			continue
+		}
+		msg := full
+		if s.delta {
+			var ok bool
+			if msg, ok = s.deltaMessage(full, sample); !ok {
+				continue
+			}
+		}
+		if err := sub.send(s.ctx, msg); err != nil {
+			slog.Debug("metrics send failed", "error", err)
+		}
+	}
+}
+
+// deltaMessage returns the message bringing the subscriber's view up to
+// sample: full the first time, then a merge patch. It reports false when
+// nothing moved beyond the threshold.
+func (s *metricsSub) deltaMessage(full Message, sample *metrics.SystemMetrics) (Message, bool) {
+	// Each subscriber decodes its own copy: applyDelta mutates the view.
+	current, err := toJSONMap(sample)
+	if err != nil {
+		slog.Warn("metrics delta encode failed", "error", err)
+		return Message{}, false
+	}
+	if s.sent == nil {
+		s.sent = current
+		return full, true
+	}
+	patch := diffMetrics(s.sent, current)
+	if len(patch) == 0 {
+		return Message{}, false
+	}
+	applyDelta(s.sent, patch)
+	return Message{Type: "metrics", Payload: mustMarshal(patch)}, true
+}
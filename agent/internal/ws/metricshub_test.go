@@ -0,0 +1,103 @@
+package ws
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/driversti/hola/internal/metrics"
+)
+
+func TestClampMetricsInterval(t *testing.T) {
+	for in, want := range map[int]time.Duration{
+		0:  3 * time.Second,
+		-1: 3 * time.Second,
+		1:  time.Second,
+		10: 10 * time.Second,
+		60: 30 * time.Second,
+	} {
+		if got := clampMetricsInterval(in); got != want {
+			t.Errorf("clampMetricsInterval(%d) = %v, want %v", in, got, want)
+		}
+	}
+}
+
+func TestMetricsHubSharesSamples(t *testing.T) {
+	var inFlight, maxInFlight, calls atomic.Int32
+	hub := newMetricsHub(func(context.Context) (*metrics.SystemMetrics, error) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		if n > maxInFlight.Load() {
+			maxInFlight.Store(n)
+		}
+		calls.Add(1)
+		return &metrics.SystemMetrics{}, nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	newSub := func(intervalSeconds int) *client {
+		c := &client{out: make(chan Message, 16), done: make(chan struct{})}
+		hub.subscribe(ctx, &subscription{c: c}, intervalSeconds, false)
+		return c
+	}
+	fast1, fast2, slow := newSub(1), newSub(1), newSub(2)
+
+	time.Sleep(2500 * time.Millisecond)
+	cancel()
+
+	// Every subscriber gets a sample on subscribing; the fast ones then
+	// get every 1s tick and the slow one every other tick. A loaded machine
+	// can drop ticks, so only bounds are checked: each fast subscriber got
+	// at least its first sample and one tick, and the slow one never got
+	// more than a fast one.
+	nFast1, nFast2, nSlow := len(fast1.out), len(fast2.out), len(slow.out)
+	if nFast1 < 2 || nFast2 < 2 {
+		t.Errorf("fast subscribers got %d and %d samples, want at least 2 each", nFast1, nFast2)
+	}
+	if nSlow < 1 || nSlow > min(nFast1, nFast2) {
+		t.Errorf("slow subscriber got %d samples, want between 1 and the fast ones' %d", nSlow, min(nFast1, nFast2))
+	}
+	// Samples are shared: no more than one collection per subscribe plus
+	// one per tick, and never more samples to a subscriber than collections.
+	n := calls.Load()
+	if n > 5 {
+		t.Errorf("%d collections for 3 subscribers over 2 ticks, want at most 5", n)
+	}
+	if int(n) < nFast1 || int(n) < nFast2 {
+		t.Errorf("%d collections but fast subscribers got %d and %d samples", n, nFast1, nFast2)
+	}
+	if n := maxInFlight.Load(); n != 1 {
+		t.Errorf("%d collections ran concurrently, want 1", n)
+	}
+}
+
+func TestMetricsHubDeltaStatePerSubscriber(t *testing.T) {
+	hub := newMetricsHub(func(context.Context) (*metrics.SystemMetrics, error) {
+		return &metrics.SystemMetrics{}, nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	a := &client{out: make(chan Message, 4), done: make(chan struct{})}
+	hub.subscribe(ctx, &subscription{c: a}, 1, true)
+	<-a.out // a's snapshot
+
+	// A later delta subscriber still starts from a full snapshot, and a's
+	// view, which already matches, gets nothing.
+	b := &client{out: make(chan Message, 4), done: make(chan struct{})}
+	hub.subscribe(ctx, &subscription{c: b}, 1, true)
+	select {
+	case msg := <-b.out:
+		if full := mustMarshal(&metrics.SystemMetrics{}); string(msg.Payload) != string(full) {
+			t.Errorf("second subscriber got %s, want a full snapshot", msg.Payload)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("second subscriber got no snapshot")
+	}
+	time.Sleep(1200 * time.Millisecond)
+	if n := len(a.out); n != 0 {
+		t.Errorf("first subscriber got %d patches for unchanged metrics, want 0", n)
+	}
+}
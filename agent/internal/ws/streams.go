@@ -2,72 +2,46 @@ package ws
 
 import (
 	"context"
-	"encoding/binary"
 	"encoding/json"
-	"io"
 	"log/slog"
-	"strings"
+	"strconv"
 	"time"
 
 	"github.com/docker/docker/api/types/container"
 	"github.com/driversti/hola/internal/docker"
-	"github.com/driversti/hola/internal/metrics"
 )
 
-// streamMetrics sends system metrics at a regular interval until the context is cancelled.
-func streamMetrics(ctx context.Context, c *client, intervalSeconds int) {
-	if intervalSeconds < 1 {
-		intervalSeconds = 3
-	}
-	if intervalSeconds > 30 {
-		intervalSeconds = 30
-	}
-
-	ticker := time.NewTicker(time.Duration(intervalSeconds) * time.Second)
-	defer ticker.Stop()
-
-	// Send an initial snapshot immediately.
-	sendMetrics(ctx, c)
-
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		case <-ticker.C:
-			sendMetrics(ctx, c)
-		}
-	}
-}
-
-func sendMetrics(ctx context.Context, c *client) {
-	m, err := metrics.Collect(ctx)
-	if err != nil {
-		slog.Warn("metrics collect failed", "error", err)
-		return
-	}
-
-	if err := c.send(ctx, Message{
-		Type:    "metrics",
-		Payload: mustMarshal(m),
-	}); err != nil {
-		slog.Debug("metrics send failed", "error", err)
-	}
-}
-
 // LogLine is the payload for individual log lines sent over WebSocket.
 type LogLine struct {
 	ContainerID string `json:"container_id"`
 	Timestamp   string `json:"timestamp"`
 	Stream      string `json:"stream"`
 	Message     string `json:"message"`
+	Cursor      string `json:"cursor,omitempty"` // send back as subscribe cursor to resume after this line
 }
 
-// streamLogs follows container logs and sends each line over the WebSocket.
-func streamLogs(ctx context.Context, c *client, dockerClient *docker.Client, containerID string) {
-	reader, err := dockerClient.StreamContainerLogs(ctx, containerID, "50")
+// streamLogs follows container logs and sends each line that passes filter
+// over the WebSocket. Without since it starts with up to lines recent lines
+// (50 when lines is zero, capped by the Docker client's MaxLogLines); with
+// since it replays every line from that point so a reconnecting client
+// misses nothing. A resume cursor takes precedence over since and also
+// skips the lines at the cursor's timestamp the client already received.
+func streamLogs(ctx context.Context, sub *subscription, dockerClient *docker.Client, containerID string, lines int, since string, resume *logCursor, filter *docker.LogFilter) {
+	tracker := logTracker{resume: resume}
+	if resume != nil {
+		since = resume.since()
+	}
+	tail := "50"
+	if lines > 0 {
+		tail = strconv.Itoa(lines)
+	}
+	if since != "" {
+		tail = "all"
+	}
+	reader, err := dockerClient.StreamContainerLogs(ctx, containerID, tail, since)
 	if err != nil {
 		slog.Warn("log stream open failed", "container", containerID, "error", err)
-		_ = c.send(ctx, Message{
+		_ = sub.send(ctx, Message{
 			Type:    "error",
 			Payload: mustMarshal(ErrorPayload{Error: "failed to open log stream: " + err.Error(), Code: "LOG_STREAM_ERROR"}),
 		})
@@ -75,9 +49,6 @@ func streamLogs(ctx context.Context, c *client, dockerClient *docker.Client, con
 	}
 	defer reader.Close()
 
-	// Docker logs use an 8-byte header per frame:
-	// [stream_type(1)][0(3)][size(4)][payload]
-	header := make([]byte, 8)
 	for {
 		select {
 		case <-ctx.Done():
@@ -85,7 +56,7 @@ func streamLogs(ctx context.Context, c *client, dockerClient *docker.Client, con
 		default:
 		}
 
-		_, err := io.ReadFull(reader, header)
+		entry, err := reader.Next()
 		if err != nil {
 			if ctx.Err() != nil {
 				return // Context cancelled — clean shutdown.
@@ -94,45 +65,22 @@ func streamLogs(ctx context.Context, c *client, dockerClient *docker.Client, con
 			return
 		}
 
-		streamType := header[0]
-		frameSize := int(binary.BigEndian.Uint32(header[4:8]))
-
-		if frameSize <= 0 || frameSize > 1<<20 { // Skip frames > 1MB.
+		cursor, seen := tracker.advance(entry.Timestamp)
+		if seen || !filter.Match(entry.Stream, entry.Message) {
 			continue
 		}
 
-		payload := make([]byte, frameSize)
-		_, err = io.ReadFull(reader, payload)
-		if err != nil {
-			if ctx.Err() != nil {
-				return
-			}
-			slog.Debug("log frame read failed", "container", containerID, "error", err)
-			return
-		}
-
-		line := strings.TrimRight(string(payload), "\n")
-		stream := "stdout"
-		if streamType == 2 {
-			stream = "stderr"
-		}
-
-		var timestamp, message string
-		if idx := strings.IndexByte(line, ' '); idx > 0 {
-			timestamp = line[:idx]
-			message = line[idx+1:]
-		} else {
-			message = line
-		}
-
 		logLine := LogLine{
 			ContainerID: containerID,
-			Timestamp:   timestamp,
-			Stream:      stream,
-			Message:     message,
+			Timestamp:   entry.Timestamp,
+			Stream:      entry.Stream,
+			Message:     entry.Message,
+		}
+		if !cursor.Timestamp.IsZero() {
+			logLine.Cursor = cursor.String()
 		}
 
-		if err := c.send(ctx, Message{
+		if err := sub.send(ctx, Message{
 			Type:    "log_line",
 			Payload: mustMarshal(logLine),
 		}); err != nil {
@@ -143,16 +91,10 @@ func streamLogs(ctx context.Context, c *client, dockerClient *docker.Client, con
 }
 
 // ContainerStatsPayload is the payload for per-container resource stats.
-type ContainerStatsPayload struct {
-	ContainerID   string  `json:"container_id"`
-	CPUPercent    float64 `json:"cpu_percent"`
-	MemUsedBytes  uint64  `json:"mem_used_bytes"`
-	MemLimitBytes uint64  `json:"mem_limit_bytes"`
-	MemPercent    float64 `json:"mem_percent"`
-}
+type ContainerStatsPayload = docker.ContainerStatsSnapshot
 
 // streamContainerStats reads Docker container stats and sends CPU/memory snapshots at a regular interval.
-func streamContainerStats(ctx context.Context, c *client, dockerClient *docker.Client, containerID string, intervalSeconds int) {
+func streamContainerStats(ctx context.Context, sub *subscription, dockerClient *docker.Client, containerID string, intervalSeconds int) {
 	if intervalSeconds < 1 {
 		intervalSeconds = 3
 	}
@@ -163,7 +105,7 @@ func streamContainerStats(ctx context.Context, c *client, dockerClient *docker.C
 	reader, err := dockerClient.ContainerStats(ctx, containerID)
 	if err != nil {
 		slog.Warn("container stats open failed", "container", containerID, "error", err)
-		_ = c.send(ctx, Message{
+		_ = sub.send(ctx, Message{
 			Type:    "error",
 			Payload: mustMarshal(ErrorPayload{Error: "failed to open stats stream: " + err.Error(), Code: "STATS_STREAM_ERROR"}),
 		})
@@ -190,24 +132,7 @@ func streamContainerStats(ctx context.Context, c *client, dockerClient *docker.C
 				return
 			}
 
-			cpuPercent := calculateCPUPercent(&stats)
-			memUsed := stats.MemoryStats.Usage
-			if cache, ok := stats.MemoryStats.Stats["cache"]; ok {
-				memUsed -= cache
-			}
-			memLimit := stats.MemoryStats.Limit
-			var memPercent float64
-			if memLimit > 0 {
-				memPercent = float64(memUsed) / float64(memLimit) * 100.0
-			}
-
-			payload := ContainerStatsPayload{
-				ContainerID:   containerID,
-				CPUPercent:    cpuPercent,
-				MemUsedBytes:  memUsed,
-				MemLimitBytes: memLimit,
-				MemPercent:    memPercent,
-			}
+			payload := docker.NewStatsSnapshot(containerID, &stats)
 
 			// Non-blocking send — drop old value if not consumed yet.
 			select {
@@ -225,7 +150,7 @@ func streamContainerStats(ctx context.Context, c *client, dockerClient *docker.C
 		return
 	case p := <-statsCh:
 		latest = &p
-		if err := c.send(ctx, Message{
+		if err := sub.send(ctx, Message{
 			Type:    "container_stats",
 			Payload: mustMarshal(p),
 		}); err != nil {
@@ -244,7 +169,7 @@ func streamContainerStats(ctx context.Context, c *client, dockerClient *docker.C
 			if latest == nil {
 				continue
 			}
-			if err := c.send(ctx, Message{
+			if err := sub.send(ctx, Message{
 				Type:    "container_stats",
 				Payload: mustMarshal(*latest),
 			}); err != nil {
@@ -254,19 +179,3 @@ func streamContainerStats(ctx context.Context, c *client, dockerClient *docker.C
 		}
 	}
 }
-
-func calculateCPUPercent(stats *container.StatsResponse) float64 {
-	cpuDelta := float64(stats.CPUStats.CPUUsage.TotalUsage - stats.PreCPUStats.CPUUsage.TotalUsage)
-	systemDelta := float64(stats.CPUStats.SystemUsage - stats.PreCPUStats.SystemUsage)
-	if systemDelta <= 0 || cpuDelta < 0 {
-		return 0.0
-	}
-	numCPUs := float64(stats.CPUStats.OnlineCPUs)
-	if numCPUs == 0 {
-		numCPUs = float64(len(stats.CPUStats.CPUUsage.PercpuUsage))
-	}
-	if numCPUs == 0 {
-		numCPUs = 1
-	}
-	return (cpuDelta / systemDelta) * numCPUs * 100.0
-}